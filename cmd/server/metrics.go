@@ -0,0 +1,65 @@
+package cmd
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"net/http"
+
+	"github.com/bhojpur/sdr/pkg/sdrlogger"
+	"github.com/bhojpur/sdr/pkg/sdrstats"
+	log "github.com/sirupsen/logrus"
+)
+
+// logSoapy receives the SoapySDR log messages. The streaming status indicators are counted in the default registry,
+// the other messages are forwarded to the logger.
+func logSoapy(level sdrlogger.SDRLogLevel, message string) {
+
+	switch level {
+	case sdrlogger.SSI:
+		sdrstats.DefaultRegistry.ObserveSSI(message)
+	case sdrlogger.Fatal, sdrlogger.Critical, sdrlogger.Error:
+		log.Error(message)
+	case sdrlogger.Warning:
+		log.Warn(message)
+	case sdrlogger.Notice, sdrlogger.Info:
+		log.Info(message)
+	case sdrlogger.Debug:
+		log.Debug(message)
+	default:
+		log.Trace(message)
+	}
+}
+
+// serveMetrics serves the statistics of the default registry in Prometheus format on /metrics.
+//
+// Params:
+//  - address: the address on which the metrics are served
+//
+// Return the error that stopped the server
+func serveMetrics(address string) error {
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", sdrstats.DefaultRegistry)
+
+	log.Infof("serving metrics on %s/metrics", address)
+
+	return http.ListenAndServe(address, mux)
+}
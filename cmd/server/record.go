@@ -0,0 +1,146 @@
+package cmd
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/recorder"
+	"github.com/bhojpur/sdr/pkg/sdrlogger"
+	"github.com/bhojpur/sdr/pkg/sdrstats"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recordDeviceArgs string
+	recordChannels   []uint
+	recordFormat     string
+	recordRate       float64
+	recordFrequency  float64
+	recordFileFormat string
+	recordMetrics    string
+	recordOptions    recorder.Options
+)
+
+// recordCmd represents the record command
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Records the channels of a RX stream to files, with rotation and disk quotas, until interrupted",
+	Run: func(cmd *cobra.Command, args []string) {
+		sdrlogger.RegisterLogHandler(logSoapy)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := record(ctx); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	recordCmd.Flags().StringVar(&recordDeviceArgs, "device", "", "arguments of the device, such as \"driver=rtlsdr\"")
+	recordCmd.Flags().UintSliceVar(&recordChannels, "channels", []uint{0}, "channels to record")
+	recordCmd.Flags().StringVar(&recordFormat, "format", "CS16", "format of the stream")
+	recordCmd.Flags().Float64Var(&recordRate, "rate", 0, "sample rate in samples per second, unchanged when 0")
+	recordCmd.Flags().Float64Var(&recordFrequency, "frequency", 0, "center frequency in Hz, unchanged when 0")
+	recordCmd.Flags().StringVar(&recordOptions.Directory, "dir", ".", "directory of the recordings")
+	recordCmd.Flags().StringVar(&recordOptions.Prefix, "prefix", "recording", "prefix of the file names")
+	recordCmd.Flags().StringVar(&recordFileFormat, "file-format", string(recorder.FileFormatRaw), "format of the files: raw, sigmf, wav, npy or npz")
+	recordCmd.Flags().DurationVar(&recordOptions.MaxDuration, "rotate", 0, "duration after which new files are started")
+	recordCmd.Flags().Int64Var(&recordOptions.MaxFileBytes, "max-file-bytes", 0, "size after which new files are started")
+	recordCmd.Flags().Int64Var(&recordOptions.MaxTotalBytes, "quota", 0, "maximum size of all the recordings")
+	recordCmd.Flags().Uint64Var(&recordOptions.MinFreeBytes, "min-free-bytes", 0, "free disk space to keep")
+	recordCmd.Flags().StringVar(&recordMetrics, "metrics", "", "address on which the health statistics of the stream are served on /metrics, such as :9090")
+	rootCmd.AddCommand(recordCmd)
+}
+
+// record configures the device and records its stream until the context is cancelled
+func record(ctx context.Context) error {
+
+	dev, err := device.MakeStrArgs(recordDeviceArgs)
+	if err != nil {
+		return err
+	}
+	defer dev.Unmake()
+
+	for _, channel := range recordChannels {
+		if recordRate > 0 {
+			if err := dev.SetSampleRate(device.DirectionRX, channel, recordRate); err != nil {
+				return err
+			}
+		}
+		if recordFrequency > 0 {
+			if err := dev.SetFrequency(device.DirectionRX, channel, recordFrequency, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	stream, err := dev.SetupSDRStream(device.DirectionRX, recordFormat, recordChannels, nil)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	// The statistics of the stream are exported with its configured sample rate, to compare with the achieved one
+	stats := sdrstats.DefaultRegistry.Register("record", map[string]string{
+		"driver":   dev.GetDriverKey(),
+		"format":   recordFormat,
+		"channels": fmt.Sprint(recordChannels),
+	})
+	defer sdrstats.DefaultRegistry.Unregister("record")
+	if len(recordChannels) > 0 {
+		stats.SetConfiguredSampleRate(dev.GetSampleRate(device.DirectionRX, recordChannels[0]))
+	}
+	stream.SetStats(stats)
+
+	if recordMetrics != "" {
+		go func() {
+			if err := serveMetrics(recordMetrics); err != nil {
+				log.Error(err)
+			}
+		}()
+	}
+
+	options := recordOptions
+	options.FileFormat = recorder.FileFormat(recordFileFormat)
+
+	rec, err := recorder.NewRecorder(dev, stream, recordChannels, options)
+	if err != nil {
+		return err
+	}
+
+	if err := stream.Activate(0, 0, 0); err != nil {
+		return err
+	}
+	defer stream.Deactivate(0, 0)
+
+	log.Infof("recording to %s until interrupted", options.Directory)
+
+	return rec.Run(ctx)
+}
@@ -42,6 +42,12 @@ import (
 // Return an error or nil in case of success
 func (dev *SDRDevice) SetBandwidth(direction Direction, channel uint, bw float64) (err sdrerror.SDRError) {
 
+	if dev.strict {
+		if err := checkRange("bandwidth", bw, dev.GetBandwidthRanges(direction, channel)); err != nil {
+			return err
+		}
+	}
+
 	return sdrerror.Err(int(C.SoapySDRDevice_setBandwidth(dev.device, C.int(direction), C.size_t(channel), C.double(bw))))
 }
 
@@ -64,7 +70,7 @@ func (dev *SDRDevice) GetBandwidth(direction Direction, channel uint) float64 {
 //  - channel: an available channel on the device
 //
 // Return a list of bandwidth ranges in Hz
-func (dev *SDRDevice) GetBandwidthRanges(direction Direction, channel uint) []SDRRange {
+func (dev *SDRDevice) GetBandwidthRanges(direction Direction, channel uint) SDRRanges {
 
 	length := C.size_t(0)
 
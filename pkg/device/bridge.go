@@ -66,9 +66,9 @@ func stringArrayClear(strings **C.char, length C.size_t) {
 /* ******************************************************************************* */
 
 // rangeArray2Go converts an array of C Range to an array of Go SDRRange
-func rangeArray2Go(ranges *C.SoapySDRRange, length C.size_t) []SDRRange {
+func rangeArray2Go(ranges *C.SoapySDRRange, length C.size_t) SDRRanges {
 
-	results := make([]SDRRange, int(length))
+	results := make(SDRRanges, int(length))
 
 	var rangeTemplate C.SoapySDRRange
 
@@ -0,0 +1,586 @@
+package device
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+const (
+	// burstWriteMargin is the time given to a write on top of the time remaining before the start of its burst
+	burstWriteMargin = 100 * time.Millisecond
+	// burstAckTimeout is the time after the end of a burst after which a burst not acknowledged is reported as sent
+	burstAckTimeout = time.Second
+	// burstStatusPollUs is the timeout in microseconds of each poll of the stream status
+	burstStatusPollUs = 100000
+)
+
+// BurstStatus is the outcome of a transmit burst
+type BurstStatus int
+
+const (
+	// BurstAcknowledged indicates that all the samples were written and the device reported the end of the burst
+	BurstAcknowledged BurstStatus = iota
+	// BurstSent indicates that all the samples were written but the device did not acknowledge the end of the burst,
+	// because the stream does not report its status or because the acknowledgement did not arrive in time
+	BurstSent
+	// BurstLate indicates that the device reported a time error: the samples reached the device after the requested
+	// start time
+	BurstLate
+	// BurstUnderflow indicates that the device reported an underflow during the burst: the samples did not reach the
+	// device fast enough
+	BurstUnderflow
+	// BurstFailed indicates that the samples of the burst could not be written
+	BurstFailed
+)
+
+// String returns a human readable name of the burst status
+func (status BurstStatus) String() string {
+
+	switch status {
+	case BurstAcknowledged:
+		return "acknowledged"
+	case BurstSent:
+		return "sent"
+	case BurstLate:
+		return "late"
+	case BurstUnderflow:
+		return "underflow"
+	case BurstFailed:
+		return "failed"
+	}
+
+	return fmt.Sprintf("BurstStatus(%d)", int(status))
+}
+
+// Burst is a block of samples to transmit starting at a given hardware time
+type Burst struct {
+	// Buffers are the samples of the burst, one buffer per channel of the stream. The Go type of the buffers must match
+	// the format of the stream, for example [][]complex64 for a CF32 stream.
+	Buffers interface{}
+	// Start is the start time of the burst. It is relative to the hardware time read when the burst is submitted,
+	// unless Absolute is set.
+	Start time.Duration
+	// Absolute indicates that Start is an absolute hardware time (in nanoseconds) instead of a delay
+	Absolute bool
+}
+
+// BurstResult is the report of a transmit burst
+type BurstResult struct {
+	// ID is the identifier returned when the burst was submitted
+	ID uint64
+	// TimeNs is the hardware time in nanoseconds at which the burst was scheduled
+	TimeNs uint
+	// NbSamples is the number of samples of the burst
+	NbSamples uint
+	// NbWritten is the number of samples written to the stream
+	NbWritten uint
+	// Status is the outcome of the burst
+	Status BurstStatus
+	// Err is the error reported by the stream, if any
+	Err error
+}
+
+// scheduledBurst is a burst accepted by the scheduler
+type scheduledBurst struct {
+	result    BurstResult
+	buffers   interface{}
+	start     time.Time
+	deadline  time.Time
+	ending    bool
+	written   bool
+	acked     bool
+	underflow bool
+	finished  bool
+}
+
+// BurstScheduler transmits bursts at precise hardware times on a TX stream.
+//
+// The bursts are written in order of submission. Each burst is split in writes of at most the MTU of the stream: the
+// first write carries the start time (StreamFlagHasTime) and the last one ends the burst (StreamFlagEndBurst). The
+// status of the stream is watched in the background to detect the acknowledgement of the end of the bursts, the time
+// errors and the underflows. The status events are matched with the bursts in order of submission.
+type BurstScheduler struct {
+	dev        *SDRDevice
+	stream     SDRStream
+	sampleRate float64
+
+	nextID      uint64
+	queue       chan *scheduledBurst
+	results     chan BurstResult
+	writerDone  chan struct{}
+	statusDone  chan struct{}
+	submitMutex sync.RWMutex
+	closed      bool
+
+	mutex           sync.Mutex
+	pending         []*scheduledBurst
+	statusSupported bool
+}
+
+// NewBurstScheduler creates a scheduler transmitting bursts on a TX stream and starts it. The stream must be
+// activated before bursts are submitted, and must not be written by anything else than the scheduler.
+//
+// Params:
+//  - dev: the device of the stream, used to read the hardware time
+//  - stream: the TX stream
+//  - sampleRate: the sample rate of the stream in samples per second
+//  - queueSize: the number of bursts and results that can be waiting before Submit blocks
+//
+// Return the scheduler or an error
+func NewBurstScheduler(dev *SDRDevice, stream SDRStream, sampleRate float64, queueSize int) (scheduler *BurstScheduler, err error) {
+
+	if sampleRate <= 0 {
+		return nil, errors.New("the sample rate of the burst scheduler must be positive")
+	}
+
+	if queueSize < 0 {
+		return nil, errors.New("the queue size of the burst scheduler can not be negative")
+	}
+
+	scheduler = &BurstScheduler{
+		dev:             dev,
+		stream:          stream,
+		sampleRate:      sampleRate,
+		queue:           make(chan *scheduledBurst, queueSize),
+		results:         make(chan BurstResult, queueSize),
+		writerDone:      make(chan struct{}),
+		statusDone:      make(chan struct{}),
+		statusSupported: true,
+	}
+
+	go scheduler.writeLoop()
+	go scheduler.statusLoop()
+
+	return scheduler, nil
+}
+
+// Submit queues a burst for transmission.
+//
+// Params:
+//  - burst: the burst to transmit
+//
+// Return the identifier of the burst, found in its result, or an error if the burst is invalid
+func (scheduler *BurstScheduler) Submit(burst Burst) (id uint64, err error) {
+
+	format, err := sdrformat.FormatOf(burst.Buffers)
+	if err != nil {
+		return 0, err
+	}
+
+	if format != scheduler.stream.Format() {
+		return 0, fmt.Errorf("the burst samples are in %v format but the stream is in %v format", format, scheduler.stream.Format())
+	}
+
+	nbChannels, nbSamples, err := sdrformat.BuffersLength(burst.Buffers)
+	if err != nil {
+		return 0, err
+	}
+
+	if uint(nbChannels) != scheduler.stream.getNbChannels() {
+		return 0, errors.New("the burst must have the same number of channels as the stream")
+	}
+
+	if nbSamples == 0 {
+		return 0, errors.New("the burst does not contain any sample")
+	}
+
+	if burst.Start < 0 {
+		return 0, errors.New("the start time of the burst can not be negative")
+	}
+
+	now := time.Now()
+	hardwareTime := scheduler.dev.GetHardwareTime("")
+
+	timeNs := uint(burst.Start)
+	if !burst.Absolute {
+		timeNs += hardwareTime
+	}
+
+	// Estimate the local time of the start of the burst, used for the timeouts
+	start := now
+	if timeNs > hardwareTime {
+		start = now.Add(time.Duration(timeNs - hardwareTime))
+	}
+
+	scheduler.submitMutex.RLock()
+	defer scheduler.submitMutex.RUnlock()
+
+	if scheduler.closed {
+		return 0, errors.New("the burst scheduler is closed")
+	}
+
+	id = atomic.AddUint64(&scheduler.nextID, 1)
+
+	scheduler.queue <- &scheduledBurst{
+		result: BurstResult{
+			ID:        id,
+			TimeNs:    timeNs,
+			NbSamples: uint(nbSamples),
+		},
+		buffers: burst.Buffers,
+		start:   start,
+	}
+
+	return id, nil
+}
+
+// Results returns the channel on which the result of each burst is sent, in order of completion. The channel must be
+// consumed, otherwise the scheduler blocks once queueSize results are waiting. It is closed by Close.
+func (scheduler *BurstScheduler) Results() <-chan BurstResult {
+	return scheduler.results
+}
+
+// Close stops accepting bursts, waits for the result of all the submitted bursts and closes the results channel. It
+// does not deactivate nor close the stream.
+func (scheduler *BurstScheduler) Close() {
+
+	scheduler.submitMutex.Lock()
+	if scheduler.closed {
+		scheduler.submitMutex.Unlock()
+		return
+	}
+	scheduler.closed = true
+	close(scheduler.queue)
+	scheduler.submitMutex.Unlock()
+
+	<-scheduler.writerDone
+	<-scheduler.statusDone
+
+	close(scheduler.results)
+}
+
+// writeLoop writes the bursts of the queue one after the other
+func (scheduler *BurstScheduler) writeLoop() {
+
+	defer close(scheduler.writerDone)
+
+	for burst := range scheduler.queue {
+		scheduler.write(burst)
+	}
+}
+
+// write writes a burst to the stream, in chunks of at most the MTU of the stream
+func (scheduler *BurstScheduler) write(burst *scheduledBurst) {
+
+	// The burst is pending before the first write, so that its status can not arrive before it is known
+	scheduler.mutex.Lock()
+	scheduler.pending = append(scheduler.pending, burst)
+	scheduler.mutex.Unlock()
+
+	nbSamples := int(burst.result.NbSamples)
+	mtu := scheduler.stream.GetMTU()
+	if mtu <= 0 {
+		mtu = nbSamples
+	}
+
+	flags := make([]int, scheduler.stream.getNbChannels())
+
+	written := 0
+	for written < nbSamples {
+
+		// The status of the stream may finish the burst while it is written, such as a time error on its first chunk.
+		// The remaining chunks would be sent without a start time, so the write stops with the recorded result.
+		if scheduler.isFinished(burst) {
+			return
+		}
+
+		count := nbSamples - written
+		if count > mtu {
+			count = mtu
+		}
+
+		chunk, err := sdrformat.SliceBuffers(burst.buffers, written, nbSamples)
+		if err != nil {
+			scheduler.finish(burst, BurstFailed, err, uint(written))
+			return
+		}
+
+		flag := 0
+		if written == 0 {
+			flag |= int(StreamFlagHasTime)
+		}
+		if written+count == nbSamples {
+			flag |= int(StreamFlagEndBurst)
+
+			// The device may acknowledge the end of the burst before the write returns, the status loop records it
+			scheduler.mutex.Lock()
+			burst.ending = true
+			scheduler.mutex.Unlock()
+		}
+		for i := range flags {
+			flags[i] = flag
+		}
+
+		timeout := time.Until(burst.start)
+		if timeout < 0 {
+			timeout = 0
+		}
+		timeout += burstWriteMargin + scheduler.duration(count)
+
+		nbWritten, err := scheduler.stream.writeAny(chunk, uint(count), flags, burst.result.TimeNs, uint(timeout/time.Microsecond))
+		if err == nil && nbWritten == 0 {
+			err = sdrerror.Err(-1)
+		}
+
+		if err != nil {
+			status := BurstFailed
+			switch err.(type) {
+			case *sdrerror.TimeError:
+				status = BurstLate
+			case *sdrerror.Underflow:
+				status = BurstUnderflow
+			}
+			scheduler.finish(burst, status, err, uint(written))
+			return
+		}
+
+		written += int(nbWritten)
+
+		scheduler.mutex.Lock()
+		burst.result.NbWritten = uint(written)
+		scheduler.mutex.Unlock()
+	}
+
+	scheduler.mutex.Lock()
+	if burst.finished {
+		scheduler.mutex.Unlock()
+		return
+	}
+	burst.written = true
+	burst.deadline = burst.start.Add(scheduler.duration(nbSamples) + burstAckTimeout)
+	statusSupported := scheduler.statusSupported
+	acked := burst.acked
+	scheduler.mutex.Unlock()
+
+	switch {
+	case acked:
+		scheduler.acknowledge(burst)
+	case !statusSupported:
+		scheduler.finish(burst, BurstSent, nil, uint(written))
+	}
+}
+
+// statusLoop watches the status of the stream and completes the pending bursts
+func (scheduler *BurstScheduler) statusLoop() {
+
+	defer close(scheduler.statusDone)
+
+	nbChannels := scheduler.stream.getNbChannels()
+	chanMask := make([]uint, nbChannels)
+	flags := make([]int, nbChannels)
+
+	for {
+		select {
+		case <-scheduler.writerDone:
+			scheduler.mutex.Lock()
+			nbPending := len(scheduler.pending)
+			scheduler.mutex.Unlock()
+			if nbPending == 0 {
+				return
+			}
+		default:
+		}
+
+		for i := range flags {
+			flags[i] = 0
+		}
+
+		_, err := scheduler.stream.ReadStreamStatus(chanMask, flags, burstStatusPollUs)
+
+		switch err.(type) {
+		case nil:
+			if flags[0]&int(StreamFlagEndBurst) != 0 {
+				if burst := scheduler.endingPending(); burst != nil {
+					scheduler.acknowledge(burst)
+				}
+			}
+		case *sdrerror.Timeout:
+		case *sdrerror.NotSupported:
+			scheduler.disableStatus()
+			return
+		case *sdrerror.TimeError:
+			if burst := scheduler.oldestPending(); burst != nil {
+				// The burst may still be written, its count of written samples is read under the lock
+				scheduler.mutex.Lock()
+				nbWritten := burst.result.NbWritten
+				scheduler.mutex.Unlock()
+				scheduler.finish(burst, BurstLate, err, nbWritten)
+			}
+		case *sdrerror.Underflow:
+			scheduler.mutex.Lock()
+			if len(scheduler.pending) > 0 {
+				scheduler.pending[0].underflow = true
+			}
+			scheduler.mutex.Unlock()
+		default:
+			// A status that can not be related to a burst, keep polling
+		}
+
+		scheduler.expire()
+	}
+}
+
+// isFinished returns whether the result of a burst was already sent
+func (scheduler *BurstScheduler) isFinished(burst *scheduledBurst) bool {
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	return burst.finished
+}
+
+// oldestPending returns the oldest pending burst, or nil if there is none
+func (scheduler *BurstScheduler) oldestPending() *scheduledBurst {
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if len(scheduler.pending) == 0 {
+		return nil
+	}
+
+	return scheduler.pending[0]
+}
+
+// endingPending handles the acknowledgement of the end of a burst. It returns the oldest pending burst if all its
+// samples are written. If its last chunk is still being written, the acknowledgement is recorded for the writer, which
+// reports the burst once the write returns, and nil is returned.
+func (scheduler *BurstScheduler) endingPending() *scheduledBurst {
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if len(scheduler.pending) == 0 {
+		return nil
+	}
+
+	burst := scheduler.pending[0]
+	if burst.written {
+		return burst
+	}
+
+	if burst.ending {
+		burst.acked = true
+	}
+
+	return nil
+}
+
+// acknowledge reports a burst whose end was acknowledged by the device, as an underflow if one was reported during
+// the burst
+func (scheduler *BurstScheduler) acknowledge(burst *scheduledBurst) {
+
+	scheduler.mutex.Lock()
+	underflow := burst.underflow
+	nbWritten := burst.result.NbWritten
+	scheduler.mutex.Unlock()
+
+	if underflow {
+		scheduler.finish(burst, BurstUnderflow, &sdrerror.Underflow{}, nbWritten)
+	} else {
+		scheduler.finish(burst, BurstAcknowledged, nil, nbWritten)
+	}
+}
+
+// expire reports the written bursts whose acknowledgement did not arrive in time as sent
+func (scheduler *BurstScheduler) expire() {
+
+	now := time.Now()
+	for {
+		scheduler.mutex.Lock()
+		if len(scheduler.pending) == 0 || !scheduler.pending[0].written || now.Before(scheduler.pending[0].deadline) {
+			scheduler.mutex.Unlock()
+			return
+		}
+		burst := scheduler.pending[0]
+		scheduler.mutex.Unlock()
+
+		status := BurstSent
+		var err error
+		if burst.underflow {
+			status = BurstUnderflow
+			err = &sdrerror.Underflow{}
+		}
+		scheduler.finish(burst, status, err, burst.result.NbWritten)
+	}
+}
+
+// disableStatus stops waiting for the acknowledgements, as the stream does not report its status. The written bursts
+// are reported as sent.
+func (scheduler *BurstScheduler) disableStatus() {
+
+	scheduler.mutex.Lock()
+	scheduler.statusSupported = false
+	var written []*scheduledBurst
+	for _, burst := range scheduler.pending {
+		if burst.written {
+			written = append(written, burst)
+		}
+	}
+	scheduler.mutex.Unlock()
+
+	for _, burst := range written {
+		scheduler.finish(burst, BurstSent, nil, burst.result.NbWritten)
+	}
+}
+
+// finish removes a burst from the pending bursts and sends its result. Nothing is done if the burst was already
+// finished.
+func (scheduler *BurstScheduler) finish(burst *scheduledBurst, status BurstStatus, err error, nbWritten uint) {
+
+	scheduler.mutex.Lock()
+	found := false
+	for i, pending := range scheduler.pending {
+		if pending == burst {
+			scheduler.pending = append(scheduler.pending[:i], scheduler.pending[i+1:]...)
+			burst.finished = true
+			found = true
+			break
+		}
+	}
+	scheduler.mutex.Unlock()
+
+	if !found {
+		return
+	}
+
+	scheduler.mutex.Lock()
+	result := burst.result
+	scheduler.mutex.Unlock()
+	result.Status = status
+	result.Err = err
+	result.NbWritten = nbWritten
+
+	scheduler.results <- result
+}
+
+// duration returns the time needed to transmit a number of samples
+func (scheduler *BurstScheduler) duration(nbSamples int) time.Duration {
+	return time.Duration(float64(nbSamples) / scheduler.sampleRate * float64(time.Second))
+}
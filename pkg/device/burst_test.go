@@ -0,0 +1,194 @@
+package device
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+)
+
+// fakeStatus is a status reported by the fake TX stream
+type fakeStatus struct {
+	flags int
+	err   error
+}
+
+// fakeTxStream is a CF32 TX stream with one channel that reports a scripted sequence of statuses while the last chunk
+// of a burst is written, as a device acknowledging a short burst before the write returns. The methods not used by the
+// burst scheduler are not implemented.
+type fakeTxStream struct {
+	SDRStream
+
+	mtu      int
+	script   []fakeStatus
+	statuses chan fakeStatus
+	polled   chan struct{}
+	flags    []int
+}
+
+// newFakeTxStream creates a fake TX stream reporting a sequence of statuses during the last write of each burst
+func newFakeTxStream(mtu int, script ...fakeStatus) *fakeTxStream {
+	return &fakeTxStream{
+		mtu:      mtu,
+		script:   script,
+		statuses: make(chan fakeStatus),
+		polled:   make(chan struct{}),
+	}
+}
+
+func (stream *fakeTxStream) GetMTU() int {
+	return stream.mtu
+}
+
+func (stream *fakeTxStream) Format() string {
+	return "CF32"
+}
+
+func (stream *fakeTxStream) getNbChannels() uint {
+	return 1
+}
+
+func (stream *fakeTxStream) writeAny(buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error) {
+
+	stream.flags = append(stream.flags, flags[0])
+
+	if flags[0]&int(StreamFlagEndBurst) != 0 {
+		for _, status := range stream.script {
+			stream.statuses <- status
+			if _, ok := status.err.(*sdrerror.NotSupported); ok {
+				break
+			}
+			// The status loop polls again once the status is handled
+			<-stream.polled
+		}
+	}
+
+	return nbElems, nil
+}
+
+func (stream *fakeTxStream) ReadStreamStatus(chanMask []uint, flags []int, timeoutUs uint) (timeNs uint, err error) {
+
+	select {
+	case stream.polled <- struct{}{}:
+	default:
+	}
+
+	select {
+	case status := <-stream.statuses:
+		flags[0] = status.flags
+		return 0, status.err
+	case <-time.After(time.Duration(timeoutUs) * time.Microsecond):
+		return 0, &sdrerror.Timeout{}
+	}
+}
+
+// queueBurst queues a burst of samples starting now, bypassing the hardware time read by Submit
+func queueBurst(scheduler *BurstScheduler, id uint64, nbSamples int) {
+
+	scheduler.queue <- &scheduledBurst{
+		result: BurstResult{
+			ID:        id,
+			TimeNs:    1000000000,
+			NbSamples: uint(nbSamples),
+		},
+		buffers: [][]complex64{make([]complex64, nbSamples)},
+		start:   time.Now(),
+	}
+}
+
+// TestBurstStatus checks the result of a burst for sequences of statuses reported while its last chunk is written. An
+// acknowledgement lost by the scheduler would report the burst as sent after burstAckTimeout.
+func TestBurstStatus(t *testing.T) {
+
+	endBurst := fakeStatus{flags: int(StreamFlagEndBurst)}
+
+	tests := []struct {
+		name   string
+		mtu    int
+		script []fakeStatus
+		status BurstStatus
+	}{
+		{"acknowledged during the write", 1000, []fakeStatus{endBurst}, BurstAcknowledged},
+		{"acknowledged during the last chunk", 64, []fakeStatus{endBurst}, BurstAcknowledged},
+		{"underflow then acknowledged", 1000, []fakeStatus{{err: &sdrerror.Underflow{}}, endBurst}, BurstUnderflow},
+		{"late", 1000, []fakeStatus{{err: &sdrerror.TimeError{}}}, BurstLate},
+		{"status not supported", 1000, []fakeStatus{{err: &sdrerror.NotSupported{}}}, BurstSent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			stream := newFakeTxStream(test.mtu, test.script...)
+			scheduler, err := NewBurstScheduler(nil, stream, 1e6, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer scheduler.Close()
+
+			queueBurst(scheduler, 1, 200)
+
+			select {
+			case result := <-scheduler.Results():
+				if result.ID != 1 {
+					t.Fatalf("result of burst %d, expected burst 1", result.ID)
+				}
+				if result.Status != test.status {
+					t.Fatalf("burst %v (%v), expected %v", result.Status, result.Err, test.status)
+				}
+				if result.Status != BurstLate && result.NbWritten != 200 {
+					t.Fatalf("%d samples written, expected 200", result.NbWritten)
+				}
+			case <-time.After(5 * burstAckTimeout):
+				t.Fatal("no result for the burst")
+			}
+		})
+	}
+}
+
+// TestBurstChunks checks that only the first chunk of a burst carries the time and only the last one ends it
+func TestBurstChunks(t *testing.T) {
+
+	stream := newFakeTxStream(64, fakeStatus{flags: int(StreamFlagEndBurst)})
+	scheduler, err := NewBurstScheduler(nil, stream, 1e6, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queueBurst(scheduler, 1, 200)
+	result := <-scheduler.Results()
+	scheduler.Close()
+
+	if result.Status != BurstAcknowledged {
+		t.Fatalf("burst %v, expected %v", result.Status, BurstAcknowledged)
+	}
+
+	expected := []int{int(StreamFlagHasTime), 0, 0, int(StreamFlagEndBurst)}
+	if len(stream.flags) != len(expected) {
+		t.Fatalf("%d writes, expected %d", len(stream.flags), len(expected))
+	}
+	for i, flags := range stream.flags {
+		if flags != expected[i] {
+			t.Fatalf("write %d has flags %#x, expected %#x", i, flags, expected[i])
+		}
+	}
+}
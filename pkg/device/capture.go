@@ -0,0 +1,303 @@
+package device
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+)
+
+// captureDefaultTimeout is the time given to a capture on top of its start delay and duration
+const captureDefaultTimeout = time.Second
+
+// CaptureRequest describes a finite capture of samples on RX channels
+type CaptureRequest struct {
+	// Channels are the RX channels to capture. They can not be empty.
+	Channels []uint
+	// Format is the format of the samples, one of the formats of the sdrformat package
+	Format string
+	// NumSamples is the number of samples to capture per channel
+	NumSamples uint
+	// StartTimeNs is the hardware time in nanoseconds of the first sample to capture, or 0 to capture as soon as
+	// possible
+	StartTimeNs uint
+	// Args are the stream args, or empty for defaults
+	Args map[string]string
+	// TimeoutUs is the time in microseconds given to the capture on top of its start delay and duration, or 0 for the
+	// default of one second
+	TimeoutUs uint
+}
+
+// CaptureResult holds the samples of a finite capture
+type CaptureResult struct {
+	// Buffers are the captured samples, one buffer per requested channel, each holding exactly NumSamples samples.
+	// Their Go type depends on the requested format, for example [][]complex64 for CF32.
+	Buffers interface{}
+	// TimeNs is the hardware time in nanoseconds of the first sample
+	TimeNs uint
+	// TimeAssumed indicates that the driver returned no timestamp with the samples, and that TimeNs is the requested
+	// start time, or 0, rather than a measured time
+	TimeAssumed bool
+	// Trimmed indicates that the driver did not support finite timed bursts, or ignored their start time, and that the
+	// samples were selected in a free running stream using their timestamps
+	Trimmed bool
+}
+
+// Capture captures a finite number of samples on RX channels, starting at a given hardware time.
+//
+// The stream is activated with StreamFlagHasTime and a finite number of elements when the driver supports it. When it
+// does not, the stream is activated in free running mode and the samples received before the start time are trimmed
+// using the timestamps of the reads. A driver accepting the finite burst but ignoring its start time would deliver
+// its samples too early: the stream is then activated again in free running mode and trimmed the same way. An error
+// is returned if the first samples are received after the start time. Exactly NumSamples samples per channel are
+// returned, with the timestamp of the first one.
+//
+// The samples returned are contiguous: the timestamps of the reads are checked against the samples already received,
+// so that samples dropped by the driver without reporting an overflow are detected. On such a gap, a capture without
+// start time is restarted with the next samples, as after an overflow, and a timed capture fails as its start time
+// has passed.
+//
+// The sample rate of the channels must be set before the call. The capture sets up its own stream, so the channels
+// must not be used by another stream.
+//
+// Params:
+//  - dev: the device to capture from
+//  - request: the description of the capture
+//
+// Return the captured samples or an error
+func Capture(dev *SDRDevice, request CaptureRequest) (result CaptureResult, err error) {
+
+	if len(request.Channels) == 0 {
+		return result, errors.New("the channels must be given explicitly for a capture")
+	}
+
+	if request.NumSamples == 0 {
+		return result, errors.New("the number of samples to capture must be positive")
+	}
+
+	sampleRate := dev.GetSampleRate(DirectionRX, request.Channels[0])
+	if sampleRate <= 0 {
+		return result, errors.New("the sample rate of the channels must be set before a capture")
+	}
+
+	stream, err := dev.SetupSDRStream(DirectionRX, request.Format, request.Channels, request.Args)
+	if err != nil {
+		return result, err
+	}
+	defer stream.Close()
+
+	// Compute the time after which the capture is abandoned
+	timeout := captureDefaultTimeout
+	if request.TimeoutUs > 0 {
+		timeout = time.Duration(request.TimeoutUs) * time.Microsecond
+	}
+	wait := time.Duration(0)
+	if request.StartTimeNs > 0 {
+		if now := dev.GetHardwareTime(""); request.StartTimeNs > now {
+			wait = time.Duration(request.StartTimeNs - now)
+		}
+	}
+	deadline := time.Now().Add(wait + time.Duration(sdrtime.TicksToTimeNs(int(request.NumSamples), sampleRate)) + timeout)
+
+	return captureStream(stream, request, sampleRate, deadline)
+}
+
+// captureStream activates a stream set up for a capture and reads the samples of the capture
+//
+// Params:
+//  - stream: the RX stream of the channels of the capture
+//  - request: the description of the capture
+//  - sampleRate: the sample rate of the channels
+//  - deadline: the time after which the capture is abandoned
+//
+// Return the captured samples or an error
+func captureStream(stream SDRStream, request CaptureRequest, sampleRate float64, deadline time.Time) (result CaptureResult, err error) {
+
+	nbChannels := len(request.Channels)
+	nbSamples := int(request.NumSamples)
+
+	buffers, err := sdrformat.MakeBuffers(request.Format, nbChannels, nbSamples)
+	if err != nil {
+		return result, err
+	}
+
+	// Activate a finite burst, or a free running stream if the driver does not support it
+	flags := StreamFlagEndBurst
+	if request.StartTimeNs > 0 {
+		flags |= StreamFlagHasTime
+	}
+
+	freeRunning := false
+	if activateErr := stream.Activate(flags, int(request.StartTimeNs), nbSamples); activateErr != nil {
+		if _, ok := activateErr.(*sdrerror.NotSupported); !ok {
+			return result, activateErr
+		}
+
+		freeRunning = true
+		if activateErr = stream.Activate(0, 0, 0); activateErr != nil {
+			return result, activateErr
+		}
+	}
+	defer stream.Deactivate(0, 0)
+
+	mtu := stream.GetMTU()
+	if mtu <= 0 {
+		mtu = nbSamples
+	}
+
+	scratch, err := sdrformat.MakeBuffers(request.Format, nbChannels, mtu)
+	if err != nil {
+		return result, err
+	}
+
+	outputFlags := make([]int, nbChannels)
+
+	tracker, err := sdrtime.NewTracker(sampleRate, 1)
+	if err != nil {
+		return result, err
+	}
+
+	collected := 0
+	for collected < nbSamples {
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return result, fmt.Errorf("capture timed out after %d of %d samples", collected, nbSamples)
+		}
+
+		timeNs, nbRead, err := stream.readAny(scratch, uint(mtu), outputFlags, uint(remaining/time.Microsecond))
+		if err != nil {
+			switch err.(type) {
+			case *sdrerror.Timeout:
+				continue
+			case *sdrerror.Overflow:
+				if freeRunning && request.StartTimeNs == 0 {
+					collected = 0
+					tracker.Reset()
+					continue
+				}
+			}
+			return result, err
+		}
+
+		if nbRead == 0 {
+			continue
+		}
+
+		chunk, err := sdrformat.SliceBuffers(scratch, 0, int(nbRead))
+		if err != nil {
+			return result, err
+		}
+
+		hasTime := outputFlags[0]&int(StreamFlagHasTime) != 0
+
+		if collected > 0 {
+			if discontinuity, found := tracker.Observe(int64(timeNs), hasTime, int64(nbRead)); found {
+				if !freeRunning || request.StartTimeNs > 0 {
+					return result, fmt.Errorf("%v of %d samples in the capture after %d of %d samples", discontinuity.Kind,
+						discontinuity.Samples, collected, nbSamples)
+				}
+
+				// Without start time, the capture starts again with the samples following the gap
+				collected = 0
+				tracker.Reset()
+			}
+		}
+
+		chunkSamples := int(nbRead)
+
+		if collected == 0 {
+			if request.StartTimeNs > 0 && hasTime {
+
+				// A driver may ignore the start time of the burst, the samples are trimmed whenever they start before
+				// it. The offset is rounded, so that a block starting less than half a sample after the start time is
+				// accepted.
+				delta := int64(timeNs) - int64(request.StartTimeNs)
+				if delta > sdrtime.TicksToTimeNs64(1, sampleRate)/2 {
+					return result, fmt.Errorf("the first samples received at %d ns are after the start time %d ns", timeNs, request.StartTimeNs)
+				}
+
+				offset := 0
+				if delta < 0 {
+					offset = int(sdrtime.TimeNsToTicks64(-delta, sampleRate))
+				}
+
+				if offset > 0 && !freeRunning {
+					// The burst holds exactly NumSamples samples starting too early, the stream runs freely instead
+					stream.Deactivate(0, 0)
+					if activateErr := stream.Activate(0, 0, 0); activateErr != nil {
+						return result, activateErr
+					}
+					freeRunning = true
+					continue
+				}
+
+				if offset >= int(nbRead) {
+					continue
+				}
+
+				if offset > 0 {
+					if chunk, err = sdrformat.SliceBuffers(chunk, offset, int(nbRead)); err != nil {
+						return result, err
+					}
+					timeNs += uint(sdrtime.TicksToTimeNs(offset, sampleRate))
+					chunkSamples -= offset
+					result.Trimmed = true
+				}
+
+			} else if !hasTime {
+				if freeRunning && request.StartTimeNs > 0 {
+					return result, errors.New("the stream does not provide timestamps, the capture can not be trimmed")
+				}
+				timeNs = request.StartTimeNs
+			}
+
+			result.TimeNs = timeNs
+			result.TimeAssumed = !hasTime
+			tracker.Observe(int64(timeNs), hasTime, int64(chunkSamples))
+		}
+
+		remainingBuffers, err := sdrformat.SliceBuffers(buffers, collected, nbSamples)
+		if err != nil {
+			return result, err
+		}
+
+		copied, err := sdrformat.CopyBuffers(remainingBuffers, chunk)
+		if err != nil {
+			return result, err
+		}
+		collected += copied
+
+		if !freeRunning && collected < nbSamples && outputFlags[0]&int(StreamFlagEndBurst) != 0 {
+			return result, fmt.Errorf("the burst ended after %d of %d samples", collected, nbSamples)
+		}
+	}
+
+	result.Buffers = buffers
+	result.Trimmed = result.Trimmed || (freeRunning && request.StartTimeNs > 0)
+
+	return result, nil
+}
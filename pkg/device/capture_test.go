@@ -0,0 +1,205 @@
+package device
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+)
+
+// fakeActivation is a call to Activate on the fake RX stream
+type fakeActivation struct {
+	flags    StreamFlag
+	timeNs   int
+	numElems int
+}
+
+// fakeRxStream is a CF32 RX stream with one channel at 1 Msps, whose samples hold their index since the time 0 in
+// their real part. It delivers blocks of at most mtu samples, like a driver that may not support finite bursts, may
+// ignore their start time or may not return timestamps. The methods not used by a capture are not implemented.
+type fakeRxStream struct {
+	SDRStream
+
+	mtu            int
+	noBursts       bool
+	ignoresTime    bool
+	noTimestamps   bool
+	gapAt          int
+	gapSamples     int
+	clock          int
+	burstRemaining int
+	activations    []fakeActivation
+}
+
+func (stream *fakeRxStream) GetMTU() int {
+	return stream.mtu
+}
+
+func (stream *fakeRxStream) Activate(flags StreamFlag, timeNs int, numElems int) sdrerror.SDRError {
+
+	stream.activations = append(stream.activations, fakeActivation{flags, timeNs, numElems})
+
+	if flags&StreamFlagEndBurst != 0 && stream.noBursts {
+		return &sdrerror.NotSupported{}
+	}
+
+	if flags&StreamFlagHasTime != 0 && !stream.ignoresTime {
+		stream.clock = timeNs / 1000
+	}
+
+	stream.burstRemaining = -1
+	if flags&StreamFlagEndBurst != 0 {
+		stream.burstRemaining = numElems
+	}
+
+	return nil
+}
+
+func (stream *fakeRxStream) Deactivate(flags StreamFlag, timeNs int) sdrerror.SDRError {
+	return nil
+}
+
+func (stream *fakeRxStream) readAny(buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error) {
+
+	if stream.burstRemaining == 0 {
+		return 0, 0, &sdrerror.Timeout{}
+	}
+
+	count := int(nbElems)
+	if count > stream.mtu {
+		count = stream.mtu
+	}
+	if stream.burstRemaining > 0 && count > stream.burstRemaining {
+		count = stream.burstRemaining
+	}
+
+	// Samples dropped by the driver without reporting an overflow
+	if stream.gapSamples > 0 && stream.clock >= stream.gapAt {
+		stream.clock += stream.gapSamples
+		stream.gapSamples = 0
+	}
+
+	samples := buffers.([][]complex64)[0]
+	for i := 0; i < count; i++ {
+		samples[i] = complex(float32(stream.clock+i), 0)
+	}
+
+	outputFlags[0] = 0
+	if !stream.noTimestamps {
+		outputFlags[0] |= int(StreamFlagHasTime)
+	}
+	if stream.burstRemaining > 0 {
+		stream.burstRemaining -= count
+		if stream.burstRemaining == 0 {
+			outputFlags[0] |= int(StreamFlagEndBurst)
+		}
+	}
+
+	timeNs = uint(stream.clock * 1000)
+	stream.clock += count
+
+	return timeNs, uint(count), nil
+}
+
+// TestCapture checks the samples and the time of captures with drivers supporting or not the finite timed bursts
+func TestCapture(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		stream      fakeRxStream
+		startTimeNs uint
+		first       int
+		timeNs      uint
+		trimmed     bool
+		timeAssumed bool
+		activations int
+		err         string
+	}{
+		{"timed burst", fakeRxStream{}, 500000, 500, 500000, false, false, 1, ""},
+		{"burst ignoring its start time", fakeRxStream{ignoresTime: true}, 500000, 500, 500000, true, false, 2, ""},
+		{"bursts not supported", fakeRxStream{noBursts: true}, 500000, 500, 500000, true, false, 2, ""},
+		{"burst without start time", fakeRxStream{clock: 70}, 0, 70, 70000, false, false, 1, ""},
+		{"timed burst without timestamps", fakeRxStream{noTimestamps: true}, 500000, 500, 500000, false, true, 1, ""},
+		{"burst without timestamps", fakeRxStream{noTimestamps: true, clock: 70}, 0, 70, 0, false, true, 1, ""},
+		{"trimming without timestamps", fakeRxStream{noBursts: true, noTimestamps: true}, 500000, 0, 0, false, false, 2,
+			"can not be trimmed"},
+		{"start time passed", fakeRxStream{ignoresTime: true, clock: 600}, 500000, 0, 0, false, false, 1,
+			"after the start time"},
+		{"gap restarting the capture", fakeRxStream{noBursts: true, gapAt: 200, gapSamples: 10}, 0, 266, 266000, false,
+			false, 2, ""},
+		{"gap in a timed capture", fakeRxStream{noBursts: true, gapAt: 600, gapSamples: 10}, 500000, 0, 0, false,
+			false, 2, "gap of 10 samples"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			stream := test.stream
+			stream.mtu = 128
+
+			request := CaptureRequest{Channels: []uint{0}, Format: "CF32", NumSamples: 300, StartTimeNs: test.startTimeNs}
+			result, err := captureStream(&stream, request, 1e6, time.Now().Add(time.Second))
+
+			if len(stream.activations) != test.activations {
+				t.Errorf("%d activations, expected %d", len(stream.activations), test.activations)
+			}
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error %v, expected %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.TimeNs != test.timeNs {
+				t.Errorf("time %d ns, expected %d ns", result.TimeNs, test.timeNs)
+			}
+			if result.Trimmed != test.trimmed {
+				t.Errorf("trimmed %v, expected %v", result.Trimmed, test.trimmed)
+			}
+			if result.TimeAssumed != test.timeAssumed {
+				t.Errorf("time assumed %v, expected %v", result.TimeAssumed, test.timeAssumed)
+			}
+
+			samples := result.Buffers.([][]complex64)[0]
+			if len(samples) != 300 {
+				t.Fatalf("%d samples, expected 300", len(samples))
+			}
+			for i, sample := range samples {
+				if int(real(sample)) != test.first+i {
+					t.Fatalf("sample %d is sample %d of the stream, expected %d", i, int(real(sample)), test.first+i)
+				}
+			}
+
+			// A driver ignoring the start time of the burst is activated again in free running mode
+			last := stream.activations[len(stream.activations)-1]
+			if (test.trimmed || test.stream.noBursts) && (last.flags != 0 || last.numElems != 0) {
+				t.Errorf("last activation %+v, expected a free running stream", last)
+			}
+		})
+	}
+}
@@ -42,6 +42,12 @@ import (
 // Return an error or nil in case of success
 func (dev *SDRDevice) SetMasterClockRate(rate float64) (err sdrerror.SDRError) {
 
+	if dev.strict {
+		if err := checkRange("master clock rate", rate, dev.GetMasterClockRates()); err != nil {
+			return err
+		}
+	}
+
 	return sdrerror.Err(int(C.SoapySDRDevice_setMasterClockRate(dev.device, C.double(rate))))
 }
 
@@ -56,7 +62,7 @@ func (dev *SDRDevice) GetMasterClockRate() float64 {
 // GetMasterClockRates gets the range of available master clock rates.
 //
 // Return a list of clock rate ranges in Hz
-func (dev *SDRDevice) GetMasterClockRates() []SDRRange {
+func (dev *SDRDevice) GetMasterClockRates() SDRRanges {
 
 	length := C.size_t(0)
 
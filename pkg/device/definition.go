@@ -28,6 +28,7 @@ import (
 	"fmt"
 
 	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrstats"
 )
 
 // Direction is the direction of the Data in the device TX and RX
@@ -96,6 +97,7 @@ const (
 // SDRDevice is the opaque structure allowing to access device functions
 type SDRDevice struct {
 	device *C.SoapySDRDevice
+	strict bool
 }
 
 // SDRStream is the opaque structure allowing to access stream functions
@@ -169,12 +171,29 @@ type SDRStream interface {
 	// Return the number of direct access buffers or 0
 	GetNumDirectAccessBuffers() uint
 
+	// SetStats sets the statistics updated by the read, write and status operations of the stream. Passing nil
+	// disables the statistics.
+	//
+	// Params:
+	//  - stats: the statistics of the stream, usually registered in a sdrstats.Registry
+	SetStats(stats *sdrstats.Stats)
+
+	// GetStats returns the statistics updated by the stream, or nil if none are set
+	GetStats() *sdrstats.Stats
+
+	// Format returns the format of the samples of the stream, for example "CS16"
+	Format() string
+
 	// getDevice returns the internal device
 	getDevice() *C.SoapySDRDevice
 	// getStream returns the internal stream
 	getStream() *C.SoapySDRStream
 	// getNbChannels returns the number of channels used by the stream
 	getNbChannels() uint
+	// readAny reads elements from the stream into buffers given as an interface
+	readAny(buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error)
+	// writeAny writes elements to the stream from buffers given as an interface
+	writeAny(buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error)
 }
 
 // SDRRange is the definition for a min/max numeric range with a step information
@@ -64,6 +64,12 @@ import (
 // Return an error or nil in case of success
 func (dev *SDRDevice) SetFrequency(direction Direction, channel uint, frequency float64, args map[string]string) (err sdrerror.SDRError) {
 
+	if dev.strict {
+		if err := checkRange("frequency", frequency, dev.GetFrequencyRange(direction, channel)); err != nil {
+			return err
+		}
+	}
+
 	cArgs, cArgsLength := go2Args(args)
 	defer argsListClear(cArgs, cArgsLength)
 
@@ -89,6 +95,12 @@ func (dev *SDRDevice) SetFrequency(direction Direction, channel uint, frequency
 // Return an error or nil in case of success
 func (dev *SDRDevice) SetFrequencyComponent(direction Direction, channel uint, name string, frequency float64, args map[string]string) (err sdrerror.SDRError) {
 
+	if dev.strict {
+		if err := checkRange(name+" frequency", frequency, dev.GetFrequencyRangeComponent(direction, channel, name)); err != nil {
+			return err
+		}
+	}
+
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
 
@@ -154,7 +166,7 @@ func (dev *SDRDevice) ListFrequencies(direction Direction, channel uint) []strin
 //  - channel: an available channel
 //
 // Return a list of frequency ranges in Hz
-func (dev *SDRDevice) GetFrequencyRange(direction Direction, channel uint) []SDRRange {
+func (dev *SDRDevice) GetFrequencyRange(direction Direction, channel uint) SDRRanges {
 
 	length := C.size_t(0)
 
@@ -172,7 +184,7 @@ func (dev *SDRDevice) GetFrequencyRange(direction Direction, channel uint) []SDR
 //  - name: the name of a tunable element
 //
 // Return a list of frequency ranges in Hz
-func (dev *SDRDevice) GetFrequencyRangeComponent(direction Direction, channel uint, name string) []SDRRange {
+func (dev *SDRDevice) GetFrequencyRangeComponent(direction Direction, channel uint, name string) SDRRanges {
 
 	length := C.size_t(0)
 
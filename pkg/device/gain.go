@@ -102,6 +102,12 @@ func (dev *SDRDevice) GetGainMode(direction Direction, channel uint) bool {
 // Return an error or nil in case of success
 func (dev *SDRDevice) SetGain(direction Direction, channel uint, gain float64) (err sdrerror.SDRError) {
 
+	if dev.strict {
+		if err := checkRange("gain", gain, SDRRanges{dev.GetGainRange(direction, channel)}); err != nil {
+			return err
+		}
+	}
+
 	return sdrerror.Err(int(C.SoapySDRDevice_setGain(dev.device, C.int(direction), C.size_t(channel), C.double(gain))))
 }
 
@@ -116,6 +122,12 @@ func (dev *SDRDevice) SetGain(direction Direction, channel uint, gain float64) (
 // Return an error or nil in case of success
 func (dev *SDRDevice) SetGainElement(direction Direction, channel uint, name string, gain float64) (err sdrerror.SDRError) {
 
+	if dev.strict {
+		if err := checkRange(name+" gain", gain, SDRRanges{dev.GetGainElementRange(direction, channel, name)}); err != nil {
+			return err
+		}
+	}
+
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
 
@@ -0,0 +1,344 @@
+package device
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// GainPolicy is the way a gain planner distributes a total gain over the amplification elements of a channel
+type GainPolicy int
+
+const (
+	// GainLowestNoise raises the gain of the elements in order RF to baseband: the LNA first, for the lowest noise
+	// figure
+	GainLowestNoise GainPolicy = iota
+	// GainBestLinearity raises the gain of the elements in order baseband to RF: the LNA last, for the best linearity
+	// with strong signals
+	GainBestLinearity
+	// GainFromTable uses the entries of the table of the planner
+	GainFromTable
+)
+
+// String returns a human readable name of the gain policy
+func (policy GainPolicy) String() string {
+
+	switch policy {
+	case GainLowestNoise:
+		return "lowest noise"
+	case GainBestLinearity:
+		return "best linearity"
+	case GainFromTable:
+		return "table"
+	}
+
+	return fmt.Sprintf("GainPolicy(%d)", int(policy))
+}
+
+// GainElement is an amplification element of a channel
+type GainElement struct {
+	// Name is the name of the element
+	Name string
+	// Range is the range of the gain of the element in dB
+	Range SDRRange
+}
+
+// ElementGain is the gain of an amplification element
+type ElementGain struct {
+	// Name is the name of the element
+	Name string
+	// Gain is the gain of the element in dB
+	Gain float64
+}
+
+// GainTableEntry is an entry of the table of a gain planner: the gains of the elements for a total gain
+type GainTableEntry struct {
+	// Gain is the total gain in dB of the entry
+	Gain float64
+	// Elements are the gains in dB of the elements by name. The elements not listed are set to their minimum gain.
+	Elements map[string]float64
+}
+
+// GainPlan is a distribution of a total gain over the amplification elements of a channel
+type GainPlan struct {
+	// Requested is the requested total gain in dB
+	Requested float64
+	// Gain is the total gain in dB of the plan, quantized by the steps of the elements
+	Gain float64
+	// Elements are the gains of the elements, in order RF to baseband
+	Elements []ElementGain
+}
+
+// GainPlanner distributes a total gain over the amplification elements of a channel
+type GainPlanner struct {
+	dev       *SDRDevice
+	direction Direction
+	channel   uint
+	policy    GainPolicy
+	elements  []GainElement
+	table     []GainTableEntry
+}
+
+// NewGainPlanner creates a gain planner for the amplification elements of a channel.
+//
+// Params:
+//  - dev: the device
+//  - direction: the channel direction RX or TX
+//  - channel: an available channel
+//  - policy: the policy of the distribution of the gain. GainFromTable requires a table set by SetTable.
+//
+// Return the planner or an error
+func NewGainPlanner(dev *SDRDevice, direction Direction, channel uint, policy GainPolicy) (planner *GainPlanner, err error) {
+
+	if policy < GainLowestNoise || policy > GainFromTable {
+		return nil, fmt.Errorf("unknown gain policy %v", policy)
+	}
+
+	planner = &GainPlanner{
+		dev:       dev,
+		direction: direction,
+		channel:   channel,
+		policy:    policy,
+	}
+
+	for _, name := range dev.ListGains(direction, channel) {
+		planner.elements = append(planner.elements, GainElement{
+			Name:  name,
+			Range: dev.GetGainElementRange(direction, channel, name),
+		})
+	}
+
+	if len(planner.elements) == 0 {
+		return nil, errors.New("the channel does not have any amplification element")
+	}
+
+	return planner, nil
+}
+
+// Policy returns the policy of the planner
+func (planner *GainPlanner) Policy() GainPolicy {
+	return planner.policy
+}
+
+// Elements returns the amplification elements of the channel, in order RF to baseband
+func (planner *GainPlanner) Elements() []GainElement {
+	return planner.elements
+}
+
+// Range returns the range of the total gain: the sums of the minimum and maximum gains of the elements, and the
+// smallest step of the elements
+func (planner *GainPlanner) Range() SDRRange {
+
+	var gainRange SDRRange
+	for _, element := range planner.elements {
+		gainRange.Minimum += element.Range.Minimum
+		gainRange.Maximum += element.Range.Maximum
+		if element.Range.Step > 0 && (gainRange.Step == 0 || element.Range.Step < gainRange.Step) {
+			gainRange.Step = element.Range.Step
+		}
+	}
+
+	return gainRange
+}
+
+// SetTable sets the table of the planner, used with the GainFromTable policy.
+//
+// Params:
+//  - table: the entries of the table, in any order
+//
+// Return an error if an entry refers to an unknown element
+func (planner *GainPlanner) SetTable(table []GainTableEntry) error {
+
+	for _, entry := range table {
+		for name := range entry.Elements {
+			if planner.element(name) < 0 {
+				return fmt.Errorf("unknown amplification element %v in the gain table", name)
+			}
+		}
+	}
+
+	planner.table = append([]GainTableEntry(nil), table...)
+	sort.Slice(planner.table, func(i, j int) bool {
+		return planner.table[i].Gain < planner.table[j].Gain
+	})
+
+	return nil
+}
+
+// Plan distributes a total gain over the elements according to the policy of the planner, without changing the
+// settings of the device. The gains of the elements are clamped to their ranges and quantized to their steps.
+//
+// Params:
+//  - gain: the total gain in dB
+//
+// Return the plan or an error
+func (planner *GainPlanner) Plan(gain float64) (plan GainPlan, err error) {
+
+	gains := make([]float64, len(planner.elements))
+
+	switch planner.policy {
+	case GainLowestNoise, GainBestLinearity:
+		order := make([]int, len(planner.elements))
+		for i := range order {
+			order[i] = i
+			if planner.policy == GainBestLinearity {
+				order[i] = len(order) - 1 - i
+			}
+		}
+		planner.fill(gains, gain, order)
+
+	case GainFromTable:
+		if len(planner.table) == 0 {
+			return GainPlan{}, errors.New("the gain planner does not have a table")
+		}
+
+		// the entry with the highest gain not above the requested one, the lowest entry otherwise
+		entry := planner.table[0]
+		for _, candidate := range planner.table {
+			if candidate.Gain <= gain {
+				entry = candidate
+			}
+		}
+
+		for i, element := range planner.elements {
+			gains[i] = element.Range.Minimum
+			if elementGain, ok := entry.Elements[element.Name]; ok {
+				gains[i] = quantizeGain(elementGain, element.Range)
+			}
+		}
+	}
+
+	plan = GainPlan{Requested: gain}
+	for i, element := range planner.elements {
+		plan.Elements = append(plan.Elements, ElementGain{Name: element.Name, Gain: gains[i]})
+		plan.Gain += gains[i]
+	}
+
+	return plan, nil
+}
+
+// Apply distributes a total gain over the elements according to the policy of the planner, sets the gains of the
+// elements and reads them back.
+//
+// Params:
+//  - gain: the total gain in dB
+//
+// Return the plan with the gains read back from the device, or an error
+func (planner *GainPlanner) Apply(gain float64) (plan GainPlan, err error) {
+
+	plan, err = planner.Plan(gain)
+	if err != nil {
+		return GainPlan{}, err
+	}
+
+	for _, element := range plan.Elements {
+		if err := planner.dev.SetGainElement(planner.direction, planner.channel, element.Name, element.Gain); err != nil {
+			return GainPlan{}, err
+		}
+	}
+
+	return planner.Read(gain), nil
+}
+
+// Read reads the current gains of the elements.
+//
+// Params:
+//  - requested: the total gain in dB reported as requested in the plan
+//
+// Return the current gains as a plan
+func (planner *GainPlanner) Read(requested float64) GainPlan {
+
+	plan := GainPlan{Requested: requested}
+	for _, element := range planner.elements {
+		gain := planner.dev.GetGainElement(planner.direction, planner.channel, element.Name)
+		plan.Elements = append(plan.Elements, ElementGain{Name: element.Name, Gain: gain})
+		plan.Gain += gain
+	}
+
+	return plan
+}
+
+// fill raises the gains of the elements in a given order until the total gain is reached
+//
+// Params:
+//  - gains: the gains of the elements, set by the function
+//  - gain: the total gain
+//  - order: the indexes of the elements in the order their gains are raised
+func (planner *GainPlanner) fill(gains []float64, gain float64, order []int) {
+
+	remaining := gain
+	for i, element := range planner.elements {
+		gains[i] = element.Range.Minimum
+		remaining -= element.Range.Minimum
+	}
+
+	for _, i := range order {
+		gainRange := planner.elements[i].Range
+		gains[i] = quantizeGain(gainRange.Minimum+math.Max(0, remaining), gainRange)
+		remaining -= gains[i] - gainRange.Minimum
+	}
+
+	// the steps of the elements may leave a rest, rounded up by the first element able to take it
+	for _, i := range order {
+		gainRange := planner.elements[i].Range
+		if gainRange.Step > 0 && remaining >= gainRange.Step/2 && gains[i]+gainRange.Step <= gainRange.Maximum {
+			gains[i] += gainRange.Step
+			remaining -= gainRange.Step
+		}
+	}
+}
+
+// element returns the index of an element
+//
+// Params:
+//  - name: the name of the element
+//
+// Return the index of the element, -1 if it is unknown
+func (planner *GainPlanner) element(name string) int {
+
+	for i, element := range planner.elements {
+		if element.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// quantizeGain clamps a gain to a range and rounds it down to the steps of the range
+//
+// Params:
+//  - gain: the gain
+//  - gainRange: the range
+//
+// Return the quantized gain
+func quantizeGain(gain float64, gainRange SDRRange) float64 {
+
+	gain = gainRange.Clamp(gain)
+	if gainRange.Step > 0 {
+		gain = gainRange.Minimum + math.Floor((gain-gainRange.Minimum)/gainRange.Step+1e-9)*gainRange.Step
+	}
+
+	return gain
+}
@@ -0,0 +1,199 @@
+package device
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"testing"
+)
+
+// testGainElements are amplification elements of a channel, in order RF to baseband: a LNA with steps of 3 dB, a
+// mixer without steps and a VGA with steps of 1 dB and a negative minimum
+var testGainElements = []GainElement{
+	{Name: "LNA", Range: SDRRange{Minimum: 0, Maximum: 30, Step: 3}},
+	{Name: "MIX", Range: SDRRange{Minimum: 0, Maximum: 10}},
+	{Name: "VGA", Range: SDRRange{Minimum: -12, Maximum: 19, Step: 1}},
+}
+
+// planGains returns the gains of the elements of a plan
+func planGains(plan GainPlan) []float64 {
+
+	gains := make([]float64, len(plan.Elements))
+	for i, element := range plan.Elements {
+		gains[i] = element.Gain
+	}
+
+	return gains
+}
+
+func TestGainPlan(t *testing.T) {
+
+	steppedElements := []GainElement{
+		{Name: "LNA", Range: SDRRange{Minimum: 0, Maximum: 30, Step: 3}},
+		{Name: "VGA", Range: SDRRange{Minimum: 0, Maximum: 20, Step: 2}},
+	}
+
+	tests := []struct {
+		name     string
+		policy   GainPolicy
+		elements []GainElement
+		gain     float64
+		gains    []float64
+	}{
+		{"LNA first", GainLowestNoise, testGainElements, 40, []float64{30, 10, 0}},
+		{"LNA first, partial LNA", GainLowestNoise, testGainElements, 10, []float64{21, 1, -12}},
+		{"LNA first, minimum", GainLowestNoise, testGainElements, -12, []float64{0, 0, -12}},
+		{"LNA first, below the range", GainLowestNoise, testGainElements, -20, []float64{0, 0, -12}},
+		{"LNA first, maximum", GainLowestNoise, testGainElements, 59, []float64{30, 10, 19}},
+		{"LNA first, above the range", GainLowestNoise, testGainElements, 100, []float64{30, 10, 19}},
+		{"LNA last", GainBestLinearity, testGainElements, 10, []float64{0, 0, 10}},
+		// The rest of 2 dB below the steps of the LNA is rounded up by it
+		{"LNA last, rest", GainBestLinearity, testGainElements, 40, []float64{12, 10, 19}},
+		{"LNA last, below the range", GainBestLinearity, testGainElements, -20, []float64{0, 0, -12}},
+		{"LNA last, above the range", GainBestLinearity, testGainElements, 100, []float64{30, 10, 19}},
+		{"steps", GainLowestNoise, steppedElements, 24, []float64{24, 0}},
+		// The rest of 1 dB is below half the step of the LNA, the VGA rounds it up
+		{"rest rounded up by the next element", GainLowestNoise, steppedElements, 25, []float64{24, 2}},
+		{"rest rounded up by the first element", GainLowestNoise, steppedElements, 25.5, []float64{27, 0}},
+		{"rest below half the steps", GainLowestNoise, steppedElements, 24.9, []float64{24, 0}},
+		// The LNA at its maximum can not round up the rest
+		{"rest at the maximum", GainLowestNoise, steppedElements, 31, []float64{30, 2}},
+		{"rest, LNA last", GainBestLinearity, steppedElements, 23, []float64{3, 20}},
+	}
+
+	for _, test := range tests {
+
+		planner := &GainPlanner{policy: test.policy, elements: test.elements}
+		plan, err := planner.Plan(test.gain)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if !sameValues(planGains(plan), test.gains) {
+			t.Errorf("%s: gains %v, expected %v", test.name, planGains(plan), test.gains)
+		}
+
+		total := 0.0
+		for i, element := range plan.Elements {
+			total += element.Gain
+			if element.Name != test.elements[i].Name {
+				t.Errorf("%s: element %d named %v, expected %v", test.name, i, element.Name, test.elements[i].Name)
+			}
+		}
+		if plan.Requested != test.gain || !sameValue(plan.Gain, total) {
+			t.Errorf("%s: plan of %v dB for %v dB, expected %v dB for %v dB", test.name, plan.Gain, plan.Requested,
+				total, test.gain)
+		}
+	}
+}
+
+func TestGainPlanTable(t *testing.T) {
+
+	planner := &GainPlanner{policy: GainFromTable, elements: testGainElements}
+	if _, err := planner.Plan(20); err == nil {
+		t.Error("expected an error without table")
+	}
+
+	if err := planner.SetTable([]GainTableEntry{{Gain: 10, Elements: map[string]float64{"TIA": 3}}}); err == nil {
+		t.Error("expected an error for an unknown element")
+	}
+
+	// Entries out of order, with gains out of the ranges and off the steps
+	err := planner.SetTable([]GainTableEntry{
+		{Gain: 40, Elements: map[string]float64{"LNA": 30, "VGA": 10}},
+		{Gain: 0},
+		{Gain: 20, Elements: map[string]float64{"LNA": 16, "MIX": 20}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		gain  float64
+		gains []float64
+	}{
+		{-20, []float64{0, 0, -12}},
+		{0, []float64{0, 0, -12}},
+		{19.9, []float64{0, 0, -12}},
+		{20, []float64{15, 10, -12}},
+		{25, []float64{15, 10, -12}},
+		{40, []float64{30, 0, 10}},
+		{100, []float64{30, 0, 10}},
+	}
+
+	for _, test := range tests {
+
+		plan, err := planner.Plan(test.gain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !sameValues(planGains(plan), test.gains) {
+			t.Errorf("%v dB: gains %v, expected %v", test.gain, planGains(plan), test.gains)
+		}
+	}
+}
+
+func TestQuantizeGain(t *testing.T) {
+
+	tests := []struct {
+		gain      float64
+		gainRange SDRRange
+		expected  float64
+	}{
+		{4, SDRRange{0, 30, 3}, 3},
+		{6, SDRRange{0, 30, 3}, 6},
+		{6 - 1e-12, SDRRange{0, 30, 3}, 6},
+		{5.99, SDRRange{0, 30, 3}, 3},
+		{31, SDRRange{0, 30, 3}, 30},
+		{-1, SDRRange{0, 30, 3}, 0},
+		{11, SDRRange{0, 10, 3}, 9},
+		{-11.5, SDRRange{-12, 19, 1}, -12},
+		{0.5, SDRRange{-12, 19, 1}, 0},
+		{-0.5, SDRRange{-12, 19, 1}, -1},
+		{3.7, SDRRange{0, 10, 0}, 3.7},
+		{2.1, SDRRange{0.5, 10, 0.5}, 2},
+	}
+
+	for _, test := range tests {
+		if gain := quantizeGain(test.gain, test.gainRange); !sameValue(gain, test.expected) {
+			t.Errorf("%v dB in %v: %v dB, expected %v dB", test.gain, test.gainRange, gain, test.expected)
+		}
+	}
+}
+
+func TestGainPlannerRange(t *testing.T) {
+
+	planner := &GainPlanner{elements: testGainElements}
+	if gainRange := planner.Range(); gainRange != (SDRRange{Minimum: -12, Maximum: 59, Step: 1}) {
+		t.Errorf("range %v, expected -12 dB to 59 dB by 1 dB", gainRange)
+	}
+
+	for policy, expected := range map[GainPolicy]string{
+		GainLowestNoise:   "lowest noise",
+		GainBestLinearity: "best linearity",
+		GainFromTable:     "table",
+		GainPolicy(5):     "GainPolicy(5)",
+	} {
+		if name := policy.String(); name != expected {
+			t.Errorf("policy %d named %v, expected %v", int(policy), name, expected)
+		}
+	}
+}
@@ -118,7 +118,9 @@ package device
 import "C"
 import (
 	"errors"
+	"fmt"
 	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrstats"
 	"unsafe"
 )
 
@@ -135,6 +137,7 @@ type {{ .StreamObjectName }} struct {
 	nbChannels     uint
 	readBuffer     **C.void
 	writeBuffer    **C.void
+	stats          *sdrstats.Stats
 }
 {{ end }}
 
@@ -232,6 +235,22 @@ func (stream *{{ .StreamObjectName }}) getNbChannels() uint {
 	return stream.nbChannels
 }
 
+// SetStats sets the statistics updated by the read, write and status operations of the stream. Passing nil disables
+// the statistics.
+func (stream *{{ .StreamObjectName }}) SetStats(stats *sdrstats.Stats) {
+	stream.stats = stats
+}
+
+// GetStats returns the statistics updated by the stream, or nil if none are set
+func (stream *{{ .StreamObjectName }}) GetStats() *sdrstats.Stats {
+	return stream.stats
+}
+
+// Format returns the format of the samples of the stream
+func (stream *{{ .StreamObjectName }}) Format() string {
+	return "{{ .SoapyFormat }}"
+}
+
 /* ********************************************************************************** */
 /*                                STREAMS FUNCTIONS                                   */            
 /* ********************************************************************************** */
@@ -366,7 +385,15 @@ func (stream *{{ .StreamObjectName }}) Read(buffers [][]{{ .GoType }}, nbElems u
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return uint(cTimeNs), 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveRead(nbElems, 0, sdrErr)
+		}
+		return uint(cTimeNs), 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveRead(nbElems, uint(result), nil)
 	}
 
 	return uint(cTimeNs), uint(result), nil
@@ -405,7 +432,7 @@ func (stream *{{ .StreamObjectName }}) Write(buffers [][]{{ .GoType }}, nbElems
 	for channelIdx := uint(0); channelIdx < stream.nbChannels; channelIdx++ {
 
 		// Get the pointer to the buffer for the channel
-		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.readBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
+		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.writeBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
 		*ptrPtrBuffer = (*C.void)(unsafe.Pointer(&buffers[channelIdx][0]))
 	}
 
@@ -423,12 +450,44 @@ func (stream *{{ .StreamObjectName }}) Write(buffers [][]{{ .GoType }}, nbElems
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveWrite(nbElems, 0, sdrErr)
+		}
+		return 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveWrite(nbElems, uint(result), nil)
 	}
 
 	return uint(result), nil
 }
 
+// readAny reads elements from the stream into buffers given as an interface. It allows the format agnostic helpers,
+// such as the capture, to read from any stream. The buffers must be a slice of slice of {{ .GoType }}.
+func (stream *{{ .StreamObjectName }}) readAny(buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error) {
+
+	typedBuffers, ok := buffers.([][]{{ .GoType }})
+	if !ok {
+		return 0, 0, fmt.Errorf("the read buffers must be of type [][]{{ .GoType }} for a {{ .SoapyFormat }} stream, got %T", buffers)
+	}
+
+	return stream.Read(typedBuffers, nbElems, outputFlags, timeoutUs)
+}
+
+// writeAny writes elements to the stream from buffers given as an interface. It allows the format agnostic helpers,
+// such as the burst scheduler, to write to any stream. The buffers must be a slice of slice of {{ .GoType }}.
+func (stream *{{ .StreamObjectName }}) writeAny(buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error) {
+
+	typedBuffers, ok := buffers.([][]{{ .GoType }})
+	if !ok {
+		return 0, fmt.Errorf("the write buffers must be of type [][]{{ .GoType }} for a {{ .SoapyFormat }} stream, got %T", buffers)
+	}
+
+	return stream.Write(typedBuffers, nbElems, flags, timeNs, timeoutUs)
+}
+
 // ReadStreamStatus reads status information about a stream.
 //
 // This call is typically used on a transmit stream to report time errors, underflows, and burst completion.
@@ -447,7 +506,12 @@ func (stream *{{ .StreamObjectName }}) Write(buffers [][]{{ .GoType }}, nbElems
 // Return the buffer's timestamp in nanoseconds in case of success, an error otherwise
 func (stream *{{ .StreamObjectName }}) ReadStreamStatus(chanMask []uint, flags []int, timeoutUs uint) (timeNs uint, err error) {
 
-	return readStreamStatus(stream, chanMask, flags, timeoutUs)
+	timeNs, err = readStreamStatus(stream, chanMask, flags, timeoutUs)
+	if stream.stats != nil {
+		stream.stats.ObserveStatus(err)
+	}
+
+	return timeNs, err
 }
 {{ end }}
 
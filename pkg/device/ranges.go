@@ -0,0 +1,360 @@
+package device
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+)
+
+// rangeTolerance is the relative tolerance of the comparisons of values with the bounds and the steps of ranges
+const rangeTolerance = 1e-9
+
+// SDRRanges is a list of ranges, as returned for the frequencies, the sample rates or the bandwidths of a channel
+type SDRRanges []SDRRange
+
+// Contains returns true if a value is a valid value of the range: within its bounds and, when the range has a step,
+// on the grid of its steps from the minimum
+//
+// Params:
+//  - value: the value
+//
+// Return true if the value is valid
+func (r SDRRange) Contains(value float64) bool {
+
+	tolerance := rangeTolerance * math.Max(math.Abs(r.Minimum), math.Abs(r.Maximum))
+
+	return value >= r.Minimum-tolerance && value <= r.Maximum+tolerance && aligned(r, value)
+}
+
+// Clamp returns a value limited to the valid bounds of the range. When the range has a step, the upper bound is the
+// last value of the grid of the steps, which is below the maximum when the span of the range is not a multiple of the
+// step. The values within the bounds are returned unchanged, use Nearest to round them to the grid.
+//
+// Params:
+//  - value: the value
+//
+// Return the value clamped to the valid bounds of the range
+func (r SDRRange) Clamp(value float64) float64 {
+	return math.Max(r.Minimum, math.Min(r.last(), value))
+}
+
+// Nearest returns the valid value of the range nearest to a value, respecting the step of the range
+//
+// Params:
+//  - value: the value
+//
+// Return the nearest valid value
+func (r SDRRange) Nearest(value float64) float64 {
+
+	value = r.Clamp(value)
+	if r.Step > 0 {
+		return math.Min(r.last(), r.Minimum+math.Round((value-r.Minimum)/r.Step)*r.Step)
+	}
+
+	return value
+}
+
+// Values enumerates the valid values of the range, from the minimum to the maximum by the step. As a range of
+// frequencies with a step of 1 Hz has millions of values, the number of values is bounded.
+//
+// Params:
+//  - maxValues: the maximum number of values enumerated
+//
+// Return the values, and false if the range is continuous or has more than maxValues values
+func (r SDRRange) Values(maxValues int) ([]float64, bool) {
+
+	if r.Step <= 0 {
+		if r.Maximum == r.Minimum && maxValues >= 1 {
+			return []float64{r.Minimum}, true
+		}
+		return nil, false
+	}
+
+	// The count is checked as a float so that huge ranges do not overflow
+	count := math.Floor((r.Maximum-r.Minimum)/r.Step+rangeTolerance) + 1
+	if !(count >= 1 && count <= float64(maxValues)) {
+		return nil, false
+	}
+
+	values := make([]float64, int(count))
+	for i := range values {
+		values[i] = r.Minimum + float64(i)*r.Step
+	}
+
+	return values, true
+}
+
+// Contains returns true if a value is a valid value of one of the ranges, see SDRRange.Contains
+//
+// Params:
+//  - value: the value
+//
+// Return true if the value is valid in a range
+func (ranges SDRRanges) Contains(value float64) bool {
+
+	for _, r := range ranges {
+		if r.Contains(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Clamp returns a value limited to the valid bounds of the nearest range, see SDRRange.Clamp
+//
+// Params:
+//  - value: the value
+//
+// Return the value clamped to the bounds of the nearest range, or the value if there is no range
+func (ranges SDRRanges) Clamp(value float64) float64 {
+	return ranges.nearest(value, SDRRange.Clamp)
+}
+
+// Nearest returns the valid value of the ranges nearest to a value, respecting the steps of the ranges
+//
+// Params:
+//  - value: the value
+//
+// Return the nearest valid value, or the value if there is no range
+func (ranges SDRRanges) Nearest(value float64) float64 {
+	return ranges.nearest(value, SDRRange.Nearest)
+}
+
+// Minimum returns the smallest minimum of the ranges, 0 if there is no range
+func (ranges SDRRanges) Minimum() float64 {
+
+	if len(ranges) == 0 {
+		return 0
+	}
+
+	minimum := ranges[0].Minimum
+	for _, r := range ranges[1:] {
+		minimum = math.Min(minimum, r.Minimum)
+	}
+
+	return minimum
+}
+
+// Maximum returns the largest maximum of the ranges, 0 if there is no range
+func (ranges SDRRanges) Maximum() float64 {
+
+	if len(ranges) == 0 {
+		return 0
+	}
+
+	maximum := ranges[0].Maximum
+	for _, r := range ranges[1:] {
+		maximum = math.Max(maximum, r.Maximum)
+	}
+
+	return maximum
+}
+
+// Merge sorts the ranges and merges the overlapping ranges with the same step and aligned values, and the ranges
+// contained in a continuous range.
+//
+// Return the merged ranges
+func (ranges SDRRanges) Merge() SDRRanges {
+
+	sorted := append(SDRRanges(nil), ranges...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Minimum != sorted[j].Minimum {
+			return sorted[i].Minimum < sorted[j].Minimum
+		}
+		return sorted[i].Maximum > sorted[j].Maximum
+	})
+
+	var merged SDRRanges
+	for _, r := range sorted {
+
+		if len(merged) == 0 {
+			merged = append(merged, r)
+			continue
+		}
+
+		last := &merged[len(merged)-1]
+		switch {
+		case last.Step <= 0 && last.Contains(r.Minimum) && last.Contains(r.Maximum):
+			// contained in a continuous range
+		case r.Step == last.Step && last.Contains(r.Minimum):
+			last.Maximum = math.Max(last.Maximum, r.Maximum)
+		default:
+			merged = append(merged, r)
+		}
+	}
+
+	return merged
+}
+
+// Intersect returns the ranges of the values valid in both lists of ranges. When both ranges of an intersection have
+// a step, the step of the range of the receiver is kept.
+//
+// Params:
+//  - other: the other ranges
+//
+// Return the merged intersections
+func (ranges SDRRanges) Intersect(other SDRRanges) SDRRanges {
+
+	var intersection SDRRanges
+	for _, a := range ranges {
+		for _, b := range other {
+
+			r := SDRRange{Minimum: math.Max(a.Minimum, b.Minimum), Maximum: math.Min(a.Maximum, b.Maximum)}
+
+			// align the bounds on the grid of the stepped range
+			grid := a
+			if grid.Step <= 0 {
+				grid = b
+			}
+			if grid.Step > 0 {
+				r.Step = grid.Step
+				r.Minimum = grid.Minimum + math.Ceil((r.Minimum-grid.Minimum)/grid.Step-rangeTolerance)*grid.Step
+				r.Maximum = grid.Minimum + math.Floor((r.Maximum-grid.Minimum)/grid.Step+rangeTolerance)*grid.Step
+			}
+
+			if r.Minimum <= r.Maximum {
+				intersection = append(intersection, r)
+			}
+		}
+	}
+
+	return intersection.Merge()
+}
+
+// Values enumerates the valid values of the ranges, sorted and without duplicates, see SDRRange.Values
+//
+// Params:
+//  - maxValues: the maximum number of values enumerated, before removing the duplicates
+//
+// Return the values, and false if a range is continuous or the ranges have more than maxValues values
+func (ranges SDRRanges) Values(maxValues int) (values []float64, ok bool) {
+
+	for _, r := range ranges {
+		rangeValues, valid := r.Values(maxValues - len(values))
+		if !valid {
+			return nil, false
+		}
+		values = append(values, rangeValues...)
+	}
+
+	sort.Float64s(values)
+
+	unique := values[:0]
+	for _, value := range values {
+		if len(unique) == 0 || !(SDRRange{Minimum: unique[len(unique)-1], Maximum: unique[len(unique)-1]}).Contains(value) {
+			unique = append(unique, value)
+		}
+	}
+
+	return unique, true
+}
+
+// ToString returns a human string with the details of the ranges
+func (ranges SDRRanges) ToString() string {
+
+	descriptions := make([]string, len(ranges))
+	for i, r := range ranges {
+		descriptions[i] = r.ToString()
+	}
+
+	return "[" + strings.Join(descriptions, ", ") + "]"
+}
+
+// SetStrict sets the strict mode of the device. In strict mode, the setters of the frequencies, the sample rates,
+// the bandwidths, the gains and the master clock rate check the values against the ranges of the device, and reject
+// the values out of the ranges with a sdrerror.OutOfRange error before calling into SoapySDR. The strict mode is
+// disabled by default.
+//
+// Params:
+//  - strict: true to enable the strict mode
+func (dev *SDRDevice) SetStrict(strict bool) {
+	dev.strict = strict
+}
+
+// IsStrict returns true if the device is in strict mode, see SetStrict
+func (dev *SDRDevice) IsStrict() bool {
+	return dev.strict
+}
+
+// nearest returns the value of the ranges nearest to a value
+//
+// Params:
+//  - value: the value
+//  - nearest: the function returning the value of a range nearest to the value
+//
+// Return the nearest value, or the value if there is no range
+func (ranges SDRRanges) nearest(value float64, nearest func(SDRRange, float64) float64) float64 {
+
+	best := value
+	for i, r := range ranges {
+		candidate := nearest(r, value)
+		if i == 0 || math.Abs(candidate-value) < math.Abs(best-value) {
+			best = candidate
+		}
+	}
+
+	return best
+}
+
+// last returns the largest valid value of a range: the maximum, or the last value of the grid of the steps
+func (r SDRRange) last() float64 {
+
+	if r.Step <= 0 {
+		return r.Maximum
+	}
+
+	return r.Minimum + math.Floor((r.Maximum-r.Minimum)/r.Step+rangeTolerance)*r.Step
+}
+
+// aligned returns true if a value is on the grid of the steps of a range
+func aligned(r SDRRange, value float64) bool {
+
+	if r.Step <= 0 {
+		return true
+	}
+
+	steps := (value - r.Minimum) / r.Step
+
+	return math.Abs(steps-math.Round(steps)) <= rangeTolerance*math.Max(1, math.Abs(steps))
+}
+
+// checkRange returns an error if a value is not a valid value of the ranges of a setting, outside of their bounds or
+// off the grid of their steps
+//
+// Params:
+//  - setting: the name of the setting
+//  - value: the value
+//  - ranges: the ranges of the setting. Any value is accepted when the list is empty.
+//
+// Return an error or nil if the value is valid
+func checkRange(setting string, value float64, ranges SDRRanges) sdrerror.SDRError {
+
+	if len(ranges) == 0 || ranges.Contains(value) {
+		return nil
+	}
+
+	return &sdrerror.OutOfRange{Setting: setting, Value: value, Ranges: ranges.ToString()}
+}
@@ -0,0 +1,246 @@
+package device
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"testing"
+)
+
+// sameValue returns true if two values of a range are equal, up to the rounding of the steps
+func sameValue(a float64, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// sameRanges returns true if two lists of ranges are equal, up to the rounding of the steps
+func sameRanges(a SDRRanges, b SDRRanges) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !sameValue(a[i].Minimum, b[i].Minimum) || !sameValue(a[i].Maximum, b[i].Maximum) || a[i].Step != b[i].Step {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameValues returns true if two lists of values are equal, up to the rounding of the steps
+func sameValues(a []float64, b []float64) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !sameValue(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestRangeContains(t *testing.T) {
+
+	tests := []struct {
+		r        SDRRange
+		value    float64
+		expected bool
+	}{
+		{SDRRange{0, 10, 0}, 5, true},
+		{SDRRange{0, 10, 0}, 10, true},
+		{SDRRange{0, 10, 0}, 10 + 1e-10, true},
+		{SDRRange{0, 10, 0}, -1, false},
+		{SDRRange{0, 10, 0}, 11, false},
+		{SDRRange{0, 10, 1}, 3, true},
+		{SDRRange{0, 10, 1}, 1.5, false},
+		{SDRRange{0, 10, 4}, 8, true},
+		{SDRRange{0, 10, 4}, 6, false},
+		{SDRRange{0, 10, 4}, 10, false},
+		{SDRRange{-20, 20, 0.5}, -19.5, true},
+		{SDRRange{-20, 20, 0.5}, 0.25, false},
+		{SDRRange{88e6, 108e6, 100e3}, 100.1e6, true},
+		{SDRRange{88e6, 108e6, 100e3}, 100.05e6, false},
+	}
+
+	for _, test := range tests {
+		if actual := test.r.Contains(test.value); actual != test.expected {
+			t.Errorf("%s.Contains(%v) = %v, expected %v", test.r.ToString(), test.value, actual, test.expected)
+		}
+	}
+}
+
+func TestRangeClamp(t *testing.T) {
+
+	tests := []struct {
+		r        SDRRange
+		value    float64
+		expected float64
+	}{
+		{SDRRange{0, 10, 0}, -1, 0},
+		{SDRRange{0, 10, 0}, 5.5, 5.5},
+		{SDRRange{0, 10, 0}, 11, 10},
+		{SDRRange{0, 10, 1}, 1.5, 1.5},
+		{SDRRange{0, 10, 4}, -3, 0},
+		{SDRRange{0, 10, 4}, 5, 5},
+		{SDRRange{0, 10, 4}, 9, 8},
+		{SDRRange{0, 10, 4}, 11, 8},
+	}
+
+	for _, test := range tests {
+		if actual := test.r.Clamp(test.value); !sameValue(actual, test.expected) {
+			t.Errorf("%s.Clamp(%v) = %v, expected %v", test.r.ToString(), test.value, actual, test.expected)
+		}
+	}
+}
+
+func TestRangeNearest(t *testing.T) {
+
+	tests := []struct {
+		r        SDRRange
+		value    float64
+		expected float64
+	}{
+		{SDRRange{0, 10, 0}, 3.3, 3.3},
+		{SDRRange{0, 10, 1}, 1.4, 1},
+		{SDRRange{0, 10, 1}, 1.5, 2},
+		{SDRRange{0, 10, 1}, 12, 10},
+		{SDRRange{0, 10, 4}, -1, 0},
+		{SDRRange{0, 10, 4}, 5, 4},
+		{SDRRange{0, 10, 4}, 6, 8},
+		{SDRRange{0, 10, 4}, 7, 8},
+		{SDRRange{0, 10, 4}, 9, 8},
+		{SDRRange{0, 10, 4}, 10, 8},
+		{SDRRange{-20, 20, 0.5}, 0.3, 0.5},
+	}
+
+	for _, test := range tests {
+		if actual := test.r.Nearest(test.value); !sameValue(actual, test.expected) {
+			t.Errorf("%s.Nearest(%v) = %v, expected %v", test.r.ToString(), test.value, actual, test.expected)
+		}
+	}
+}
+
+func TestRangesNearestValue(t *testing.T) {
+
+	ranges := SDRRanges{{0, 10, 1}, {20, 30, 2}}
+
+	tests := []struct {
+		value    float64
+		contains bool
+		clamped  float64
+		nearest  float64
+	}{
+		{5, true, 5, 5},
+		{5.5, false, 5.5, 6},
+		{15, false, 10, 10},
+		{16, false, 20, 20},
+		{17, false, 20, 20},
+		{21, false, 21, 22},
+		{22, true, 22, 22},
+		{23, false, 23, 24},
+		{31, false, 30, 30},
+	}
+
+	for _, test := range tests {
+		if actual := ranges.Contains(test.value); actual != test.contains {
+			t.Errorf("%s.Contains(%v) = %v, expected %v", ranges.ToString(), test.value, actual, test.contains)
+		}
+		if actual := ranges.Clamp(test.value); !sameValue(actual, test.clamped) {
+			t.Errorf("%s.Clamp(%v) = %v, expected %v", ranges.ToString(), test.value, actual, test.clamped)
+		}
+		if actual := ranges.Nearest(test.value); !sameValue(actual, test.nearest) {
+			t.Errorf("%s.Nearest(%v) = %v, expected %v", ranges.ToString(), test.value, actual, test.nearest)
+		}
+	}
+}
+
+func TestRangesMerge(t *testing.T) {
+
+	tests := []struct {
+		ranges   SDRRanges
+		expected SDRRanges
+	}{
+		{SDRRanges{{10, 20, 0}, {0, 5, 0}, {12, 15, 0}}, SDRRanges{{0, 5, 0}, {10, 20, 0}}},
+		{SDRRanges{{0, 10, 0}, {5, 20, 0}}, SDRRanges{{0, 20, 0}}},
+		{SDRRanges{{0, 10, 0}, {10, 20, 0}}, SDRRanges{{0, 20, 0}}},
+		{SDRRanges{{0, 10, 2}, {6, 20, 2}}, SDRRanges{{0, 20, 2}}},
+		{SDRRanges{{0, 10, 2}, {5, 20, 2}}, SDRRanges{{0, 10, 2}, {5, 20, 2}}},
+		{SDRRanges{{5, 20, 2}, {0, 10, 1}}, SDRRanges{{0, 10, 1}, {5, 20, 2}}},
+		{SDRRanges{{0, 10, 0}, {20, 30, 0}}, SDRRanges{{0, 10, 0}, {20, 30, 0}}},
+	}
+
+	for _, test := range tests {
+		if actual := test.ranges.Merge(); !sameRanges(actual, test.expected) {
+			t.Errorf("%s.Merge() = %s, expected %s", test.ranges.ToString(), actual.ToString(), test.expected.ToString())
+		}
+	}
+}
+
+func TestRangesIntersect(t *testing.T) {
+
+	tests := []struct {
+		a        SDRRanges
+		b        SDRRanges
+		expected SDRRanges
+	}{
+		{SDRRanges{{0, 100, 0}}, SDRRanges{{50, 150, 0}}, SDRRanges{{50, 100, 0}}},
+		{SDRRanges{{0, 10, 4}}, SDRRanges{{1, 9, 0}}, SDRRanges{{4, 8, 4}}},
+		{SDRRanges{{1, 9, 0}}, SDRRanges{{0, 10, 4}}, SDRRanges{{4, 8, 4}}},
+		{SDRRanges{{0, 10, 0}}, SDRRanges{{20, 30, 0}}, nil},
+		{SDRRanges{{0, 10, 0}, {20, 30, 0}}, SDRRanges{{5, 25, 0}}, SDRRanges{{5, 10, 0}, {20, 25, 0}}},
+	}
+
+	for _, test := range tests {
+		if actual := test.a.Intersect(test.b); !sameRanges(actual, test.expected) {
+			t.Errorf("%s.Intersect(%s) = %s, expected %s", test.a.ToString(), test.b.ToString(), actual.ToString(), test.expected.ToString())
+		}
+	}
+}
+
+func TestRangesValues(t *testing.T) {
+
+	tests := []struct {
+		ranges    SDRRanges
+		maxValues int
+		expected  []float64
+		ok        bool
+	}{
+		{SDRRanges{{0, 10, 4}}, 10, []float64{0, 4, 8}, true},
+		{SDRRanges{{0, 10, 4}}, 2, nil, false},
+		{SDRRanges{{0, 10, 0}}, 10, nil, false},
+		{SDRRanges{{5, 5, 0}}, 1, []float64{5}, true},
+		{SDRRanges{{0, 1e6, 1}}, 1000, nil, false},
+		{SDRRanges{{2, 6, 2}, {0, 4, 2}}, 10, []float64{0, 2, 4, 6}, true},
+		{SDRRanges{{0, 4, 2}, {2, 6, 2}}, 5, nil, false},
+	}
+
+	for _, test := range tests {
+		actual, ok := test.ranges.Values(test.maxValues)
+		if ok != test.ok || !sameValues(actual, test.expected) {
+			t.Errorf("%s.Values(%d) = %v, %v, expected %v, %v", test.ranges.ToString(), test.maxValues, actual, ok, test.expected, test.ok)
+		}
+	}
+}
@@ -42,6 +42,12 @@ import (
 // Return an error or nil in case of success
 func (dev *SDRDevice) SetSampleRate(direction Direction, channel uint, rate float64) (err sdrerror.SDRError) {
 
+	if dev.strict {
+		if err := checkRange("sample rate", rate, dev.GetSampleRateRange(direction, channel)); err != nil {
+			return err
+		}
+	}
+
 	return sdrerror.Err(int(C.SoapySDRDevice_setSampleRate(dev.device, C.int(direction), C.size_t(channel), C.double(rate))))
 }
 
@@ -64,7 +70,7 @@ func (dev *SDRDevice) GetSampleRate(direction Direction, channel uint) float64 {
 //  - channel: an available channel on the device
 //
 // Return a list of sample rate ranges in samples per second
-func (dev *SDRDevice) GetSampleRateRange(direction Direction, channel uint) []SDRRange {
+func (dev *SDRDevice) GetSampleRateRange(direction Direction, channel uint) SDRRanges {
 
 	length := C.size_t(0)
 
@@ -32,11 +32,77 @@ package device
 import "C"
 import (
 	"errors"
+	"fmt"
 	"unsafe"
 
 	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
 )
 
+// SetupSDRStream initializes a stream given its format, a list of channels and stream arguments. It calls the
+// Setup function of the stream type matching the format, for example SetupSDRStreamCS16 for "CS16".
+//
+// Params:
+//  - direction: the channel direction ('DirectionRX' or 'DirectionTX')
+//  - format: the format of the stream, one of the formats of the sdrformat package
+//  - channels: a list of channels, which can not be empty
+//  - args: stream args or empty for defaults
+//
+// Return the stream and an error
+func (dev *SDRDevice) SetupSDRStream(direction Direction, format string, channels []uint, args map[string]string) (stream SDRStream, err error) {
+
+	switch format {
+	case sdrformat.CU8:
+		return dev.SetupSDRStreamCU8(direction, channels, args)
+	case sdrformat.CS8:
+		return dev.SetupSDRStreamCS8(direction, channels, args)
+	case sdrformat.CU16:
+		return dev.SetupSDRStreamCU16(direction, channels, args)
+	case sdrformat.CS16:
+		return dev.SetupSDRStreamCS16(direction, channels, args)
+	case sdrformat.CF32:
+		return dev.SetupSDRStreamCF32(direction, channels, args)
+	case sdrformat.CF64:
+		return dev.SetupSDRStreamCF64(direction, channels, args)
+	}
+
+	return nil, fmt.Errorf("%w: %v", sdrformat.ErrUnknownFormat, format)
+}
+
+// ReadBuffers reads elements from a stream into buffers given as an interface, whatever the format of the stream. It
+// is the format agnostic version of the Read function of the streams.
+//
+// Params:
+//  - stream: the stream to read from
+//  - buffers: the buffers of all the channels, of the Go type matching the format of the stream, for example
+//    [][]complex64 for a CF32 stream. See sdrformat.MakeBuffers.
+//  - nbElems: the number of samples to read
+//  - outputFlags: the flag indicators of the result by channel
+//  - timeoutUs: the timeout in microseconds
+//
+// Return the buffer's timestamp in nanoseconds, the number of elements read per buffer and an error
+func ReadBuffers(stream SDRStream, buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error) {
+
+	return stream.readAny(buffers, nbElems, outputFlags, timeoutUs)
+}
+
+// WriteBuffers writes elements to a stream from buffers given as an interface, whatever the format of the stream. It
+// is the format agnostic version of the Write function of the streams.
+//
+// Params:
+//  - stream: the stream to write to
+//  - buffers: the buffers of all the channels, of the Go type matching the format of the stream
+//  - nbElems: the number of samples to write
+//  - flags: input flags by channel
+//  - timeNs: the buffer's timestamp in nanoseconds
+//  - timeoutUs: the timeout in microseconds
+//
+// Return the number of elements written per buffer and an error
+func WriteBuffers(stream SDRStream, buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error) {
+
+	return stream.writeAny(buffers, nbElems, flags, timeNs, timeoutUs)
+}
+
 // GetStreamFormats queries a list of the available stream formats.
 //
 // Format:
@@ -85,7 +151,7 @@ func (dev *SDRDevice) GetNativeStreamFormat(direction Direction, channel uint) (
 	val := (*C.char)(C.SoapySDRDevice_getNativeStreamFormat(dev.device, C.int(direction), C.size_t(channel), &scale))
 	defer C.free(unsafe.Pointer(val))
 
-	return C.GoString(val), float64(fullScale)
+	return C.GoString(val), float64(scale)
 }
 
 // GetStreamArgsInfo queries the argument info description for stream args.
@@ -1,5 +1,5 @@
 // Code generated by Bhojpur SDR (go generate); DO NOT EDIT.
-// This file was generated by gen_streams.go at 2022-05-15 07:23:15.12968 +0530 IST m=+0.003061442
+// This file was generated by gen_streams.go at 2026-10-19 16:12:06.886816495 +0000 UTC m=+0.000794393
 
 package device
 
@@ -35,7 +35,9 @@ package device
 import "C"
 import (
 	"errors"
+	"fmt"
 	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrstats"
 	"unsafe"
 )
 
@@ -52,6 +54,7 @@ type SDRStreamCU8 struct {
 	nbChannels     uint
 	readBuffer     **C.void
 	writeBuffer    **C.void
+	stats          *sdrstats.Stats
 }
 
 // SDRStreamCS8 is a stream for accessing data in CS8 format
@@ -61,6 +64,7 @@ type SDRStreamCS8 struct {
 	nbChannels     uint
 	readBuffer     **C.void
 	writeBuffer    **C.void
+	stats          *sdrstats.Stats
 }
 
 // SDRStreamCU16 is a stream for accessing data in CU16 format
@@ -70,6 +74,7 @@ type SDRStreamCU16 struct {
 	nbChannels     uint
 	readBuffer     **C.void
 	writeBuffer    **C.void
+	stats          *sdrstats.Stats
 }
 
 // SDRStreamCS16 is a stream for accessing data in CS16 format
@@ -79,6 +84,7 @@ type SDRStreamCS16 struct {
 	nbChannels     uint
 	readBuffer     **C.void
 	writeBuffer    **C.void
+	stats          *sdrstats.Stats
 }
 
 // SDRStreamCF32 is a stream for accessing data in CF32 format
@@ -88,6 +94,7 @@ type SDRStreamCF32 struct {
 	nbChannels     uint
 	readBuffer     **C.void
 	writeBuffer    **C.void
+	stats          *sdrstats.Stats
 }
 
 // SDRStreamCF64 is a stream for accessing data in CF64 format
@@ -97,6 +104,7 @@ type SDRStreamCF64 struct {
 	nbChannels     uint
 	readBuffer     **C.void
 	writeBuffer    **C.void
+	stats          *sdrstats.Stats
 }
 
 
@@ -194,6 +202,22 @@ func (stream *SDRStreamCU8) getNbChannels() uint {
 	return stream.nbChannels
 }
 
+// SetStats sets the statistics updated by the read, write and status operations of the stream. Passing nil disables
+// the statistics.
+func (stream *SDRStreamCU8) SetStats(stats *sdrstats.Stats) {
+	stream.stats = stats
+}
+
+// GetStats returns the statistics updated by the stream, or nil if none are set
+func (stream *SDRStreamCU8) GetStats() *sdrstats.Stats {
+	return stream.stats
+}
+
+// Format returns the format of the samples of the stream
+func (stream *SDRStreamCU8) Format() string {
+	return "CU8"
+}
+
 /* ********************************************************************************** */
 /*                                STREAMS FUNCTIONS                                   */            
 /* ********************************************************************************** */
@@ -328,7 +352,15 @@ func (stream *SDRStreamCU8) Read(buffers [][]uint8, nbElems uint, outputFlags []
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return uint(cTimeNs), 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveRead(nbElems, 0, sdrErr)
+		}
+		return uint(cTimeNs), 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveRead(nbElems, uint(result), nil)
 	}
 
 	return uint(cTimeNs), uint(result), nil
@@ -367,7 +399,7 @@ func (stream *SDRStreamCU8) Write(buffers [][]uint8, nbElems uint, flags []int,
 	for channelIdx := uint(0); channelIdx < stream.nbChannels; channelIdx++ {
 
 		// Get the pointer to the buffer for the channel
-		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.readBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
+		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.writeBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
 		*ptrPtrBuffer = (*C.void)(unsafe.Pointer(&buffers[channelIdx][0]))
 	}
 
@@ -385,12 +417,44 @@ func (stream *SDRStreamCU8) Write(buffers [][]uint8, nbElems uint, flags []int,
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveWrite(nbElems, 0, sdrErr)
+		}
+		return 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveWrite(nbElems, uint(result), nil)
 	}
 
 	return uint(result), nil
 }
 
+// readAny reads elements from the stream into buffers given as an interface. It allows the format agnostic helpers,
+// such as the capture, to read from any stream. The buffers must be a slice of slice of uint8.
+func (stream *SDRStreamCU8) readAny(buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error) {
+
+	typedBuffers, ok := buffers.([][]uint8)
+	if !ok {
+		return 0, 0, fmt.Errorf("the read buffers must be of type [][]uint8 for a CU8 stream, got %T", buffers)
+	}
+
+	return stream.Read(typedBuffers, nbElems, outputFlags, timeoutUs)
+}
+
+// writeAny writes elements to the stream from buffers given as an interface. It allows the format agnostic helpers,
+// such as the burst scheduler, to write to any stream. The buffers must be a slice of slice of uint8.
+func (stream *SDRStreamCU8) writeAny(buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error) {
+
+	typedBuffers, ok := buffers.([][]uint8)
+	if !ok {
+		return 0, fmt.Errorf("the write buffers must be of type [][]uint8 for a CU8 stream, got %T", buffers)
+	}
+
+	return stream.Write(typedBuffers, nbElems, flags, timeNs, timeoutUs)
+}
+
 // ReadStreamStatus reads status information about a stream.
 //
 // This call is typically used on a transmit stream to report time errors, underflows, and burst completion.
@@ -409,7 +473,12 @@ func (stream *SDRStreamCU8) Write(buffers [][]uint8, nbElems uint, flags []int,
 // Return the buffer's timestamp in nanoseconds in case of success, an error otherwise
 func (stream *SDRStreamCU8) ReadStreamStatus(chanMask []uint, flags []int, timeoutUs uint) (timeNs uint, err error) {
 
-	return readStreamStatus(stream, chanMask, flags, timeoutUs)
+	timeNs, err = readStreamStatus(stream, chanMask, flags, timeoutUs)
+	if stream.stats != nil {
+		stream.stats.ObserveStatus(err)
+	}
+
+	return timeNs, err
 }
 
 /* ********************************************************************************** */
@@ -505,6 +574,22 @@ func (stream *SDRStreamCS8) getNbChannels() uint {
 	return stream.nbChannels
 }
 
+// SetStats sets the statistics updated by the read, write and status operations of the stream. Passing nil disables
+// the statistics.
+func (stream *SDRStreamCS8) SetStats(stats *sdrstats.Stats) {
+	stream.stats = stats
+}
+
+// GetStats returns the statistics updated by the stream, or nil if none are set
+func (stream *SDRStreamCS8) GetStats() *sdrstats.Stats {
+	return stream.stats
+}
+
+// Format returns the format of the samples of the stream
+func (stream *SDRStreamCS8) Format() string {
+	return "CS8"
+}
+
 /* ********************************************************************************** */
 /*                                STREAMS FUNCTIONS                                   */            
 /* ********************************************************************************** */
@@ -639,7 +724,15 @@ func (stream *SDRStreamCS8) Read(buffers [][]int8, nbElems uint, outputFlags []i
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return uint(cTimeNs), 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveRead(nbElems, 0, sdrErr)
+		}
+		return uint(cTimeNs), 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveRead(nbElems, uint(result), nil)
 	}
 
 	return uint(cTimeNs), uint(result), nil
@@ -678,7 +771,7 @@ func (stream *SDRStreamCS8) Write(buffers [][]int8, nbElems uint, flags []int, t
 	for channelIdx := uint(0); channelIdx < stream.nbChannels; channelIdx++ {
 
 		// Get the pointer to the buffer for the channel
-		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.readBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
+		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.writeBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
 		*ptrPtrBuffer = (*C.void)(unsafe.Pointer(&buffers[channelIdx][0]))
 	}
 
@@ -696,12 +789,44 @@ func (stream *SDRStreamCS8) Write(buffers [][]int8, nbElems uint, flags []int, t
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveWrite(nbElems, 0, sdrErr)
+		}
+		return 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveWrite(nbElems, uint(result), nil)
 	}
 
 	return uint(result), nil
 }
 
+// readAny reads elements from the stream into buffers given as an interface. It allows the format agnostic helpers,
+// such as the capture, to read from any stream. The buffers must be a slice of slice of int8.
+func (stream *SDRStreamCS8) readAny(buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error) {
+
+	typedBuffers, ok := buffers.([][]int8)
+	if !ok {
+		return 0, 0, fmt.Errorf("the read buffers must be of type [][]int8 for a CS8 stream, got %T", buffers)
+	}
+
+	return stream.Read(typedBuffers, nbElems, outputFlags, timeoutUs)
+}
+
+// writeAny writes elements to the stream from buffers given as an interface. It allows the format agnostic helpers,
+// such as the burst scheduler, to write to any stream. The buffers must be a slice of slice of int8.
+func (stream *SDRStreamCS8) writeAny(buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error) {
+
+	typedBuffers, ok := buffers.([][]int8)
+	if !ok {
+		return 0, fmt.Errorf("the write buffers must be of type [][]int8 for a CS8 stream, got %T", buffers)
+	}
+
+	return stream.Write(typedBuffers, nbElems, flags, timeNs, timeoutUs)
+}
+
 // ReadStreamStatus reads status information about a stream.
 //
 // This call is typically used on a transmit stream to report time errors, underflows, and burst completion.
@@ -720,7 +845,12 @@ func (stream *SDRStreamCS8) Write(buffers [][]int8, nbElems uint, flags []int, t
 // Return the buffer's timestamp in nanoseconds in case of success, an error otherwise
 func (stream *SDRStreamCS8) ReadStreamStatus(chanMask []uint, flags []int, timeoutUs uint) (timeNs uint, err error) {
 
-	return readStreamStatus(stream, chanMask, flags, timeoutUs)
+	timeNs, err = readStreamStatus(stream, chanMask, flags, timeoutUs)
+	if stream.stats != nil {
+		stream.stats.ObserveStatus(err)
+	}
+
+	return timeNs, err
 }
 
 /* ********************************************************************************** */
@@ -816,6 +946,22 @@ func (stream *SDRStreamCU16) getNbChannels() uint {
 	return stream.nbChannels
 }
 
+// SetStats sets the statistics updated by the read, write and status operations of the stream. Passing nil disables
+// the statistics.
+func (stream *SDRStreamCU16) SetStats(stats *sdrstats.Stats) {
+	stream.stats = stats
+}
+
+// GetStats returns the statistics updated by the stream, or nil if none are set
+func (stream *SDRStreamCU16) GetStats() *sdrstats.Stats {
+	return stream.stats
+}
+
+// Format returns the format of the samples of the stream
+func (stream *SDRStreamCU16) Format() string {
+	return "CU16"
+}
+
 /* ********************************************************************************** */
 /*                                STREAMS FUNCTIONS                                   */            
 /* ********************************************************************************** */
@@ -950,7 +1096,15 @@ func (stream *SDRStreamCU16) Read(buffers [][]uint16, nbElems uint, outputFlags
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return uint(cTimeNs), 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveRead(nbElems, 0, sdrErr)
+		}
+		return uint(cTimeNs), 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveRead(nbElems, uint(result), nil)
 	}
 
 	return uint(cTimeNs), uint(result), nil
@@ -989,7 +1143,7 @@ func (stream *SDRStreamCU16) Write(buffers [][]uint16, nbElems uint, flags []int
 	for channelIdx := uint(0); channelIdx < stream.nbChannels; channelIdx++ {
 
 		// Get the pointer to the buffer for the channel
-		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.readBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
+		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.writeBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
 		*ptrPtrBuffer = (*C.void)(unsafe.Pointer(&buffers[channelIdx][0]))
 	}
 
@@ -1007,12 +1161,44 @@ func (stream *SDRStreamCU16) Write(buffers [][]uint16, nbElems uint, flags []int
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveWrite(nbElems, 0, sdrErr)
+		}
+		return 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveWrite(nbElems, uint(result), nil)
 	}
 
 	return uint(result), nil
 }
 
+// readAny reads elements from the stream into buffers given as an interface. It allows the format agnostic helpers,
+// such as the capture, to read from any stream. The buffers must be a slice of slice of uint16.
+func (stream *SDRStreamCU16) readAny(buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error) {
+
+	typedBuffers, ok := buffers.([][]uint16)
+	if !ok {
+		return 0, 0, fmt.Errorf("the read buffers must be of type [][]uint16 for a CU16 stream, got %T", buffers)
+	}
+
+	return stream.Read(typedBuffers, nbElems, outputFlags, timeoutUs)
+}
+
+// writeAny writes elements to the stream from buffers given as an interface. It allows the format agnostic helpers,
+// such as the burst scheduler, to write to any stream. The buffers must be a slice of slice of uint16.
+func (stream *SDRStreamCU16) writeAny(buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error) {
+
+	typedBuffers, ok := buffers.([][]uint16)
+	if !ok {
+		return 0, fmt.Errorf("the write buffers must be of type [][]uint16 for a CU16 stream, got %T", buffers)
+	}
+
+	return stream.Write(typedBuffers, nbElems, flags, timeNs, timeoutUs)
+}
+
 // ReadStreamStatus reads status information about a stream.
 //
 // This call is typically used on a transmit stream to report time errors, underflows, and burst completion.
@@ -1031,7 +1217,12 @@ func (stream *SDRStreamCU16) Write(buffers [][]uint16, nbElems uint, flags []int
 // Return the buffer's timestamp in nanoseconds in case of success, an error otherwise
 func (stream *SDRStreamCU16) ReadStreamStatus(chanMask []uint, flags []int, timeoutUs uint) (timeNs uint, err error) {
 
-	return readStreamStatus(stream, chanMask, flags, timeoutUs)
+	timeNs, err = readStreamStatus(stream, chanMask, flags, timeoutUs)
+	if stream.stats != nil {
+		stream.stats.ObserveStatus(err)
+	}
+
+	return timeNs, err
 }
 
 /* ********************************************************************************** */
@@ -1127,6 +1318,22 @@ func (stream *SDRStreamCS16) getNbChannels() uint {
 	return stream.nbChannels
 }
 
+// SetStats sets the statistics updated by the read, write and status operations of the stream. Passing nil disables
+// the statistics.
+func (stream *SDRStreamCS16) SetStats(stats *sdrstats.Stats) {
+	stream.stats = stats
+}
+
+// GetStats returns the statistics updated by the stream, or nil if none are set
+func (stream *SDRStreamCS16) GetStats() *sdrstats.Stats {
+	return stream.stats
+}
+
+// Format returns the format of the samples of the stream
+func (stream *SDRStreamCS16) Format() string {
+	return "CS16"
+}
+
 /* ********************************************************************************** */
 /*                                STREAMS FUNCTIONS                                   */            
 /* ********************************************************************************** */
@@ -1261,7 +1468,15 @@ func (stream *SDRStreamCS16) Read(buffers [][]int16, nbElems uint, outputFlags [
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return uint(cTimeNs), 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveRead(nbElems, 0, sdrErr)
+		}
+		return uint(cTimeNs), 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveRead(nbElems, uint(result), nil)
 	}
 
 	return uint(cTimeNs), uint(result), nil
@@ -1300,7 +1515,7 @@ func (stream *SDRStreamCS16) Write(buffers [][]int16, nbElems uint, flags []int,
 	for channelIdx := uint(0); channelIdx < stream.nbChannels; channelIdx++ {
 
 		// Get the pointer to the buffer for the channel
-		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.readBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
+		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.writeBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
 		*ptrPtrBuffer = (*C.void)(unsafe.Pointer(&buffers[channelIdx][0]))
 	}
 
@@ -1318,12 +1533,44 @@ func (stream *SDRStreamCS16) Write(buffers [][]int16, nbElems uint, flags []int,
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveWrite(nbElems, 0, sdrErr)
+		}
+		return 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveWrite(nbElems, uint(result), nil)
 	}
 
 	return uint(result), nil
 }
 
+// readAny reads elements from the stream into buffers given as an interface. It allows the format agnostic helpers,
+// such as the capture, to read from any stream. The buffers must be a slice of slice of int16.
+func (stream *SDRStreamCS16) readAny(buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error) {
+
+	typedBuffers, ok := buffers.([][]int16)
+	if !ok {
+		return 0, 0, fmt.Errorf("the read buffers must be of type [][]int16 for a CS16 stream, got %T", buffers)
+	}
+
+	return stream.Read(typedBuffers, nbElems, outputFlags, timeoutUs)
+}
+
+// writeAny writes elements to the stream from buffers given as an interface. It allows the format agnostic helpers,
+// such as the burst scheduler, to write to any stream. The buffers must be a slice of slice of int16.
+func (stream *SDRStreamCS16) writeAny(buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error) {
+
+	typedBuffers, ok := buffers.([][]int16)
+	if !ok {
+		return 0, fmt.Errorf("the write buffers must be of type [][]int16 for a CS16 stream, got %T", buffers)
+	}
+
+	return stream.Write(typedBuffers, nbElems, flags, timeNs, timeoutUs)
+}
+
 // ReadStreamStatus reads status information about a stream.
 //
 // This call is typically used on a transmit stream to report time errors, underflows, and burst completion.
@@ -1342,7 +1589,12 @@ func (stream *SDRStreamCS16) Write(buffers [][]int16, nbElems uint, flags []int,
 // Return the buffer's timestamp in nanoseconds in case of success, an error otherwise
 func (stream *SDRStreamCS16) ReadStreamStatus(chanMask []uint, flags []int, timeoutUs uint) (timeNs uint, err error) {
 
-	return readStreamStatus(stream, chanMask, flags, timeoutUs)
+	timeNs, err = readStreamStatus(stream, chanMask, flags, timeoutUs)
+	if stream.stats != nil {
+		stream.stats.ObserveStatus(err)
+	}
+
+	return timeNs, err
 }
 
 /* ********************************************************************************** */
@@ -1438,6 +1690,22 @@ func (stream *SDRStreamCF32) getNbChannels() uint {
 	return stream.nbChannels
 }
 
+// SetStats sets the statistics updated by the read, write and status operations of the stream. Passing nil disables
+// the statistics.
+func (stream *SDRStreamCF32) SetStats(stats *sdrstats.Stats) {
+	stream.stats = stats
+}
+
+// GetStats returns the statistics updated by the stream, or nil if none are set
+func (stream *SDRStreamCF32) GetStats() *sdrstats.Stats {
+	return stream.stats
+}
+
+// Format returns the format of the samples of the stream
+func (stream *SDRStreamCF32) Format() string {
+	return "CF32"
+}
+
 /* ********************************************************************************** */
 /*                                STREAMS FUNCTIONS                                   */            
 /* ********************************************************************************** */
@@ -1572,7 +1840,15 @@ func (stream *SDRStreamCF32) Read(buffers [][]complex64, nbElems uint, outputFla
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return uint(cTimeNs), 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveRead(nbElems, 0, sdrErr)
+		}
+		return uint(cTimeNs), 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveRead(nbElems, uint(result), nil)
 	}
 
 	return uint(cTimeNs), uint(result), nil
@@ -1611,7 +1887,7 @@ func (stream *SDRStreamCF32) Write(buffers [][]complex64, nbElems uint, flags []
 	for channelIdx := uint(0); channelIdx < stream.nbChannels; channelIdx++ {
 
 		// Get the pointer to the buffer for the channel
-		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.readBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
+		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.writeBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
 		*ptrPtrBuffer = (*C.void)(unsafe.Pointer(&buffers[channelIdx][0]))
 	}
 
@@ -1629,12 +1905,44 @@ func (stream *SDRStreamCF32) Write(buffers [][]complex64, nbElems uint, flags []
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveWrite(nbElems, 0, sdrErr)
+		}
+		return 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveWrite(nbElems, uint(result), nil)
 	}
 
 	return uint(result), nil
 }
 
+// readAny reads elements from the stream into buffers given as an interface. It allows the format agnostic helpers,
+// such as the capture, to read from any stream. The buffers must be a slice of slice of complex64.
+func (stream *SDRStreamCF32) readAny(buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error) {
+
+	typedBuffers, ok := buffers.([][]complex64)
+	if !ok {
+		return 0, 0, fmt.Errorf("the read buffers must be of type [][]complex64 for a CF32 stream, got %T", buffers)
+	}
+
+	return stream.Read(typedBuffers, nbElems, outputFlags, timeoutUs)
+}
+
+// writeAny writes elements to the stream from buffers given as an interface. It allows the format agnostic helpers,
+// such as the burst scheduler, to write to any stream. The buffers must be a slice of slice of complex64.
+func (stream *SDRStreamCF32) writeAny(buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error) {
+
+	typedBuffers, ok := buffers.([][]complex64)
+	if !ok {
+		return 0, fmt.Errorf("the write buffers must be of type [][]complex64 for a CF32 stream, got %T", buffers)
+	}
+
+	return stream.Write(typedBuffers, nbElems, flags, timeNs, timeoutUs)
+}
+
 // ReadStreamStatus reads status information about a stream.
 //
 // This call is typically used on a transmit stream to report time errors, underflows, and burst completion.
@@ -1653,7 +1961,12 @@ func (stream *SDRStreamCF32) Write(buffers [][]complex64, nbElems uint, flags []
 // Return the buffer's timestamp in nanoseconds in case of success, an error otherwise
 func (stream *SDRStreamCF32) ReadStreamStatus(chanMask []uint, flags []int, timeoutUs uint) (timeNs uint, err error) {
 
-	return readStreamStatus(stream, chanMask, flags, timeoutUs)
+	timeNs, err = readStreamStatus(stream, chanMask, flags, timeoutUs)
+	if stream.stats != nil {
+		stream.stats.ObserveStatus(err)
+	}
+
+	return timeNs, err
 }
 
 /* ********************************************************************************** */
@@ -1749,6 +2062,22 @@ func (stream *SDRStreamCF64) getNbChannels() uint {
 	return stream.nbChannels
 }
 
+// SetStats sets the statistics updated by the read, write and status operations of the stream. Passing nil disables
+// the statistics.
+func (stream *SDRStreamCF64) SetStats(stats *sdrstats.Stats) {
+	stream.stats = stats
+}
+
+// GetStats returns the statistics updated by the stream, or nil if none are set
+func (stream *SDRStreamCF64) GetStats() *sdrstats.Stats {
+	return stream.stats
+}
+
+// Format returns the format of the samples of the stream
+func (stream *SDRStreamCF64) Format() string {
+	return "CF64"
+}
+
 /* ********************************************************************************** */
 /*                                STREAMS FUNCTIONS                                   */            
 /* ********************************************************************************** */
@@ -1883,7 +2212,15 @@ func (stream *SDRStreamCF64) Read(buffers [][]complex128, nbElems uint, outputFl
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return uint(cTimeNs), 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveRead(nbElems, 0, sdrErr)
+		}
+		return uint(cTimeNs), 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveRead(nbElems, uint(result), nil)
 	}
 
 	return uint(cTimeNs), uint(result), nil
@@ -1922,7 +2259,7 @@ func (stream *SDRStreamCF64) Write(buffers [][]complex128, nbElems uint, flags [
 	for channelIdx := uint(0); channelIdx < stream.nbChannels; channelIdx++ {
 
 		// Get the pointer to the buffer for the channel
-		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.readBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
+		ptrPtrBuffer := (**C.void)(unsafe.Pointer(uintptr(unsafe.Pointer(stream.writeBuffer)) + uintptr(channelIdx)*unsafe.Sizeof(voidPtrTemplate)))
 		*ptrPtrBuffer = (*C.void)(unsafe.Pointer(&buffers[channelIdx][0]))
 	}
 
@@ -1940,12 +2277,44 @@ func (stream *SDRStreamCF64) Write(buffers [][]complex128, nbElems uint, flags [
 			C.long(timeoutUs)))
 
 	if result < 0 {
-		return 0, sdrerror.Err(int(result))
+		sdrErr := sdrerror.Err(int(result))
+		if stream.stats != nil {
+			stream.stats.ObserveWrite(nbElems, 0, sdrErr)
+		}
+		return 0, sdrErr
+	}
+
+	if stream.stats != nil {
+		stream.stats.ObserveWrite(nbElems, uint(result), nil)
 	}
 
 	return uint(result), nil
 }
 
+// readAny reads elements from the stream into buffers given as an interface. It allows the format agnostic helpers,
+// such as the capture, to read from any stream. The buffers must be a slice of slice of complex128.
+func (stream *SDRStreamCF64) readAny(buffers interface{}, nbElems uint, outputFlags []int, timeoutUs uint) (timeNs uint, numElemsRead uint, err error) {
+
+	typedBuffers, ok := buffers.([][]complex128)
+	if !ok {
+		return 0, 0, fmt.Errorf("the read buffers must be of type [][]complex128 for a CF64 stream, got %T", buffers)
+	}
+
+	return stream.Read(typedBuffers, nbElems, outputFlags, timeoutUs)
+}
+
+// writeAny writes elements to the stream from buffers given as an interface. It allows the format agnostic helpers,
+// such as the burst scheduler, to write to any stream. The buffers must be a slice of slice of complex128.
+func (stream *SDRStreamCF64) writeAny(buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (NbElemsWritten uint, err error) {
+
+	typedBuffers, ok := buffers.([][]complex128)
+	if !ok {
+		return 0, fmt.Errorf("the write buffers must be of type [][]complex128 for a CF64 stream, got %T", buffers)
+	}
+
+	return stream.Write(typedBuffers, nbElems, flags, timeNs, timeoutUs)
+}
+
 // ReadStreamStatus reads status information about a stream.
 //
 // This call is typically used on a transmit stream to report time errors, underflows, and burst completion.
@@ -1964,7 +2333,12 @@ func (stream *SDRStreamCF64) Write(buffers [][]complex128, nbElems uint, flags [
 // Return the buffer's timestamp in nanoseconds in case of success, an error otherwise
 func (stream *SDRStreamCF64) ReadStreamStatus(chanMask []uint, flags []int, timeoutUs uint) (timeNs uint, err error) {
 
-	return readStreamStatus(stream, chanMask, flags, timeoutUs)
+	timeNs, err = readStreamStatus(stream, chanMask, flags, timeoutUs)
+	if stream.stats != nil {
+		stream.stats.ObserveStatus(err)
+	}
+
+	return timeNs, err
 }
 
 
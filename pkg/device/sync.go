@@ -0,0 +1,313 @@
+package device
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// syncPPSTimeout is the maximum time waited for a PPS edge
+	syncPPSTimeout = 1500 * time.Millisecond
+	// syncPPSPollPeriod is the period of the polls of the time of the last PPS edge
+	syncPPSPollPeriod = 10 * time.Millisecond
+)
+
+// DefaultSyncClockSources are the clock sources preferred by a SyncGroup, in order of preference
+var DefaultSyncClockSources = []string{"external", "gpsdo", "mimo"}
+
+// syncPPSTimeNames are the names of the PPS time counter used by the drivers, such as "PPS" for SoapyUHD
+var syncPPSTimeNames = []string{"pps", "PPS"}
+
+// DefaultSyncTimeSources are the time sources preferred by a SyncGroup, in order of preference
+var DefaultSyncTimeSources = []string{"external", "gpsdo", "mimo"}
+
+// SyncGroup aligns the clocks and the hardware times of several devices, for example the devices created by MakeList.
+//
+// The devices share a clock source and a time source, chosen among the preferred sources available on all the devices.
+// The hardware times are then set at the same PPS edge, and their alignment is verified.
+type SyncGroup struct {
+	// ClockSources are the preferred clock sources, in order of preference
+	ClockSources []string
+	// TimeSources are the preferred time sources, in order of preference
+	TimeSources []string
+	// Tolerance is the maximum residual offset between the hardware times of the devices for them to be considered
+	// aligned. The offsets are measured from the host, so the tolerance must include the latency of the hardware time
+	// reads.
+	Tolerance time.Duration
+
+	devices []*SDRDevice
+}
+
+// SyncReport is the result of the synchronization of a device of a SyncGroup
+type SyncReport struct {
+	// Index is the index of the device in the group
+	Index int
+	// ClockSource is the clock source of the device after the synchronization
+	ClockSource string
+	// TimeSource is the time source of the device after the synchronization
+	TimeSource string
+	// PPS indicates that the hardware time was set on a PPS edge. When false, the hardware time was set immediately,
+	// with the latency of the host.
+	PPS bool
+	// PPSTimeName is the name of the PPS time counter of the device used to set the hardware time, empty when the
+	// hardware time was set immediately
+	PPSTimeName string
+	// HardwareTimeNs is the hardware time read during the verification
+	HardwareTimeNs uint
+	// Offset is the residual offset of the hardware time relatively to the first device of the group
+	Offset time.Duration
+	// Sensors are the values of the lock sensors of the device, such as "ref_locked"
+	Sensors map[string]bool
+	// Aligned indicates that the offset is within the tolerance and that all the lock sensors are locked
+	Aligned bool
+}
+
+// NewSyncGroup creates a group of devices to synchronize.
+//
+// Params:
+//  - devices: the devices to synchronize, the first one being the reference for the offsets
+//
+// Return the group or an error if no device is given
+func NewSyncGroup(devices []*SDRDevice) (group *SyncGroup, err error) {
+
+	if len(devices) == 0 {
+		return nil, errors.New("a sync group requires at least one device")
+	}
+
+	return &SyncGroup{
+		ClockSources: DefaultSyncClockSources,
+		TimeSources:  DefaultSyncTimeSources,
+		Tolerance:    time.Millisecond,
+		devices:      devices,
+	}, nil
+}
+
+// SelectSources selects the first preferred clock source and time source available on all the devices and sets them
+// on the devices. When no preferred source is shared by all the devices, the source is left unchanged.
+//
+// Return the selected clock source and time source, empty when left unchanged, or an error
+func (group *SyncGroup) SelectSources() (clockSource string, timeSource string, err error) {
+
+	clockSources := make([][]string, len(group.devices))
+	timeSources := make([][]string, len(group.devices))
+	for i, dev := range group.devices {
+		clockSources[i] = dev.ListClockSources()
+		timeSources[i] = dev.ListTimeSources()
+	}
+
+	clockSource = sharedSource(group.ClockSources, clockSources)
+	timeSource = sharedSource(group.TimeSources, timeSources)
+
+	for i, dev := range group.devices {
+		if clockSource != "" {
+			if err := dev.SetClockSource(clockSource); err != nil {
+				return "", "", fmt.Errorf("can not set the clock source %v of device %d: %w", clockSource, i, err)
+			}
+		}
+		if timeSource != "" {
+			if err := dev.SetTimeSource(timeSource); err != nil {
+				return "", "", fmt.Errorf("can not set the time source %v of device %d: %w", timeSource, i, err)
+			}
+		}
+	}
+
+	return clockSource, timeSource, nil
+}
+
+// Synchronize sets the hardware time of all the devices at the next PPS edge, then verifies their alignment.
+//
+// When all the devices have a PPS time counter, the time of the last PPS edge is polled on the first device until an
+// edge occurs, then the time of the next PPS edge is armed on all the devices. Otherwise, the hardware times are set
+// immediately one after the other. The reports tell which method was used, and the name of the PPS time counter of
+// each device, as the drivers name it differently ("pps", "PPS").
+//
+// Params:
+//  - timeNs: the hardware time in nanoseconds to set at the next PPS edge
+//
+// Return a report per device or an error if the hardware times could not be set
+func (group *SyncGroup) Synchronize(timeNs uint) (reports []SyncReport, err error) {
+
+	pps := true
+	ppsNames := make([]string, len(group.devices))
+	for i, dev := range group.devices {
+		ppsNames[i] = ppsTimeName(dev)
+		if ppsNames[i] == "" {
+			pps = false
+		}
+	}
+
+	if pps {
+		// Wait for a PPS edge, so that all the devices are armed well before the next one
+		if err := group.waitPPS(ppsNames[0]); err != nil {
+			return nil, err
+		}
+		for i, dev := range group.devices {
+			if err := dev.SetHardwareTime(timeNs, ppsNames[i]); err != nil {
+				return nil, fmt.Errorf("can not arm the hardware time of device %d on %v: %w", i, ppsNames[i], err)
+			}
+		}
+		// Wait for the PPS edge on which the time is set
+		if err := group.waitPPS(ppsNames[0]); err != nil {
+			return nil, err
+		}
+	} else {
+		for i, dev := range group.devices {
+			if err := dev.SetHardwareTime(timeNs, ""); err != nil {
+				return nil, fmt.Errorf("can not set the hardware time of device %d: %w", i, err)
+			}
+		}
+	}
+
+	reports = group.Verify(pps)
+	if pps {
+		for i := range reports {
+			reports[i].PPSTimeName = ppsNames[i]
+		}
+	}
+
+	return reports, nil
+}
+
+// Verify reads the hardware times and the lock sensors of the devices and reports their alignment. The offsets are
+// corrected by the host time elapsed between the reads.
+//
+// Params:
+//  - pps: whether the hardware times were set on a PPS edge, copied in the reports
+//
+// Return a report per device
+func (group *SyncGroup) Verify(pps bool) []SyncReport {
+
+	hardwareTimes := make([]uint, len(group.devices))
+	hostTimes := make([]time.Time, len(group.devices))
+	for i, dev := range group.devices {
+		hostTimes[i] = time.Now()
+		hardwareTimes[i] = dev.GetHardwareTime("")
+	}
+
+	reports := make([]SyncReport, len(group.devices))
+	for i, dev := range group.devices {
+
+		offset := time.Duration(int64(hardwareTimes[i])-int64(hardwareTimes[0])) - hostTimes[i].Sub(hostTimes[0])
+
+		reports[i] = SyncReport{
+			Index:          i,
+			ClockSource:    dev.GetClockSource(),
+			TimeSource:     dev.GetTimeSource(),
+			PPS:            pps,
+			HardwareTimeNs: hardwareTimes[i],
+			Offset:         offset,
+			Sensors:        lockSensors(dev),
+		}
+
+		aligned := offset <= group.Tolerance && offset >= -group.Tolerance
+		for _, locked := range reports[i].Sensors {
+			aligned = aligned && locked
+		}
+		reports[i].Aligned = aligned
+	}
+
+	return reports
+}
+
+// waitPPS polls the time of the last PPS edge of the first device until it changes
+func (group *SyncGroup) waitPPS(ppsName string) error {
+
+	dev := group.devices[0]
+	last := dev.GetHardwareTime(ppsName)
+
+	deadline := time.Now().Add(syncPPSTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(syncPPSPollPeriod)
+		if dev.GetHardwareTime(ppsName) != last {
+			return nil
+		}
+	}
+
+	return errors.New("no PPS edge detected, check the time source of the devices")
+}
+
+// ppsTimeName returns the name of the PPS time counter of a device, or an empty string if the device has none. The
+// known names are tried first, then the time sources of the device matching "pps" regardless of the case.
+func ppsTimeName(dev *SDRDevice) string {
+
+	candidates := append([]string{}, syncPPSTimeNames...)
+	for _, source := range dev.ListTimeSources() {
+		if strings.EqualFold(source, "pps") && !containsString(candidates, source) {
+			candidates = append(candidates, source)
+		}
+	}
+
+	for _, name := range candidates {
+		if dev.HasHardwareTime(name) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// sharedSource returns the first preferred source found in the sources of all the devices, or an empty string
+func sharedSource(preferred []string, sources [][]string) string {
+
+	for _, candidate := range preferred {
+		shared := true
+		for _, deviceSources := range sources {
+			if !containsString(deviceSources, candidate) {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// containsString returns whether a list of strings contains a value
+func containsString(values []string, value string) bool {
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lockSensors reads the global sensors of a device whose name contains "lock", such as "ref_locked"
+func lockSensors(dev *SDRDevice) map[string]bool {
+
+	sensors := make(map[string]bool)
+	for _, key := range dev.ListSensors() {
+		if strings.Contains(strings.ToLower(key), "lock") {
+			sensors[key] = strings.EqualFold(strings.TrimSpace(dev.ReadSensor(key)), "true")
+		}
+	}
+
+	return sensors
+}
@@ -0,0 +1,484 @@
+package agc
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the software automatic gain control of the receivers without a hardware one: the power of a RX stream is
+// measured and the gain of the device is adjusted to keep the stream at a target level.
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/dsp/power"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+)
+
+const (
+	// DefaultTarget is the average level of the stream in dBFS when none is given
+	DefaultTarget = -20.0
+	// DefaultHysteresis is the distance in dB to the target within which the gain is not changed when none is given
+	DefaultHysteresis = 3.0
+	// DefaultHeadroom is the distance in dB between the peaks of the stream and the full scale when none is given
+	DefaultHeadroom = 3.0
+	// DefaultResolution is the smallest gain change in dB when none is given
+	DefaultResolution = 1.0
+	// DefaultAttack is the time constant of the gain reductions when none is given
+	DefaultAttack = 10 * time.Millisecond
+	// DefaultDecay is the time constant of the gain increases when none is given
+	DefaultDecay = 500 * time.Millisecond
+	// DefaultSettle is the time during which the samples are not measured after a gain change when none is given
+	DefaultSettle = 20 * time.Millisecond
+)
+
+// Options are the options of an automatic gain control
+type Options struct {
+	// SampleRate is the sample rate of the stream in Hz
+	SampleRate float64
+	// Target is the average level of the stream in dBFS, DefaultTarget when 0
+	Target float64
+	// Hysteresis is the distance in dB to the target within which the gain is not changed, DefaultHysteresis when 0
+	Hysteresis float64
+	// Headroom is the distance in dB kept between the peaks of the stream and the full scale, DefaultHeadroom when 0.
+	// The gain is reduced at once when the peaks exceed it, whatever the attack.
+	Headroom float64
+	// Resolution is the smallest gain change in dB, DefaultResolution when 0. It is raised to the step of the gain of
+	// the device.
+	Resolution float64
+	// Attack is the time constant of the gain reductions, DefaultAttack when 0
+	Attack time.Duration
+	// Decay is the time constant of the gain increases, DefaultDecay when 0
+	Decay time.Duration
+	// Settle is the time during which the samples are not measured after a gain change, DefaultSettle when 0. It must
+	// cover the samples buffered by the device and the driver, read after the change but received before it.
+	Settle time.Duration
+	// WindowSize is the number of samples of each measurement, power.DefaultWindowSize when 0
+	WindowSize int
+	// FullScale is the value of a full scale sample in the unit of the buffers, as returned by
+	// GetNativeStreamFormat, or 0 for the full range of the type of the buffers (1 for the float formats)
+	FullScale float64
+	// Elements are the amplification elements driven by the control, filled in order: the first element reaches its
+	// maximum gain before the gain of the second one is raised. The overall gain of the channel is driven when empty.
+	Elements []string
+}
+
+// FromDevice sets the sample rate of the options from the current settings of a RX channel of a device.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+func (options *Options) FromDevice(dev *device.SDRDevice, channel uint) {
+	options.SampleRate = dev.GetSampleRate(device.DirectionRX, channel)
+}
+
+// GainChange is a change of the gain of the device made by the control
+type GainChange struct {
+	// SampleIndex is the index in the stream of the first sample read after the change
+	SampleIndex int64
+	// HasTime is true when TimeNs is set
+	HasTime bool
+	// TimeNs is the time of the sample SampleIndex, from the times of the processed blocks
+	TimeNs int64
+	// HasHardwareTime is true when HardwareTimeNs is set
+	HasHardwareTime bool
+	// HardwareTimeNs is the time of the hardware clock of the device read once the gain is set
+	HardwareTimeNs int64
+	// Previous is the gain in dB before the change
+	Previous float64
+	// Gain is the gain in dB after the change, as read back from the device
+	Gain float64
+	// Elements are the gains in dB of the driven elements after the change, as read back from the device
+	Elements map[string]float64
+	// Level is the average level in dBFS measured before the change
+	Level float64
+	// Peak is the peak level in dBFS measured before the change
+	Peak float64
+	// Clipped is true if the ADC clipped samples before the change
+	Clipped bool
+}
+
+// AGC is a software automatic gain control driving the gain of a RX channel of a device
+type AGC struct {
+	options         Options
+	dev             *device.SDRDevice
+	channel         uint
+	meter           *power.Meter
+	ranges          []device.SDRRange
+	minimum         float64
+	maximum         float64
+	hasHardwareTime bool
+
+	gain        float64
+	pending     float64
+	position    int64
+	measured    int64
+	settleUntil int64
+	decided     int64
+
+	sum     float64
+	windows int
+	peak    float64
+	clipped bool
+}
+
+// New creates an automatic gain control of a RX channel of a device, and disables the automatic gain control of the
+// device if it has one.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+//  - options: the options of the control
+//
+// Return the control or an error
+func New(dev *device.SDRDevice, channel uint, options Options) (agc *AGC, err error) {
+
+	var ranges []device.SDRRange
+	if len(options.Elements) == 0 {
+		ranges = []device.SDRRange{dev.GetGainRange(device.DirectionRX, channel)}
+	} else {
+		for _, name := range options.Elements {
+			ranges = append(ranges, dev.GetGainElementRange(device.DirectionRX, channel, name))
+		}
+	}
+
+	agc, err = newAGC(options, ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	if dev.HasGainMode(device.DirectionRX, channel) {
+		if err := dev.SetGainMode(device.DirectionRX, channel, false); err != nil {
+			return nil, err
+		}
+	}
+
+	agc.dev = dev
+	agc.channel = channel
+	agc.hasHardwareTime = dev.HasHardwareTime("")
+	agc.gain, _ = agc.readGain()
+
+	return agc, nil
+}
+
+// newAGC creates an automatic gain control, without device.
+//
+// Params:
+//  - options: the options of the control
+//  - ranges: the gain ranges of the driven elements, or the overall gain range of the channel
+//
+// Return the control or an error
+func newAGC(options Options, ranges []device.SDRRange) (agc *AGC, err error) {
+
+	if options.Target == 0 {
+		options.Target = DefaultTarget
+	}
+	if options.Hysteresis == 0 {
+		options.Hysteresis = DefaultHysteresis
+	}
+	if options.Headroom == 0 {
+		options.Headroom = DefaultHeadroom
+	}
+	if options.Resolution == 0 {
+		options.Resolution = DefaultResolution
+	}
+	if options.Attack == 0 {
+		options.Attack = DefaultAttack
+	}
+	if options.Decay == 0 {
+		options.Decay = DefaultDecay
+	}
+	if options.Settle == 0 {
+		options.Settle = DefaultSettle
+	}
+
+	if options.SampleRate <= 0 {
+		return nil, errors.New("the sample rate of an automatic gain control must be positive")
+	}
+
+	if options.Target > 0 {
+		return nil, errors.New("the target of an automatic gain control can not be above the full scale")
+	}
+
+	if options.Hysteresis < 0 || options.Headroom < 0 || options.Resolution < 0 || options.Attack < 0 ||
+		options.Decay < 0 || options.Settle < 0 {
+		return nil, errors.New("the hysteresis, headroom, resolution and times of an automatic gain control can not " +
+			"be negative")
+	}
+
+	meter, err := power.NewMeter(power.Options{WindowSize: options.WindowSize, FullScale: options.FullScale})
+	if err != nil {
+		return nil, err
+	}
+	options.WindowSize = meter.Options().WindowSize
+
+	agc = &AGC{
+		options: options,
+		meter:   meter,
+		ranges:  ranges,
+	}
+
+	step := 0.0
+	for _, gainRange := range agc.ranges {
+		if gainRange.Maximum < gainRange.Minimum {
+			return nil, errors.New("the gain range of the device is invalid")
+		}
+		agc.minimum += gainRange.Minimum
+		agc.maximum += gainRange.Maximum
+		if gainRange.Step > 0 && (step == 0 || gainRange.Step < step) {
+			step = gainRange.Step
+		}
+	}
+	if step > agc.options.Resolution {
+		agc.options.Resolution = step
+	}
+
+	return agc, nil
+}
+
+// Options returns the options of the control, completed with the default values
+func (agc *AGC) Options() Options {
+	return agc.options
+}
+
+// Gain returns the current gain in dB
+func (agc *AGC) Gain() float64 {
+	return agc.gain
+}
+
+// Process measures the samples of a block read from the stream and changes the gain of the device if needed. At most
+// one change is made per block, after its last sample.
+//
+// Params:
+//  - buffer: the samples of the block, such as a []complex64 or a []int16
+//  - timeNs: the time of the first sample of the block
+//  - hasTime: true if timeNs is set
+//
+// Return the change of the gain, true if the gain was changed, and an error
+func (agc *AGC) Process(buffer interface{}, timeNs int64, hasTime bool) (change GainChange, changed bool, err error) {
+
+	length, err := sdrformat.BufferLength(buffer)
+	if err != nil {
+		return GainChange{}, false, err
+	}
+
+	measurements, err := agc.meter.Write(buffer)
+	if err != nil {
+		return GainChange{}, false, err
+	}
+
+	agc.measure(length, measurements)
+	if agc.windows == 0 {
+		return GainChange{}, false, nil
+	}
+
+	change = GainChange{
+		SampleIndex: agc.position,
+		Previous:    agc.gain,
+		Level:       decibels(agc.sum / float64(agc.windows)),
+		Peak:        agc.peak,
+		Clipped:     agc.clipped,
+	}
+
+	gain, ok := agc.nextGain()
+	if !ok {
+		return GainChange{}, false, nil
+	}
+
+	if hasTime {
+		change.HasTime = true
+		change.TimeNs = timeNs + sdrtime.TicksToTimeNs64(int64(length), agc.options.SampleRate)
+	}
+
+	if err := agc.setGain(gain); err != nil {
+		return GainChange{}, false, err
+	}
+
+	if agc.hasHardwareTime {
+		change.HasHardwareTime = true
+		change.HardwareTimeNs = int64(agc.dev.GetHardwareTime(""))
+	}
+
+	agc.gain, change.Elements = agc.readGain()
+	change.Gain = agc.gain
+
+	agc.settleUntil = agc.position + int64(agc.options.Settle.Seconds()*agc.options.SampleRate)
+
+	return change, true, nil
+}
+
+// Reset forgets the measurements and the pending gain change, and reads the gain of the device again
+func (agc *AGC) Reset() {
+
+	agc.meter.Reset()
+	agc.pending = 0
+	agc.position = 0
+	agc.measured = 0
+	agc.settleUntil = 0
+	agc.decided = 0
+	agc.restart()
+
+	agc.gain, _ = agc.readGain()
+}
+
+// measure accumulates the measurements of a block of samples, except the windows starting while the gain settles
+//
+// Params:
+//  - length: the number of samples of the block
+//  - measurements: the measurements of the windows completed by the block
+func (agc *AGC) measure(length int, measurements []power.Measurement) {
+
+	agc.position += int64(length)
+
+	for _, measurement := range measurements {
+
+		start := agc.measured
+		agc.measured += int64(measurement.NbSamples)
+		if start < agc.settleUntil {
+			continue
+		}
+
+		agc.sum += math.Pow(10, measurement.RMS/10)
+		if agc.windows == 0 || measurement.Peak > agc.peak {
+			agc.peak = measurement.Peak
+		}
+		agc.clipped = agc.clipped || measurement.Clipped()
+		agc.windows++
+	}
+}
+
+// nextGain decides the gain from the measurements since the last decision
+//
+// Return the new gain, and true if it differs from the current gain
+func (agc *AGC) nextGain() (gain float64, ok bool) {
+
+	options := agc.options
+
+	level := decibels(agc.sum / float64(agc.windows))
+	duration := float64(agc.position-agc.decided) / options.SampleRate
+	agc.decided = agc.position
+
+	// the room before the peaks reach the headroom; clipped samples hide the actual peaks
+	room := -options.Headroom - agc.peak
+	if agc.clipped {
+		room = math.Min(room, -2*options.Headroom)
+	}
+
+	delta := math.Min(options.Target-level, room)
+
+	agc.restart()
+
+	var step float64
+	if room < 0 {
+		// overload, reduce at once
+		agc.pending = 0
+		step = math.Floor(delta/options.Resolution) * options.Resolution
+	} else {
+		if math.Abs(delta) <= options.Hysteresis {
+			agc.pending = 0
+			return agc.gain, false
+		}
+
+		timeConstant := options.Decay
+		if delta < 0 {
+			timeConstant = options.Attack
+		}
+
+		// the pending change accumulates the fractions of the resolution until a change can be made
+		if agc.pending*delta < 0 {
+			agc.pending = 0
+		}
+		agc.pending += delta * (1 - math.Exp(-duration/timeConstant.Seconds()))
+		step = math.Trunc(agc.pending/options.Resolution) * options.Resolution
+		agc.pending -= step
+	}
+
+	gain = math.Max(agc.minimum, math.Min(agc.maximum, agc.gain+step))
+	if gain != agc.gain+step {
+		// at a limit of the range, drop the rest of the change
+		agc.pending = 0
+	}
+
+	return gain, math.Abs(gain-agc.gain) > 1e-9
+}
+
+// restart starts a new measurement
+func (agc *AGC) restart() {
+
+	agc.sum = 0
+	agc.windows = 0
+	agc.peak = 0
+	agc.clipped = false
+}
+
+// setGain sets the gain of the device, distributed over the driven elements in order
+//
+// Params:
+//  - gain: the gain in dB
+//
+// Return an error or nil in case of success
+func (agc *AGC) setGain(gain float64) error {
+
+	if len(agc.options.Elements) == 0 {
+		return agc.dev.SetGain(device.DirectionRX, agc.channel, gain)
+	}
+
+	remaining := gain - agc.minimum
+	for i, name := range agc.options.Elements {
+
+		gainRange := agc.ranges[i]
+		elementGain := math.Min(remaining, gainRange.Maximum-gainRange.Minimum)
+		if gainRange.Step > 0 {
+			elementGain = math.Floor(elementGain/gainRange.Step+1e-9) * gainRange.Step
+		}
+		remaining -= elementGain
+
+		if err := agc.dev.SetGainElement(device.DirectionRX, agc.channel, name, gainRange.Minimum+elementGain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readGain reads the gain of the device
+//
+// Return the gain in dB and the gains of the driven elements
+func (agc *AGC) readGain() (gain float64, elements map[string]float64) {
+
+	if len(agc.options.Elements) == 0 {
+		return agc.dev.GetGain(device.DirectionRX, agc.channel), nil
+	}
+
+	elements = make(map[string]float64, len(agc.options.Elements))
+	for _, name := range agc.options.Elements {
+		elements[name] = agc.dev.GetGainElement(device.DirectionRX, agc.channel, name)
+		gain += elements[name]
+	}
+
+	return gain, elements
+}
+
+// decibels converts a power ratio to dB
+func decibels(power float64) float64 {
+	return 10 * math.Log10(power)
+}
@@ -0,0 +1,232 @@
+package agc
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/dsp/power"
+)
+
+// testWindowSize is the number of samples of the windows of the tests, 1 ms at 1 MHz
+const testWindowSize = 1000
+
+// windows returns the measurements of consecutive windows of the same levels
+func windows(count int, rms float64, peak float64, clipped int) []power.Measurement {
+
+	measurements := make([]power.Measurement, count)
+	for i := range measurements {
+		measurements[i] = power.Measurement{
+			NbSamples:      testWindowSize,
+			RMS:            rms,
+			Peak:           peak,
+			CrestFactor:    peak - rms,
+			ClippedSamples: clipped,
+		}
+	}
+
+	return measurements
+}
+
+// decision is a block of measurements and the gain decided after it
+type decision struct {
+	measurements []power.Measurement
+	gain         float64
+	changed      bool
+	pending      float64
+}
+
+// TestNextGain checks the gains decided from the measurements: the accumulation of the changes with the attack and
+// the decay, the hysteresis, the reductions at once when the peaks exceed the headroom, and the clamping to the range
+func TestNextGain(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		gain      float64
+		decisions []decision
+	}{
+		{"within the hysteresis", 30, []decision{
+			{windows(1, -18, -10, 0), 30, false, 0},
+			{windows(100, -22.5, -10, 0), 30, false, 0},
+		}},
+		// -10 dB with 1 ms of a 10 ms attack accumulate -0.95 dB at each decision
+		{"attack", 30, []decision{
+			{windows(1, -10, -14, 0), 30, false, -10 * (1 - math.Exp(-0.1))},
+			{windows(1, -10, -14, 0), 29, true, -20*(1-math.Exp(-0.1)) + 1},
+			{windows(1, -10, -14, 0), 28, true, -30*(1-math.Exp(-0.1)) + 2},
+		}},
+		// +10 dB with 100 ms of a 500 ms decay accumulate 1.81 dB
+		{"decay", 30, []decision{
+			{windows(1, -30, -25, 0), 30, false, 10 * (1 - math.Exp(-0.002))},
+			{windows(99, -30, -25, 0), 31, true, 10*(1-math.Exp(-0.002)) + 10*(1-math.Exp(-0.198)) - 1},
+		}},
+		{"hysteresis drops the pending change", 30, []decision{
+			{windows(1, -30, -25, 0), 30, false, 10 * (1 - math.Exp(-0.002))},
+			{windows(1, -21, -15, 0), 30, false, 0},
+		}},
+		{"opposite change drops the pending change", 30, []decision{
+			{windows(49, -30, -25, 0), 30, false, 10 * (1 - math.Exp(-0.098))},
+			{windows(1, -10, -14, 0), 30, false, -10 * (1 - math.Exp(-0.1))},
+		}},
+		// The peaks above -3 dBFS reduce the gain at once, whatever the level and the attack
+		{"headroom", 30, []decision{
+			{windows(1, -25, -2, 0), 29, true, 0},
+			{windows(1, -25, -1.5, 0), 27, true, 0},
+			{windows(1, -25, -3, 0), 27, false, 0},
+		}},
+		{"strongest peak", 30, []decision{
+			{append(windows(5, -25, -10, 0), windows(1, -25, -1, 0)...), 28, true, 0},
+		}},
+		// The clipped peaks are assumed at least 3 dB above the full scale
+		{"clipping", 30, []decision{
+			{windows(1, -25, 0, 1), 24, true, 0},
+			{append(windows(1, -30, -10, 0), windows(1, -25, -5, 10)...), 18, true, 0},
+		}},
+		{"clipping drops the pending change", 30, []decision{
+			{windows(49, -30, -25, 0), 30, false, 10 * (1 - math.Exp(-0.098))},
+			{windows(1, -25, 0, 1), 24, true, 0},
+		}},
+		{"maximum", 59, []decision{
+			{windows(5000, -40, -35, 0), 60, true, 0},
+			{windows(5000, -40, -35, 0), 60, false, 0},
+		}},
+		{"minimum", 2, []decision{
+			{windows(1, -10, 0, 1), 0, true, 0},
+			{windows(1000, 0, 0, 1), 0, false, 0},
+		}},
+	}
+
+	for _, test := range tests {
+
+		agc, err := newAGC(Options{SampleRate: 1e6, WindowSize: testWindowSize},
+			[]device.SDRRange{{Minimum: 0, Maximum: 60, Step: 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		agc.gain = test.gain
+
+		for i, decision := range test.decisions {
+
+			agc.measure(len(decision.measurements)*testWindowSize, decision.measurements)
+			gain, changed := agc.nextGain()
+
+			if gain != decision.gain || changed != decision.changed {
+				t.Errorf("%s: decision %d of the gain %v (changed %v), expected %v (%v)", test.name, i, gain, changed,
+					decision.gain, decision.changed)
+			}
+			if math.Abs(agc.pending-decision.pending) > 1e-9 {
+				t.Errorf("%s: decision %d with %v dB pending, expected %v dB", test.name, i, agc.pending,
+					decision.pending)
+			}
+			if agc.windows != 0 {
+				t.Errorf("%s: decision %d did not restart the measurement", test.name, i)
+			}
+
+			agc.gain = gain
+		}
+	}
+}
+
+// TestMeasure checks the accumulation of the measurements and the windows skipped while the gain settles
+func TestMeasure(t *testing.T) {
+
+	agc, err := newAGC(Options{SampleRate: 1e6, WindowSize: testWindowSize},
+		[]device.SDRRange{{Minimum: 0, Maximum: 60}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A change settling until the sample 2500: the windows starting at 0 and 1000 and 2000 are skipped
+	agc.settleUntil = 2500
+	agc.measure(2500, windows(2, 0, 0, 10))
+	if agc.windows != 0 {
+		t.Fatalf("%d windows measured while the gain settles", agc.windows)
+	}
+
+	measurements := append(windows(1, 0, 0, 10), windows(1, -20, -15, 0)...)
+	measurements = append(measurements, windows(1, -10, -5, 0)...)
+	agc.measure(2000, measurements)
+
+	if agc.windows != 2 || agc.clipped || agc.peak != -5 {
+		t.Errorf("%d windows measured with a peak of %v dBFS (clipped %v), expected 2 of -5 dBFS", agc.windows,
+			agc.peak, agc.clipped)
+	}
+	if level := decibels(agc.sum / float64(agc.windows)); math.Abs(level-decibels((0.01+0.1)/2)) > 1e-9 {
+		t.Errorf("level of %v dBFS, expected the average power of the windows", level)
+	}
+	if agc.position != 4500 || agc.measured != 5000 {
+		t.Errorf("position %d and %d samples measured, expected 4500 and 5000", agc.position, agc.measured)
+	}
+
+	// The peak of silence, -Inf dBFS, is kept as the first peak measured
+	agc.restart()
+	agc.measure(testWindowSize, windows(1, math.Inf(-1), math.Inf(-1), 0))
+	if agc.windows != 1 || !math.IsInf(agc.peak, -1) {
+		t.Errorf("%d windows measured with a peak of %v dBFS, expected 1 of -Inf", agc.windows, agc.peak)
+	}
+}
+
+// TestNewAGC checks the default options, the resolution raised to the step of the gain and the invalid options
+func TestNewAGC(t *testing.T) {
+
+	agc, err := newAGC(Options{SampleRate: 1e6}, []device.SDRRange{
+		{Minimum: 0, Maximum: 40, Step: 2},
+		{Minimum: -10, Maximum: 20, Step: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := agc.Options()
+	if options.Target != DefaultTarget || options.Hysteresis != DefaultHysteresis ||
+		options.Headroom != DefaultHeadroom || options.Attack != DefaultAttack || options.Decay != DefaultDecay ||
+		options.Settle != DefaultSettle || options.WindowSize != power.DefaultWindowSize {
+		t.Errorf("options %+v, expected the defaults", options)
+	}
+	if options.Resolution != 2 {
+		t.Errorf("resolution of %v dB, expected the smallest step of 2 dB", options.Resolution)
+	}
+	if agc.minimum != -10 || agc.maximum != 60 {
+		t.Errorf("range from %v dB to %v dB, expected -10 dB to 60 dB", agc.minimum, agc.maximum)
+	}
+
+	tests := []struct {
+		name    string
+		options Options
+		ranges  []device.SDRRange
+	}{
+		{"no sample rate", Options{}, []device.SDRRange{{Maximum: 60}}},
+		{"target above the full scale", Options{SampleRate: 1e6, Target: 3}, []device.SDRRange{{Maximum: 60}}},
+		{"negative hysteresis", Options{SampleRate: 1e6, Hysteresis: -1}, []device.SDRRange{{Maximum: 60}}},
+		{"negative attack", Options{SampleRate: 1e6, Attack: -time.Millisecond}, []device.SDRRange{{Maximum: 60}}},
+		{"negative window", Options{SampleRate: 1e6, WindowSize: -1}, []device.SDRRange{{Maximum: 60}}},
+		{"invalid range", Options{SampleRate: 1e6}, []device.SDRRange{{Minimum: 10, Maximum: 0}}},
+	}
+
+	for _, test := range tests {
+		if _, err := newAGC(test.options, test.ranges); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package channelizer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the polyphase filterbank channelizers splitting a wideband stream into equally spaced channels.
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+
+	"github.com/bhojpur/sdr/pkg/dsp/filter"
+	"github.com/bhojpur/sdr/pkg/dsp/spectrum"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+)
+
+// DefaultAttenuation is the attenuation in dB of the adjacent channels when none is given
+const DefaultAttenuation = 70
+
+// Options are the options of a channelizer
+type Options struct {
+	// Channels is the number of channels N. The channel c is centered on c * SampleRate / N, the channels above N/2
+	// being the negative frequencies.
+	Channels int
+	// Decimation is the ratio of the input and channel sample rates, which must divide the number of channels. The
+	// channelizer is critically sampled when it equals the number of channels (the default when 0), oversampled
+	// otherwise, N/2 being usual. An oversampled channelizer has no aliasing at the edges of the channels.
+	Decimation int
+	// SampleRate is the sample rate of the wideband stream, needed by the timestamps of the blocks
+	SampleRate float64
+	// Attenuation is the attenuation in dB of the prototype filter outside its band, DefaultAttenuation when 0
+	Attenuation float64
+	// TapsPerChannel is the number of taps of the prototype filter by channel, estimated from the attenuation when 0
+	TapsPerChannel int
+}
+
+// Block is a block of samples of a channel
+type Block struct {
+	// Channel is the index of the channel
+	Channel int
+	// Samples are the samples of the channel, valid until the next call to Process
+	Samples []complex64
+	// TimeNs is the time of the first sample in nanoseconds, corrected by the delay of the filter
+	TimeNs int64
+	// HasTime is true when TimeNs is valid
+	HasTime bool
+}
+
+// Channelizer splits a wideband stream into equally spaced channels with a polyphase filterbank: the samples are
+// weighted by the polyphase components of a prototype lowpass filter, then an FFT computes all the channels at once.
+// When few channels are enabled, they are computed by direct transforms instead. On a single core of an x86 server, a
+// critically sampled channelizer processes about 45 Msps with 16 channels, 35 Msps with 256 channels and 31 Msps with
+// 1024 channels, see BenchmarkProcess.
+type Channelizer struct {
+	options   Options
+	fft       *spectrum.FFT
+	taps      []float32
+	branches  []float32
+	length    int
+	enabled   []bool
+	nbEnabled int
+	twiddles  []complex128
+	bins      []int
+
+	history []complex64
+	work    []complex64
+	next    int
+	output  int64
+	folded  []complex128
+	outputs []complex128
+	buffers [][]complex64
+	blocks  []Block
+}
+
+// New creates a channelizer, with all its channels enabled.
+//
+// Params:
+//  - options: the options of the channelizer
+//
+// Return the channelizer or an error
+func New(options Options) (channelizer *Channelizer, err error) {
+
+	if options.Channels < 1 {
+		return nil, errors.New("a channelizer needs at least one channel")
+	}
+	if options.Decimation == 0 {
+		options.Decimation = options.Channels
+	}
+	if options.Attenuation == 0 {
+		options.Attenuation = DefaultAttenuation
+	}
+
+	channels := options.Channels
+	if options.Decimation < 1 || channels%options.Decimation != 0 {
+		return nil, errors.New("the decimation of a channelizer must divide its number of channels")
+	}
+
+	fft, err := spectrum.NewFFT(channels)
+	if err != nil {
+		return nil, err
+	}
+
+	// The critically sampled channels cross at -6 dB, the oversampled ones keep their band free of aliases
+	cutoff := 0.5 / float64(channels)
+	transition := 0.3 / float64(channels)
+	if options.Decimation < channels {
+		transition = 0.5 / float64(channels)
+	}
+
+	numTaps, beta := filter.KaiserParameters(options.Attenuation, transition)
+	if options.TapsPerChannel > 0 {
+		numTaps = options.TapsPerChannel * channels
+	}
+
+	var prototype []float64
+	if cutoff < 0.5 {
+		prototype, err = filter.Design(filter.Specification{
+			Type:       filter.Lowpass,
+			NumTaps:    numTaps,
+			Cutoff:     cutoff,
+			Window:     spectrum.WindowKaiser,
+			KaiserBeta: beta,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		prototype = []float64{1}
+	}
+
+	// The filter is padded to a multiple of the number of channels
+	length := (len(prototype) + channels - 1) / channels * channels
+	taps := make([]float32, length)
+	for n, tap := range prototype {
+		taps[length-1-n] = float32(tap)
+	}
+
+	// The inverse transform of the channel c is the forward transform at the bin N-c
+	bins := make([]int, channels)
+	for c := 1; c < channels; c++ {
+		bins[c] = channels - c
+	}
+
+	// The taps of each polyphase component are stored contiguously for the fold
+	perChannel := length / channels
+	branches := make([]float32, length)
+	for j := 0; j < channels; j++ {
+		for k := 0; k < perChannel; k++ {
+			branches[j*perChannel+k] = taps[j+k*channels]
+		}
+	}
+
+	twiddles := make([]complex128, channels)
+	for k := range twiddles {
+		twiddles[k] = cmplx.Rect(1, 2*math.Pi*float64(k)/float64(channels))
+	}
+
+	channelizer = &Channelizer{
+		options:   options,
+		fft:       fft,
+		taps:      taps,
+		branches:  branches,
+		length:    length,
+		enabled:   make([]bool, channels),
+		nbEnabled: channels,
+		twiddles:  twiddles,
+		bins:      bins,
+		history:   make([]complex64, length-1),
+		folded:    make([]complex128, channels),
+		outputs:   make([]complex128, channels),
+		buffers:   make([][]complex64, channels),
+	}
+	for channel := range channelizer.enabled {
+		channelizer.enabled[channel] = true
+	}
+
+	return channelizer, nil
+}
+
+// Options returns the options of the channelizer, completed with the default values
+func (channelizer *Channelizer) Options() Options {
+	return channelizer.options
+}
+
+// OutputRate returns the sample rate of the channels in samples per second
+func (channelizer *Channelizer) OutputRate() float64 {
+	return channelizer.options.SampleRate / float64(channelizer.options.Decimation)
+}
+
+// Frequency returns the center frequency of a channel.
+//
+// Params:
+//  - channel: the index of the channel
+//  - centerFrequency: the center frequency of the wideband stream in Hz
+//
+// Return the center frequency of the channel in Hz
+func (channelizer *Channelizer) Frequency(channel int, centerFrequency float64) float64 {
+
+	channels := channelizer.options.Channels
+	if channel >= (channels+1)/2 {
+		channel -= channels
+	}
+
+	return centerFrequency + float64(channel)*channelizer.options.SampleRate/float64(channels)
+}
+
+// Enable enables or disables a channel. The disabled channels are not computed.
+//
+// Params:
+//  - channel: the index of the channel
+//  - enabled: true to compute the channel
+func (channelizer *Channelizer) Enable(channel int, enabled bool) {
+
+	if channel < 0 || channel >= len(channelizer.enabled) || channelizer.enabled[channel] == enabled {
+		return
+	}
+
+	channelizer.enabled[channel] = enabled
+	if enabled {
+		channelizer.nbEnabled++
+	} else {
+		channelizer.nbEnabled--
+	}
+}
+
+// EnableAll enables or disables all the channels.
+//
+// Params:
+//  - enabled: true to compute all the channels, false to compute none
+func (channelizer *Channelizer) EnableAll(enabled bool) {
+
+	for channel := range channelizer.enabled {
+		channelizer.Enable(channel, enabled)
+	}
+}
+
+// Enabled returns true if a channel is enabled
+func (channelizer *Channelizer) Enabled(channel int) bool {
+	return channel >= 0 && channel < len(channelizer.enabled) && channelizer.enabled[channel]
+}
+
+// Process splits a block of the wideband stream.
+//
+// Params:
+//  - src: the samples of the wideband stream
+//  - timeNs: the time of the first sample in nanoseconds
+//  - hasTime: true if timeNs is valid
+//
+// Return a block by enabled channel, by increasing index, whose samples are valid until the next call
+func (channelizer *Channelizer) Process(src []complex64, timeNs int64, hasTime bool) []Block {
+
+	channels := channelizer.options.Channels
+	decimation := channelizer.options.Decimation
+
+	// The number of outputs of the block, one every decimation samples
+	nbOutputs := 0
+	if channelizer.next < len(src) {
+		nbOutputs = (len(src) - channelizer.next + decimation - 1) / decimation
+	}
+
+	// One block per enabled channel, reusing the buffers of the previous blocks
+	blocks := channelizer.blocks[:0]
+	for channel, enabled := range channelizer.enabled {
+		if enabled {
+			if cap(channelizer.buffers[channel]) < nbOutputs {
+				channelizer.buffers[channel] = make([]complex64, nbOutputs)
+			}
+			blocks = append(blocks, Block{Channel: channel, Samples: channelizer.buffers[channel][:nbOutputs]})
+		}
+	}
+
+	// The time of the first output, the time of the input ending its window minus the delay of the filter
+	if hasTime && channelizer.options.SampleRate > 0 {
+		delay := float64(len(channelizer.taps)-1) / 2 * 1e9 / channelizer.options.SampleRate
+		firstNs := timeNs + sdrtime.TicksToTimeNs64(int64(channelizer.next), channelizer.options.SampleRate) - int64(math.Round(delay))
+		for i := range blocks {
+			blocks[i].TimeNs = firstNs
+			blocks[i].HasTime = true
+		}
+	}
+
+	channelizer.work = append(append(channelizer.work[:0], channelizer.history...), src...)
+
+	// Use the FFT when it is cheaper than the direct transforms of the enabled channels
+	useFFT := float64(channelizer.nbEnabled) > 2*math.Log2(float64(channels)+1)
+	outputs := channelizer.outputs
+
+	for output := 0; output < nbOutputs; output++ {
+
+		window := channelizer.work[channelizer.next : channelizer.next+channelizer.length]
+		channelizer.fold(window)
+		channelizer.next += decimation
+
+		// The phase of the channels at the time of the output, 0 when critically sampled
+		rotation := int((channelizer.output * int64(decimation)) % int64(channels))
+		channelizer.output++
+
+		if useFFT {
+			channelizer.fft.Transform(outputs, channelizer.folded)
+		} else {
+			for i := range blocks {
+				outputs[channelizer.bins[blocks[i].Channel]] = channelizer.transform(blocks[i].Channel)
+			}
+		}
+
+		if rotation == 0 {
+			for i := range blocks {
+				blocks[i].Samples[output] = complex64(outputs[channelizer.bins[blocks[i].Channel]])
+			}
+			continue
+		}
+
+		for i := range blocks {
+			channel := blocks[i].Channel
+			blocks[i].Samples[output] = complex64(outputs[channelizer.bins[channel]] * cmplx.Conj(channelizer.twiddles[(channel*rotation)%channels]))
+		}
+	}
+	channelizer.next -= len(src)
+
+	copy(channelizer.history, channelizer.work[len(src):])
+	channelizer.blocks = blocks
+
+	return blocks
+}
+
+// Reset clears the state of the channelizer
+func (channelizer *Channelizer) Reset() {
+
+	channelizer.next = 0
+	channelizer.output = 0
+	for i := range channelizer.history {
+		channelizer.history[i] = 0
+	}
+}
+
+// fold weights a window of samples by the prototype filter and sums its polyphase components:
+// folded[p] = sum over k of h[p + kN] x[m - p - kN]
+func (channelizer *Channelizer) fold(window []complex64) {
+
+	channels := channelizer.options.Channels
+	perChannel := channelizer.length / channels
+	window = window[:channelizer.length]
+
+	// The sample j of each segment of N samples belongs to the component N-1-j, the taps of a component are contiguous
+	for j := 0; j < channels; j++ {
+		taps := channelizer.branches[j*perChannel : (j+1)*perChannel]
+		var sumRe, sumIm float32
+		index := j
+		for _, tap := range taps {
+			sample := window[index]
+			sumRe += real(sample) * tap
+			sumIm += imag(sample) * tap
+			index += channels
+		}
+		channelizer.folded[channels-1-j] = complex(float64(sumRe), float64(sumIm))
+	}
+}
+
+// transform computes a channel from the folded samples by a direct inverse transform
+func (channelizer *Channelizer) transform(channel int) complex128 {
+
+	channels := channelizer.options.Channels
+
+	var sum complex128
+	index := 0
+	for _, value := range channelizer.folded {
+		sum += value * channelizer.twiddles[index]
+		index += channel
+		if index >= channels {
+			index -= channels
+		}
+	}
+
+	return sum
+}
@@ -0,0 +1,129 @@
+package channelizer
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"testing"
+	"time"
+)
+
+// channelTone returns a tone of unit amplitude at the center of a channel
+func channelTone(channel int, channels int, length int) []complex64 {
+
+	tone := make([]complex64, length)
+	for i := range tone {
+		tone[i] = complex64(cmplx.Rect(1, 2*math.Pi*float64(channel)*float64(i)/float64(channels)))
+	}
+
+	return tone
+}
+
+// TestChannels checks that a tone at the center of each channel comes out of this channel only, as a constant of unit
+// amplitude, with the FFT and with the direct transforms, critically sampled and oversampled
+func TestChannels(t *testing.T) {
+
+	const channels = 16
+
+	for _, decimation := range []int{channels, channels / 2, channels / 4} {
+		for _, direct := range []bool{false, true} {
+			t.Run(fmt.Sprintf("decimation %d direct %v", decimation, direct), func(t *testing.T) {
+
+				for channel := 0; channel < channels; channel++ {
+
+					channelizer, err := New(Options{Channels: channels, Decimation: decimation, SampleRate: 1e6})
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					// The direct transforms are used when few channels are enabled
+					neighbours := []int{channel, (channel + 1) % channels, (channel + channels - 1) % channels}
+					if direct {
+						channelizer.EnableAll(false)
+						for _, neighbour := range neighbours {
+							channelizer.Enable(neighbour, true)
+						}
+					}
+
+					if useFFT := float64(channelizer.nbEnabled) > 2*math.Log2(channels+1); useFFT == direct {
+						t.Fatalf("channel %d: %d channels enabled, expected the FFT %v", channel, channelizer.nbEnabled, !direct)
+					}
+
+					// Skip the outputs of the filter filling up
+					skip := channelizer.length/decimation + 1
+
+					for _, block := range channelizer.Process(channelTone(channel, channels, 200*channels), 0, false) {
+
+						samples := block.Samples[skip:]
+
+						if block.Channel != channel {
+							for i, sample := range samples {
+								if level := 20 * math.Log10(cmplx.Abs(complex128(sample))); level > -60 {
+									t.Fatalf("tone of channel %d: sample %d of channel %d at %.1f dB", channel, i, block.Channel, level)
+								}
+							}
+							continue
+						}
+
+						// The tone is brought to 0 Hz, each output equals the previous one
+						for i, sample := range samples {
+							if amplitude := cmplx.Abs(complex128(sample)); math.Abs(amplitude-1) > 0.01 {
+								t.Fatalf("channel %d: amplitude %.4f at sample %d, expected 1", channel, amplitude, i)
+							}
+							if i > 0 && cmplx.Abs(complex128(sample-samples[i-1])) > 0.01 {
+								t.Fatalf("channel %d: sample %d is %v after %v, expected a constant", channel, i, sample, samples[i-1])
+							}
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkProcess measures the throughput of critically sampled channelizers with all their channels enabled, in
+// input samples per second
+func BenchmarkProcess(b *testing.B) {
+
+	for _, channels := range []int{16, 256, 1024} {
+		b.Run(fmt.Sprintf("%d channels", channels), func(b *testing.B) {
+
+			channelizer, err := New(Options{Channels: channels, SampleRate: 20e6})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			src := make([]complex64, 1<<16)
+			for i := range src {
+				src[i] = complex64(cmplx.Rect(1, 2*math.Pi*0.1234*float64(i)))
+			}
+
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				channelizer.Process(src, 0, false)
+			}
+			b.ReportMetric(float64(b.N*len(src))/time.Since(start).Seconds()/1e6, "Msps")
+		})
+	}
+}
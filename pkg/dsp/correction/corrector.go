@@ -0,0 +1,265 @@
+package correction
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the software corrections of the defects of the analog frontends: the DC offset and the IQ imbalance.
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+
+	"github.com/bhojpur/sdr/pkg/device"
+)
+
+const (
+	// DefaultDCTimeConstant is the time constant in samples of the estimation of the DC offset when none is given
+	DefaultDCTimeConstant = 1 << 16
+	// DefaultIQTimeConstant is the time constant in samples of the estimation of the IQ imbalance when none is given
+	DefaultIQTimeConstant = 1 << 18
+)
+
+// Options are the options of a corrector
+type Options struct {
+	// DCTimeConstant is the time constant in samples of the estimation of the DC offset, DefaultDCTimeConstant when 0
+	DCTimeConstant float64
+	// IQTimeConstant is the time constant in samples of the estimation of the IQ imbalance, DefaultIQTimeConstant when
+	// 0
+	IQTimeConstant float64
+	// DisableDC disables the estimation and the correction of the DC offset, when the hardware corrects it
+	DisableDC bool
+	// DisableIQ disables the estimation and the correction of the IQ imbalance
+	DisableIQ bool
+	// FullScale is the value of a full scale sample, the estimates being relative to it. 1 when 0.
+	FullScale float64
+}
+
+// Corrector estimates and removes the DC offset and the IQ imbalance of a stream of samples.
+//
+// The DC offset is the mean of the samples. The IQ imbalance is estimated blindly, assuming that the received signal
+// is proper (circular), as noise or the sum of several signals are: its image is the part of the samples correlated
+// with their conjugate. The imbalance y = m x + n conj(x) is corrected by y + b conj(y), with b = -n/conj(m).
+type Corrector struct {
+	options Options
+	alphaDC float64
+	alphaIQ float64
+
+	dc      complex128
+	moment  complex128
+	power   float64
+	balance complex128
+}
+
+// NewCorrector creates a corrector.
+//
+// Params:
+//  - options: the options of the corrector
+//
+// Return the corrector or an error
+func NewCorrector(options Options) (corrector *Corrector, err error) {
+
+	if options.DCTimeConstant == 0 {
+		options.DCTimeConstant = DefaultDCTimeConstant
+	}
+	if options.IQTimeConstant == 0 {
+		options.IQTimeConstant = DefaultIQTimeConstant
+	}
+	if options.FullScale == 0 {
+		options.FullScale = 1
+	}
+
+	if options.DCTimeConstant < 1 || options.IQTimeConstant < 1 {
+		return nil, errors.New("the time constants of a corrector must be at least one sample")
+	}
+
+	if !(options.FullScale > 0) {
+		return nil, errors.New("the full scale of a corrector must be positive")
+	}
+
+	return &Corrector{
+		options: options,
+		alphaDC: 1 / options.DCTimeConstant,
+		alphaIQ: 1 / options.IQTimeConstant,
+	}, nil
+}
+
+// Options returns the options of the corrector, completed with the default values
+func (corrector *Corrector) Options() Options {
+	return corrector.options
+}
+
+// Process estimates the defects from a block of samples and corrects them. The correction of the IQ imbalance uses
+// the estimate of the previous blocks.
+//
+// Params:
+//  - dst: the corrected samples, at least as long as the source. It can be the source.
+//  - src: the samples to correct
+func (corrector *Corrector) Process(dst []complex64, src []complex64) {
+
+	correctDC := !corrector.options.DisableDC
+	correctIQ := !corrector.options.DisableIQ
+	balance := corrector.balance
+
+	for i, value := range src {
+
+		sample := complex128(value)
+
+		if correctDC {
+			corrector.dc += complex(corrector.alphaDC, 0) * (sample - corrector.dc)
+			sample -= corrector.dc
+		}
+
+		if correctIQ {
+			corrector.moment += complex(corrector.alphaIQ, 0) * (sample*sample - corrector.moment)
+			corrector.power += corrector.alphaIQ * (real(sample)*real(sample) + imag(sample)*imag(sample) - corrector.power)
+			sample += balance * cmplx.Conj(sample)
+		}
+
+		dst[i] = complex64(sample)
+	}
+
+	if correctIQ {
+		corrector.updateBalance()
+	}
+}
+
+// DCOffset returns the correction of the DC offset, relative to the full scale, in the form of SetDCOffset: the value
+// added to the samples.
+//
+// Return the corrections of the I and Q components
+func (corrector *Corrector) DCOffset() (offsetI float64, offsetQ float64) {
+
+	correction := -corrector.dc / complex(corrector.options.FullScale, 0)
+
+	return real(correction), imag(correction)
+}
+
+// IQBalance returns the correction of the IQ imbalance in the form of SetIQBalance: the complex factor b of the
+// correction y + b conj(y).
+//
+// Return the real and imaginary parts of the correction
+func (corrector *Corrector) IQBalance() (balanceI float64, balanceQ float64) {
+	return real(corrector.balance), imag(corrector.balance)
+}
+
+// ImageRejection returns the image rejection ratio of the uncorrected samples in dB, the ratio of the power of a
+// signal and of its image, or +Inf if there is no imbalance
+func (corrector *Corrector) ImageRejection() float64 {
+	return -20 * math.Log10(cmplx.Abs(corrector.balance))
+}
+
+// Configure chooses between the hardware and software corrections of the DC offset of a RX channel of a device: the
+// automatic correction of the device if it has one, the software correction otherwise.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+//
+// Return true if the hardware corrects the DC offset, and an error
+func (corrector *Corrector) Configure(dev *device.SDRDevice, channel uint) (hardware bool, err error) {
+
+	if !dev.HasDCOffsetMode(device.DirectionRX, channel) {
+		corrector.options.DisableDC = false
+		return false, nil
+	}
+
+	if err := dev.SetDCOffsetMode(device.DirectionRX, channel, true); err != nil {
+		return false, err
+	}
+
+	corrector.options.DisableDC = true
+	corrector.dc = 0
+
+	return true, nil
+}
+
+// PushToHardware adds the software corrections to the corrections of a RX channel of a device, for the defects the
+// device can correct, and restarts their estimation. The samples read before the corrections of the device take
+// effect are corrected again, so the pushes should be rare, once the estimates are stable.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+//
+// Return an error or nil in case of success
+func (corrector *Corrector) PushToHardware(dev *device.SDRDevice, channel uint) error {
+
+	if !corrector.options.DisableDC && dev.HasDCOffset(device.DirectionRX, channel) {
+
+		offsetI, offsetQ, err := dev.GetDCOffset(device.DirectionRX, channel)
+		if err != nil {
+			return err
+		}
+
+		correctionI, correctionQ := corrector.DCOffset()
+		if err := dev.SetDCOffset(device.DirectionRX, channel, offsetI+correctionI, offsetQ+correctionQ); err != nil {
+			return err
+		}
+
+		corrector.dc = 0
+	}
+
+	if !corrector.options.DisableIQ && dev.HasIQBalance(device.DirectionRX, channel) {
+
+		balanceI, balanceQ, err := dev.GetIQBalance(device.DirectionRX, channel)
+		if err != nil {
+			return err
+		}
+
+		correctionI, correctionQ := corrector.IQBalance()
+		if err := dev.SetIQBalance(device.DirectionRX, channel, balanceI+correctionI, balanceQ+correctionQ); err != nil {
+			return err
+		}
+
+		corrector.moment = 0
+		corrector.balance = 0
+	}
+
+	return nil
+}
+
+// Reset restarts the estimations
+func (corrector *Corrector) Reset() {
+
+	corrector.dc = 0
+	corrector.moment = 0
+	corrector.power = 0
+	corrector.balance = 0
+}
+
+// updateBalance computes the correction of the IQ imbalance from the moments of the samples. With y = m x + n conj(x)
+// and a proper x, E[y^2] / E[|y|^2] = 2w / (1 + |w|^2) where w = n / conj(m).
+func (corrector *Corrector) updateBalance() {
+
+	if !(corrector.power > 0) {
+		return
+	}
+
+	ratio := corrector.moment / complex(corrector.power, 0)
+	magnitude := cmplx.Abs(ratio)
+	if magnitude >= 1 {
+		// A real signal, or no signal, the imbalance can not be estimated
+		return
+	}
+
+	w := ratio / complex(1+math.Sqrt(1-magnitude*magnitude), 0)
+	corrector.balance = -w
+}
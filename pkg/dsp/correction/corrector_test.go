@@ -0,0 +1,186 @@
+package correction
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// imbalanced returns complex gaussian noise x of a RMS amplitude through a frontend with an IQ imbalance and a DC
+// offset: m x + n conj(x) + dc
+func imbalanced(length int, amplitude float64, m complex128, n complex128, dc complex128) []complex64 {
+
+	random := rand.New(rand.NewSource(1))
+	scale := amplitude / math.Sqrt2
+
+	samples := make([]complex64, length)
+	for i := range samples {
+		x := complex(random.NormFloat64()*scale, random.NormFloat64()*scale)
+		samples[i] = complex64(m*x + n*cmplx.Conj(x) + dc)
+	}
+
+	return samples
+}
+
+// TestCorrector checks that the estimates converge to the DC offset and the IQ imbalance of the samples, and that the
+// corrected samples have no image
+func TestCorrector(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		m         complex128
+		n         complex128
+		dc        complex128
+		fullScale float64
+	}{
+		{"imbalance", cmplx.Rect(0.95, 0.05), complex(0.03, 0.02), complex(0.1, -0.05), 1},
+		{"no imbalance", 1, 0, complex(-0.02, 0.04), 1},
+		{"strong imbalance", cmplx.Rect(1.1, -0.2), complex(-0.1, 0.15), 0, 1},
+		{"16 bits samples", cmplx.Rect(0.95, 0.05), complex(0.03, 0.02), complex(0.1, -0.05), 32768},
+	}
+
+	for _, test := range tests {
+
+		corrector, err := NewCorrector(Options{
+			DCTimeConstant: 1 << 12,
+			IQTimeConstant: 1 << 16,
+			FullScale:      test.fullScale,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scale := complex(test.fullScale, 0)
+		samples := imbalanced(1<<20, 0.1*test.fullScale, test.m, test.n, test.dc*scale)
+		corrected := make([]complex64, len(samples))
+
+		for i := 0; i < len(samples); i += 4096 {
+			corrector.Process(corrected[i:i+4096], samples[i:i+4096])
+		}
+
+		offsetI, offsetQ := corrector.DCOffset()
+		if math.Abs(offsetI+real(test.dc)) > 5e-3 || math.Abs(offsetQ+imag(test.dc)) > 5e-3 {
+			t.Errorf("%s: DC offset correction %v%+vi, expected %v", test.name, offsetI, offsetQ, -test.dc)
+		}
+
+		expected := -test.n / cmplx.Conj(test.m)
+		balanceI, balanceQ := corrector.IQBalance()
+		if balance := complex(balanceI, balanceQ); cmplx.Abs(balance-expected) > 5e-3 {
+			t.Errorf("%s: IQ balance correction %v, expected %v", test.name, balance, expected)
+		}
+
+		rejection := 20 * math.Log10(cmplx.Abs(test.m)/cmplx.Abs(test.n))
+		if rejection < 40 {
+			if math.Abs(corrector.ImageRejection()-rejection) > 1 {
+				t.Errorf("%s: image rejection of %v dB, expected %v dB", test.name, corrector.ImageRejection(),
+					rejection)
+			}
+		} else if corrector.ImageRejection() < 40 {
+			t.Errorf("%s: image rejection of %v dB, expected at least 40 dB", test.name, corrector.ImageRejection())
+		}
+
+		// The corrected samples of the second half are proper, without DC
+		var mean, moment complex128
+		var power float64
+		half := corrected[len(corrected)/2:]
+		for _, value := range half {
+			sample := complex128(value)
+			mean += sample
+			moment += sample * sample
+			power += real(sample)*real(sample) + imag(sample)*imag(sample)
+		}
+		mean /= complex(float64(len(half)), 0)
+
+		if cmplx.Abs(mean/scale) > 5e-3 {
+			t.Errorf("%s: corrected mean of %v", test.name, mean)
+		}
+		if ratio := cmplx.Abs(moment) / power; ratio > 0.01 {
+			t.Errorf("%s: image of the corrected samples at %v dB", test.name, 10*math.Log10(ratio))
+		}
+	}
+}
+
+// TestCorrectorRealSignal checks that the imbalance is not estimated from a real signal, whose image can not be told
+// from the signal
+func TestCorrectorRealSignal(t *testing.T) {
+
+	corrector, err := NewCorrector(Options{DCTimeConstant: 1 << 10, IQTimeConstant: 1 << 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	samples := make([]complex64, 1<<16)
+	for i := range samples {
+		samples[i] = complex(float32(0.1*random.NormFloat64()), 0)
+	}
+
+	corrector.Process(samples, samples)
+
+	if balanceI, balanceQ := corrector.IQBalance(); balanceI != 0 || balanceQ != 0 {
+		t.Errorf("IQ balance correction %v%+vi estimated from a real signal", balanceI, balanceQ)
+	}
+	if !math.IsInf(corrector.ImageRejection(), 1) {
+		t.Errorf("image rejection of %v dB, expected +Inf", corrector.ImageRejection())
+	}
+
+	// Silence
+	corrector.Reset()
+	corrector.Process(make([]complex64, 1024), make([]complex64, 1024))
+	if balanceI, balanceQ := corrector.IQBalance(); balanceI != 0 || balanceQ != 0 {
+		t.Errorf("IQ balance correction %v%+vi estimated from silence", balanceI, balanceQ)
+	}
+}
+
+// TestCorrectorDisabled checks that the disabled corrections leave the samples unchanged
+func TestCorrectorDisabled(t *testing.T) {
+
+	corrector, err := NewCorrector(Options{DisableDC: true, DisableIQ: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := imbalanced(4096, 0.1, cmplx.Rect(0.9, 0.1), 0.05, 0.1)
+	corrected := make([]complex64, len(samples))
+	corrector.Process(corrected, samples)
+
+	for i := range samples {
+		if corrected[i] != samples[i] {
+			t.Fatalf("sample %d corrected from %v to %v", i, samples[i], corrected[i])
+		}
+	}
+
+	if offsetI, offsetQ := corrector.DCOffset(); offsetI != 0 || offsetQ != 0 {
+		t.Errorf("DC offset correction %v%+vi estimated while disabled", offsetI, offsetQ)
+	}
+	if balanceI, balanceQ := corrector.IQBalance(); balanceI != 0 || balanceQ != 0 {
+		t.Errorf("IQ balance correction %v%+vi estimated while disabled", balanceI, balanceQ)
+	}
+
+	for _, options := range []Options{{DCTimeConstant: 0.5}, {IQTimeConstant: -1}, {FullScale: -1}} {
+		if _, err := NewCorrector(options); err == nil {
+			t.Errorf("expected an error for the options %+v", options)
+		}
+	}
+}
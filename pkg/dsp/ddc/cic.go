@@ -0,0 +1,146 @@
+package ddc
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// cicBits is the number of bits of the registers of the CIC filters, minus a guard bit
+	cicBits = 62
+	// cicMinResolution is the minimum number of bits of the samples in the CIC filters
+	cicMinResolution = 16
+)
+
+// CIC is a cascaded integrator-comb decimator: a lowpass filter without multiplication, whose response droops over
+// the band of the output and must be compensated by the following filter. It runs on integers, whose wrapping is
+// harmless in the integrators.
+type CIC struct {
+	order       int
+	decimation  int
+	scale       float64
+	gain        float64
+	phase       int
+	integrators [][2]int64
+	delays      [][2]int64
+}
+
+// NewCIC creates a CIC decimator of complex samples normalized to 1.
+//
+// Params:
+//  - order: the number of integrator and comb stages, 4 or 5 usually
+//  - decimation: the decimation factor
+//
+// Return the decimator or an error if the growth of the registers leaves too few bits to the samples
+func NewCIC(order int, decimation int) (cic *CIC, err error) {
+
+	if order < 1 || decimation < 1 {
+		return nil, errors.New("the order and the decimation of a CIC filter must be at least 1")
+	}
+
+	// The registers grow by order*log2(decimation) bits
+	resolution := cicBits - int(math.Ceil(float64(order)*math.Log2(float64(decimation))))
+	if resolution < cicMinResolution {
+		return nil, errors.New("the decimation of the CIC filter is too high for its order")
+	}
+
+	scale := math.Ldexp(1, resolution)
+
+	return &CIC{
+		order:       order,
+		decimation:  decimation,
+		scale:       scale,
+		gain:        1 / (scale * math.Pow(float64(decimation), float64(order))),
+		integrators: make([][2]int64, order),
+		delays:      make([][2]int64, order),
+	}, nil
+}
+
+// Decimation returns the decimation factor
+func (cic *CIC) Decimation() int {
+	return cic.decimation
+}
+
+// Response returns the amplitude response of the filter, normalized to 1 at 0 Hz.
+//
+// Params:
+//  - frequency: the frequency, as a fraction of the output sample rate
+//
+// Return the gain
+func (cic *CIC) Response(frequency float64) float64 {
+
+	if frequency == 0 {
+		return 1
+	}
+
+	ratio := math.Sin(math.Pi*frequency) / (float64(cic.decimation) * math.Sin(math.Pi*frequency/float64(cic.decimation)))
+
+	return math.Pow(math.Abs(ratio), float64(cic.order))
+}
+
+// Filter decimates a block of samples and appends the outputs to a slice.
+//
+// Params:
+//  - dst: the slice receiving the outputs
+//  - src: the input samples
+//
+// Return the slice with the outputs appended
+func (cic *CIC) Filter(dst []complex64, src []complex64) []complex64 {
+
+	for _, sample := range src {
+
+		value := [2]int64{int64(float64(real(sample)) * cic.scale), int64(float64(imag(sample)) * cic.scale)}
+		for stage := range cic.integrators {
+			cic.integrators[stage][0] += value[0]
+			cic.integrators[stage][1] += value[1]
+			value = cic.integrators[stage]
+		}
+
+		cic.phase++
+		if cic.phase < cic.decimation {
+			continue
+		}
+		cic.phase = 0
+
+		for stage := range cic.delays {
+			previous := cic.delays[stage]
+			cic.delays[stage] = value
+			value[0] -= previous[0]
+			value[1] -= previous[1]
+		}
+
+		dst = append(dst, complex(float32(float64(value[0])*cic.gain), float32(float64(value[1])*cic.gain)))
+	}
+
+	return dst
+}
+
+// Reset clears the state of the filter
+func (cic *CIC) Reset() {
+
+	cic.phase = 0
+	for stage := range cic.integrators {
+		cic.integrators[stage] = [2]int64{}
+		cic.delays[stage] = [2]int64{}
+	}
+}
@@ -0,0 +1,80 @@
+package ddc
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"testing"
+)
+
+// TestCIC checks the gain of the CIC filters against their response
+func TestCIC(t *testing.T) {
+
+	for _, decimation := range []int{2, 10, 64} {
+
+		cic, err := NewCIC(DefaultCICOrder, decimation)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, frequency := range []float64{0, 0.1, 0.25, 0.4} {
+
+			cic.Reset()
+			outputs := cic.Filter(nil, tone(frequency/float64(decimation), 400*decimation))
+			if len(outputs) != 400 {
+				t.Fatalf("decimation %d: %d outputs", decimation, len(outputs))
+			}
+
+			// The output rate is the rate of the tone after the decimation
+			amplitude := toneAmplitude(outputs[DefaultCICOrder+1:], frequency)
+			if expected := cic.Response(frequency); math.Abs(amplitude-expected) > 1e-4 {
+				t.Errorf("decimation %d: gain %v at %v instead of %v", decimation, amplitude, frequency, expected)
+			}
+		}
+	}
+
+	if _, err := NewCIC(8, 1<<10); err == nil {
+		t.Error("a CIC filter without enough bits was created")
+	}
+}
+
+// TestNCO checks that the oscillator shifts a tone with a continuous phase from a block to the next
+func TestNCO(t *testing.T) {
+
+	input := tone(0.1, 10000)
+	expected := tone(-0.05, 10000)
+
+	nco := NewNCO(-0.15)
+	outputs := make([]complex64, len(input))
+	for start := 0; start < len(input); start += 999 {
+		end := start + 999
+		if end > len(input) {
+			end = len(input)
+		}
+		nco.Mix(outputs[start:end], input[start:end])
+	}
+
+	for i := range outputs {
+		if difference := outputs[i] - expected[i]; math.Hypot(float64(real(difference)), float64(imag(difference))) > 1e-4 {
+			t.Fatalf("sample %d is %v instead of %v", i, outputs[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package ddc
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/dsp/filter"
+	"github.com/bhojpur/sdr/pkg/dsp/resample"
+	"github.com/bhojpur/sdr/pkg/dsp/spectrum"
+)
+
+const (
+	// DefaultCICOrder is the order of the CIC filter when none is given
+	DefaultCICOrder = 4
+	// DefaultBandwidthRatio is the ratio of the bandwidth of the channel to the output rate when none is given
+	DefaultBandwidthRatio = 0.8
+	// DefaultAttenuation is the attenuation in dB of the signals outside the channel when none is given
+	DefaultAttenuation = 80
+
+	// maxFIRDecimation is the largest decimation done by the FIR filter alone
+	maxFIRDecimation = 8
+	// farrowOversampling is the minimum ratio of the rate of the channel and the output rate before a Farrow resampler
+	farrowOversampling = 8
+)
+
+// firDecimations are the decimations of the FIR filter following a CIC filter, by order of preference
+var firDecimations = []int{4, 5, 6, 8, 7, 3, 2}
+
+// Options are the options of a downconverter
+type Options struct {
+	// InputRate is the sample rate of the wideband stream in samples per second
+	InputRate float64
+	// CenterFrequency is the frequency the device is tuned to in Hz
+	CenterFrequency float64
+	// Frequency is the absolute center frequency of the channel in Hz
+	Frequency float64
+	// OutputRate is the sample rate of the channel in samples per second
+	OutputRate float64
+	// Bandwidth is the bandwidth of the channel in Hz, DefaultBandwidthRatio * OutputRate when 0
+	Bandwidth float64
+	// Attenuation is the attenuation in dB of the signals outside the channel, DefaultAttenuation when 0
+	Attenuation float64
+	// CICOrder is the order of the CIC filter, DefaultCICOrder when 0
+	CICOrder int
+}
+
+// FromDevice sets the input rate and the center frequency of the options from a RX channel of a device.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+func (options *Options) FromDevice(dev *device.SDRDevice, channel uint) {
+
+	options.InputRate = dev.GetSampleRate(device.DirectionRX, channel)
+	options.CenterFrequency = dev.GetFrequency(device.DirectionRX, channel)
+}
+
+// DDC is a digital downconverter extracting a channel from a wideband stream. An NCO shifts the channel to 0 Hz, a CIC
+// filter does most of the decimation, a FIR filter compensates its droop, selects the channel and does the rest of the
+// integer decimation, and a resampler gives the output rate if it is not an integer fraction of the input rate. When
+// the ratio is not a fraction of small integers, the channel is kept oversampled for the arbitrary ratio resampler.
+type DDC struct {
+	options   Options
+	nco       *NCO
+	cic       *CIC
+	fir       *filter.ComplexFIR
+	resampler resample.Resampler
+
+	mixed     []complex64
+	decimated []complex64
+	filtered  []complex64
+}
+
+// New creates a downconverter.
+//
+// Params:
+//  - options: the options of the downconverter
+//
+// Return the downconverter or an error
+func New(options Options) (ddc *DDC, err error) {
+
+	if options.Bandwidth == 0 {
+		options.Bandwidth = DefaultBandwidthRatio * options.OutputRate
+	}
+	if options.Attenuation == 0 {
+		options.Attenuation = DefaultAttenuation
+	}
+	if options.CICOrder == 0 {
+		options.CICOrder = DefaultCICOrder
+	}
+
+	if !(options.InputRate > 0) || !(options.OutputRate > 0) || options.OutputRate > options.InputRate {
+		return nil, errors.New("the output rate of a downconverter must be positive and below its input rate")
+	}
+
+	if !(options.Bandwidth > 0 && options.Bandwidth < options.OutputRate) {
+		return nil, errors.New("the bandwidth of a channel must be positive and below its output rate")
+	}
+
+	ddc = &DDC{
+		options: options,
+		nco:     NewNCO(0),
+	}
+
+	if err := ddc.SetFrequency(options.Frequency); err != nil {
+		return nil, err
+	}
+
+	// Integer decimation, the resampler does the rest
+	decimation := int(math.Floor(options.InputRate/options.OutputRate + 1e-9))
+	cicDecimation, firDecimation := decimationFactors(decimation)
+	decimation = cicDecimation * firDecimation
+	rate := options.InputRate / float64(decimation)
+
+	plan, err := resample.PlanRate(device.SDRRanges{{Minimum: rate, Maximum: rate}}, options.OutputRate, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cubic interpolation of an arbitrary ratio is accurate for the signals occupying a fraction of the band, the
+	// channel is oversampled before it
+	if !plan.Exact() && decimation > 1 {
+		decimation = int(math.Max(math.Floor(options.InputRate/(farrowOversampling*options.OutputRate)), 1))
+		cicDecimation, firDecimation = decimationFactors(decimation)
+		decimation = cicDecimation * firDecimation
+		rate = options.InputRate / float64(decimation)
+		if plan, err = resample.PlanRate(device.SDRRanges{{Minimum: rate, Maximum: rate}}, options.OutputRate, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if cicDecimation > 1 {
+		if ddc.cic, err = NewCIC(options.CICOrder, cicDecimation); err != nil {
+			return nil, err
+		}
+	}
+
+	if ddc.fir, err = ddc.designFIR(rate, firDecimation); err != nil {
+		return nil, err
+	}
+
+	if ddc.resampler, err = plan.NewResampler(options.Attenuation); err != nil {
+		return nil, err
+	}
+
+	return ddc, nil
+}
+
+// Options returns the options of the downconverter, completed with the default values and the last frequencies
+func (ddc *DDC) Options() Options {
+	return ddc.options
+}
+
+// OutputRate returns the sample rate of the channel in samples per second
+func (ddc *DDC) OutputRate() float64 {
+	return ddc.options.OutputRate
+}
+
+// Offset returns the frequency of the channel relatively to the center frequency of the device in Hz
+func (ddc *DDC) Offset() float64 {
+	return ddc.options.Frequency - ddc.options.CenterFrequency
+}
+
+// SetFrequency moves the channel to another absolute frequency.
+//
+// Params:
+//  - frequency: the absolute center frequency of the channel in Hz
+//
+// Return an error if the channel is not inside the band of the device
+func (ddc *DDC) SetFrequency(frequency float64) error {
+	return ddc.retune(ddc.options.CenterFrequency, frequency)
+}
+
+// SetCenterFrequency follows a retuning of the device, keeping the absolute frequency of the channel.
+//
+// Params:
+//  - centerFrequency: the frequency the device is tuned to in Hz
+//
+// Return an error if the channel is no more inside the band of the device
+func (ddc *DDC) SetCenterFrequency(centerFrequency float64) error {
+	return ddc.retune(centerFrequency, ddc.options.Frequency)
+}
+
+// Follow reads the frequency of a RX channel of a device, to keep the absolute frequency of the channel after a
+// retuning of the device.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+//
+// Return an error if the channel is no more inside the band of the device
+func (ddc *DDC) Follow(dev *device.SDRDevice, channel uint) error {
+	return ddc.SetCenterFrequency(dev.GetFrequency(device.DirectionRX, channel))
+}
+
+// Tune tunes a RX channel of a device and follows the frequency actually set, keeping the absolute frequency of the
+// channel.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+//  - centerFrequency: the frequency to tune the device to in Hz
+//  - args: optional tuner arguments, see SetFrequency
+//
+// Return an error if the tuning fails or the channel is no more inside the band of the device
+func (ddc *DDC) Tune(dev *device.SDRDevice, channel uint, centerFrequency float64, args map[string]string) error {
+
+	if err := dev.SetFrequency(device.DirectionRX, channel, centerFrequency, args); err != nil {
+		return err
+	}
+
+	return ddc.Follow(dev, channel)
+}
+
+// Process extracts the channel from a block of samples and appends its samples to a slice, for example
+// out = ddc.Process(out[:0], in).
+//
+// Params:
+//  - dst: the slice receiving the samples of the channel
+//  - src: the samples of the wideband stream
+//
+// Return the slice with the samples of the channel appended
+func (ddc *DDC) Process(dst []complex64, src []complex64) []complex64 {
+
+	if cap(ddc.mixed) < len(src) {
+		ddc.mixed = make([]complex64, len(src))
+	}
+	samples := ddc.mixed[:len(src)]
+	ddc.nco.Mix(samples, src)
+
+	if ddc.cic != nil {
+		ddc.decimated = ddc.cic.Filter(ddc.decimated[:0], samples)
+		samples = ddc.decimated
+	}
+
+	if ddc.resampler == nil {
+		return ddc.fir.Filter(dst, samples)
+	}
+
+	ddc.filtered = ddc.fir.Filter(ddc.filtered[:0], samples)
+
+	return ddc.resampler.Resample(dst, ddc.filtered)
+}
+
+// Reset clears the state of the filters of the downconverter
+func (ddc *DDC) Reset() {
+
+	ddc.nco.Reset()
+	if ddc.cic != nil {
+		ddc.cic.Reset()
+	}
+	ddc.fir.Reset()
+	if ddc.resampler != nil {
+		ddc.resampler.Reset()
+	}
+}
+
+// retune sets the frequencies of the downconverter and the frequency of its NCO
+func (ddc *DDC) retune(centerFrequency float64, frequency float64) error {
+
+	offset := frequency - centerFrequency
+	if math.Abs(offset)+ddc.options.Bandwidth/2 > ddc.options.InputRate/2 {
+		return fmt.Errorf("the channel at %v Hz is outside the band of the device tuned to %v Hz", frequency, centerFrequency)
+	}
+
+	ddc.options.CenterFrequency = centerFrequency
+	ddc.options.Frequency = frequency
+	ddc.nco.SetFrequency(-offset / ddc.options.InputRate)
+
+	return nil
+}
+
+// designFIR designs the FIR filter selecting the channel, compensating the droop of the CIC filter
+func (ddc *DDC) designFIR(rate float64, decimation int) (*filter.ComplexFIR, error) {
+
+	inputRate := rate * float64(decimation)
+	halfBandwidth := ddc.options.Bandwidth / 2
+
+	// The aliases of the decimation may fall in the transition band but not in the channel
+	pass := halfBandwidth / inputRate
+	stop := math.Min((rate-halfBandwidth)/inputRate, 0.5)
+
+	numTaps, beta := filter.KaiserParameters(ddc.options.Attenuation, stop-pass)
+
+	var taps []float64
+	var err error
+
+	if ddc.cic == nil {
+		taps, err = filter.Design(filter.Specification{
+			Type:       filter.Lowpass,
+			NumTaps:    numTaps,
+			Cutoff:     (pass + stop) / 2,
+			Window:     spectrum.WindowKaiser,
+			KaiserBeta: beta,
+		})
+	} else {
+		// The window spreads the edge of the ideal response over the transition band
+		cic := ddc.cic
+		cutoff := (pass + stop) / 2
+		taps, err = filter.DesignResponse(numTaps, func(frequency float64) float64 {
+			if frequency < cutoff {
+				return 1 / cic.Response(frequency)
+			}
+			return 0
+		}, spectrum.WindowKaiser, beta)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.NewComplexDecimator(taps, decimation)
+}
+
+// decimationFactors splits an integer decimation between the CIC and FIR filters, reducing it if it can not be split
+func decimationFactors(decimation int) (cicDecimation int, firDecimation int) {
+
+	if decimation <= maxFIRDecimation {
+		return 1, decimation
+	}
+
+	for ; decimation > maxFIRDecimation; decimation-- {
+		for _, firDecimation := range firDecimations {
+			if decimation%firDecimation == 0 {
+				return decimation / firDecimation, firDecimation
+			}
+		}
+	}
+
+	return 1, decimation
+}
@@ -0,0 +1,156 @@
+package ddc
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// tone returns samples of a complex tone of amplitude 1
+func tone(frequency float64, count int) []complex64 {
+
+	samples := make([]complex64, count)
+	for i := range samples {
+		samples[i] = complex64(cmplx.Rect(1, 2*math.Pi*frequency*float64(i)))
+	}
+
+	return samples
+}
+
+// toneAmplitude returns the amplitude of a tone in samples
+func toneAmplitude(samples []complex64, frequency float64) float64 {
+
+	correlation := complex128(0)
+	for i, sample := range samples {
+		correlation += complex128(sample) * cmplx.Rect(1, -2*math.Pi*frequency*float64(i))
+	}
+
+	return cmplx.Abs(correlation) / float64(len(samples))
+}
+
+// rms returns the root mean square of samples
+func rms(samples []complex64) float64 {
+
+	sum := 0.0
+	for _, sample := range samples {
+		sum += math.Pow(cmplx.Abs(complex128(sample)), 2)
+	}
+
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// TestDDC checks that the tones of the channel are shifted and kept, and the tones outside it removed, for the CIC,
+// FIR and resampler paths
+func TestDDC(t *testing.T) {
+
+	const centerFrequency = 100e6
+
+	tests := []struct {
+		inputRate  float64
+		outputRate float64
+	}{
+		// FIR filter only
+		{1e6, 250e3},
+		// CIC and FIR filters
+		{2.4e6, 48e3},
+		// Resampler after the filters
+		{2.4e6, 44.1e3},
+	}
+
+	for _, test := range tests {
+
+		frequency := centerFrequency + 0.3*test.inputRate
+
+		for _, offset := range []float64{0, 0.2, -0.3, 0.7, -1.5} {
+
+			ddc, err := New(Options{
+				InputRate:       test.inputRate,
+				CenterFrequency: centerFrequency,
+				Frequency:       frequency,
+				OutputRate:      test.outputRate,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The tone is at an offset from the channel, as a fraction of the output rate
+			input := tone((frequency+offset*test.outputRate-centerFrequency)/test.inputRate, int(test.inputRate/10))
+			var outputs []complex64
+			for start := 0; start < len(input); start += 4096 {
+				end := start + 4096
+				if end > len(input) {
+					end = len(input)
+				}
+				outputs = ddc.Process(outputs, input[start:end])
+			}
+
+			if expected := float64(len(input)) * test.outputRate / test.inputRate; math.Abs(float64(len(outputs))-expected) > 2 {
+				t.Fatalf("%v to %v: %d outputs instead of %v", test.inputRate, test.outputRate, len(outputs), expected)
+			}
+
+			// The transients of the filters are skipped
+			outputs = outputs[len(outputs)/4:]
+
+			if math.Abs(offset) < DefaultBandwidthRatio/2 {
+				if amplitude := toneAmplitude(outputs, offset); math.Abs(20*math.Log10(amplitude)) > 0.1 {
+					t.Errorf("%v to %v: tone at %v of the output rate with an amplitude of %v", test.inputRate,
+						test.outputRate, offset, amplitude)
+				}
+			} else {
+				if level := 20 * math.Log10(rms(outputs)); level > -DefaultAttenuation+10 {
+					t.Errorf("%v to %v: tone at %v of the output rate at %.1f dB", test.inputRate, test.outputRate,
+						offset, level)
+				}
+			}
+		}
+	}
+}
+
+// TestDDCFrequency checks the frequencies of the channel and the channels outside the band of the device
+func TestDDCFrequency(t *testing.T) {
+
+	ddc, err := New(Options{InputRate: 1e6, CenterFrequency: 100e6, Frequency: 100.2e6, OutputRate: 100e3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ddc.Offset() != 0.2e6 {
+		t.Errorf("offset of %v Hz instead of 200 kHz", ddc.Offset())
+	}
+
+	// The channel keeps its absolute frequency when the device is retuned
+	if err := ddc.SetCenterFrequency(100.1e6); err != nil {
+		t.Fatal(err)
+	}
+	if ddc.Options().Frequency != 100.2e6 || math.Abs(ddc.Offset()-0.1e6) > 1e-6 {
+		t.Errorf("channel at %v Hz with an offset of %v Hz after retuning", ddc.Options().Frequency, ddc.Offset())
+	}
+
+	if err := ddc.SetFrequency(100.59e6); err == nil {
+		t.Error("a channel outside the band of the device was accepted")
+	}
+
+	if _, err := New(Options{InputRate: 1e6, CenterFrequency: 100e6, Frequency: 100e6, OutputRate: 2e6}); err == nil {
+		t.Error("an output rate above the input rate was accepted")
+	}
+}
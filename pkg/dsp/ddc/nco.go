@@ -0,0 +1,85 @@
+package ddc
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the digital downconverters extracting narrowband channels from a wideband RX stream: a numerically
+// controlled oscillator shifting the channel to 0 Hz, then a CIC and FIR decimation chain. It also groups the offset
+// tuning of the receivers, keeping the signal of interest away from the LO.
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// NCO is a numerically controlled oscillator shifting the frequency of a stream of samples. Its phase is kept from a
+// block to the next and when its frequency changes.
+type NCO struct {
+	frequency float64
+	phase     float64
+}
+
+// NewNCO creates an oscillator.
+//
+// Params:
+//  - frequency: the frequency shift, as a fraction of the sample rate
+//
+// Return the oscillator
+func NewNCO(frequency float64) *NCO {
+	return &NCO{frequency: frequency}
+}
+
+// SetFrequency changes the frequency shift of the oscillator, keeping its phase continuous.
+//
+// Params:
+//  - frequency: the frequency shift, as a fraction of the sample rate
+func (nco *NCO) SetFrequency(frequency float64) {
+	nco.frequency = frequency
+}
+
+// Frequency returns the frequency shift of the oscillator, as a fraction of the sample rate
+func (nco *NCO) Frequency() float64 {
+	return nco.frequency
+}
+
+// Mix shifts the frequency of a block of samples. The destination can be the source.
+//
+// Params:
+//  - dst: the shifted samples, at least as long as the source
+//  - src: the samples to shift
+func (nco *NCO) Mix(dst []complex64, src []complex64) {
+
+	// The phasor rotates from the exact phase of the start of the block, the phase is accumulated in cycles
+	phasor := cmplx.Rect(1, 2*math.Pi*nco.phase)
+	step := cmplx.Rect(1, 2*math.Pi*nco.frequency)
+
+	for i, sample := range src {
+		dst[i] = complex64(complex128(sample) * phasor)
+		phasor *= step
+	}
+
+	cycles := nco.phase + nco.frequency*float64(len(src))
+	nco.phase = cycles - math.Floor(cycles)
+}
+
+// Reset sets the phase of the oscillator to 0
+func (nco *NCO) Reset() {
+	nco.phase = 0
+}
@@ -0,0 +1,245 @@
+package ddc
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+)
+
+// OffsetMode is the way an offset tuner moves the LO of the device away from the signal
+type OffsetMode int
+
+const (
+	// OffsetAuto selects the first mode supported by the device: OffsetArgs, OffsetComponents, then OffsetSoftware
+	OffsetAuto OffsetMode = iota
+	// OffsetArgs tunes with the "OFFSET" tuner argument: the driver tunes the RF frontend off the signal and brings
+	// the signal back to the center of the stream with its baseband DSP
+	OffsetArgs
+	// OffsetComponents tunes the "RF" and "BB" frequency components: the RF frontend off the signal and the baseband
+	// DSP back to the signal
+	OffsetComponents
+	// OffsetSoftware tunes the device off the signal and brings the signal back to the center of the stream with a
+	// software oscillator, see Correct
+	OffsetSoftware
+)
+
+// String returns a human readable name of the offset mode
+func (mode OffsetMode) String() string {
+
+	switch mode {
+	case OffsetAuto:
+		return "auto"
+	case OffsetArgs:
+		return "args"
+	case OffsetComponents:
+		return "components"
+	case OffsetSoftware:
+		return "software"
+	}
+
+	return fmt.Sprintf("OffsetMode(%d)", int(mode))
+}
+
+// frequencyTuner sets the frequencies of the channels. It is implemented by *device.SDRDevice.
+type frequencyTuner interface {
+	SetFrequency(direction device.Direction, channel uint, frequency float64, args map[string]string) sdrerror.SDRError
+	GetFrequency(direction device.Direction, channel uint) float64
+	SetFrequencyComponent(direction device.Direction, channel uint, name string, frequency float64, args map[string]string) sdrerror.SDRError
+	GetFrequencyComponent(direction device.Direction, channel uint, name string) float64
+}
+
+// OffsetTuner tunes a RX channel of a device with its LO away from the signal of interest, so that the DC spike and
+// the LO leakage of zero-IF receivers fall outside of the signal. The signal is at the center of the stream, after
+// Correct.
+type OffsetTuner struct {
+	dev        frequencyTuner
+	channel    uint
+	offset     float64
+	sampleRate float64
+	mode       OffsetMode
+	components bool
+	nco        *NCO
+
+	frequency   float64
+	loFrequency float64
+}
+
+// NewOffsetTuner creates an offset tuner of a RX channel of a device. The sample rate of the channel must be set
+// before, and the tuner must be created again when it changes.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+//  - offset: the frequency of the LO relative to the signal in Hz, within half the sample rate
+//  - mode: the offset mode, OffsetAuto to select the first mode supported by the device
+//
+// Return the tuner or an error
+func NewOffsetTuner(dev *device.SDRDevice, channel uint, offset float64, mode OffsetMode) (tuner *OffsetTuner, err error) {
+
+	sampleRate := dev.GetSampleRate(device.DirectionRX, channel)
+	if sampleRate <= 0 {
+		return nil, errors.New("the sample rate of the channel must be set before an offset tuner is created")
+	}
+
+	if offset == 0 || math.Abs(offset) >= sampleRate/2 {
+		return nil, fmt.Errorf("the offset of the LO must be non zero and within half the sample rate of %v Hz", sampleRate)
+	}
+
+	components := hasOffsetComponents(dev, channel)
+
+	if mode == OffsetAuto {
+		mode = OffsetSoftware
+		if hasOffsetArg(dev, channel) {
+			mode = OffsetArgs
+		} else if components {
+			mode = OffsetComponents
+		}
+	}
+
+	switch mode {
+	case OffsetArgs, OffsetSoftware:
+	case OffsetComponents:
+		if !components {
+			return nil, errors.New("the channel does not have RF and BB frequency components")
+		}
+	default:
+		return nil, fmt.Errorf("unknown offset mode %v", mode)
+	}
+
+	return &OffsetTuner{
+		dev:        dev,
+		channel:    channel,
+		offset:     offset,
+		sampleRate: sampleRate,
+		mode:       mode,
+		components: components,
+		nco:        NewNCO(0),
+	}, nil
+}
+
+// Mode returns the offset mode of the tuner
+func (tuner *OffsetTuner) Mode() OffsetMode {
+	return tuner.mode
+}
+
+// Tune tunes the channel to a signal, with the LO at the offset of the tuner from the signal. In software mode, the
+// shift of the oscillator is computed from the frequency actually set, so that the frequency of the signal is kept
+// whatever the resolution of the tuner of the device, and the phase of the oscillator is kept across the retunings.
+//
+// Params:
+//  - frequency: the frequency of the signal in Hz
+//
+// Return the effective frequency of the signal at the center of the stream, or an error
+func (tuner *OffsetTuner) Tune(frequency float64) (effective float64, err error) {
+
+	switch tuner.mode {
+	case OffsetArgs:
+		args := map[string]string{"OFFSET": strconv.FormatFloat(tuner.offset, 'f', -1, 64)}
+		if err := tuner.dev.SetFrequency(device.DirectionRX, tuner.channel, frequency, args); err != nil {
+			return 0, err
+		}
+		tuner.frequency = tuner.dev.GetFrequency(device.DirectionRX, tuner.channel)
+		tuner.loFrequency = tuner.frequency + tuner.offset
+		if tuner.components {
+			tuner.loFrequency = tuner.dev.GetFrequencyComponent(device.DirectionRX, tuner.channel, "RF")
+		}
+
+	case OffsetComponents:
+		if err := tuner.dev.SetFrequencyComponent(device.DirectionRX, tuner.channel, "RF", frequency+tuner.offset, nil); err != nil {
+			return 0, err
+		}
+		tuner.loFrequency = tuner.dev.GetFrequencyComponent(device.DirectionRX, tuner.channel, "RF")
+		if err := tuner.dev.SetFrequencyComponent(device.DirectionRX, tuner.channel, "BB", frequency-tuner.loFrequency, nil); err != nil {
+			return 0, err
+		}
+		tuner.frequency = tuner.dev.GetFrequency(device.DirectionRX, tuner.channel)
+
+	case OffsetSoftware:
+		if err := tuner.dev.SetFrequency(device.DirectionRX, tuner.channel, frequency+tuner.offset, nil); err != nil {
+			return 0, err
+		}
+		loFrequency := tuner.dev.GetFrequency(device.DirectionRX, tuner.channel)
+		if math.Abs(loFrequency-frequency) >= tuner.sampleRate/2 {
+			return 0, fmt.Errorf("the device was tuned to %v Hz, too far from the signal at %v Hz", loFrequency, frequency)
+		}
+		tuner.loFrequency = loFrequency
+		tuner.nco.SetFrequency((loFrequency - frequency) / tuner.sampleRate)
+		tuner.frequency = loFrequency - tuner.nco.Frequency()*tuner.sampleRate
+	}
+
+	return tuner.frequency, nil
+}
+
+// Frequency returns the effective frequency of the signal at the center of the stream in Hz
+func (tuner *OffsetTuner) Frequency() float64 {
+	return tuner.frequency
+}
+
+// LOFrequency returns the frequency of the LO of the RF frontend in Hz, where the DC spike is
+func (tuner *OffsetTuner) LOFrequency() float64 {
+	return tuner.loFrequency
+}
+
+// Correct brings the signal to the center of a block of samples of the stream. It shifts the samples in software
+// mode, and copies them otherwise. The destination can be the source.
+//
+// Params:
+//  - dst: the corrected samples, at least as long as the source
+//  - src: the samples of the stream
+func (tuner *OffsetTuner) Correct(dst []complex64, src []complex64) {
+
+	if tuner.mode == OffsetSoftware {
+		tuner.nco.Mix(dst, src)
+		return
+	}
+
+	copy(dst, src)
+}
+
+// hasOffsetArg returns true if the tuner of a RX channel of a device accepts the "OFFSET" argument
+func hasOffsetArg(dev *device.SDRDevice, channel uint) bool {
+
+	for _, info := range dev.GetFrequencyArgsInfo(device.DirectionRX, channel) {
+		if info.Key == "OFFSET" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasOffsetComponents returns true if a RX channel of a device has the "RF" and "BB" frequency components
+func hasOffsetComponents(dev *device.SDRDevice, channel uint) bool {
+
+	rf, bb := false, false
+	for _, name := range dev.ListFrequencies(device.DirectionRX, channel) {
+		rf = rf || name == "RF"
+		bb = bb || name == "BB"
+	}
+
+	return rf && bb
+}
@@ -0,0 +1,221 @@
+package ddc
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"strconv"
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+)
+
+// fakeTuner is a tuner with a RF frontend of a given resolution and a baseband DSP of any frequency. The frequency of
+// the channel is the sum of their frequencies.
+type fakeTuner struct {
+	resolution float64
+	rf         float64
+	bb         float64
+}
+
+func (tuner *fakeTuner) SetFrequency(direction device.Direction, channel uint, frequency float64, args map[string]string) sdrerror.SDRError {
+
+	offset := 0.0
+	if value, ok := args["OFFSET"]; ok {
+		offset, _ = strconv.ParseFloat(value, 64)
+	}
+
+	tuner.rf = math.Round((frequency+offset)/tuner.resolution) * tuner.resolution
+	tuner.bb = 0
+	if offset != 0 {
+		tuner.bb = frequency - tuner.rf
+	}
+
+	return nil
+}
+
+func (tuner *fakeTuner) GetFrequency(direction device.Direction, channel uint) float64 {
+	return tuner.rf + tuner.bb
+}
+
+func (tuner *fakeTuner) SetFrequencyComponent(direction device.Direction, channel uint, name string, frequency float64, args map[string]string) sdrerror.SDRError {
+
+	if name == "RF" {
+		tuner.rf = math.Round(frequency/tuner.resolution) * tuner.resolution
+	} else {
+		tuner.bb = frequency
+	}
+
+	return nil
+}
+
+func (tuner *fakeTuner) GetFrequencyComponent(direction device.Direction, channel uint, name string) float64 {
+
+	if name == "RF" {
+		return tuner.rf
+	}
+
+	return tuner.bb
+}
+
+// TestOffsetTuner checks the frequency of the signal and of the LO after tuning, whatever the resolution of the RF
+// frontend
+func TestOffsetTuner(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		mode       OffsetMode
+		components bool
+		resolution float64
+		frequency  float64
+		lo         float64
+	}{
+		{"software", OffsetSoftware, false, 1, 100e6, 100.25e6},
+		{"software, quantized LO", OffsetSoftware, false, 1e3, 100.0006e6, 100.251e6},
+		{"software, coarse LO", OffsetSoftware, false, 100e3, 433.92e6, 434.2e6},
+		{"args", OffsetArgs, false, 1e3, 100.0006e6, 100.2506e6},
+		{"args with components", OffsetArgs, true, 1e3, 100.0006e6, 100.251e6},
+		{"components", OffsetComponents, true, 1e3, 100.0006e6, 100.251e6},
+	}
+
+	for _, test := range tests {
+
+		tuner := &OffsetTuner{
+			dev:        &fakeTuner{resolution: test.resolution},
+			offset:     250e3,
+			sampleRate: 1e6,
+			mode:       test.mode,
+			components: test.components,
+			nco:        NewNCO(0),
+		}
+
+		frequency, err := tuner.Tune(test.frequency)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if math.Abs(frequency-test.frequency) > 1e-3 || tuner.Frequency() != frequency {
+			t.Errorf("%s: tuned to %v Hz (%v Hz), expected %v Hz", test.name, frequency, tuner.Frequency(),
+				test.frequency)
+		}
+		if math.Abs(tuner.LOFrequency()-test.lo) > 1e-3 {
+			t.Errorf("%s: LO at %v Hz, expected %v Hz", test.name, tuner.LOFrequency(), test.lo)
+		}
+	}
+}
+
+// TestOffsetTunerTooFar checks that a LO tuned by the device too far from the signal is refused
+func TestOffsetTunerTooFar(t *testing.T) {
+
+	tuner := &OffsetTuner{
+		dev:        &fakeTuner{resolution: 1e6},
+		offset:     250e3,
+		sampleRate: 1e6,
+		mode:       OffsetSoftware,
+		nco:        NewNCO(0),
+	}
+
+	// The LO at 100.65 MHz is rounded to 101 MHz, 600 kHz from the signal
+	if _, err := tuner.Tune(100.4e6); err == nil {
+		t.Error("expected an error for a LO out of the stream")
+	}
+}
+
+// TestOffsetTunerCorrect checks that the signal is brought to the center of the stream in software mode, with the
+// phase of the oscillator kept across the blocks, and that the samples are copied in the other modes
+func TestOffsetTunerCorrect(t *testing.T) {
+
+	const sampleRate = 1e6
+
+	dev := &fakeTuner{resolution: 1e3}
+	tuner := &OffsetTuner{
+		dev:        dev,
+		offset:     250e3,
+		sampleRate: sampleRate,
+		mode:       OffsetSoftware,
+		nco:        NewNCO(0),
+	}
+
+	for _, frequency := range []float64{100.0001234e6, 100.0507e6} {
+
+		if _, err := tuner.Tune(frequency); err != nil {
+			t.Fatal(err)
+		}
+
+		// The signal is at the frequency of the LO minus the offset actually set in the stream, another signal 10 kHz
+		// above it
+		shift := (frequency - tuner.LOFrequency()) / sampleRate
+		samples := tone(shift, 4096)
+		other := tone(shift+10e3/sampleRate, 4096)
+
+		corrected := make([]complex64, len(samples))
+		for i := 0; i < len(samples); i += 1000 {
+			end := i + 1000
+			if end > len(samples) {
+				end = len(samples)
+			}
+			tuner.Correct(corrected[i:end], samples[i:end])
+		}
+
+		// The signal is at DC, with the phase of the oscillator
+		for i := 1; i < len(corrected); i++ {
+			if cmplx.Abs(complex128(corrected[i]-corrected[0])) > 1e-4 {
+				t.Fatalf("%v Hz: sample %d at %v, expected %v", frequency, i, corrected[i], corrected[0])
+			}
+		}
+		if amplitude := toneAmplitude(corrected, 0); math.Abs(amplitude-1) > 1e-4 {
+			t.Errorf("%v Hz: amplitude of %v at DC, expected 1", frequency, amplitude)
+		}
+
+		tuner.nco.Reset()
+		tuner.Correct(other, other)
+		if amplitude := toneAmplitude(other, 10e3/sampleRate); math.Abs(amplitude-1) > 1e-4 {
+			t.Errorf("%v Hz: amplitude of %v at 10 kHz, expected 1", frequency, amplitude)
+		}
+	}
+
+	tuner.mode = OffsetArgs
+	samples := tone(0.1, 100)
+	corrected := make([]complex64, len(samples))
+	tuner.Correct(corrected, samples)
+	for i := range samples {
+		if corrected[i] != samples[i] {
+			t.Fatalf("sample %d changed from %v to %v without software offset", i, samples[i], corrected[i])
+		}
+	}
+}
+
+func TestOffsetModeString(t *testing.T) {
+
+	for mode, expected := range map[OffsetMode]string{
+		OffsetAuto:       "auto",
+		OffsetArgs:       "args",
+		OffsetComponents: "components",
+		OffsetSoftware:   "software",
+		OffsetMode(7):    "OffsetMode(7)",
+	} {
+		if name := mode.String(); name != expected {
+			t.Errorf("mode %d named %v, expected %v", int(mode), name, expected)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package filter
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the design of FIR filters, by the windowed-sinc and the Parks-McClellan (Remez) methods, and the
+// streaming filters applying them to blocks of samples, with decimating and interpolating variants.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+
+	"github.com/bhojpur/sdr/pkg/dsp/spectrum"
+)
+
+// Type is the type of the frequency response of a filter
+type Type int
+
+const (
+	// Lowpass passes the frequencies below the cutoff
+	Lowpass Type = iota
+	// Highpass passes the frequencies above the cutoff
+	Highpass
+	// Bandpass passes the frequencies between the cutoffs
+	Bandpass
+	// Bandstop rejects the frequencies between the cutoffs
+	Bandstop
+)
+
+// String returns the name of the filter type
+func (filterType Type) String() string {
+
+	switch filterType {
+	case Lowpass:
+		return "lowpass"
+	case Highpass:
+		return "highpass"
+	case Bandpass:
+		return "bandpass"
+	case Bandstop:
+		return "bandstop"
+	}
+
+	return fmt.Sprintf("type(%d)", int(filterType))
+}
+
+// Method is the design method of a filter
+type Method int
+
+const (
+	// MethodWindow truncates the ideal impulse response with a window
+	MethodWindow Method = iota
+	// MethodRemez computes the equiripple filter of the Parks-McClellan algorithm
+	MethodRemez
+)
+
+// Specification is the specification of a filter. The frequencies are fractions of the sample rate, between 0 and
+// 0.5 (the Nyquist frequency).
+type Specification struct {
+	// Type is the type of the frequency response
+	Type Type
+	// Method is the design method
+	Method Method
+	// NumTaps is the number of taps. When 0, it is estimated from Attenuation and Transition.
+	NumTaps int
+	// Cutoff is the cutoff frequency of the lowpass and highpass filters, and the lower cutoff of the bandpass and
+	// bandstop filters. The response is -6 dB at the cutoffs with the window method, they are the centers of the
+	// transition bands with the Remez method.
+	Cutoff float64
+	// HighCutoff is the upper cutoff of the bandpass and bandstop filters
+	HighCutoff float64
+	// Transition is the width of the transition bands, needed by the Remez method and to estimate the number of taps
+	Transition float64
+	// Attenuation is the attenuation in dB of the stop bands used to estimate the number of taps. With the window
+	// method, it also selects a Kaiser window of matching beta.
+	Attenuation float64
+	// Window is the window of the window method, when the number of taps is given
+	Window spectrum.WindowType
+	// KaiserBeta is the beta parameter of the Kaiser window
+	KaiserBeta float64
+	// StopbandWeight is the weight of the errors in the stop bands relatively to the pass bands with the Remez method,
+	// 1 when 0
+	StopbandWeight float64
+}
+
+// Design computes the taps of a filter. The filters are linear phase, with a gain of 1 in their pass bands.
+//
+// Params:
+//  - specification: the specification of the filter
+//
+// Return the taps or an error if the specification is invalid
+func Design(specification Specification) (taps []float64, err error) {
+
+	switch specification.Type {
+	case Lowpass, Highpass:
+		if !(specification.Cutoff > 0 && specification.Cutoff < 0.5) {
+			return nil, errors.New("the cutoff of a filter must be between 0 and 0.5 excluded")
+		}
+	case Bandpass, Bandstop:
+		if !(specification.Cutoff > 0 && specification.Cutoff < specification.HighCutoff && specification.HighCutoff < 0.5) {
+			return nil, errors.New("the cutoffs of a band filter must be increasing between 0 and 0.5 excluded")
+		}
+	default:
+		return nil, fmt.Errorf("unknown filter type: %v", specification.Type)
+	}
+
+	if specification.NumTaps == 0 {
+		if !(specification.Attenuation > 0 && specification.Transition > 0) {
+			return nil, errors.New("the number of taps or the attenuation and the transition width must be given")
+		}
+		numTaps, beta := KaiserParameters(specification.Attenuation, specification.Transition)
+		specification.NumTaps = numTaps
+		if specification.Method == MethodWindow {
+			specification.Window = spectrum.WindowKaiser
+			specification.KaiserBeta = beta
+		}
+	}
+
+	if specification.NumTaps < 1 {
+		return nil, errors.New("a filter needs at least one tap")
+	}
+
+	switch specification.Method {
+	case MethodWindow:
+		return windowedSinc(specification)
+	case MethodRemez:
+		return remezFilter(specification)
+	}
+
+	return nil, fmt.Errorf("unknown design method: %v", specification.Method)
+}
+
+// DesignResponse computes the taps of a linear phase filter approaching an arbitrary amplitude response by the
+// window method, for example to compensate the droop of a CIC filter.
+//
+// Params:
+//  - numTaps: the number of taps
+//  - response: the desired amplitude response for the frequencies between 0 and 0.5, as fractions of the sample rate
+//  - window: the window truncating the impulse response
+//  - beta: the beta parameter of the Kaiser window, see KaiserParameters
+//
+// Return the taps or an error
+func DesignResponse(numTaps int, response func(frequency float64) float64, window spectrum.WindowType, beta float64) (taps []float64, err error) {
+
+	if numTaps < 1 {
+		return nil, errors.New("a filter needs at least one tap")
+	}
+
+	coefficients, err := symmetricWindow(window, numTaps, beta)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sample the response finely enough for the length of the filter
+	nbPoints := 8 * numTaps
+	if nbPoints < 1024 {
+		nbPoints = 1024
+	}
+	samples := make([]float64, nbPoints)
+	for k := range samples {
+		samples[k] = response((float64(k) + 0.5) / float64(2*nbPoints))
+	}
+
+	// The impulse response of an even real response, integrated with the midpoint rule
+	taps = make([]float64, numTaps)
+	center := float64(numTaps-1) / 2
+	for n := range taps {
+		t := float64(n) - center
+		sum := 0.0
+		for k, sample := range samples {
+			sum += sample * math.Cos(2*math.Pi*t*(float64(k)+0.5)/float64(2*nbPoints))
+		}
+		taps[n] = sum / float64(nbPoints) * coefficients[n]
+	}
+
+	return taps, nil
+}
+
+// KaiserParameters estimates the number of taps of a filter and the beta parameter of its Kaiser window from the
+// attenuation of its stop bands and the width of its transition bands. The number of taps is odd.
+//
+// Params:
+//  - attenuation: the attenuation in dB
+//  - transition: the width of the transition bands, as a fraction of the sample rate
+//
+// Return the number of taps and the beta parameter
+func KaiserParameters(attenuation float64, transition float64) (numTaps int, beta float64) {
+
+	switch {
+	case attenuation > 50:
+		beta = 0.1102 * (attenuation - 8.7)
+	case attenuation >= 21:
+		beta = 0.5842*math.Pow(attenuation-21, 0.4) + 0.07886*(attenuation-21)
+	}
+
+	numTaps = int(math.Ceil((attenuation-7.95)/(14.36*transition))) + 1
+	if numTaps < 1 {
+		numTaps = 1
+	}
+	if numTaps%2 == 0 {
+		numTaps++
+	}
+
+	return numTaps, beta
+}
+
+// Shift shifts the frequency response of a filter, making a complex filter passing a band centered on a non null
+// frequency, for example a lowpass filter becomes a bandpass filter centered on the frequency.
+//
+// Params:
+//  - taps: the taps of the filter
+//  - frequency: the frequency shift, as a fraction of the sample rate, between -0.5 and 0.5
+//
+// Return the complex taps
+func Shift(taps []float64, frequency float64) []complex128 {
+
+	shifted := make([]complex128, len(taps))
+	center := float64(len(taps)-1) / 2
+	for n, tap := range taps {
+		shifted[n] = complex(tap, 0) * cmplx.Rect(1, 2*math.Pi*frequency*(float64(n)-center))
+	}
+
+	return shifted
+}
+
+// Response returns the frequency response of a filter.
+//
+// Params:
+//  - taps: the taps of the filter, a []float64 or a []complex128
+//  - frequency: the frequency, as a fraction of the sample rate
+//
+// Return the complex gain of the filter at the frequency
+func Response(taps interface{}, frequency float64) complex128 {
+
+	response := complex128(0)
+
+	switch typed := taps.(type) {
+	case []float64:
+		for n, tap := range typed {
+			response += complex(tap, 0) * cmplx.Rect(1, -2*math.Pi*frequency*float64(n))
+		}
+	case []complex128:
+		for n, tap := range typed {
+			response += tap * cmplx.Rect(1, -2*math.Pi*frequency*float64(n))
+		}
+	}
+
+	return response
+}
+
+// windowedSinc designs a filter with the window method
+func windowedSinc(specification Specification) ([]float64, error) {
+
+	numTaps := specification.NumTaps
+
+	if numTaps%2 == 0 && (specification.Type == Highpass || specification.Type == Bandstop) {
+		return nil, errors.New("the highpass and bandstop filters need an odd number of taps")
+	}
+
+	window, err := symmetricWindow(specification.Window, numTaps, specification.KaiserBeta)
+	if err != nil {
+		return nil, err
+	}
+
+	taps := make([]float64, numTaps)
+	center := float64(numTaps-1) / 2
+
+	reference := 0.0
+	for n := range taps {
+
+		t := float64(n) - center
+
+		switch specification.Type {
+		case Lowpass:
+			taps[n] = sinc(specification.Cutoff, t)
+		case Highpass:
+			taps[n] = -sinc(specification.Cutoff, t)
+			reference = 0.5
+		case Bandpass:
+			taps[n] = sinc(specification.HighCutoff, t) - sinc(specification.Cutoff, t)
+			reference = (specification.Cutoff + specification.HighCutoff) / 2
+		case Bandstop:
+			taps[n] = sinc(specification.Cutoff, t) - sinc(specification.HighCutoff, t)
+		}
+
+		if t == 0 && (specification.Type == Highpass || specification.Type == Bandstop) {
+			taps[n]++
+		}
+
+		taps[n] *= window[n]
+	}
+
+	return normalize(taps, reference), nil
+}
+
+// sinc returns the ideal impulse response of a lowpass filter of a cutoff at a time
+func sinc(cutoff float64, t float64) float64 {
+
+	if t == 0 {
+		return 2 * cutoff
+	}
+
+	return math.Sin(2*math.Pi*cutoff*t) / (math.Pi * t)
+}
+
+// symmetricWindow returns the symmetric version of a window, as suited to filter design, from its periodic version
+func symmetricWindow(window spectrum.WindowType, size int, beta float64) ([]float64, error) {
+
+	if size == 1 {
+		return []float64{1}, nil
+	}
+
+	periodic, err := spectrum.MakeWindow(window, size-1, beta)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(periodic, periodic[0]), nil
+}
+
+// normalize scales the taps to a gain of 1 at a reference frequency
+func normalize(taps []float64, reference float64) []float64 {
+
+	gain := cmplx.Abs(Response(taps, reference))
+	if gain == 0 {
+		return taps
+	}
+
+	for n := range taps {
+		taps[n] /= gain
+	}
+
+	return taps
+}
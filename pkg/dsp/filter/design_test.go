@@ -0,0 +1,106 @@
+package filter
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// TestDesignWindow checks the filters of each type designed with a Kaiser window from their attenuation
+func TestDesignWindow(t *testing.T) {
+
+	tests := []struct {
+		specification Specification
+		passbands     []float64
+		stopbands     []float64
+	}{
+		{Specification{Type: Lowpass, Cutoff: 0.1, Transition: 0.02, Attenuation: 80},
+			[]float64{0, 0.09}, []float64{0.11, 0.5}},
+		{Specification{Type: Highpass, Cutoff: 0.3, Transition: 0.05, Attenuation: 60},
+			[]float64{0.325, 0.5}, []float64{0, 0.275}},
+		{Specification{Type: Bandpass, Cutoff: 0.1, HighCutoff: 0.2, Transition: 0.02, Attenuation: 70},
+			[]float64{0.11, 0.19}, []float64{0, 0.09, 0.21, 0.5}},
+		{Specification{Type: Bandstop, Cutoff: 0.1, HighCutoff: 0.2, Transition: 0.02, Attenuation: 70},
+			[]float64{0, 0.09, 0.21, 0.5}, []float64{0.11, 0.19}},
+	}
+
+	for _, test := range tests {
+
+		taps, err := Design(test.specification)
+		if err != nil {
+			t.Fatalf("%v: %v", test.specification.Type, err)
+		}
+
+		// The Kaiser window gives about the same error in the pass and stop bands
+		ripple, attenuation := measure(taps, test.passbands, test.stopbands)
+		expected := test.specification.Attenuation - 3
+		if -decibels(attenuation) < expected || -decibels(ripple) < expected {
+			t.Errorf("%v: passband ripple at %.1f dB, stopband at %.1f dB, expected below -%.1f dB",
+				test.specification.Type, decibels(ripple), decibels(attenuation), expected)
+		}
+
+		// The taps are symmetric
+		for i := range taps {
+			if math.Abs(taps[i]-taps[len(taps)-1-i]) > 1e-12 {
+				t.Fatalf("%v: the taps are not symmetric", test.specification.Type)
+			}
+		}
+	}
+}
+
+// TestDesignCutoff checks that the window method gives -6 dB at the cutoffs
+func TestDesignCutoff(t *testing.T) {
+
+	for _, window := range []Specification{
+		{Type: Lowpass, Cutoff: 0.15, NumTaps: 101},
+		{Type: Lowpass, Cutoff: 0.15, Transition: 0.02, Attenuation: 80},
+	} {
+		taps, err := Design(window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gain := decibels(amplitude(taps, window.Cutoff)); math.Abs(gain+6.02) > 0.3 {
+			t.Errorf("%d taps: %.2f dB at the cutoff", len(taps), gain)
+		}
+	}
+}
+
+// TestShift checks that a shifted lowpass filter passes the band around the shift only
+func TestShift(t *testing.T) {
+
+	taps, err := Design(Specification{Type: Lowpass, Cutoff: 0.05, Transition: 0.02, Attenuation: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shifted := Shift(taps, -0.2)
+	for _, test := range []struct {
+		frequency float64
+		gain      float64
+	}{{-0.2, 0}, {-0.17, 0}, {0, -60}, {0.2, -60}, {-0.3, -60}} {
+		gain := decibels(cmplx.Abs(Response(shifted, test.frequency)))
+		if (test.gain == 0 && math.Abs(gain) > 0.05) || (test.gain < 0 && gain > test.gain) {
+			t.Errorf("%.2f dB at %v", gain, test.frequency)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package filter
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ComplexFIR filters a stream of complex64 samples with real or complex taps, keeping its state from a block to the
+// next. A decimating filter computes only one output every Decimation inputs.
+type ComplexFIR struct {
+	taps        []float32
+	complexTaps []complex64
+	decimation  int
+	phase       int
+	history     []complex64
+	work        []complex64
+}
+
+// NewComplexFIR creates a filter of complex64 samples.
+//
+// Params:
+//  - taps: the taps of the filter, a []float64 or a []complex128 (see Shift)
+//
+// Return the filter or an error
+func NewComplexFIR(taps interface{}) (fir *ComplexFIR, err error) {
+	return NewComplexDecimator(taps, 1)
+}
+
+// NewComplexDecimator creates a decimating filter of complex64 samples. The taps must reject the frequencies above
+// 0.5/decimation of the input sample rate, to avoid aliasing.
+//
+// Params:
+//  - taps: the taps of the filter, a []float64 or a []complex128
+//  - decimation: the ratio of the input and output sample rates
+//
+// Return the filter or an error
+func NewComplexDecimator(taps interface{}, decimation int) (fir *ComplexFIR, err error) {
+
+	if decimation < 1 {
+		return nil, errors.New("the decimation of a filter must be at least 1")
+	}
+
+	fir = &ComplexFIR{
+		decimation: decimation,
+	}
+
+	switch typed := taps.(type) {
+	case []float64:
+		fir.taps = reverseReal(typed, 1)
+	case []complex128:
+		fir.complexTaps = reverseComplex(typed, 1)
+	default:
+		return nil, fmt.Errorf("the taps must be a []float64 or a []complex128, not a %T", taps)
+	}
+
+	numTaps := len(fir.taps) + len(fir.complexTaps)
+	if numTaps == 0 {
+		return nil, errors.New("a filter needs at least one tap")
+	}
+	fir.history = make([]complex64, numTaps-1)
+
+	return fir, nil
+}
+
+// Decimation returns the ratio of the input and output sample rates
+func (fir *ComplexFIR) Decimation() int {
+	return fir.decimation
+}
+
+// Delay returns the group delay of the linear phase filters in input samples
+func (fir *ComplexFIR) Delay() float64 {
+	return float64(len(fir.history)) / 2
+}
+
+// Filter filters a block of samples and appends the outputs to a slice, for example out = fir.Filter(out[:0], in).
+//
+// Params:
+//  - dst: the slice receiving the outputs
+//  - src: the input samples
+//
+// Return the slice with the outputs appended
+func (fir *ComplexFIR) Filter(dst []complex64, src []complex64) []complex64 {
+
+	numTaps := len(fir.history) + 1
+
+	// The delay line followed by the block
+	fir.work = append(append(fir.work[:0], fir.history...), src...)
+
+	i := fir.phase
+	for ; i < len(src); i += fir.decimation {
+		window := fir.work[i : i+numTaps]
+		if fir.taps != nil {
+			dst = append(dst, dotRealTaps(window, fir.taps))
+		} else {
+			dst = append(dst, dotComplexTaps(window, fir.complexTaps))
+		}
+	}
+	fir.phase = i - len(src)
+
+	copy(fir.history, fir.work[len(src):])
+
+	return dst
+}
+
+// Reset clears the state of the filter
+func (fir *ComplexFIR) Reset() {
+
+	fir.phase = 0
+	for i := range fir.history {
+		fir.history[i] = 0
+	}
+}
+
+// RealFIR filters a stream of float32 samples, keeping its state from a block to the next. A decimating filter
+// computes only one output every Decimation inputs.
+type RealFIR struct {
+	taps       []float32
+	decimation int
+	phase      int
+	history    []float32
+	work       []float32
+}
+
+// NewRealFIR creates a filter of float32 samples.
+//
+// Params:
+//  - taps: the taps of the filter
+//
+// Return the filter or an error
+func NewRealFIR(taps []float64) (fir *RealFIR, err error) {
+	return NewRealDecimator(taps, 1)
+}
+
+// NewRealDecimator creates a decimating filter of float32 samples. The taps must reject the frequencies above
+// 0.5/decimation of the input sample rate, to avoid aliasing.
+//
+// Params:
+//  - taps: the taps of the filter
+//  - decimation: the ratio of the input and output sample rates
+//
+// Return the filter or an error
+func NewRealDecimator(taps []float64, decimation int) (fir *RealFIR, err error) {
+
+	if decimation < 1 {
+		return nil, errors.New("the decimation of a filter must be at least 1")
+	}
+
+	if len(taps) == 0 {
+		return nil, errors.New("a filter needs at least one tap")
+	}
+
+	return &RealFIR{
+		taps:       reverseReal(taps, 1),
+		decimation: decimation,
+		history:    make([]float32, len(taps)-1),
+	}, nil
+}
+
+// Decimation returns the ratio of the input and output sample rates
+func (fir *RealFIR) Decimation() int {
+	return fir.decimation
+}
+
+// Delay returns the group delay of the linear phase filters in input samples
+func (fir *RealFIR) Delay() float64 {
+	return float64(len(fir.history)) / 2
+}
+
+// Filter filters a block of samples and appends the outputs to a slice, for example out = fir.Filter(out[:0], in).
+//
+// Params:
+//  - dst: the slice receiving the outputs
+//  - src: the input samples
+//
+// Return the slice with the outputs appended
+func (fir *RealFIR) Filter(dst []float32, src []float32) []float32 {
+
+	numTaps := len(fir.taps)
+
+	fir.work = append(append(fir.work[:0], fir.history...), src...)
+
+	i := fir.phase
+	for ; i < len(src); i += fir.decimation {
+		dst = append(dst, dotReal(fir.work[i:i+numTaps], fir.taps))
+	}
+	fir.phase = i - len(src)
+
+	copy(fir.history, fir.work[len(src):])
+
+	return dst
+}
+
+// Reset clears the state of the filter
+func (fir *RealFIR) Reset() {
+
+	fir.phase = 0
+	for i := range fir.history {
+		fir.history[i] = 0
+	}
+}
+
+// reverseReal returns the taps in reverse order and scaled, to compute the convolution as a dot product with the
+// delay line
+func reverseReal(taps []float64, scale float64) []float32 {
+
+	reversed := make([]float32, len(taps))
+	for n, tap := range taps {
+		reversed[len(taps)-1-n] = float32(tap * scale)
+	}
+
+	return reversed
+}
+
+// reverseComplex returns the complex taps in reverse order and scaled
+func reverseComplex(taps []complex128, scale float64) []complex64 {
+
+	reversed := make([]complex64, len(taps))
+	for n, tap := range taps {
+		reversed[len(taps)-1-n] = complex64(tap * complex(scale, 0))
+	}
+
+	return reversed
+}
+
+// dotRealTaps returns the dot product of complex samples and real taps
+func dotRealTaps(samples []complex64, taps []float32) complex64 {
+
+	var re, im float32
+	samples = samples[:len(taps)]
+	for k, tap := range taps {
+		re += real(samples[k]) * tap
+		im += imag(samples[k]) * tap
+	}
+
+	return complex(re, im)
+}
+
+// dotComplexTaps returns the dot product of complex samples and complex taps
+func dotComplexTaps(samples []complex64, taps []complex64) complex64 {
+
+	var sum complex64
+	samples = samples[:len(taps)]
+	for k, tap := range taps {
+		sum += samples[k] * tap
+	}
+
+	return sum
+}
+
+// dotReal returns the dot product of real samples and real taps
+func dotReal(samples []float32, taps []float32) float32 {
+
+	var sum float32
+	samples = samples[:len(taps)]
+	for k, tap := range taps {
+		sum += samples[k] * tap
+	}
+
+	return sum
+}
@@ -0,0 +1,197 @@
+package filter
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// randomSignal returns random complex samples
+func randomSignal(random *rand.Rand, count int) []complex64 {
+
+	samples := make([]complex64, count)
+	for i := range samples {
+		samples[i] = complex(float32(random.NormFloat64()), float32(random.NormFloat64()))
+	}
+
+	return samples
+}
+
+// convolve returns the full convolution of samples with taps, starting with zeros
+func convolve(samples []complex64, taps []complex128) []complex128 {
+
+	outputs := make([]complex128, len(samples))
+	for n := range outputs {
+		for k, tap := range taps {
+			if n-k >= 0 {
+				outputs[n] += tap * complex128(samples[n-k])
+			}
+		}
+	}
+
+	return outputs
+}
+
+// blocks splits samples in blocks of random sizes
+func blocks(random *rand.Rand, samples []complex64) [][]complex64 {
+
+	var split [][]complex64
+	for len(samples) > 0 {
+		size := random.Intn(100)
+		if size > len(samples) {
+			size = len(samples)
+		}
+		split = append(split, samples[:size])
+		samples = samples[size:]
+	}
+
+	return split
+}
+
+// TestComplexDecimator compares the decimating filters with a direct convolution, the samples being given in blocks
+// of random sizes
+func TestComplexDecimator(t *testing.T) {
+
+	random := rand.New(rand.NewSource(1))
+	samples := randomSignal(random, 2000)
+
+	taps := make([]float64, 37)
+	for i := range taps {
+		taps[i] = random.NormFloat64()
+	}
+	complexTaps := Shift(taps, 0.1)
+
+	for _, decimation := range []int{1, 3, 8} {
+		for _, filterTaps := range []interface{}{taps, complexTaps} {
+
+			fir, err := NewComplexDecimator(filterTaps, decimation)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var outputs []complex64
+			for _, block := range blocks(random, samples) {
+				outputs = fir.Filter(outputs, block)
+			}
+
+			reference := make([]complex128, len(taps))
+			switch typed := filterTaps.(type) {
+			case []float64:
+				for i, tap := range typed {
+					reference[i] = complex(tap, 0)
+				}
+			case []complex128:
+				copy(reference, typed)
+			}
+			expected := convolve(samples, reference)
+
+			if len(outputs) != (len(samples)+decimation-1)/decimation {
+				t.Fatalf("decimation %d: %d outputs for %d samples", decimation, len(outputs), len(samples))
+			}
+			for i, output := range outputs {
+				if cmplx.Abs(complex128(output)-expected[i*decimation]) > 1e-4 {
+					t.Fatalf("decimation %d: output %d is %v instead of %v", decimation, i, output, expected[i*decimation])
+				}
+			}
+		}
+	}
+}
+
+// TestComplexInterpolator compares the interpolators with the convolution of the samples separated by zeros
+func TestComplexInterpolator(t *testing.T) {
+
+	random := rand.New(rand.NewSource(2))
+	samples := randomSignal(random, 500)
+
+	taps := make([]float64, 41)
+	reference := make([]complex128, len(taps))
+	for i := range taps {
+		taps[i] = random.NormFloat64()
+	}
+
+	for _, interpolation := range []int{1, 2, 5} {
+
+		interpolator, err := NewComplexInterpolator(taps, interpolation)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var outputs []complex64
+		for _, block := range blocks(random, samples) {
+			outputs = interpolator.Filter(outputs, block)
+		}
+
+		stuffed := make([]complex64, len(samples)*interpolation)
+		for i, sample := range samples {
+			stuffed[i*interpolation] = sample * complex(float32(interpolation), 0)
+		}
+		for i, tap := range taps {
+			reference[i] = complex(tap, 0)
+		}
+		expected := convolve(stuffed, reference)
+
+		if len(outputs) != len(expected) {
+			t.Fatalf("interpolation %d: %d outputs for %d samples", interpolation, len(outputs), len(samples))
+		}
+		for i, output := range outputs {
+			if cmplx.Abs(complex128(output)-expected[i]) > 1e-3 {
+				t.Fatalf("interpolation %d: output %d is %v instead of %v", interpolation, i, output, expected[i])
+			}
+		}
+	}
+}
+
+// TestRealFIR checks the gain of a lowpass filter on tones in and out of its pass band
+func TestRealFIR(t *testing.T) {
+
+	taps, err := Design(Specification{Type: Lowpass, Cutoff: 0.1, Transition: 0.04, Attenuation: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		frequency float64
+		gain      float64
+	}{{0.03, 1}, {0.2, 0}, {0.4, 0}} {
+
+		fir, err := NewRealFIR(taps)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		samples := make([]float32, 4000)
+		for i := range samples {
+			samples[i] = float32(math.Cos(2 * math.Pi * test.frequency * float64(i)))
+		}
+		outputs := fir.Filter(nil, samples)
+
+		peak := 0.0
+		for _, output := range outputs[len(taps):] {
+			peak = math.Max(peak, math.Abs(float64(output)))
+		}
+		if math.Abs(peak-test.gain) > 1e-3 {
+			t.Errorf("tone at %v: amplitude %v instead of %v", test.frequency, peak, test.gain)
+		}
+	}
+}
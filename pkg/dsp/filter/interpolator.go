@@ -0,0 +1,232 @@
+package filter
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ComplexInterpolator increases the sample rate of a stream of complex64 samples by an integer factor with a polyphase
+// filter, keeping its state from a block to the next. Each input gives Interpolation outputs.
+type ComplexInterpolator struct {
+	phases        [][]float32
+	complexPhases [][]complex64
+	interpolation int
+	history       []complex64
+	work          []complex64
+}
+
+// NewComplexInterpolator creates an interpolator of complex64 samples. The taps, designed at the output sample rate,
+// must reject the frequencies above 0.5/interpolation of the output rate, to remove the images of the spectrum. They
+// are multiplied by the interpolation factor to keep the amplitude of the signal.
+//
+// Params:
+//  - taps: the taps of the filter, a []float64 or a []complex128
+//  - interpolation: the ratio of the output and input sample rates
+//
+// Return the interpolator or an error
+func NewComplexInterpolator(taps interface{}, interpolation int) (interpolator *ComplexInterpolator, err error) {
+
+	if interpolation < 1 {
+		return nil, errors.New("the interpolation of a filter must be at least 1")
+	}
+
+	interpolator = &ComplexInterpolator{
+		interpolation: interpolation,
+	}
+
+	scale := float64(interpolation)
+	numTaps := 0
+
+	switch typed := taps.(type) {
+	case []float64:
+		numTaps = len(typed)
+		for phase := 0; phase < interpolation; phase++ {
+			interpolator.phases = append(interpolator.phases, reverseReal(polyphaseReal(typed, interpolation, phase), scale))
+		}
+	case []complex128:
+		numTaps = len(typed)
+		for phase := 0; phase < interpolation; phase++ {
+			interpolator.complexPhases = append(interpolator.complexPhases, reverseComplex(polyphaseComplex(typed, interpolation, phase), scale))
+		}
+	default:
+		return nil, fmt.Errorf("the taps must be a []float64 or a []complex128, not a %T", taps)
+	}
+
+	if numTaps == 0 {
+		return nil, errors.New("a filter needs at least one tap")
+	}
+	interpolator.history = make([]complex64, phaseLength(numTaps, interpolation)-1)
+
+	return interpolator, nil
+}
+
+// Interpolation returns the ratio of the output and input sample rates
+func (interpolator *ComplexInterpolator) Interpolation() int {
+	return interpolator.interpolation
+}
+
+// Filter interpolates a block of samples and appends the outputs to a slice.
+//
+// Params:
+//  - dst: the slice receiving the outputs
+//  - src: the input samples
+//
+// Return the slice with the outputs appended
+func (interpolator *ComplexInterpolator) Filter(dst []complex64, src []complex64) []complex64 {
+
+	length := len(interpolator.history) + 1
+
+	interpolator.work = append(append(interpolator.work[:0], interpolator.history...), src...)
+
+	for i := range src {
+		window := interpolator.work[i : i+length]
+		for phase := 0; phase < interpolator.interpolation; phase++ {
+			if interpolator.phases != nil {
+				dst = append(dst, dotRealTaps(window, interpolator.phases[phase]))
+			} else {
+				dst = append(dst, dotComplexTaps(window, interpolator.complexPhases[phase]))
+			}
+		}
+	}
+
+	copy(interpolator.history, interpolator.work[len(src):])
+
+	return dst
+}
+
+// Reset clears the state of the interpolator
+func (interpolator *ComplexInterpolator) Reset() {
+
+	for i := range interpolator.history {
+		interpolator.history[i] = 0
+	}
+}
+
+// RealInterpolator increases the sample rate of a stream of float32 samples by an integer factor with a polyphase
+// filter, keeping its state from a block to the next. Each input gives Interpolation outputs.
+type RealInterpolator struct {
+	phases        [][]float32
+	interpolation int
+	history       []float32
+	work          []float32
+}
+
+// NewRealInterpolator creates an interpolator of float32 samples. The taps, designed at the output sample rate, must
+// reject the frequencies above 0.5/interpolation of the output rate. They are multiplied by the interpolation factor
+// to keep the amplitude of the signal.
+//
+// Params:
+//  - taps: the taps of the filter
+//  - interpolation: the ratio of the output and input sample rates
+//
+// Return the interpolator or an error
+func NewRealInterpolator(taps []float64, interpolation int) (interpolator *RealInterpolator, err error) {
+
+	if interpolation < 1 {
+		return nil, errors.New("the interpolation of a filter must be at least 1")
+	}
+
+	if len(taps) == 0 {
+		return nil, errors.New("a filter needs at least one tap")
+	}
+
+	interpolator = &RealInterpolator{
+		interpolation: interpolation,
+		history:       make([]float32, phaseLength(len(taps), interpolation)-1),
+	}
+
+	for phase := 0; phase < interpolation; phase++ {
+		interpolator.phases = append(interpolator.phases, reverseReal(polyphaseReal(taps, interpolation, phase), float64(interpolation)))
+	}
+
+	return interpolator, nil
+}
+
+// Interpolation returns the ratio of the output and input sample rates
+func (interpolator *RealInterpolator) Interpolation() int {
+	return interpolator.interpolation
+}
+
+// Filter interpolates a block of samples and appends the outputs to a slice.
+//
+// Params:
+//  - dst: the slice receiving the outputs
+//  - src: the input samples
+//
+// Return the slice with the outputs appended
+func (interpolator *RealInterpolator) Filter(dst []float32, src []float32) []float32 {
+
+	length := len(interpolator.history) + 1
+
+	interpolator.work = append(append(interpolator.work[:0], interpolator.history...), src...)
+
+	for i := range src {
+		window := interpolator.work[i : i+length]
+		for _, phase := range interpolator.phases {
+			dst = append(dst, dotReal(window, phase))
+		}
+	}
+
+	copy(interpolator.history, interpolator.work[len(src):])
+
+	return dst
+}
+
+// Reset clears the state of the interpolator
+func (interpolator *RealInterpolator) Reset() {
+
+	for i := range interpolator.history {
+		interpolator.history[i] = 0
+	}
+}
+
+// phaseLength returns the number of taps of each phase of a polyphase filter
+func phaseLength(numTaps int, nbPhases int) int {
+	return (numTaps + nbPhases - 1) / nbPhases
+}
+
+// polyphaseReal returns the taps of a phase of a polyphase filter, padded with zeros
+func polyphaseReal(taps []float64, nbPhases int, phase int) []float64 {
+
+	subTaps := make([]float64, phaseLength(len(taps), nbPhases))
+	for k := range subTaps {
+		if n := phase + k*nbPhases; n < len(taps) {
+			subTaps[k] = taps[n]
+		}
+	}
+
+	return subTaps
+}
+
+// polyphaseComplex returns the complex taps of a phase of a polyphase filter, padded with zeros
+func polyphaseComplex(taps []complex128, nbPhases int, phase int) []complex128 {
+
+	subTaps := make([]complex128, phaseLength(len(taps), nbPhases))
+	for k := range subTaps {
+		if n := phase + k*nbPhases; n < len(taps) {
+			subTaps[k] = taps[n]
+		}
+	}
+
+	return subTaps
+}
@@ -0,0 +1,519 @@
+package filter
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// remezGridDensity is the number of points of the dense grid by extremal frequency
+	remezGridDensity = 16
+	// remezMaxIterations is the maximum number of iterations of the exchange algorithm
+	remezMaxIterations = 40
+	// remezScalingThreshold is the number of extremal frequencies above which the initial reference is scaled from the
+	// design of a shorter filter
+	remezScalingThreshold = 24
+	// remezTolerance is the relative excess of the maximum weighted error of the taps over the equiripple error
+	// accepted
+	remezTolerance = 0.01
+	// remezPrecision is the maximum weighted error of the taps accepted whatever the equiripple error, about the
+	// precision of the single precision samples filtered
+	remezPrecision = 1e-7
+)
+
+// Remez computes the taps of the linear phase equiripple filter minimizing the maximum weighted error with a desired
+// piecewise constant frequency response, with the Parks-McClellan algorithm. The frequencies are fractions of the
+// sample rate, between 0 and 0.5. The filters of an even number of taps have a null response at 0.5. The rounding
+// errors grow with the attenuation, the filters whose weighted errors exceed both their ripple and 1e-7 are rejected.
+//
+// Params:
+//  - numTaps: the number of taps
+//  - bands: the edges of the bands, in pairs of increasing frequencies, such as [0, 0.1, 0.15, 0.5]
+//  - desired: the desired gain of each band
+//  - weights: the weight of the errors in each band, or nil for equal weights
+//
+// Return the taps or an error if the parameters are invalid, the algorithm does not converge or its rounding errors
+// exceed the ripple
+func Remez(numTaps int, bands []float64, desired []float64, weights []float64) (taps []float64, err error) {
+
+	numBands := len(bands) / 2
+	if numTaps < 3 {
+		return nil, errors.New("the Remez method needs at least 3 taps")
+	}
+	if numBands == 0 || len(bands) != 2*numBands || len(desired) != numBands {
+		return nil, errors.New("the bands must be pairs of frequencies with a desired gain each")
+	}
+	if weights == nil {
+		weights = make([]float64, numBands)
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	if len(weights) != numBands {
+		return nil, errors.New("the bands must have a weight each")
+	}
+
+	for i, edge := range bands {
+		if edge < 0 || edge > 0.5 || (i > 0 && edge < bands[i-1]) || (i%2 == 1 && edge == bands[i-1]) {
+			return nil, errors.New("the edges of the bands must be increasing between 0 and 0.5")
+		}
+	}
+	for _, weight := range weights {
+		if !(weight > 0) {
+			return nil, errors.New("the weights of the bands must be positive")
+		}
+	}
+
+	solver, err := remezSolve(numTaps, bands, desired, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sample the frequency response and compute the taps
+	samples := make([]float64, numTaps/2+1)
+	for i := range samples {
+		c := 1.0
+		if numTaps%2 == 0 {
+			c = math.Cos(math.Pi * float64(i) / float64(numTaps))
+		}
+		samples[i] = solver.response(float64(i)/float64(numTaps)) * c
+	}
+
+	taps = frequencySampling(numTaps, samples)
+
+	// The response between the bands is sensitive to the rounding errors, which dominate the ripple of the longest
+	// filters
+	if solver.maxError(taps) > math.Max(math.Abs(solver.delta)*(1+remezTolerance), remezPrecision) {
+		return nil, errors.New("the ripple of the filter is below the precision of the Remez algorithm")
+	}
+
+	return taps, nil
+}
+
+// remezSolve runs the exchange algorithm until the errors at the extremal frequencies are equal
+func remezSolve(numTaps int, bands []float64, desired []float64, weights []float64) (*remezSolver, error) {
+
+	// Number of extremal frequencies minus one
+	r := numTaps / 2
+	if numTaps%2 == 1 {
+		r++
+	}
+
+	grid, desiredGrid, weightGrid, edges := remezGrid(r, bands, desired, weights)
+
+	// The response of an even number of taps is null at 0.5, whose error can not be weighted
+	if last := len(grid) - 1; numTaps%2 == 0 && grid[last] > 0.5-0.25/float64(remezGridDensity*r) {
+		grid, desiredGrid, weightGrid, edges = grid[:last], desiredGrid[:last], weightGrid[:last], edges[:last]
+		if last > 0 {
+			edges[last-1] = true
+		}
+	}
+	if len(grid) <= r {
+		return nil, errors.New("the bands are too narrow for the number of taps")
+	}
+
+	// An even number of taps has a response of the form cos(pi f) P(f)
+	if numTaps%2 == 0 {
+		for i, frequency := range grid {
+			c := math.Cos(math.Pi * frequency)
+			desiredGrid[i] /= c
+			weightGrid[i] *= c
+		}
+	}
+
+	solver := &remezSolver{
+		r:       r,
+		grid:    grid,
+		desired: desiredGrid,
+		weights: weightGrid,
+		edges:   edges,
+		ad:      make([]float64, r+1),
+		x:       make([]float64, r+1),
+		y:       make([]float64, r+1),
+		errors:  make([]float64, len(grid)),
+		extrema: make([]int, r+1),
+	}
+
+	solver.initialReference(numTaps, bands, desired, weights)
+
+	converged := false
+	for iteration := 0; iteration < remezMaxIterations && !converged; iteration++ {
+		solver.parameters()
+		solver.computeErrors()
+		if !solver.search() {
+			return nil, errors.New("the Remez algorithm lost the alternation of the errors")
+		}
+		converged = solver.converged()
+	}
+	if !converged {
+		return nil, errors.New("the Remez algorithm did not converge")
+	}
+	solver.parameters()
+
+	return solver, nil
+}
+
+// remezFilter designs a filter of a specification with the Remez method
+func remezFilter(specification Specification) ([]float64, error) {
+
+	if !(specification.Transition > 0) {
+		return nil, errors.New("the Remez method needs the width of the transition bands")
+	}
+
+	stopbandWeight := specification.StopbandWeight
+	if stopbandWeight == 0 {
+		stopbandWeight = 1
+	}
+
+	half := specification.Transition / 2
+	low := specification.Cutoff
+	high := specification.HighCutoff
+
+	var bands, desired, weights []float64
+	switch specification.Type {
+	case Lowpass:
+		bands = []float64{0, low - half, low + half, 0.5}
+		desired = []float64{1, 0}
+		weights = []float64{1, stopbandWeight}
+	case Highpass:
+		bands = []float64{0, low - half, low + half, 0.5}
+		desired = []float64{0, 1}
+		weights = []float64{stopbandWeight, 1}
+	case Bandpass:
+		bands = []float64{0, low - half, low + half, high - half, high + half, 0.5}
+		desired = []float64{0, 1, 0}
+		weights = []float64{stopbandWeight, 1, stopbandWeight}
+	case Bandstop:
+		bands = []float64{0, low - half, low + half, high - half, high + half, 0.5}
+		desired = []float64{1, 0, 1}
+		weights = []float64{1, stopbandWeight, 1}
+	}
+
+	if specification.NumTaps%2 == 0 && desired[len(desired)-1] != 0 {
+		return nil, errors.New("the filters with a gain at 0.5 need an odd number of taps")
+	}
+
+	return Remez(specification.NumTaps, bands, desired, weights)
+}
+
+// remezGrid returns the dense grid of frequencies of the bands, with the desired gains and the weights of its points,
+// and whether they are the edges of a band
+func remezGrid(r int, bands []float64, desired []float64, weights []float64) (grid []float64, desiredGrid []float64, weightGrid []float64, edges []bool) {
+
+	step := 0.5 / float64(remezGridDensity*r)
+
+	for band := 0; band < len(bands)/2; band++ {
+
+		low := bands[2*band]
+		high := bands[2*band+1]
+
+		count := int((high-low)/step + 0.5)
+		if count < 1 {
+			count = 1
+		}
+
+		for i := 0; i < count; i++ {
+			grid = append(grid, low+float64(i)*step)
+			desiredGrid = append(desiredGrid, desired[band])
+			weightGrid = append(weightGrid, weights[band])
+			edges = append(edges, i == 0 || i == count-1)
+		}
+		grid[len(grid)-1] = high
+	}
+
+	return grid, desiredGrid, weightGrid, edges
+}
+
+// remezSolver holds the state of the exchange algorithm
+type remezSolver struct {
+	r       int
+	grid    []float64
+	desired []float64
+	weights []float64
+	edges   []bool
+	ad      []float64
+	x       []float64
+	y       []float64
+	errors  []float64
+	extrema []int
+	delta   float64
+}
+
+// initialReference sets the extremal frequencies the exchange algorithm starts from. The long filters start from the
+// extremal frequencies of a filter of half their length, scaled to their number: a reference evenly spread over the
+// grid gives errors far below the rounding errors of the interpolation, from which the extrema can not be found.
+func (solver *remezSolver) initialReference(numTaps int, bands []float64, desired []float64, weights []float64) {
+
+	last := len(solver.grid) - 1
+
+	shorter := numTaps / 2
+	if shorter%2 != numTaps%2 {
+		shorter++
+	}
+
+	if solver.r > remezScalingThreshold {
+		if reference, err := remezSolve(shorter, bands, desired, weights); err == nil {
+
+			// Grid indices of the extremal frequencies of the shorter filter
+			indices := make([]float64, reference.r+1)
+			position := 0
+			for i, extremum := range reference.extrema {
+				frequency := reference.grid[extremum]
+				for position < last && math.Abs(solver.grid[position+1]-frequency) <= math.Abs(solver.grid[position]-frequency) {
+					position++
+				}
+				indices[i] = float64(position)
+			}
+
+			previous := -1
+			for i := range solver.extrema {
+				t := float64(i*reference.r) / float64(solver.r)
+				j := int(t)
+				index := indices[j]
+				if j < reference.r {
+					index += (t - float64(j)) * (indices[j+1] - indices[j])
+				}
+				extremum := int(math.Round(index))
+				// The extremal frequencies must be distinct and leave room for the next ones
+				if extremum <= previous {
+					extremum = previous + 1
+				}
+				if extremum > last-(solver.r-i) {
+					extremum = last - (solver.r - i)
+				}
+				solver.extrema[i] = extremum
+				previous = extremum
+			}
+			return
+		}
+	}
+
+	// Extremal frequencies evenly spread over the grid
+	for i := range solver.extrema {
+		solver.extrema[i] = i * last / solver.r
+	}
+}
+
+// parameters computes the interpolation of the response through the extremal frequencies (Oppenheim & Schafer
+// 7.131 to 7.133)
+func (solver *remezSolver) parameters() {
+
+	r := solver.r
+
+	for i := 0; i <= r; i++ {
+		solver.x[i] = math.Cos(2 * math.Pi * solver.grid[solver.extrema[i]])
+	}
+
+	// The weights of the barycentric formula are products of r differences, which underflow or overflow for long
+	// filters. As the formulas only use their ratios, they are computed as logarithms and scaled by the largest.
+	logs := make([]float64, r+1)
+	signs := make([]float64, r+1)
+	largest := math.Inf(-1)
+	for i := 0; i <= r; i++ {
+		logs[i], signs[i] = 0, 1
+		xi := solver.x[i]
+		for k := 0; k <= r; k++ {
+			if k != i {
+				difference := xi - solver.x[k]
+				if difference < 0 {
+					signs[i] = -signs[i]
+				}
+				logs[i] -= math.Log(math.Abs(difference))
+			}
+		}
+		largest = math.Max(largest, logs[i])
+	}
+	for i := 0; i <= r; i++ {
+		solver.ad[i] = signs[i] * math.Exp(logs[i]-largest)
+	}
+
+	numerator := 0.0
+	denominator := 0.0
+	sign := 1.0
+	for i := 0; i <= r; i++ {
+		extremum := solver.extrema[i]
+		numerator += solver.ad[i] * solver.desired[extremum]
+		denominator += sign * solver.ad[i] / solver.weights[extremum]
+		sign = -sign
+	}
+	delta := numerator / denominator
+	solver.delta = delta
+
+	sign = 1
+	for i := 0; i <= r; i++ {
+		extremum := solver.extrema[i]
+		solver.y[i] = solver.desired[extremum] - sign*delta/solver.weights[extremum]
+		sign = -sign
+	}
+}
+
+// response returns the interpolated response at a frequency, with the barycentric Lagrange formula
+func (solver *remezSolver) response(frequency float64) float64 {
+
+	numerator := 0.0
+	denominator := 0.0
+	xc := math.Cos(2 * math.Pi * frequency)
+
+	for i := 0; i <= solver.r; i++ {
+		c := xc - solver.x[i]
+		if c == 0 {
+			return solver.y[i]
+		}
+		c = solver.ad[i] / c
+		denominator += c
+		numerator += c * solver.y[i]
+	}
+
+	return numerator / denominator
+}
+
+// computeErrors computes the weighted errors over the grid
+func (solver *remezSolver) computeErrors() {
+
+	for i, frequency := range solver.grid {
+		solver.errors[i] = solver.weights[i] * (solver.desired[i] - solver.response(frequency))
+	}
+}
+
+// search finds the new extremal frequencies among the extrema of the errors, and returns false if there are not
+// enough
+func (solver *remezSolver) search() bool {
+
+	e := solver.errors
+	found := make([]int, 0, 2*solver.r)
+
+	// The edges of the bands are candidates whatever their neighbours, the extrema of the bands compare with their
+	// neighbours in the band
+	for i := range e {
+		switch {
+		case e[i] == 0:
+		case solver.edges[i]:
+			found = append(found, i)
+		case (e[i] >= e[i-1] && e[i] > e[i+1] && e[i] > 0) || (e[i] <= e[i-1] && e[i] < e[i+1] && e[i] < 0):
+			found = append(found, i)
+		}
+	}
+
+	if len(found) < solver.r+1 {
+		return false
+	}
+
+	// Of the adjacent extrema of the same sign, the largest is kept
+	alternating := found[:1]
+	for _, index := range found[1:] {
+		previous := alternating[len(alternating)-1]
+		if (e[index] > 0) != (e[previous] > 0) {
+			alternating = append(alternating, index)
+		} else if math.Abs(e[index]) > math.Abs(e[previous]) {
+			alternating[len(alternating)-1] = index
+		}
+	}
+	found = alternating
+
+	if len(found) < solver.r+1 {
+		return false
+	}
+
+	// Remove the extra extrema keeping the alternation: the adjacent pair of smallest errors while there are two extra
+	// extrema or more, then the smallest of the ends
+	for len(found) > solver.r+1 {
+		if len(found)-(solver.r+1) == 1 {
+			if math.Abs(e[found[len(found)-1]]) < math.Abs(e[found[0]]) {
+				found = found[:len(found)-1]
+			} else {
+				found = found[1:]
+			}
+			break
+		}
+		smallest := 0
+		for j := 1; j < len(found)-1; j++ {
+			if math.Abs(e[found[j]])+math.Abs(e[found[j+1]]) < math.Abs(e[found[smallest]])+math.Abs(e[found[smallest+1]]) {
+				smallest = j
+			}
+		}
+		found = append(found[:smallest], found[smallest+2:]...)
+	}
+
+	copy(solver.extrema, found)
+
+	return true
+}
+
+// converged returns true when the errors at the extremal frequencies are equal
+func (solver *remezSolver) converged() bool {
+
+	minimum := math.Abs(solver.errors[solver.extrema[0]])
+	maximum := minimum
+	for _, extremum := range solver.extrema[1:] {
+		current := math.Abs(solver.errors[extremum])
+		minimum = math.Min(minimum, current)
+		maximum = math.Max(maximum, current)
+	}
+
+	return maximum == 0 || (maximum-minimum)/maximum < 0.0001
+}
+
+// maxError returns the maximum weighted error of the response of symmetric taps over the grid
+func (solver *remezSolver) maxError(taps []float64) float64 {
+
+	center := float64(len(taps)-1) / 2
+	maximum := 0.0
+
+	for i, frequency := range solver.grid {
+		response := 0.0
+		for n, tap := range taps {
+			response += tap * math.Cos(2*math.Pi*frequency*(float64(n)-center))
+		}
+		// The desired gains and the weights of an even number of taps are those of P(f)
+		if len(taps)%2 == 0 {
+			response /= math.Cos(math.Pi * frequency)
+		}
+		maximum = math.Max(maximum, math.Abs(solver.weights[i]*(solver.desired[i]-response)))
+	}
+
+	return maximum
+}
+
+// frequencySampling computes the taps of a symmetric filter from samples of its amplitude response at the
+// frequencies k/numTaps
+func frequencySampling(numTaps int, samples []float64) []float64 {
+
+	taps := make([]float64, numTaps)
+	center := float64(numTaps-1) / 2
+
+	last := numTaps / 2
+	if numTaps%2 == 0 {
+		last = numTaps/2 - 1
+	}
+
+	for n := range taps {
+		value := samples[0]
+		x := 2 * math.Pi * (float64(n) - center) / float64(numTaps)
+		for k := 1; k <= last; k++ {
+			value += 2 * samples[k] * math.Cos(x*float64(k))
+		}
+		taps[n] = value / float64(numTaps)
+	}
+
+	return taps
+}
@@ -0,0 +1,189 @@
+package filter
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// responsePoints is the number of frequencies between 0 and 0.5 where the responses are measured
+const responsePoints = 8192
+
+// amplitude returns the amplitude response of a filter at a frequency
+func amplitude(taps []float64, frequency float64) float64 {
+	return cmplx.Abs(Response(taps, frequency))
+}
+
+// measure returns the largest deviation from 1 of the amplitude response in the pass bands, and the largest amplitude
+// in the stop bands. The bands are pairs of frequencies.
+func measure(taps []float64, passbands []float64, stopbands []float64) (ripple float64, stopband float64) {
+
+	for i := 0; i <= responsePoints; i++ {
+		frequency := 0.5 * float64(i) / responsePoints
+		for band := 0; band < len(passbands); band += 2 {
+			if frequency >= passbands[band] && frequency <= passbands[band+1] {
+				ripple = math.Max(ripple, math.Abs(amplitude(taps, frequency)-1))
+			}
+		}
+		for band := 0; band < len(stopbands); band += 2 {
+			if frequency >= stopbands[band] && frequency <= stopbands[band+1] {
+				stopband = math.Max(stopband, amplitude(taps, frequency))
+			}
+		}
+	}
+
+	return ripple, stopband
+}
+
+// decibels returns an amplitude in dB
+func decibels(amplitude float64) float64 {
+	return 20 * math.Log10(amplitude)
+}
+
+// kaiserAttenuation returns the attenuation in dB of a Kaiser window filter of a number of taps and a transition width
+func kaiserAttenuation(numTaps int, transition float64) float64 {
+	return 14.36*transition*float64(numTaps-1) + 7.95
+}
+
+// TestRemezLowpass checks the equiripple lowpass filters over lengths of both parities, including long filters whose
+// reference used to be lost or whose weights underflowed
+func TestRemezLowpass(t *testing.T) {
+
+	tests := []struct {
+		cutoff     float64
+		transition float64
+		lengths    []int
+	}{
+		{0.2, 0.05, []int{11, 30, 51, 72, 101, 150, 191, 201, 211, 231, 251, 252, 271, 291}},
+		{0.05, 0.05, []int{12, 22, 61, 132, 262}},
+		{0.1, 0.05, []int{32, 45, 64, 127}},
+		{0.25, 0.02, []int{101, 202, 301, 401}},
+		{0.2, 0.01, []int{151, 400, 401}},
+	}
+
+	for _, test := range tests {
+		for _, numTaps := range test.lengths {
+
+			passband := test.cutoff - test.transition/2
+			stopband := test.cutoff + test.transition/2
+
+			taps, err := Remez(numTaps, []float64{0, passband, stopband, 0.5}, []float64{1, 0}, nil)
+			if err != nil {
+				t.Errorf("cutoff %v, transition %v, %d taps: %v", test.cutoff, test.transition, numTaps, err)
+				continue
+			}
+			if len(taps) != numTaps {
+				t.Fatalf("%d taps designed instead of %d", len(taps), numTaps)
+			}
+
+			ripple, attenuation := measure(taps, []float64{0, passband}, []float64{stopband, 0.5})
+
+			// An optimal filter does about as well as a Kaiser window filter of the same length, down to the precision
+			expected := math.Min(0.9*kaiserAttenuation(numTaps, test.transition), 130)
+			if -decibels(attenuation) < expected {
+				t.Errorf("cutoff %v, transition %v, %d taps: stopband at %.1f dB, expected below -%.1f dB",
+					test.cutoff, test.transition, numTaps, decibels(attenuation), expected)
+			}
+
+			// Equal weights give equal errors in both bands
+			if math.Abs(ripple-attenuation) > 0.05*ripple+remezPrecision {
+				t.Errorf("cutoff %v, transition %v, %d taps: passband ripple %g and stopband %g are not equal",
+					test.cutoff, test.transition, numTaps, ripple, attenuation)
+			}
+		}
+	}
+}
+
+// TestRemezWeights checks that the errors of the bands are in the inverse ratio of their weights
+func TestRemezWeights(t *testing.T) {
+
+	for _, weight := range []float64{0.1, 10, 100} {
+
+		taps, err := Remez(61, []float64{0, 0.1, 0.15, 0.5}, []float64{1, 0}, []float64{1, weight})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ripple, attenuation := measure(taps, []float64{0, 0.1}, []float64{0.15, 0.5})
+		if ratio := ripple / attenuation; math.Abs(ratio-weight) > 0.05*weight {
+			t.Errorf("stopband weight %v: the ratio of the errors is %v", weight, ratio)
+		}
+	}
+}
+
+// TestRemezDesign checks the filters of each type designed with the Remez method
+func TestRemezDesign(t *testing.T) {
+
+	tests := []struct {
+		specification Specification
+		passbands     []float64
+		stopbands     []float64
+	}{
+		{Specification{Type: Lowpass, Cutoff: 0.1, Transition: 0.04, NumTaps: 80},
+			[]float64{0, 0.08}, []float64{0.12, 0.5}},
+		{Specification{Type: Highpass, Cutoff: 0.3, Transition: 0.04, NumTaps: 81},
+			[]float64{0.32, 0.5}, []float64{0, 0.28}},
+		{Specification{Type: Bandpass, Cutoff: 0.1, HighCutoff: 0.3, Transition: 0.04, NumTaps: 121},
+			[]float64{0.12, 0.28}, []float64{0, 0.08, 0.32, 0.5}},
+		{Specification{Type: Bandstop, Cutoff: 0.1, HighCutoff: 0.3, Transition: 0.04, NumTaps: 121},
+			[]float64{0, 0.08, 0.32, 0.5}, []float64{0.12, 0.28}},
+		{Specification{Type: Lowpass, Cutoff: 0.1, Transition: 0.02, Attenuation: 90},
+			[]float64{0, 0.09}, []float64{0.11, 0.5}},
+	}
+
+	for _, test := range tests {
+
+		test.specification.Method = MethodRemez
+		taps, err := Design(test.specification)
+		if err != nil {
+			t.Fatalf("%v: %v", test.specification.Type, err)
+		}
+
+		ripple, attenuation := measure(taps, test.passbands, test.stopbands)
+		expected := 0.9 * kaiserAttenuation(len(taps), test.specification.Transition)
+		if -decibels(attenuation) < expected || ripple > attenuation*1.05 {
+			t.Errorf("%v: passband ripple %g, stopband at %.1f dB, expected below -%.1f dB",
+				test.specification.Type, ripple, decibels(attenuation), expected)
+		}
+	}
+
+	// The highpass filters need an odd number of taps
+	if _, err := Design(Specification{Type: Highpass, Method: MethodRemez, Cutoff: 0.3, Transition: 0.04, NumTaps: 80}); err == nil {
+		t.Error("a highpass filter of an even number of taps was designed")
+	}
+}
+
+// TestRemezPrecision checks that the filters whose ripple is below the precision of the algorithm are rejected
+// instead of returning wrong taps
+func TestRemezPrecision(t *testing.T) {
+
+	for _, numTaps := range []int{301, 401} {
+		taps, err := Remez(numTaps, []float64{0, 0.175, 0.225, 0.5}, []float64{1, 0}, nil)
+		if err == nil {
+			ripple, attenuation := measure(taps, []float64{0, 0.175}, []float64{0.225, 0.5})
+			if ripple > remezPrecision*2 || attenuation > remezPrecision*2 {
+				t.Errorf("%d taps: passband ripple %g and stopband %g without error", numTaps, ripple, attenuation)
+			}
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package power
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+
+	"github.com/bhojpur/sdr/pkg/device"
+)
+
+// ErrNotCalibrated is returned when a calibration has no point for the antenna of a receiver
+var ErrNotCalibrated = errors.New("the calibration has no point for the settings of the receiver")
+
+// Settings are the settings of a receiver changing the absolute level of its samples
+type Settings struct {
+	// Frequency is the center frequency in Hz
+	Frequency float64 `json:"frequency"`
+	// Antenna is the name of the selected antenna
+	Antenna string `json:"antenna,omitempty"`
+	// Gain is the overall gain in dB
+	Gain float64 `json:"gain"`
+	// Elements are the gains in dB of the gain elements by name, if known
+	Elements map[string]float64 `json:"elements,omitempty"`
+}
+
+// ReadSettings reads the settings of a RX channel of a device.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+//
+// Return the settings
+func ReadSettings(dev *device.SDRDevice, channel uint) Settings {
+
+	settings := Settings{
+		Frequency: dev.GetFrequency(device.DirectionRX, channel),
+		Antenna:   dev.GetAntennas(device.DirectionRX, channel),
+		Gain:      dev.GetGain(device.DirectionRX, channel),
+	}
+
+	names := dev.ListGains(device.DirectionRX, channel)
+	if len(names) > 0 {
+		settings.Elements = make(map[string]float64, len(names))
+		for _, name := range names {
+			settings.Elements[name] = dev.GetGainElement(device.DirectionRX, channel, name)
+		}
+	}
+
+	return settings
+}
+
+// CalibrationPoint is a measurement of the level of a receiver with given settings, usually made with a signal
+// generator of known power
+type CalibrationPoint struct {
+	Settings
+	// FullScaleDBm is the power in dBm at the antenna input of a full scale signal (0 dBFS) with the settings
+	FullScaleDBm float64 `json:"full_scale_dbm"`
+}
+
+// Calibration is the calibration of the RX channel of a device: a set of points measured at various frequencies,
+// antennas and gains. The full scale power for other settings is interpolated linearly in frequency between the
+// nearest points, and corrected by the difference of gain with the nearest point in gain.
+type Calibration struct {
+	// Device identifies the calibrated device, for example its serial number
+	Device string `json:"device,omitempty"`
+	// Points are the calibration points
+	Points []CalibrationPoint `json:"points"`
+}
+
+// LoadCalibration reads a calibration from a JSON file.
+//
+// Params:
+//  - path: the path of the file
+//
+// Return the calibration or an error
+func LoadCalibration(path string) (calibration *Calibration, err error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	calibration = &Calibration{}
+	if err := json.Unmarshal(data, calibration); err != nil {
+		return nil, err
+	}
+
+	return calibration, nil
+}
+
+// Save writes the calibration to a JSON file.
+//
+// Params:
+//  - path: the path of the file
+//
+// Return an error or nil in case of success
+func (calibration *Calibration) Save(path string) error {
+
+	data, err := json.MarshalIndent(calibration, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// FullScaleDBm returns the power in dBm at the antenna input of a full scale signal for the settings of a receiver.
+// The points of other antennas are ignored, the points without antenna match any antenna.
+//
+// Params:
+//  - settings: the settings of the receiver
+//
+// Return the power in dBm, or ErrNotCalibrated if no point matches the antenna
+func (calibration *Calibration) FullScaleDBm(settings Settings) (float64, error) {
+
+	// The nearest frequencies below and above the frequency of the settings
+	below := math.Inf(-1)
+	above := math.Inf(1)
+	found := false
+
+	for _, point := range calibration.Points {
+
+		if point.Antenna != "" && point.Antenna != settings.Antenna {
+			continue
+		}
+		found = true
+
+		if point.Frequency <= settings.Frequency && point.Frequency > below {
+			below = point.Frequency
+		}
+		if point.Frequency >= settings.Frequency && point.Frequency < above {
+			above = point.Frequency
+		}
+	}
+
+	if !found {
+		return 0, ErrNotCalibrated
+	}
+
+	switch {
+	case math.IsInf(below, -1):
+		return calibration.atFrequency(above, settings), nil
+	case math.IsInf(above, 1) || above == below:
+		return calibration.atFrequency(below, settings), nil
+	}
+
+	lower := calibration.atFrequency(below, settings)
+	upper := calibration.atFrequency(above, settings)
+	ratio := (settings.Frequency - below) / (above - below)
+
+	return lower + ratio*(upper-lower), nil
+}
+
+// atFrequency returns the full scale power of the settings from the point of a frequency nearest to them in gain
+func (calibration *Calibration) atFrequency(frequency float64, settings Settings) float64 {
+
+	var nearest *CalibrationPoint
+	distance := math.Inf(1)
+
+	for i := range calibration.Points {
+
+		point := &calibration.Points[i]
+		if point.Frequency != frequency || (point.Antenna != "" && point.Antenna != settings.Antenna) {
+			continue
+		}
+
+		if d := gainDistance(point.Settings, settings); d < distance {
+			nearest = point
+			distance = d
+		}
+	}
+
+	return nearest.FullScaleDBm - gainDifference(nearest.Settings, settings)
+}
+
+// byElement returns true when the gains of the settings can be compared with the gains of the point element by element,
+// both knowing the gains of the same elements. Otherwise, the overall gains are compared.
+func byElement(point Settings, settings Settings) bool {
+
+	if len(settings.Elements) == 0 || len(point.Elements) != len(settings.Elements) {
+		return false
+	}
+
+	for name := range settings.Elements {
+		if _, ok := point.Elements[name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// gainDifference returns the gain of the settings minus the gain of the point, by element when both know the gains of
+// the same elements
+func gainDifference(point Settings, settings Settings) float64 {
+
+	if !byElement(point, settings) {
+		return settings.Gain - point.Gain
+	}
+
+	difference := 0.0
+	for name, gain := range settings.Elements {
+		difference += gain - point.Elements[name]
+	}
+
+	return difference
+}
+
+// gainDistance returns how far the gains of the settings are from the gains of the point
+func gainDistance(point Settings, settings Settings) float64 {
+
+	if !byElement(point, settings) {
+		return math.Abs(settings.Gain - point.Gain)
+	}
+
+	sum := 0.0
+	for name, gain := range settings.Elements {
+		difference := gain - point.Elements[name]
+		sum += difference * difference
+	}
+
+	return math.Sqrt(sum)
+}
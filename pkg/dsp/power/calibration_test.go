@@ -0,0 +1,155 @@
+package power
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// point returns a calibration point without gain elements
+func point(frequency float64, antenna string, gain float64, fullScaleDBm float64) CalibrationPoint {
+	return CalibrationPoint{
+		Settings:     Settings{Frequency: frequency, Antenna: antenna, Gain: gain},
+		FullScaleDBm: fullScaleDBm,
+	}
+}
+
+// elementsPoint returns a calibration point with the gains of a LNA and a VGA
+func elementsPoint(frequency float64, lna float64, vga float64, fullScaleDBm float64) CalibrationPoint {
+	return CalibrationPoint{
+		Settings: Settings{
+			Frequency: frequency,
+			Gain:      lna + vga,
+			Elements:  map[string]float64{"LNA": lna, "VGA": vga},
+		},
+		FullScaleDBm: fullScaleDBm,
+	}
+}
+
+// TestFullScaleDBm checks the interpolation in frequency, the correction of gain and the selection of the points by
+// antenna
+func TestFullScaleDBm(t *testing.T) {
+
+	points := []CalibrationPoint{
+		point(100e6, "", 0, -10),
+		point(200e6, "", 0, -20),
+		point(200e6, "", 30, -50),
+		point(150e6, "RX2", 0, 0),
+	}
+
+	tests := []struct {
+		name     string
+		points   []CalibrationPoint
+		settings Settings
+		expected float64
+		err      error
+	}{
+		{"point", points, Settings{Frequency: 100e6}, -10, nil},
+		{"interpolation", points, Settings{Frequency: 125e6}, -12.5, nil},
+		{"below the first point", points, Settings{Frequency: 50e6}, -10, nil},
+		{"above the last point", points, Settings{Frequency: 1e9}, -20, nil},
+		{"gain correction", points, Settings{Frequency: 100e6, Gain: 10}, -20, nil},
+		{"nearest gain", points, Settings{Frequency: 200e6, Gain: 25}, -45, nil},
+		{"interpolation of the nearest gains", points, Settings{Frequency: 150e6, Gain: 20}, -35, nil},
+		{"antenna", points, Settings{Frequency: 150e6, Antenna: "RX2"}, 0, nil},
+		// The points without antenna match RX2 at 100 MHz
+		{"antenna interpolation", points, Settings{Frequency: 125e6, Antenna: "RX2"}, -5, nil},
+		{"other antenna", points, Settings{Frequency: 150e6, Antenna: "TX/RX"}, -15, nil},
+		{"no point of the antenna", []CalibrationPoint{point(100e6, "RX2", 0, 0)}, Settings{Antenna: "TX/RX"}, 0,
+			ErrNotCalibrated},
+		{"no point", nil, Settings{Frequency: 100e6}, 0, ErrNotCalibrated},
+		{
+			"elements",
+			[]CalibrationPoint{elementsPoint(100e6, 10, 10, -30), elementsPoint(100e6, 30, 0, -40)},
+			Settings{Frequency: 100e6, Gain: 25, Elements: map[string]float64{"LNA": 10, "VGA": 15}},
+			-35,
+			nil,
+		},
+		{
+			// The overall gains are compared, not the VGA gain with 0 dB
+			"element unknown to the point",
+			[]CalibrationPoint{{
+				Settings:     Settings{Frequency: 100e6, Gain: 20, Elements: map[string]float64{"LNA": 10}},
+				FullScaleDBm: -30,
+			}},
+			Settings{Frequency: 100e6, Gain: 25, Elements: map[string]float64{"LNA": 10, "VGA": 15}},
+			-35,
+			nil,
+		},
+		{
+			"element unknown to the settings",
+			[]CalibrationPoint{elementsPoint(100e6, 10, 10, -30)},
+			Settings{Frequency: 100e6, Gain: 25, Elements: map[string]float64{"LNA": 20}},
+			-35,
+			nil,
+		},
+		{
+			"settings without elements",
+			[]CalibrationPoint{elementsPoint(100e6, 10, 10, -30)},
+			Settings{Frequency: 100e6, Gain: 25},
+			-35,
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+
+		calibration := &Calibration{Points: test.points}
+		fullScaleDBm, err := calibration.FullScaleDBm(test.settings)
+
+		if err != test.err {
+			t.Errorf("%s: error %v, expected %v", test.name, err, test.err)
+			continue
+		}
+		if math.Abs(fullScaleDBm-test.expected) > 1e-9 {
+			t.Errorf("%s: full scale of %v dBm, expected %v dBm", test.name, fullScaleDBm, test.expected)
+		}
+	}
+}
+
+// TestSaveCalibration checks that a saved calibration is loaded unchanged
+func TestSaveCalibration(t *testing.T) {
+
+	calibration := &Calibration{
+		Device: "serial=1234",
+		Points: []CalibrationPoint{point(100e6, "RX2", 20, -30), elementsPoint(200e6, 10, 5, -25)},
+	}
+
+	path := filepath.Join(t.TempDir(), "calibration.json")
+	if err := calibration.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadCalibration(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, calibration) {
+		t.Errorf("loaded %+v, expected %+v", loaded, calibration)
+	}
+
+	if _, err := LoadCalibration(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
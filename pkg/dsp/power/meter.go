@@ -0,0 +1,276 @@
+package power
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the measurement of the power of RX streams: RMS and peak levels, crest factor and clipping, in dBFS or in
+// dBm with a calibration of the receiver.
+
+import (
+	"errors"
+	"math"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+const (
+	// DefaultWindowSize is the number of samples of a measurement when none is given
+	DefaultWindowSize = 4096
+	// DefaultClipLevel is the level, relative to the full scale, of a clipped component when none is given
+	DefaultClipLevel = 0.99
+)
+
+// Options are the options of a meter
+type Options struct {
+	// WindowSize is the number of samples of each measurement, DefaultWindowSize when 0
+	WindowSize int
+	// FullScale is the value of a full scale sample in the unit of the buffers, as returned by
+	// GetNativeStreamFormat, or 0 for the full range of the type of the buffers (1 for the float formats)
+	FullScale float64
+	// ClipLevel is the level, relative to the full scale, above which the I or Q component of a sample is considered
+	// clipped by the ADC, DefaultClipLevel when 0
+	ClipLevel float64
+	// Calibration gives levels in dBm, see SetSettings and Calibrate
+	Calibration *Calibration
+}
+
+// Measurement is the measurement of the power of a window of samples
+type Measurement struct {
+	// NbSamples is the number of samples of the window
+	NbSamples int
+	// RMS is the average power in dBFS. A full scale complex tone reads 0 dBFS.
+	RMS float64
+	// Peak is the power of the strongest sample in dBFS
+	Peak float64
+	// CrestFactor is the ratio of the peak power to the average power in dB
+	CrestFactor float64
+	// Calibrated is true when RMSDBm and PeakDBm are set
+	Calibrated bool
+	// RMSDBm is the average power in dBm at the antenna input
+	RMSDBm float64
+	// PeakDBm is the power of the strongest sample in dBm at the antenna input
+	PeakDBm float64
+	// ClippedSamples is the number of samples with a clipped component
+	ClippedSamples int
+}
+
+// Clipped returns true if the ADC clipped some samples of the window, in which case the levels are underestimated
+func (measurement Measurement) Clipped() bool {
+	return measurement.ClippedSamples > 0
+}
+
+// Meter measures the power of a stream of samples over consecutive windows
+type Meter struct {
+	options    Options
+	offset     float64
+	calibrated bool
+
+	count   int
+	sum     float64
+	peak    float64
+	clipped int
+	samples []complex128
+}
+
+// NewMeter creates a meter.
+//
+// Params:
+//  - options: the options of the meter
+//
+// Return the meter or an error
+func NewMeter(options Options) (meter *Meter, err error) {
+
+	if options.WindowSize == 0 {
+		options.WindowSize = DefaultWindowSize
+	}
+	if options.ClipLevel == 0 {
+		options.ClipLevel = DefaultClipLevel
+	}
+
+	if options.WindowSize < 0 {
+		return nil, errors.New("the window of a meter can not be negative")
+	}
+
+	if options.FullScale < 0 || options.ClipLevel < 0 {
+		return nil, errors.New("the full scale and the clip level of a meter can not be negative")
+	}
+
+	return &Meter{
+		options: options,
+	}, nil
+}
+
+// Options returns the options of the meter, completed with the default values
+func (meter *Meter) Options() Options {
+	return meter.options
+}
+
+// SetSettings sets the settings of the receiver, to convert the levels to dBm with the calibration of the meter.
+//
+// Params:
+//  - settings: the settings of the receiver
+//
+// Return an error if the meter has no calibration or the calibration does not cover the settings
+func (meter *Meter) SetSettings(settings Settings) error {
+
+	meter.calibrated = false
+
+	if meter.options.Calibration == nil {
+		return ErrNotCalibrated
+	}
+
+	fullScaleDBm, err := meter.options.Calibration.FullScaleDBm(settings)
+	if err != nil {
+		return err
+	}
+
+	meter.offset = fullScaleDBm
+	meter.calibrated = true
+
+	return nil
+}
+
+// Calibrate reads the settings of a RX channel of a device, to convert the levels to dBm with the calibration of the
+// meter. It must be called again after changing the frequency, the antenna or the gains of the channel.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+//
+// Return an error if the meter has no calibration or the calibration does not cover the settings
+func (meter *Meter) Calibrate(dev *device.SDRDevice, channel uint) error {
+	return meter.SetSettings(ReadSettings(dev, channel))
+}
+
+// Write processes samples and returns the measurements of the windows completed by the samples.
+//
+// Params:
+//  - buffer: the samples of a single channel, such as a []complex64 or a []int16
+//
+// Return the measurements or an error
+func (meter *Meter) Write(buffer interface{}) (measurements []Measurement, err error) {
+
+	length, err := sdrformat.BufferLength(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	if cap(meter.samples) < length {
+		meter.samples = make([]complex128, length)
+	}
+	samples := meter.samples[:length]
+
+	if _, err := sdrformat.ToComplex128(samples, buffer); err != nil {
+		return nil, err
+	}
+
+	if meter.options.FullScale > 0 {
+		format, _ := sdrformat.FormatOf(buffer)
+		factor := complex(sdrformat.NormalizationScale(format)/meter.options.FullScale, 0)
+		for i := range samples {
+			samples[i] *= factor
+		}
+	}
+
+	return meter.WriteComplex(samples), nil
+}
+
+// WriteComplex processes samples normalized to a full scale of 1 and returns the measurements of the windows completed
+// by the samples.
+//
+// Params:
+//  - samples: the samples
+//
+// Return the measurements
+func (meter *Meter) WriteComplex(samples []complex128) (measurements []Measurement) {
+
+	clipLevel := meter.options.ClipLevel
+
+	for _, sample := range samples {
+
+		power := real(sample)*real(sample) + imag(sample)*imag(sample)
+
+		meter.sum += power
+		if power > meter.peak {
+			meter.peak = power
+		}
+		if math.Abs(real(sample)) >= clipLevel || math.Abs(imag(sample)) >= clipLevel {
+			meter.clipped++
+		}
+
+		meter.count++
+		if meter.count == meter.options.WindowSize {
+			measurements = append(measurements, meter.measurement())
+		}
+	}
+
+	return measurements
+}
+
+// Flush returns the measurement of the samples of the incomplete window, if any, and starts a new window
+func (meter *Meter) Flush() (measurement Measurement, ok bool) {
+
+	if meter.count == 0 {
+		return Measurement{}, false
+	}
+
+	return meter.measurement(), true
+}
+
+// Reset drops the samples of the incomplete window
+func (meter *Meter) Reset() {
+
+	meter.count = 0
+	meter.sum = 0
+	meter.peak = 0
+	meter.clipped = 0
+}
+
+// measurement returns the measurement of the current window and starts a new window
+func (meter *Meter) measurement() Measurement {
+
+	measurement := Measurement{
+		NbSamples:      meter.count,
+		RMS:            decibels(meter.sum / float64(meter.count)),
+		Peak:           decibels(meter.peak),
+		ClippedSamples: meter.clipped,
+	}
+
+	measurement.CrestFactor = measurement.Peak - measurement.RMS
+	if meter.peak == 0 {
+		measurement.CrestFactor = 0
+	}
+
+	if meter.calibrated {
+		measurement.Calibrated = true
+		measurement.RMSDBm = measurement.RMS + meter.offset
+		measurement.PeakDBm = measurement.Peak + meter.offset
+	}
+
+	meter.Reset()
+
+	return measurement
+}
+
+// decibels converts a power ratio to dB
+func decibels(power float64) float64 {
+	return 10 * math.Log10(power)
+}
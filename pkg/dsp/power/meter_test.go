@@ -0,0 +1,244 @@
+package power
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// tone returns a complex tone of an amplitude relative to the full scale
+func tone(length int, amplitude float64) []complex128 {
+
+	samples := make([]complex128, length)
+	for i := range samples {
+		samples[i] = complex(amplitude, 0) * cmplx.Exp(complex(0, 2*math.Pi*float64(i)/16))
+	}
+
+	return samples
+}
+
+// TestMeter checks the levels, the crest factor and the clipping of the measurements of normalized samples
+func TestMeter(t *testing.T) {
+
+	// A tone at -20 dBFS with a single sample at full scale
+	pulse := tone(100, 0.1)
+	pulse[50] = 1
+
+	tests := []struct {
+		name        string
+		samples     []complex128
+		rms         float64
+		peak        float64
+		crestFactor float64
+		clipped     int
+	}{
+		// The components of the tone reach the full scale at every 4th sample
+		{"full scale tone", tone(100, 1), 0, 0, 0, 25},
+		{"tone at -20 dBFS", tone(100, 0.1), -20, -20, 0, 0},
+		{"pulse", pulse, decibels((99*0.01 + 1) / 100), 0, -decibels((99*0.01 + 1) / 100), 1},
+		{"real full scale", []complex128{1, -1, 1, -1}, 0, 0, 0, 4},
+		{"below the clip level", []complex128{0.98, complex(0, -0.98)}, decibels(0.98 * 0.98), decibels(0.98 * 0.98), 0, 0},
+		{"silence", make([]complex128, 10), math.Inf(-1), math.Inf(-1), 0, 0},
+	}
+
+	for _, test := range tests {
+
+		meter, err := NewMeter(Options{WindowSize: len(test.samples)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		measurements := meter.WriteComplex(test.samples)
+		if len(measurements) != 1 {
+			t.Fatalf("%s: %d measurements, expected 1", test.name, len(measurements))
+		}
+
+		measurement := measurements[0]
+		if measurement.NbSamples != len(test.samples) {
+			t.Errorf("%s: %d samples, expected %d", test.name, measurement.NbSamples, len(test.samples))
+		}
+		if !near(measurement.RMS, test.rms) || !near(measurement.Peak, test.peak) {
+			t.Errorf("%s: RMS %v dBFS and peak %v dBFS, expected %v and %v", test.name, measurement.RMS,
+				measurement.Peak, test.rms, test.peak)
+		}
+		if !near(measurement.CrestFactor, test.crestFactor) {
+			t.Errorf("%s: crest factor %v dB, expected %v", test.name, measurement.CrestFactor, test.crestFactor)
+		}
+		if measurement.ClippedSamples != test.clipped || measurement.Clipped() != (test.clipped > 0) {
+			t.Errorf("%s: %d clipped samples, expected %d", test.name, measurement.ClippedSamples, test.clipped)
+		}
+		if measurement.Calibrated {
+			t.Errorf("%s: calibrated without calibration", test.name)
+		}
+	}
+}
+
+// near returns true if two levels in dB are equal within 1e-9, or both infinite of the same sign
+func near(level float64, expected float64) bool {
+	return level == expected || math.Abs(level-expected) < 1e-9
+}
+
+// TestMeterFormats checks the levels and the clipping of the measurements of int16 and CU8 buffers
+func TestMeterFormats(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		fullScale float64
+		buffer    interface{}
+		rms       float64
+		clipped   int
+	}{
+		{"int16 full scale", 0, []int16{32767, 0, 0, -32768, -32768, 0, 0, 32767}, decibels((32767.0*32767/32768/32768 + 1) / 2), 4},
+		{"int16 half scale", 0, []int16{16384, 0, 0, -16384, -16384, 0, 0, 16384}, decibels(0.25), 0},
+		// A 12 bits ADC in 16 bits samples
+		{"int16 12 bits", 2048, []int16{2047, 0, 0, -2048, -2048, 0, 0, 2047}, decibels((2047.0*2047/2048/2048 + 1) / 2), 4},
+		{"int16 12 bits half scale", 2048, []int16{1024, 0, 0, -1024, -1024, 0, 0, 1024}, decibels(0.25), 0},
+		{"CU8 full scale", 0, []uint8{255, 128, 128, 0, 0, 128, 128, 255}, decibels((127.0*127/128/128 + 1) / 2), 4},
+		{"CU8 half scale", 0, []uint8{192, 128, 128, 64, 64, 128, 128, 192}, decibels(0.25), 0},
+		{"CU8 silence", 0, []uint8{128, 128, 128, 128, 128, 128, 128, 128}, math.Inf(-1), 0},
+	}
+
+	for _, test := range tests {
+
+		meter, err := NewMeter(Options{WindowSize: 4, FullScale: test.fullScale})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		measurements, err := meter.Write(test.buffer)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(measurements) != 1 {
+			t.Fatalf("%s: %d measurements, expected 1", test.name, len(measurements))
+		}
+
+		if !near(measurements[0].RMS, test.rms) {
+			t.Errorf("%s: RMS %v dBFS, expected %v", test.name, measurements[0].RMS, test.rms)
+		}
+		if measurements[0].ClippedSamples != test.clipped {
+			t.Errorf("%s: %d clipped samples, expected %d", test.name, measurements[0].ClippedSamples, test.clipped)
+		}
+	}
+
+	meter, _ := NewMeter(Options{})
+	if _, err := meter.Write([]string{"samples"}); err == nil {
+		t.Error("expected an error for an unknown buffer type")
+	}
+}
+
+// TestMeterWindows checks the measurements of the windows completed over several writes and of an incomplete window
+func TestMeterWindows(t *testing.T) {
+
+	meter, err := NewMeter(Options{WindowSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if measurements := meter.WriteComplex(tone(7, 1)); len(measurements) != 0 {
+		t.Fatalf("%d measurements of an incomplete window", len(measurements))
+	}
+
+	// 3 samples complete the first window, 10 the second, 2 remain
+	measurements := meter.WriteComplex(tone(15, 0.1))
+	if len(measurements) != 2 {
+		t.Fatalf("%d measurements, expected 2", len(measurements))
+	}
+	if rms := decibels((7 + 3*0.01) / 10); !near(measurements[0].RMS, rms) || measurements[0].ClippedSamples != 2 {
+		t.Errorf("first window of %v dBFS with %d clipped samples, expected %v dBFS with 2", measurements[0].RMS,
+			measurements[0].ClippedSamples, rms)
+	}
+	if !near(measurements[1].RMS, -20) || measurements[1].ClippedSamples != 0 {
+		t.Errorf("second window of %v dBFS with %d clipped samples, expected -20 dBFS with none",
+			measurements[1].RMS, measurements[1].ClippedSamples)
+	}
+
+	measurement, ok := meter.Flush()
+	if !ok || measurement.NbSamples != 2 || !near(measurement.RMS, -20) {
+		t.Errorf("flushed %d samples of %v dBFS, expected 2 of -20 dBFS", measurement.NbSamples, measurement.RMS)
+	}
+	if _, ok := meter.Flush(); ok {
+		t.Error("flushed an empty window")
+	}
+
+	meter.WriteComplex(tone(5, 1))
+	meter.Reset()
+	if _, ok := meter.Flush(); ok {
+		t.Error("flushed a reset window")
+	}
+}
+
+// TestMeterCalibration checks the levels in dBm of a calibrated meter
+func TestMeterCalibration(t *testing.T) {
+
+	meter, err := NewMeter(Options{WindowSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := meter.SetSettings(Settings{Frequency: 100e6}); err != ErrNotCalibrated {
+		t.Errorf("error %v without calibration, expected ErrNotCalibrated", err)
+	}
+
+	calibration := &Calibration{Points: []CalibrationPoint{point(100e6, "RX2", 10, -20)}}
+	meter, err = NewMeter(Options{WindowSize: 16, Calibration: calibration})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := meter.SetSettings(Settings{Frequency: 100e6, Antenna: "TX/RX"}); err != ErrNotCalibrated {
+		t.Errorf("error %v for another antenna, expected ErrNotCalibrated", err)
+	}
+
+	if err := meter.SetSettings(Settings{Frequency: 100e6, Antenna: "RX2", Gain: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	measurement := meter.WriteComplex(tone(16, 0.1))[0]
+	if !measurement.Calibrated || !near(measurement.RMSDBm, -50) || !near(measurement.PeakDBm, -50) {
+		t.Errorf("levels of %v dBm and %v dBm (calibrated %v), expected -50 dBm", measurement.RMSDBm,
+			measurement.PeakDBm, measurement.Calibrated)
+	}
+
+	// A failed calibration leaves the levels in dBFS only
+	meter.SetSettings(Settings{Antenna: "TX/RX"})
+	if measurement := meter.WriteComplex(tone(16, 0.1))[0]; measurement.Calibrated {
+		t.Error("levels in dBm after a failed calibration")
+	}
+}
+
+// TestNewMeter checks the default and invalid options
+func TestNewMeter(t *testing.T) {
+
+	meter, err := NewMeter(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options := meter.Options(); options.WindowSize != DefaultWindowSize || options.ClipLevel != DefaultClipLevel {
+		t.Errorf("options %+v, expected the defaults", options)
+	}
+
+	for _, options := range []Options{{WindowSize: -1}, {FullScale: -1}, {ClipLevel: -0.5}} {
+		if _, err := NewMeter(options); err == nil {
+			t.Errorf("expected an error for the options %+v", options)
+		}
+	}
+}
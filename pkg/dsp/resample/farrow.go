@@ -0,0 +1,159 @@
+package resample
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"math"
+
+	"github.com/bhojpur/sdr/pkg/dsp/filter"
+)
+
+// Farrow resamples a stream by an arbitrary ratio, which can change while streaming, with a cubic Lagrange
+// interpolator in the Farrow structure. The interpolation is accurate for signals occupying a fraction of their band;
+// when decimating, a lowpass filter first removes the frequencies above the output band.
+type Farrow struct {
+	ratio     float64
+	step      float64
+	position  float64
+	prefilter *filter.ComplexFIR
+	filtered  []complex64
+	history   []complex64
+	work      []complex64
+}
+
+// farrowHistory is the number of previous samples used by the interpolator
+const farrowHistory = 3
+
+// NewFarrow creates an arbitrary ratio resampler.
+//
+// Params:
+//  - ratio: the ratio of the output and input sample rates
+//  - attenuation: the attenuation in dB of the aliases by the lowpass filter when the ratio is below 1,
+//    DefaultAttenuation when 0
+//
+// Return the resampler or an error
+func NewFarrow(ratio float64, attenuation float64) (farrow *Farrow, err error) {
+
+	if !(ratio > 0) || math.IsInf(ratio, 1) {
+		return nil, errors.New("the ratio of a resampler must be positive")
+	}
+
+	if attenuation == 0 {
+		attenuation = DefaultAttenuation
+	}
+
+	farrow = &Farrow{
+		position: 1,
+		history:  make([]complex64, farrowHistory),
+	}
+	farrow.SetRatio(ratio)
+
+	if ratio < 1 {
+		taps, err := filter.Design(filter.Specification{
+			Type:        filter.Lowpass,
+			Cutoff:      0.5 * ratio,
+			Transition:  0.1 * ratio,
+			Attenuation: attenuation,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if farrow.prefilter, err = filter.NewComplexFIR(taps); err != nil {
+			return nil, err
+		}
+	}
+
+	return farrow, nil
+}
+
+// SetRatio changes the ratio of the output and input sample rates, to follow the drift of a clock for example. The
+// lowpass filter is not changed.
+//
+// Params:
+//  - ratio: the ratio of the output and input sample rates
+func (farrow *Farrow) SetRatio(ratio float64) {
+
+	farrow.ratio = ratio
+	farrow.step = 1 / ratio
+}
+
+// Ratio returns the ratio of the output and input sample rates
+func (farrow *Farrow) Ratio() float64 {
+	return farrow.ratio
+}
+
+// Resample resamples a block of samples and appends the outputs to a slice.
+//
+// Params:
+//  - dst: the slice receiving the outputs
+//  - src: the input samples
+//
+// Return the slice with the outputs appended
+func (farrow *Farrow) Resample(dst []complex64, src []complex64) []complex64 {
+
+	if farrow.prefilter != nil {
+		farrow.filtered = farrow.prefilter.Filter(farrow.filtered[:0], src)
+		src = farrow.filtered
+	}
+
+	farrow.work = append(append(farrow.work[:0], farrow.history...), src...)
+
+	// The position of the output between the samples 1 and 2 of the 4 samples of the interpolation
+	for {
+		n := int(farrow.position)
+		if n+2 >= len(farrow.work) {
+			break
+		}
+
+		mu := float32(farrow.position - float64(n))
+		x0 := farrow.work[n-1]
+		x1 := farrow.work[n]
+		x2 := farrow.work[n+1]
+		x3 := farrow.work[n+2]
+
+		c1 := -x0/3 - x1/2 + x2 - x3/6
+		c2 := x0/2 - x1 + x2/2
+		c3 := -x0/6 + x1/2 - x2/2 + x3/6
+
+		m := complex(mu, 0)
+		dst = append(dst, ((c3*m+c2)*m+c1)*m+x1)
+
+		farrow.position += farrow.step
+	}
+	farrow.position -= float64(len(src))
+
+	copy(farrow.history, farrow.work[len(src):])
+
+	return dst
+}
+
+// Reset clears the state of the resampler
+func (farrow *Farrow) Reset() {
+
+	farrow.position = 1
+	for i := range farrow.history {
+		farrow.history[i] = 0
+	}
+	if farrow.prefilter != nil {
+		farrow.prefilter.Reset()
+	}
+}
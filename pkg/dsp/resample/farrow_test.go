@@ -0,0 +1,50 @@
+package resample
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"testing"
+)
+
+// TestFarrow checks the frequency and the amplitude of a tone resampled by arbitrary ratios
+func TestFarrow(t *testing.T) {
+
+	for _, ratio := range []float64{0.37, 0.9, 1.0001, 1.7, 3.14} {
+
+		farrow, err := NewFarrow(ratio, 80)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The cubic interpolation is accurate for the signals occupying a fraction of the band
+		frequency := 0.02 * math.Min(ratio, 1)
+		outputs := resampleBlocks(farrow, tone(frequency, 20000), 999)
+		if expected := 20000 * ratio; math.Abs(float64(len(outputs))-expected) > 2 {
+			t.Fatalf("ratio %v: %d outputs instead of %v", ratio, len(outputs), expected)
+		}
+
+		amplitude, residual := toneError(outputs[len(outputs)/4:], frequency/ratio)
+		if math.Abs(amplitude-1) > 1e-3 || residual > 1e-3 {
+			t.Errorf("ratio %v: amplitude %v with residual %v", ratio, amplitude, residual)
+		}
+	}
+}
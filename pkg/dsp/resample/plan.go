@@ -0,0 +1,218 @@
+package resample
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/bhojpur/sdr/pkg/device"
+)
+
+// DefaultMaxFactor is the largest interpolation or decimation factor considered by PlanRate when none is given
+const DefaultMaxFactor = 1024
+
+// rateTolerance is the relative tolerance of the comparisons of sample rates
+const rateTolerance = 1e-9
+
+// Plan is a hardware sample rate and the resampling giving a requested rate from it
+type Plan struct {
+	// RequestedRate is the requested sample rate in samples per second
+	RequestedRate float64
+	// HardwareRate is the sample rate of the device in samples per second
+	HardwareRate float64
+	// Interpolation is the interpolation factor of the rational resampler, 0 if a Farrow resampler is needed
+	Interpolation int
+	// Decimation is the decimation factor of the rational resampler, 0 if a Farrow resampler is needed
+	Decimation int
+}
+
+// Exact returns true if the requested rate is an exact rational ratio of the hardware rate
+func (plan Plan) Exact() bool {
+	return plan.Interpolation > 0
+}
+
+// Ratio returns the ratio of the requested and hardware sample rates
+func (plan Plan) Ratio() float64 {
+
+	if plan.Exact() {
+		return float64(plan.Interpolation) / float64(plan.Decimation)
+	}
+
+	return plan.RequestedRate / plan.HardwareRate
+}
+
+// NewResampler creates the resampler of the plan, nil if the hardware rate is the requested rate.
+//
+// Params:
+//  - attenuation: the attenuation in dB of the images and aliases, DefaultAttenuation when 0
+//
+// Return the resampler or an error
+func (plan Plan) NewResampler(attenuation float64) (Resampler, error) {
+
+	if plan.Exact() {
+		if plan.Interpolation == 1 && plan.Decimation == 1 {
+			return nil, nil
+		}
+		return NewRational(plan.Interpolation, plan.Decimation, attenuation)
+	}
+
+	return NewFarrow(plan.Ratio(), attenuation)
+}
+
+// PlanRate chooses the hardware sample rate giving a requested rate. It looks for a rate of the ranges equal to the
+// requested rate times a ratio M/L of small factors, preferring the smallest factors and then decimation (M >= L),
+// which keeps the whole band of the requested rate. Without such a rate, it chooses the lowest rate above the
+// requested rate, or the highest rate below, with a Farrow resampler.
+//
+// Params:
+//  - ranges: the ranges of the sample rates of the device, see GetSampleRateRange
+//  - requested: the requested sample rate in samples per second
+//  - maxFactor: the largest factor L or M considered, DefaultMaxFactor when 0
+//
+// Return the plan or an error if the ranges are empty
+func PlanRate(ranges device.SDRRanges, requested float64, maxFactor int) (plan Plan, err error) {
+
+	if !(requested > 0) {
+		return Plan{}, errors.New("the requested sample rate must be positive")
+	}
+
+	if len(ranges) == 0 {
+		return Plan{}, errors.New("the device has no sample rate range")
+	}
+
+	if maxFactor == 0 {
+		maxFactor = DefaultMaxFactor
+	}
+
+	plan = Plan{RequestedRate: requested}
+
+	// The candidates of a same largest factor are compared together
+	for factor := 1; factor <= maxFactor; factor++ {
+
+		found := false
+		for other := 1; other <= factor; other++ {
+
+			if gcd(factor, other) != 1 {
+				continue
+			}
+
+			// Decimation by factor/other, then interpolation by factor/other
+			for _, pair := range [][2]int{{other, factor}, {factor, other}} {
+
+				interpolation, decimation := pair[0], pair[1]
+				rate := requested * float64(decimation) / float64(interpolation)
+				if !sameRate(ranges.Nearest(rate), rate) {
+					continue
+				}
+
+				if !found || betterRate(interpolation, decimation, rate, plan) {
+					plan.HardwareRate = rate
+					plan.Interpolation = interpolation
+					plan.Decimation = decimation
+					found = true
+				}
+			}
+		}
+
+		if found {
+			return plan, nil
+		}
+	}
+
+	// No exact ratio, the nearest rate above the requested rate keeps its whole band
+	above := math.Inf(1)
+	below := 0.0
+	for _, r := range ranges {
+		for _, rate := range []float64{r.Nearest(requested), r.Minimum, r.Nearest(r.Maximum)} {
+			if rate >= requested && rate < above {
+				above = rate
+			}
+			if rate < requested && rate > below {
+				below = rate
+			}
+		}
+	}
+
+	plan.HardwareRate = above
+	if math.IsInf(above, 1) {
+		plan.HardwareRate = below
+	}
+
+	if !(plan.HardwareRate > 0) {
+		return Plan{}, fmt.Errorf("no sample rate of the device can give %v samples per second", requested)
+	}
+
+	return plan, nil
+}
+
+// Setup chooses the sample rate of a RX channel of a device giving a requested rate, sets it and creates the
+// resampler.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+//  - requested: the requested sample rate in samples per second
+//  - attenuation: the attenuation in dB of the images and aliases, DefaultAttenuation when 0
+//
+// Return the plan, the resampler, nil if the device gives the requested rate, or an error
+func Setup(dev *device.SDRDevice, channel uint, requested float64, attenuation float64) (plan Plan, resampler Resampler, err error) {
+
+	plan, err = PlanRate(dev.GetSampleRateRange(device.DirectionRX, channel), requested, 0)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+
+	if err := dev.SetSampleRate(device.DirectionRX, channel, plan.HardwareRate); err != nil {
+		return Plan{}, nil, err
+	}
+
+	// The driver may round the rate, the plan is computed again from the actual rate
+	if actual := dev.GetSampleRate(device.DirectionRX, channel); actual > 0 && !sameRate(actual, plan.HardwareRate) {
+		plan, err = PlanRate(device.SDRRanges{{Minimum: actual, Maximum: actual}}, requested, 0)
+		if err != nil {
+			return Plan{}, nil, err
+		}
+	}
+
+	resampler, err = plan.NewResampler(attenuation)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+
+	return plan, resampler, nil
+}
+
+// betterRate returns true if a candidate is better than the current plan, for a same largest factor
+func betterRate(interpolation int, decimation int, rate float64, plan Plan) bool {
+
+	if (decimation >= interpolation) != (plan.Decimation >= plan.Interpolation) {
+		return decimation >= interpolation
+	}
+
+	return rate < plan.HardwareRate
+}
+
+// sameRate returns true if two rates are equal within the tolerance
+func sameRate(a float64, b float64) bool {
+	return math.Abs(a-b) <= rateTolerance*math.Max(math.Abs(a), math.Abs(b))
+}
@@ -0,0 +1,69 @@
+package resample
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/device"
+)
+
+// TestPlanRate checks the hardware rates and the ratios chosen for requested rates
+func TestPlanRate(t *testing.T) {
+
+	tests := []struct {
+		ranges        device.SDRRanges
+		requested     float64
+		hardware      float64
+		interpolation int
+		decimation    int
+	}{
+		// Supported rate
+		{device.SDRRanges{{Minimum: 1e6, Maximum: 10e6}}, 2e6, 2e6, 1, 1},
+		// Decimation preferred to interpolation
+		{device.SDRRanges{{Minimum: 1e6, Maximum: 1e6}, {Minimum: 2e6, Maximum: 2e6}}, 250e3, 1e6, 1, 4},
+		// Rational ratio
+		{device.SDRRanges{{Minimum: 2.4e6, Maximum: 2.4e6}}, 48e3 * 10, 2.4e6, 1, 5},
+		{device.SDRRanges{{Minimum: 48e3, Maximum: 48e3}}, 44.1e3, 48e3, 147, 160},
+		// No ratio, the lowest rate above is resampled
+		{device.SDRRanges{{Minimum: 1e6 + 1, Maximum: 1e6 + 1}, {Minimum: 3e6 + 7, Maximum: 3e6 + 7}}, 2e6, 3e6 + 7, 0, 0},
+	}
+
+	for _, test := range tests {
+
+		plan, err := PlanRate(test.ranges, test.requested, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !sameRate(plan.HardwareRate, test.hardware) || plan.Interpolation != test.interpolation || plan.Decimation != test.decimation {
+			t.Errorf("%v at %v: plan %+v", test.requested, test.ranges.ToString(), plan)
+		}
+		if !sameRate(plan.HardwareRate*plan.Ratio(), test.requested) {
+			t.Errorf("%v at %v: the ratio %v gives %v", test.requested, test.ranges.ToString(), plan.Ratio(),
+				plan.HardwareRate*plan.Ratio())
+		}
+	}
+
+	if _, err := PlanRate(nil, 1e6, 0); err == nil {
+		t.Error("a plan was found without sample rate range")
+	}
+}
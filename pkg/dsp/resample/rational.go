@@ -0,0 +1,220 @@
+package resample
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the resamplers changing the sample rate of streams of complex64 samples, by a rational ratio with a
+// polyphase filter or by an arbitrary ratio with a Farrow interpolator, and the planning of the hardware sample rate
+// and the resampler giving a requested rate.
+
+import (
+	"errors"
+
+	"github.com/bhojpur/sdr/pkg/dsp/filter"
+)
+
+// DefaultAttenuation is the attenuation in dB of the images and aliases of the automatically designed filters
+const DefaultAttenuation = 80
+
+// Resampler changes the sample rate of a stream of samples, keeping its state from a block to the next
+type Resampler interface {
+	// Resample resamples a block of samples and appends the outputs to a slice, for example
+	// out = resampler.Resample(out[:0], in).
+	//
+	// Params:
+	//  - dst: the slice receiving the outputs
+	//  - src: the input samples
+	//
+	// Return the slice with the outputs appended
+	Resample(dst []complex64, src []complex64) []complex64
+
+	// Ratio returns the ratio of the output and input sample rates
+	Ratio() float64
+
+	// Reset clears the state of the resampler
+	Reset()
+}
+
+// Rational resamples a stream by a rational ratio Interpolation/Decimation with a polyphase filter, computing only the
+// outputs kept after the decimation
+type Rational struct {
+	interpolation int
+	decimation    int
+	phases        [][]float32
+	history       []complex64
+	work          []complex64
+	next          int
+	phase         int
+}
+
+// NewRational creates a rational resampler with automatically designed filter.
+//
+// Params:
+//  - interpolation: the interpolation factor L
+//  - decimation: the decimation factor M, the output rate is the input rate * L / M
+//  - attenuation: the attenuation in dB of the images and aliases, DefaultAttenuation when 0
+//
+// Return the resampler or an error
+func NewRational(interpolation int, decimation int, attenuation float64) (rational *Rational, err error) {
+
+	if interpolation < 1 || decimation < 1 {
+		return nil, errors.New("the factors of a resampler must be at least 1")
+	}
+
+	divisor := gcd(interpolation, decimation)
+	interpolation /= divisor
+	decimation /= divisor
+
+	if attenuation == 0 {
+		attenuation = DefaultAttenuation
+	}
+
+	// The filter runs at the interpolated rate and keeps 90% of the narrowest of the input and output bands
+	factor := float64(interpolation)
+	if decimation > interpolation {
+		factor = float64(decimation)
+	}
+
+	taps, err := filter.Design(filter.Specification{
+		Type:        filter.Lowpass,
+		Cutoff:      0.5 / factor,
+		Transition:  0.1 / factor,
+		Attenuation: attenuation,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRationalWithTaps(interpolation, decimation, taps)
+}
+
+// NewRationalWithTaps creates a rational resampler with the taps of a lowpass filter designed at the interpolated
+// rate, which must reject the frequencies above 0.5/max(L, M) of this rate. The taps are multiplied by the
+// interpolation factor to keep the amplitude of the signal.
+//
+// Params:
+//  - interpolation: the interpolation factor L
+//  - decimation: the decimation factor M
+//  - taps: the taps of the filter
+//
+// Return the resampler or an error
+func NewRationalWithTaps(interpolation int, decimation int, taps []float64) (rational *Rational, err error) {
+
+	if interpolation < 1 || decimation < 1 {
+		return nil, errors.New("the factors of a resampler must be at least 1")
+	}
+
+	if len(taps) == 0 {
+		return nil, errors.New("a resampler needs at least one tap")
+	}
+
+	length := (len(taps) + interpolation - 1) / interpolation
+
+	rational = &Rational{
+		interpolation: interpolation,
+		decimation:    decimation,
+		history:       make([]complex64, length-1),
+	}
+
+	// Each phase is reversed to compute the convolution as a dot product with the delay line
+	for phase := 0; phase < interpolation; phase++ {
+		subTaps := make([]float32, length)
+		for k := 0; k < length; k++ {
+			if n := phase + k*interpolation; n < len(taps) {
+				subTaps[length-1-k] = float32(taps[n] * float64(interpolation))
+			}
+		}
+		rational.phases = append(rational.phases, subTaps)
+	}
+
+	return rational, nil
+}
+
+// Interpolation returns the interpolation factor L
+func (rational *Rational) Interpolation() int {
+	return rational.interpolation
+}
+
+// Decimation returns the decimation factor M
+func (rational *Rational) Decimation() int {
+	return rational.decimation
+}
+
+// Ratio returns the ratio of the output and input sample rates, L/M
+func (rational *Rational) Ratio() float64 {
+	return float64(rational.interpolation) / float64(rational.decimation)
+}
+
+// Resample resamples a block of samples and appends the outputs to a slice.
+//
+// Params:
+//  - dst: the slice receiving the outputs
+//  - src: the input samples
+//
+// Return the slice with the outputs appended
+func (rational *Rational) Resample(dst []complex64, src []complex64) []complex64 {
+
+	length := len(rational.history) + 1
+
+	rational.work = append(append(rational.work[:0], rational.history...), src...)
+
+	// The output m uses the phase mM mod L of the filter, ending with the input floor(mM/L)
+	for rational.next < len(src) {
+
+		window := rational.work[rational.next : rational.next+length]
+		taps := rational.phases[rational.phase]
+
+		var re, im float32
+		for k, tap := range taps {
+			re += real(window[k]) * tap
+			im += imag(window[k]) * tap
+		}
+		dst = append(dst, complex(re, im))
+
+		rational.phase += rational.decimation
+		rational.next += rational.phase / rational.interpolation
+		rational.phase %= rational.interpolation
+	}
+	rational.next -= len(src)
+
+	copy(rational.history, rational.work[len(src):])
+
+	return dst
+}
+
+// Reset clears the state of the resampler
+func (rational *Rational) Reset() {
+
+	rational.next = 0
+	rational.phase = 0
+	for i := range rational.history {
+		rational.history[i] = 0
+	}
+}
+
+// gcd returns the greatest common divisor of two positive integers
+func gcd(a int, b int) int {
+
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
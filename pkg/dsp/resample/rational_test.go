@@ -0,0 +1,125 @@
+package resample
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// tone returns samples of a complex tone of amplitude 1
+func tone(frequency float64, count int) []complex64 {
+
+	samples := make([]complex64, count)
+	for i := range samples {
+		samples[i] = complex64(cmplx.Rect(1, 2*math.Pi*frequency*float64(i)))
+	}
+
+	return samples
+}
+
+// resampleBlocks resamples samples given in blocks of a size
+func resampleBlocks(resampler Resampler, samples []complex64, size int) []complex64 {
+
+	var outputs []complex64
+	for start := 0; start < len(samples); start += size {
+		end := start + size
+		if end > len(samples) {
+			end = len(samples)
+		}
+		outputs = resampler.Resample(outputs, samples[start:end])
+	}
+
+	return outputs
+}
+
+// toneError returns the amplitude of a tone in samples and the RMS of the rest of the samples, relatively to the tone
+func toneError(samples []complex64, frequency float64) (amplitude float64, residual float64) {
+
+	correlation := complex128(0)
+	for i, sample := range samples {
+		correlation += complex128(sample) * cmplx.Rect(1, -2*math.Pi*frequency*float64(i))
+	}
+	correlation /= complex(float64(len(samples)), 0)
+
+	for i, sample := range samples {
+		difference := complex128(sample) - correlation*cmplx.Rect(1, 2*math.Pi*frequency*float64(i))
+		residual += real(difference)*real(difference) + imag(difference)*imag(difference)
+	}
+
+	return cmplx.Abs(correlation), math.Sqrt(residual/float64(len(samples))) / cmplx.Abs(correlation)
+}
+
+// TestRational checks the frequency and the amplitude of a tone resampled by rational ratios, and the rejection of the
+// tones above the output band
+func TestRational(t *testing.T) {
+
+	tests := []struct {
+		interpolation int
+		decimation    int
+	}{{1, 2}, {2, 1}, {3, 2}, {2, 3}, {4, 5}, {147, 160}}
+
+	for _, test := range tests {
+
+		ratio := float64(test.interpolation) / float64(test.decimation)
+		band := 0.5 * math.Min(ratio, 1)
+
+		for _, frequency := range []float64{0.1 * band, -0.6 * band} {
+
+			rational, err := NewRational(test.interpolation, test.decimation, 80)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rational.Ratio() != ratio {
+				t.Fatalf("%d/%d: ratio %v", test.interpolation, test.decimation, rational.Ratio())
+			}
+
+			outputs := resampleBlocks(rational, tone(frequency, 20000), 1000)
+			if expected := 20000 * ratio; math.Abs(float64(len(outputs))-expected) > 1 {
+				t.Fatalf("%d/%d: %d outputs instead of %v", test.interpolation, test.decimation, len(outputs), expected)
+			}
+
+			// The filter transient is skipped
+			amplitude, residual := toneError(outputs[len(outputs)/4:], frequency/ratio)
+			if math.Abs(amplitude-1) > 1e-3 || residual > 1e-3 {
+				t.Errorf("%d/%d: tone at %v of amplitude %v with residual %v", test.interpolation, test.decimation,
+					frequency, amplitude, residual)
+			}
+		}
+
+		// A tone above the output band is removed, when the transition band leaves room for it
+		if ratio < 0.7 {
+			rational, err := NewRational(test.interpolation, test.decimation, 80)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs := resampleBlocks(rational, tone(0.45, 20000), 777)
+			power := 0.0
+			for _, output := range outputs[len(outputs)/4:] {
+				power += math.Pow(cmplx.Abs(complex128(output)), 2)
+			}
+			if level := 10 * math.Log10(power/float64(len(outputs)*3/4)); level > -70 {
+				t.Errorf("%d/%d: tone out of the band at %.1f dB", test.interpolation, test.decimation, level)
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package spectrum
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the computation of power spectra: a pure Go FFT, window functions and a Welch estimator averaging the
+// spectra of overlapping segments of a stream.
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// FFT computes the discrete Fourier transforms of a given size. Sizes which are powers of two use an iterative radix-2
+// algorithm, the other sizes a recursive mixed-radix algorithm. An FFT keeps scratch buffers and must not be used by
+// several goroutines at the same time.
+type FFT struct {
+	size     int
+	twiddles []complex128
+	factors  []int
+	reversed []int
+	work     []complex128
+	scratch  []complex128
+}
+
+// NewFFT creates an FFT.
+//
+// Params:
+//  - size: the number of points of the transforms, which must be positive
+//
+// Return the FFT or an error
+func NewFFT(size int) (fft *FFT, err error) {
+
+	if size <= 0 {
+		return nil, errors.New("the size of an FFT must be positive")
+	}
+
+	fft = &FFT{
+		size:     size,
+		twiddles: make([]complex128, size),
+	}
+
+	for i := range fft.twiddles {
+		fft.twiddles[i] = cmplx.Rect(1, -2*math.Pi*float64(i)/float64(size))
+	}
+
+	if size&(size-1) == 0 {
+		// Bit reversal permutation of the radix-2 algorithm
+		shift := bits.UintSize - bits.Len(uint(size)) + 1
+		fft.reversed = make([]int, size)
+		for i := range fft.reversed {
+			fft.reversed[i] = int(bits.Reverse(uint(i)) >> uint(shift))
+		}
+		return fft, nil
+	}
+
+	fft.factors = factorize(size)
+
+	largest := 0
+	for _, factor := range fft.factors {
+		if factor > largest {
+			largest = factor
+		}
+	}
+
+	fft.work = make([]complex128, size)
+	fft.scratch = make([]complex128, 2*largest)
+
+	return fft, nil
+}
+
+// Size returns the number of points of the transforms
+func (fft *FFT) Size() int {
+	return fft.size
+}
+
+// Transform computes the forward transform of samples, X[k] = sum x[n] exp(-2i*pi*k*n/N).
+//
+// Params:
+//  - dst: the transform, of the size of the FFT. It can be the same slice as src.
+//  - src: the samples, of the size of the FFT
+func (fft *FFT) Transform(dst []complex128, src []complex128) {
+
+	dst = dst[:fft.size]
+	src = src[:fft.size]
+
+	if fft.reversed != nil {
+		fft.radix2(dst, src)
+		return
+	}
+
+	copy(fft.work, src)
+	fft.mixedRadix(dst, fft.work, fft.size, 1, fft.factors)
+}
+
+// Inverse computes the inverse transform, x[n] = 1/N sum X[k] exp(2i*pi*k*n/N).
+//
+// Params:
+//  - dst: the samples, of the size of the FFT. It can be the same slice as src.
+//  - src: the transform, of the size of the FFT
+func (fft *FFT) Inverse(dst []complex128, src []complex128) {
+
+	dst = dst[:fft.size]
+	src = src[:fft.size]
+
+	// The inverse transform is the conjugate of the transform of the conjugate
+	for i, value := range src {
+		dst[i] = cmplx.Conj(value)
+	}
+
+	fft.Transform(dst, dst)
+
+	scale := 1 / float64(fft.size)
+	for i, value := range dst {
+		dst[i] = complex(real(value)*scale, -imag(value)*scale)
+	}
+}
+
+// radix2 computes the transform of a power of two size in place after a bit reversal permutation
+func (fft *FFT) radix2(dst []complex128, src []complex128) {
+
+	if &dst[0] == &src[0] {
+		for i, j := range fft.reversed {
+			if i < j {
+				dst[i], dst[j] = dst[j], dst[i]
+			}
+		}
+	} else {
+		for i, j := range fft.reversed {
+			dst[j] = src[i]
+		}
+	}
+
+	for half := 1; half < fft.size; half *= 2 {
+		step := fft.size / (2 * half)
+		for start := 0; start < fft.size; start += 2 * half {
+			for k := 0; k < half; k++ {
+				even := dst[start+k]
+				odd := dst[start+k+half] * fft.twiddles[k*step]
+				dst[start+k] = even + odd
+				dst[start+k+half] = even - odd
+			}
+		}
+	}
+}
+
+// mixedRadix computes the transform of the n samples of src separated by stride into dst, by combining the
+// transforms of the first factor sub-sequences
+func (fft *FFT) mixedRadix(dst []complex128, src []complex128, n int, stride int, factors []int) {
+
+	radix := factors[0]
+	m := n / radix
+
+	if m == 1 {
+		// Direct transform of the radix points
+		for k := 0; k < radix; k++ {
+			sum := complex(0, 0)
+			for q := 0; q < radix; q++ {
+				sum += src[q*stride] * fft.twiddles[(q*k%radix)*(fft.size/radix)]
+			}
+			dst[k] = sum
+		}
+		return
+	}
+
+	for q := 0; q < radix; q++ {
+		fft.mixedRadix(dst[q*m:(q+1)*m], src[q*stride:], m, stride*radix, factors[1:])
+	}
+
+	twiddleStep := fft.size / n
+	rootStep := fft.size / radix
+	inputs := fft.scratch[:radix]
+	outputs := fft.scratch[radix : 2*radix]
+
+	for k := 0; k < m; k++ {
+
+		for q := 0; q < radix; q++ {
+			inputs[q] = dst[q*m+k] * fft.twiddles[q*k*twiddleStep]
+		}
+
+		if radix == 2 {
+			outputs[0] = inputs[0] + inputs[1]
+			outputs[1] = inputs[0] - inputs[1]
+		} else {
+			for r := 0; r < radix; r++ {
+				sum := complex(0, 0)
+				for q := 0; q < radix; q++ {
+					sum += inputs[q] * fft.twiddles[(q*r%radix)*rootStep]
+				}
+				outputs[r] = sum
+			}
+		}
+
+		for r := 0; r < radix; r++ {
+			dst[r*m+k] = outputs[r]
+		}
+	}
+}
+
+// factorize returns the factors of a size, the small radixes first
+func factorize(size int) []int {
+
+	var factors []int
+	for _, radix := range []int{4, 2, 3, 5} {
+		for size%radix == 0 {
+			factors = append(factors, radix)
+			size /= radix
+		}
+	}
+
+	for radix := 7; size > 1; radix += 2 {
+		for size%radix == 0 {
+			factors = append(factors, radix)
+			size /= radix
+		}
+	}
+
+	return factors
+}
@@ -0,0 +1,157 @@
+package spectrum
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// directDFT returns the discrete Fourier transform of samples computed from its definition
+func directDFT(samples []complex128) []complex128 {
+
+	size := len(samples)
+	roots := make([]complex128, size)
+	for i := range roots {
+		roots[i] = cmplx.Rect(1, -2*math.Pi*float64(i)/float64(size))
+	}
+
+	transform := make([]complex128, size)
+	for k := range transform {
+		sum := complex(0, 0)
+		for n, sample := range samples {
+			sum += sample * roots[k*n%size]
+		}
+		transform[k] = sum
+	}
+
+	return transform
+}
+
+// maxError returns the largest magnitude of the differences between two sequences
+func maxError(a []complex128, b []complex128) float64 {
+
+	largest := 0.0
+	for i := range a {
+		largest = math.Max(largest, cmplx.Abs(a[i]-b[i]))
+	}
+
+	return largest
+}
+
+// TestFFT compares the transforms of random samples with the direct DFT for all the sizes up to 1024, which cover the
+// radix-2 algorithm, the mixed-radix algorithm and the prime sizes
+func TestFFT(t *testing.T) {
+
+	random := rand.New(rand.NewSource(1))
+
+	for size := 1; size <= 1024; size++ {
+
+		fft, err := NewFFT(size)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if fft.Size() != size {
+			t.Fatalf("size %d: Size() = %d", size, fft.Size())
+		}
+
+		samples := make([]complex128, size)
+		for i := range samples {
+			samples[i] = complex(random.NormFloat64(), random.NormFloat64())
+		}
+
+		expected := directDFT(samples)
+		tolerance := 1e-9 * math.Sqrt(float64(size))
+
+		transform := make([]complex128, size)
+		fft.Transform(transform, samples)
+		if e := maxError(transform, expected); e > tolerance {
+			t.Errorf("size %d: transform error %g", size, e)
+		}
+
+		// In place
+		inPlace := append([]complex128(nil), samples...)
+		fft.Transform(inPlace, inPlace)
+		if e := maxError(inPlace, expected); e > tolerance {
+			t.Errorf("size %d: in place transform error %g", size, e)
+		}
+
+		// The second call must not depend on the scratch buffers left by the first one
+		fft.Transform(transform, samples)
+		if e := maxError(transform, expected); e > tolerance {
+			t.Errorf("size %d: repeated transform error %g", size, e)
+		}
+
+		inverse := make([]complex128, size)
+		fft.Inverse(inverse, expected)
+		if e := maxError(inverse, samples); e > tolerance/float64(size)+1e-12 {
+			t.Errorf("size %d: inverse error %g", size, e)
+		}
+	}
+}
+
+// TestFFTImpulse checks the transforms of an impulse and of a constant
+func TestFFTImpulse(t *testing.T) {
+
+	for _, size := range []int{1, 2, 7, 12, 64, 97, 1000} {
+
+		fft, err := NewFFT(size)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+
+		impulse := make([]complex128, size)
+		impulse[0] = 1
+		fft.Transform(impulse, impulse)
+		for k, bin := range impulse {
+			if cmplx.Abs(bin-1) > 1e-12 {
+				t.Errorf("size %d: bin %d of the impulse is %v, expected 1", size, k, bin)
+			}
+		}
+
+		constant := make([]complex128, size)
+		for i := range constant {
+			constant[i] = 1
+		}
+		fft.Transform(constant, constant)
+		for k, bin := range constant {
+			expected := complex(0, 0)
+			if k == 0 {
+				expected = complex(float64(size), 0)
+			}
+			if cmplx.Abs(bin-expected) > 1e-9 {
+				t.Errorf("size %d: bin %d of the constant is %v, expected %v", size, k, bin, expected)
+			}
+		}
+	}
+}
+
+// TestFFTSize checks that the sizes which are not positive are rejected
+func TestFFTSize(t *testing.T) {
+
+	for _, size := range []int{0, -1} {
+		if _, err := NewFFT(size); err == nil {
+			t.Errorf("size %d: expected an error", size)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package spectrum
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+const (
+	// DefaultSize is the default number of points of the spectra
+	DefaultSize = 1024
+	// DefaultKaiserBeta is the default beta parameter of the Kaiser window
+	DefaultKaiserBeta = 8.6
+)
+
+// Mode is the way the power spectra of the segments are combined
+type Mode int
+
+const (
+	// ModeAverage averages the power spectra of the segments of each spectrum (Welch method)
+	ModeAverage Mode = iota
+	// ModePeakHold keeps the maximum power of each bin over all the segments since the last reset
+	ModePeakHold
+	// ModeMinHold keeps the minimum power of each bin over all the segments since the last reset
+	ModeMinHold
+)
+
+// String returns the name of the mode
+func (mode Mode) String() string {
+
+	switch mode {
+	case ModeAverage:
+		return "average"
+	case ModePeakHold:
+		return "peak-hold"
+	case ModeMinHold:
+		return "min-hold"
+	}
+
+	return fmt.Sprintf("mode(%d)", int(mode))
+}
+
+// Calibration converts the levels relative to the full scale to approximate absolute levels. It ignores the
+// frequency response of the receiver.
+type Calibration struct {
+	// FullScaleDBm is the power in dBm at the antenna input of a full scale signal with a gain of 0 dB
+	FullScaleDBm float64
+	// Gain is the overall gain of the receiver in dB, see GetGain
+	Gain float64
+}
+
+// Offset returns the offset in dB to add to a level in dBFS to get a level in dBm
+func (calibration Calibration) Offset() float64 {
+	return calibration.FullScaleDBm - calibration.Gain
+}
+
+// Options are the options of an analyzer
+type Options struct {
+	// Size is the number of points of the spectra, DefaultSize when 0. It does not need to be a power of two.
+	Size int
+	// Window is the window applied to the segments
+	Window WindowType
+	// KaiserBeta is the beta parameter of the Kaiser window, DefaultKaiserBeta when 0
+	KaiserBeta float64
+	// Overlap is the fraction of the segments overlapping the previous segment, between 0 and 1 excluded. 0.5 suits
+	// the Hann window, 0.66 to 0.75 the Blackman-Harris, flat top and Kaiser windows.
+	Overlap float64
+	// Averages is the number of segments of each spectrum, 1 when 0
+	Averages int
+	// Mode is the way the segments are combined
+	Mode Mode
+	// SampleRate is the sample rate in samples per second, needed by the densities and the frequencies of the bins
+	SampleRate float64
+	// FullScale is the value of a full scale sample in the unit of the buffers, as returned by
+	// GetNativeStreamFormat, or 0 for the full range of the type of the buffers (1 for the float formats)
+	FullScale float64
+	// Density gives the power spectral density in dB/Hz instead of the power of each bin. A full scale tone reads
+	// 0 dBFS when false.
+	Density bool
+	// Calibration gives levels in dBm instead of dBFS when not nil
+	Calibration *Calibration
+}
+
+// Analyzer computes the power spectra of a stream of samples with the Welch method: the stream is cut in overlapping
+// segments, which are windowed and transformed, and the power spectra of the segments are combined.
+//
+// The spectra are in dB, with the negative frequencies first: the bin i is at the frequency
+// (i - Size/2) * SampleRate / Size relatively to the center frequency. See Frequencies.
+type Analyzer struct {
+	options Options
+	fft     *FFT
+	window  []float64
+	hop     int
+	scale   float64
+	offset  float64
+
+	pending []complex128
+	segment []complex128
+	power   []float64
+	count   int
+	held    bool
+	samples []complex128
+}
+
+// NewAnalyzer creates an analyzer.
+//
+// Params:
+//  - options: the options of the analyzer
+//
+// Return the analyzer or an error
+func NewAnalyzer(options Options) (analyzer *Analyzer, err error) {
+
+	if options.Size == 0 {
+		options.Size = DefaultSize
+	}
+	if options.Averages <= 0 {
+		options.Averages = 1
+	}
+	if options.KaiserBeta == 0 {
+		options.KaiserBeta = DefaultKaiserBeta
+	}
+
+	if options.Overlap < 0 || options.Overlap >= 1 {
+		return nil, errors.New("the overlap of the segments must be between 0 and 1 excluded")
+	}
+
+	if options.Density && !(options.SampleRate > 0) {
+		return nil, errors.New("the power spectral density needs the sample rate")
+	}
+
+	fft, err := NewFFT(options.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	window, err := MakeWindow(options.Window, options.Size, options.KaiserBeta)
+	if err != nil {
+		return nil, err
+	}
+
+	hop := int(math.Round(float64(options.Size) * (1 - options.Overlap)))
+	if hop < 1 {
+		hop = 1
+	}
+
+	// Normalization of the power of the bins
+	sum := 0.0
+	sumSquares := 0.0
+	for _, coefficient := range window {
+		sum += coefficient
+		sumSquares += coefficient * coefficient
+	}
+
+	scale := 1 / (sum * sum)
+	if options.Density {
+		scale = 1 / (sumSquares * options.SampleRate)
+	}
+
+	offset := 0.0
+	if options.Calibration != nil {
+		offset = options.Calibration.Offset()
+	}
+
+	return &Analyzer{
+		options: options,
+		fft:     fft,
+		window:  window,
+		hop:     hop,
+		scale:   scale,
+		offset:  offset,
+		segment: make([]complex128, options.Size),
+		power:   make([]float64, options.Size),
+	}, nil
+}
+
+// Options returns the options of the analyzer, completed with the default values
+func (analyzer *Analyzer) Options() Options {
+	return analyzer.options
+}
+
+// Write processes samples and returns the spectra completed by the samples, usually none or one.
+//
+// Params:
+//  - buffer: the samples of a single channel, such as a []complex64 or a []int16
+//
+// Return the spectra in dBFS, or dBm with a calibration, or an error
+func (analyzer *Analyzer) Write(buffer interface{}) (spectra [][]float64, err error) {
+
+	length, err := sdrformat.BufferLength(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	if cap(analyzer.samples) < length {
+		analyzer.samples = make([]complex128, length)
+	}
+	samples := analyzer.samples[:length]
+
+	if _, err := sdrformat.ToComplex128(samples, buffer); err != nil {
+		return nil, err
+	}
+
+	if analyzer.options.FullScale > 0 {
+		format, _ := sdrformat.FormatOf(buffer)
+		factor := complex(sdrformat.NormalizationScale(format)/analyzer.options.FullScale, 0)
+		for i := range samples {
+			samples[i] *= factor
+		}
+	}
+
+	return analyzer.WriteComplex(samples), nil
+}
+
+// WriteComplex processes samples normalized to a full scale of 1 and returns the spectra completed by the samples.
+//
+// Params:
+//  - samples: the samples
+//
+// Return the spectra in dBFS, or dBm with a calibration
+func (analyzer *Analyzer) WriteComplex(samples []complex128) (spectra [][]float64) {
+
+	size := analyzer.options.Size
+
+	for len(samples) > 0 {
+
+		needed := size - len(analyzer.pending)
+		if needed > len(samples) {
+			needed = len(samples)
+		}
+
+		analyzer.pending = append(analyzer.pending, samples[:needed]...)
+		samples = samples[needed:]
+
+		if len(analyzer.pending) < size {
+			break
+		}
+
+		analyzer.accumulate(analyzer.pending)
+
+		// Keep the overlapping samples for the next segment
+		if analyzer.hop < size {
+			kept := copy(analyzer.pending, analyzer.pending[analyzer.hop:])
+			analyzer.pending = analyzer.pending[:kept]
+		} else {
+			analyzer.pending = analyzer.pending[:0]
+			skip := analyzer.hop - size
+			if skip > len(samples) {
+				skip = len(samples)
+			}
+			samples = samples[skip:]
+		}
+
+		if analyzer.count >= analyzer.options.Averages {
+			spectra = append(spectra, analyzer.spectrum())
+		}
+	}
+
+	return spectra
+}
+
+// Frequencies returns the frequencies of the bins of the spectra.
+//
+// Params:
+//  - centerFrequency: the center frequency of the stream in Hz, or 0 for the frequencies relative to the center
+//
+// Return the frequencies in Hz
+func (analyzer *Analyzer) Frequencies(centerFrequency float64) []float64 {
+
+	size := analyzer.options.Size
+	frequencies := make([]float64, size)
+	for i := range frequencies {
+		frequencies[i] = centerFrequency + float64(i-size/2)*analyzer.options.SampleRate/float64(size)
+	}
+
+	return frequencies
+}
+
+// Reset forgets the pending samples and the held levels
+func (analyzer *Analyzer) Reset() {
+
+	analyzer.pending = analyzer.pending[:0]
+	analyzer.count = 0
+	analyzer.held = false
+}
+
+// accumulate adds the power spectrum of a segment
+func (analyzer *Analyzer) accumulate(samples []complex128) {
+
+	for i, sample := range samples {
+		analyzer.segment[i] = sample * complex(analyzer.window[i], 0)
+	}
+
+	analyzer.fft.Transform(analyzer.segment, analyzer.segment)
+
+	first := analyzer.count == 0 && (analyzer.options.Mode == ModeAverage || !analyzer.held)
+
+	for i, bin := range analyzer.segment {
+
+		power := (real(bin)*real(bin) + imag(bin)*imag(bin)) * analyzer.scale
+
+		switch {
+		case first:
+			analyzer.power[i] = power
+		case analyzer.options.Mode == ModePeakHold:
+			analyzer.power[i] = math.Max(analyzer.power[i], power)
+		case analyzer.options.Mode == ModeMinHold:
+			analyzer.power[i] = math.Min(analyzer.power[i], power)
+		default:
+			analyzer.power[i] += power
+		}
+	}
+
+	analyzer.count++
+	analyzer.held = true
+}
+
+// spectrum returns the spectrum of the accumulated segments in dB, the negative frequencies first
+func (analyzer *Analyzer) spectrum() []float64 {
+
+	size := analyzer.options.Size
+	divider := 1.0
+	if analyzer.options.Mode == ModeAverage {
+		divider = float64(analyzer.count)
+	}
+
+	spectrum := make([]float64, size)
+	for i := range spectrum {
+		bin := (i + (size+1)/2) % size
+		spectrum[i] = 10*math.Log10(analyzer.power[bin]/divider) + analyzer.offset
+	}
+
+	analyzer.count = 0
+
+	return spectrum
+}
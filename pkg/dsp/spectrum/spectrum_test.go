@@ -0,0 +1,205 @@
+package spectrum
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// windows are all the window types
+var windows = []WindowType{WindowRectangular, WindowHann, WindowBlackmanHarris, WindowFlatTop, WindowKaiser}
+
+// tone returns samples of a complex tone of amplitude 1, the frequency being relative to the sample rate
+func tone(frequency float64, count int) []complex64 {
+
+	samples := make([]complex64, count)
+	for i := range samples {
+		samples[i] = complex64(cmplx.Rect(1, 2*math.Pi*frequency*float64(i)))
+	}
+
+	return samples
+}
+
+// peak returns the index and the level of the largest bin of a spectrum
+func peak(spectrum []float64) (int, float64) {
+
+	index := 0
+	for i, level := range spectrum {
+		if level > spectrum[index] {
+			index = i
+		}
+	}
+
+	return index, spectrum[index]
+}
+
+// TestFullScaleTone checks that a full scale tone at the center of a bin reads 0 dBFS with every window, for a
+// radix-2 and a mixed-radix size
+func TestFullScaleTone(t *testing.T) {
+
+	for _, size := range []int{1024, 1000} {
+		for _, window := range windows {
+
+			analyzer, err := NewAnalyzer(Options{Size: size, Window: window, Overlap: 0.5, Averages: 4, SampleRate: 1e6})
+			if err != nil {
+				t.Fatalf("%v %d: %v", window, size, err)
+			}
+
+			const bin = 100
+			spectra, err := analyzer.Write(tone(float64(bin)/float64(size), 4*size))
+			if err != nil {
+				t.Fatalf("%v %d: %v", window, size, err)
+			}
+			if len(spectra) != 1 {
+				t.Fatalf("%v %d: %d spectra, expected 1", window, size, len(spectra))
+			}
+
+			index, level := peak(spectra[0])
+			if index != size/2+bin {
+				t.Errorf("%v %d: peak in bin %d, expected %d", window, size, index, size/2+bin)
+			}
+			if math.Abs(level) > 0.01 {
+				t.Errorf("%v %d: full scale tone at %.3f dBFS, expected 0", window, size, level)
+			}
+
+			frequency := analyzer.Frequencies(100e6)[index]
+			if expected := 100e6 + float64(bin)*1e6/float64(size); math.Abs(frequency-expected) > 1e-3 {
+				t.Errorf("%v %d: peak at %f Hz, expected %f", window, size, frequency, expected)
+			}
+		}
+	}
+}
+
+// TestFlatTopScalloping checks that the flat top window keeps the level of a tone between two bins
+func TestFlatTopScalloping(t *testing.T) {
+
+	const size = 1024
+
+	analyzer, err := NewAnalyzer(Options{Size: size, Window: WindowFlatTop})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spectra := analyzer.WriteComplex(toComplex128(tone(100.5/size, size)))
+	if len(spectra) != 1 {
+		t.Fatalf("%d spectra, expected 1", len(spectra))
+	}
+
+	if _, level := peak(spectra[0]); math.Abs(level) > 0.02 {
+		t.Errorf("tone between two bins at %.3f dBFS, expected 0", level)
+	}
+}
+
+// TestFullScale checks the scaling of the integer buffers by the full scale of the device and the calibration
+func TestFullScale(t *testing.T) {
+
+	const size = 256
+
+	samples := make([]int16, 2*size)
+	for i, sample := range tone(16.0/size, size) {
+		samples[2*i] = int16(math.Round(float64(real(sample)) * 2047))
+		samples[2*i+1] = int16(math.Round(float64(imag(sample)) * 2047))
+	}
+
+	tests := []struct {
+		fullScale   float64
+		calibration *Calibration
+		expected    float64
+	}{
+		// Full range of the type
+		{0, nil, 20 * math.Log10(2047.0/32768)},
+		// 12 bits device
+		{2047, nil, 0},
+		{2047, &Calibration{FullScaleDBm: -10, Gain: 30}, -40},
+	}
+
+	for _, test := range tests {
+
+		analyzer, err := NewAnalyzer(Options{Size: size, Window: WindowHann, FullScale: test.fullScale,
+			Calibration: test.calibration})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		spectra, err := analyzer.Write(samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(spectra) != 1 {
+			t.Fatalf("full scale %g: %d spectra, expected 1", test.fullScale, len(spectra))
+		}
+
+		if _, level := peak(spectra[0]); math.Abs(level-test.expected) > 0.01 {
+			t.Errorf("full scale %g: tone at %.3f dB, expected %.3f", test.fullScale, level, test.expected)
+		}
+	}
+}
+
+// TestWindows checks the coherent gains and the equivalent noise bandwidths of the windows
+func TestWindows(t *testing.T) {
+
+	tests := []struct {
+		window       WindowType
+		coherentGain float64
+		bandwidth    float64
+	}{
+		{WindowRectangular, 1, 1},
+		{WindowHann, 0.5, 1.5},
+		{WindowBlackmanHarris, 0.35875, 2.0044},
+		{WindowFlatTop, 0.21557895, 3.7702},
+		{WindowKaiser, 0.4208, 1.7214},
+	}
+
+	for _, test := range tests {
+
+		coefficients, err := MakeWindow(test.window, 4096, DefaultKaiserBeta)
+		if err != nil {
+			t.Fatalf("%v: %v", test.window, err)
+		}
+		if len(coefficients) != 4096 {
+			t.Fatalf("%v: %d coefficients", test.window, len(coefficients))
+		}
+
+		if gain := CoherentGain(coefficients); math.Abs(gain-test.coherentGain) > 1e-3 {
+			t.Errorf("%v: coherent gain %f, expected %f", test.window, gain, test.coherentGain)
+		}
+		if bandwidth := EquivalentNoiseBandwidth(coefficients); math.Abs(bandwidth-test.bandwidth) > 1e-3 {
+			t.Errorf("%v: equivalent noise bandwidth %f, expected %f", test.window, bandwidth, test.bandwidth)
+		}
+	}
+
+	if _, err := MakeWindow(WindowType(99), 16, 0); err == nil {
+		t.Error("expected an error for an unknown window")
+	}
+}
+
+// toComplex128 converts samples to complex128
+func toComplex128(samples []complex64) []complex128 {
+
+	converted := make([]complex128, len(samples))
+	for i, sample := range samples {
+		converted[i] = complex128(sample)
+	}
+
+	return converted
+}
@@ -0,0 +1,163 @@
+package spectrum
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"math"
+)
+
+// WindowType is the type of the window applied to the segments of samples before their transform
+type WindowType int
+
+const (
+	// WindowRectangular applies no weighting
+	WindowRectangular WindowType = iota
+	// WindowHann is the raised cosine window, a common default
+	WindowHann
+	// WindowBlackmanHarris is the 4-term Blackman-Harris window, with sidelobes below -92 dB
+	WindowBlackmanHarris
+	// WindowFlatTop is a flat top window, for accurate amplitudes of the tones between two bins
+	WindowFlatTop
+	// WindowKaiser is the Kaiser window, whose beta parameter trades the main lobe width for the sidelobe level
+	WindowKaiser
+)
+
+// String returns the name of the window type
+func (window WindowType) String() string {
+
+	switch window {
+	case WindowRectangular:
+		return "rectangular"
+	case WindowHann:
+		return "hann"
+	case WindowBlackmanHarris:
+		return "blackman-harris"
+	case WindowFlatTop:
+		return "flat-top"
+	case WindowKaiser:
+		return "kaiser"
+	}
+
+	return fmt.Sprintf("window(%d)", int(window))
+}
+
+// MakeWindow returns the coefficients of a window. The windows are periodic (DFT-even), as suited to spectral
+// analysis.
+//
+// Params:
+//  - window: the type of window
+//  - size: the number of coefficients
+//  - beta: the beta parameter of the Kaiser window, ignored by the other windows. 8.6 gives sidelobes similar to the
+//    Blackman-Harris window.
+//
+// Return the coefficients or an error if the type is unknown
+func MakeWindow(window WindowType, size int, beta float64) ([]float64, error) {
+
+	switch window {
+	case WindowRectangular:
+		coefficients := make([]float64, size)
+		for i := range coefficients {
+			coefficients[i] = 1
+		}
+		return coefficients, nil
+	case WindowHann:
+		return cosineWindow(size, 0.5, 0.5), nil
+	case WindowBlackmanHarris:
+		return cosineWindow(size, 0.35875, 0.48829, 0.14128, 0.01168), nil
+	case WindowFlatTop:
+		return cosineWindow(size, 0.21557895, 0.41663158, 0.277263158, 0.083578947, 0.006947368), nil
+	case WindowKaiser:
+		return kaiserWindow(size, beta), nil
+	}
+
+	return nil, fmt.Errorf("unknown window: %v", window)
+}
+
+// CoherentGain returns the mean of the coefficients of a window, which is the amplitude of a tone at the center of a
+// bin relatively to a rectangular window
+func CoherentGain(window []float64) float64 {
+
+	sum := 0.0
+	for _, coefficient := range window {
+		sum += coefficient
+	}
+
+	return sum / float64(len(window))
+}
+
+// EquivalentNoiseBandwidth returns the equivalent noise bandwidth of a window in bins
+func EquivalentNoiseBandwidth(window []float64) float64 {
+
+	sum := 0.0
+	sumSquares := 0.0
+	for _, coefficient := range window {
+		sum += coefficient
+		sumSquares += coefficient * coefficient
+	}
+
+	return float64(len(window)) * sumSquares / (sum * sum)
+}
+
+// cosineWindow returns a generalized cosine window, w[n] = a0 - a1 cos(2 pi n/N) + a2 cos(4 pi n/N) - ...
+func cosineWindow(size int, terms ...float64) []float64 {
+
+	coefficients := make([]float64, size)
+	for n := range coefficients {
+		sign := 1.0
+		for k, term := range terms {
+			coefficients[n] += sign * term * math.Cos(2*math.Pi*float64(k*n)/float64(size))
+			sign = -sign
+		}
+	}
+
+	return coefficients
+}
+
+// kaiserWindow returns a Kaiser window
+func kaiserWindow(size int, beta float64) []float64 {
+
+	coefficients := make([]float64, size)
+	scale := 1 / besselI0(beta)
+	for n := range coefficients {
+		ratio := 2*float64(n)/float64(size) - 1
+		coefficients[n] = besselI0(beta*math.Sqrt(1-ratio*ratio)) * scale
+	}
+
+	return coefficients
+}
+
+// besselI0 returns the modified Bessel function of the first kind of order 0, from its power series
+func besselI0(x float64) float64 {
+
+	sum := 1.0
+	term := 1.0
+	half := x / 2
+	for k := 1; k < 500; k++ {
+		term *= (half / float64(k)) * (half / float64(k))
+		sum += term
+		if term < sum*1e-17 {
+			break
+		}
+	}
+
+	return sum
+}
@@ -0,0 +1,139 @@
+package waterfall
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the rendering of spectrograms (waterfalls) of RX streams to images, with colormaps and axes.
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Colormap converts a level between 0 and 1 to a color
+type Colormap int
+
+const (
+	// ColormapViridis is the perceptually uniform colormap of matplotlib
+	ColormapViridis Colormap = iota
+	// ColormapTurbo is the rainbow colormap of Google, with a high contrast
+	ColormapTurbo
+	// ColormapGrayscale goes from black to white
+	ColormapGrayscale
+)
+
+// String returns the name of the colormap
+func (colormap Colormap) String() string {
+
+	switch colormap {
+	case ColormapViridis:
+		return "viridis"
+	case ColormapTurbo:
+		return "turbo"
+	case ColormapGrayscale:
+		return "grayscale"
+	}
+
+	return fmt.Sprintf("colormap(%d)", int(colormap))
+}
+
+// ParseColormap returns the colormap of a name, as returned by String
+func ParseColormap(name string) (Colormap, error) {
+
+	for _, colormap := range []Colormap{ColormapViridis, ColormapTurbo, ColormapGrayscale} {
+		if colormap.String() == name {
+			return colormap, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown colormap: %v", name)
+}
+
+// Color returns the color of a level, clamped between 0 and 1
+func (colormap Colormap) Color(level float64) color.RGBA {
+
+	if !(level > 0) {
+		level = 0
+	}
+	if level > 1 {
+		level = 1
+	}
+
+	switch colormap {
+	case ColormapTurbo:
+		return turbo(level)
+	case ColormapGrayscale:
+		gray := uint8(math.Round(255 * level))
+		return color.RGBA{R: gray, G: gray, B: gray, A: 255}
+	}
+
+	return viridis(level)
+}
+
+// table returns the colors of 256 levels
+func (colormap Colormap) table() []color.RGBA {
+
+	table := make([]color.RGBA, 256)
+	for i := range table {
+		table[i] = colormap.Color(float64(i) / 255)
+	}
+
+	return table
+}
+
+// viridis returns the color of a level with a polynomial approximation of the viridis colormap
+func viridis(t float64) color.RGBA {
+
+	red := polynomial(t, 0.2777273272234177, 0.1050930431085774, -0.3308618287255563, -4.634230498983486,
+		6.228269936347081, 4.776384997670288, -5.435455855934631)
+	green := polynomial(t, 0.005407344544966578, 1.404613529898575, 0.214847559468213, -5.799100973351585,
+		14.17993336680509, -13.74514537774601, 4.645852612178535)
+	blue := polynomial(t, 0.3340998053353061, 1.384590162594685, 0.09509516302823659, -19.33244095627987,
+		56.69055260068105, -65.35303263337234, 26.3124352495832)
+
+	return color.RGBA{R: channel(red), G: channel(green), B: channel(blue), A: 255}
+}
+
+// turbo returns the color of a level with the polynomial approximation of the turbo colormap
+func turbo(t float64) color.RGBA {
+
+	red := polynomial(t, 0.13572138, 4.61539260, -42.66032258, 132.13108234, -152.94239396, 59.28637943)
+	green := polynomial(t, 0.09140261, 2.19418839, 4.84296658, -14.18503333, 4.27729857, 2.82956604)
+	blue := polynomial(t, 0.10667330, 12.64194608, -60.58204836, 110.36276771, -89.90310912, 27.34824973)
+
+	return color.RGBA{R: channel(red), G: channel(green), B: channel(blue), A: 255}
+}
+
+// polynomial evaluates a polynomial given by its coefficients, the constant first
+func polynomial(t float64, coefficients ...float64) float64 {
+
+	value := 0.0
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		value = value*t + coefficients[i]
+	}
+
+	return value
+}
+
+// channel converts a color component between 0 and 1 to 8 bits
+func channel(value float64) uint8 {
+	return uint8(math.Round(255 * math.Max(0, math.Min(1, value))))
+}
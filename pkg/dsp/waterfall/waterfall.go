@@ -0,0 +1,463 @@
+package waterfall
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/dsp/spectrum"
+)
+
+const (
+	// defaultHeight is the number of rows kept when none is given
+	defaultHeight = 512
+	// defaultMinDB is the level of the first color of the colormap when no range is given
+	defaultMinDB = -120
+	// defaultMaxDB is the level of the last color of the colormap when no range is given
+	defaultMaxDB = 0
+
+	// leftMargin is the room of the time labels
+	leftMargin = 56
+	// rightMargin is the room of the color bar and its labels
+	rightMargin = 72
+	// topMargin is the space above the spectrogram
+	topMargin = 8
+	// bottomMargin is the room of the frequency labels
+	bottomMargin = 24
+	// tickLength is the length of the ticks of the axes
+	tickLength = 4
+	// colorBarWidth is the width of the color bar
+	colorBarWidth = 12
+)
+
+var (
+	// background is the color of the margins
+	background = color.RGBA{A: 255}
+	// ink is the color of the axes and the labels
+	ink = color.RGBA{R: 220, G: 220, B: 220, A: 255}
+)
+
+// Options are the options of a waterfall
+type Options struct {
+	// Spectrum are the options of the spectra of the rows. The sample rate is needed for the axes.
+	Spectrum spectrum.Options
+	// CenterFrequency is the center frequency of the stream in Hz, used by the frequency axis
+	CenterFrequency float64
+	// Width is the number of columns, the size of the spectra when 0. When smaller than the size of the spectra,
+	// each column shows the maximum level of its bins.
+	Width int
+	// Height is the number of rows kept, 512 when 0. Each row is a spectrum, the newest at the top.
+	Height int
+	// Colormap is the colormap of the levels
+	Colormap Colormap
+	// MinDB is the level of the first color of the colormap. With MaxDB, -120 dB when both are 0.
+	MinDB float64
+	// MaxDB is the level of the last color of the colormap. With MinDB, 0 dB when both are 0.
+	MaxDB float64
+	// Axes draws the time and frequency axes and the color bar around the spectrogram
+	Axes bool
+}
+
+// FromDevice sets the center frequency and the sample rate of the options from a RX channel of a device.
+//
+// Params:
+//  - dev: the device
+//  - channel: the RX channel
+func (options *Options) FromDevice(dev *device.SDRDevice, channel uint) {
+
+	options.CenterFrequency = dev.GetFrequency(device.DirectionRX, channel)
+	options.Spectrum.SampleRate = dev.GetSampleRate(device.DirectionRX, channel)
+}
+
+// Waterfall renders the spectrogram of a stream: a scrolling image whose rows are the successive spectra of the
+// stream.
+type Waterfall struct {
+	options     Options
+	analyzer    *spectrum.Analyzer
+	colors      []color.RGBA
+	rows        [][]float64
+	next        int
+	count       int
+	total       int
+	rowDuration time.Duration
+}
+
+// New creates a waterfall.
+//
+// Params:
+//  - options: the options of the waterfall
+//
+// Return the waterfall or an error
+func New(options Options) (waterfall *Waterfall, err error) {
+
+	analyzer, err := spectrum.NewAnalyzer(options.Spectrum)
+	if err != nil {
+		return nil, err
+	}
+	options.Spectrum = analyzer.Options()
+
+	if options.Width == 0 {
+		options.Width = options.Spectrum.Size
+	}
+	if options.Height == 0 {
+		options.Height = defaultHeight
+	}
+	if options.MinDB == 0 && options.MaxDB == 0 {
+		options.MinDB = defaultMinDB
+		options.MaxDB = defaultMaxDB
+	}
+
+	if options.Width < 0 || options.Height < 0 {
+		return nil, errors.New("the size of a waterfall can not be negative")
+	}
+
+	if options.MaxDB <= options.MinDB {
+		return nil, errors.New("the maximum level of a waterfall must be above its minimum level")
+	}
+
+	waterfall = &Waterfall{
+		options:  options,
+		analyzer: analyzer,
+		colors:   options.Colormap.table(),
+		rows:     make([][]float64, options.Height),
+	}
+
+	if options.Spectrum.SampleRate > 0 {
+		hop := math.Max(1, math.Round(float64(options.Spectrum.Size)*(1-options.Spectrum.Overlap)))
+		seconds := hop * float64(options.Spectrum.Averages) / options.Spectrum.SampleRate
+		waterfall.rowDuration = time.Duration(seconds * float64(time.Second))
+	}
+
+	return waterfall, nil
+}
+
+// Write processes samples and adds the rows of the spectra they complete.
+//
+// Params:
+//  - buffer: the samples of a single channel, such as a []complex64 or a []int16
+//
+// Return the new rows, as images of one pixel high, for live displays, or an error
+func (waterfall *Waterfall) Write(buffer interface{}) (rows []*image.RGBA, err error) {
+
+	spectra, err := waterfall.analyzer.Write(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, spectrum := range spectra {
+		rows = append(rows, waterfall.AddSpectrum(spectrum))
+	}
+
+	return rows, nil
+}
+
+// AddSpectrum adds a row from a spectrum computed by the caller, in dB with the negative frequencies first.
+//
+// Params:
+//  - spectrum: the levels of the bins
+//
+// Return the row, as an image of one pixel high, or nil when the spectrum is empty, no row being added
+func (waterfall *Waterfall) AddSpectrum(spectrum []float64) *image.RGBA {
+
+	if len(spectrum) == 0 {
+		return nil
+	}
+
+	width := waterfall.options.Width
+
+	row := waterfall.rows[waterfall.next]
+	if row == nil {
+		row = make([]float64, width)
+	}
+
+	// Each column keeps the maximum level of its bins
+	for column := range row {
+		start := column * len(spectrum) / width
+		end := (column + 1) * len(spectrum) / width
+		if end <= start {
+			end = start + 1
+		}
+		row[column] = math.Inf(-1)
+		for _, level := range spectrum[start:end] {
+			row[column] = math.Max(row[column], level)
+		}
+	}
+
+	waterfall.rows[waterfall.next] = row
+	waterfall.next = (waterfall.next + 1) % len(waterfall.rows)
+	if waterfall.count < len(waterfall.rows) {
+		waterfall.count++
+	}
+	waterfall.total++
+
+	img := image.NewRGBA(image.Rect(0, 0, width, 1))
+	waterfall.drawRow(img, 0, 0, row)
+
+	return img
+}
+
+// SetCenterFrequency changes the center frequency of the frequency axis, after retuning the device for example
+func (waterfall *Waterfall) SetCenterFrequency(centerFrequency float64) {
+	waterfall.options.CenterFrequency = centerFrequency
+}
+
+// RowDuration returns the duration of the samples of a row, or 0 if the sample rate is unknown
+func (waterfall *Waterfall) RowDuration() time.Duration {
+	return waterfall.rowDuration
+}
+
+// Rows returns the number of rows added since the creation of the waterfall
+func (waterfall *Waterfall) Rows() int {
+	return waterfall.total
+}
+
+// Image renders the rows kept, the newest at the top, with the axes when enabled
+func (waterfall *Waterfall) Image() *image.RGBA {
+
+	width := waterfall.options.Width
+	height := waterfall.options.Height
+
+	origin := image.Pt(0, 0)
+	bounds := image.Rect(0, 0, width, height)
+	if waterfall.options.Axes {
+		origin = image.Pt(leftMargin, topMargin)
+		bounds = image.Rect(0, 0, leftMargin+width+rightMargin, topMargin+height+bottomMargin)
+	}
+
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, &image.Uniform{C: background}, image.Point{}, draw.Src)
+
+	for y := 0; y < waterfall.count; y++ {
+		index := (waterfall.next - 1 - y + 2*len(waterfall.rows)) % len(waterfall.rows)
+		waterfall.drawRow(img, origin.X, origin.Y+y, waterfall.rows[index])
+	}
+
+	if waterfall.options.Axes {
+		waterfall.drawFrequencyAxis(img, origin)
+		waterfall.drawTimeAxis(img, origin)
+		waterfall.drawColorBar(img, origin)
+	}
+
+	return img
+}
+
+// WritePNG encodes the image of the waterfall in PNG format.
+//
+// Params:
+//  - writer: the destination of the image
+//
+// Return an error or nil in case of success
+func (waterfall *Waterfall) WritePNG(writer io.Writer) error {
+	return png.Encode(writer, waterfall.Image())
+}
+
+// SavePNG writes the image of the waterfall to a PNG file.
+//
+// Params:
+//  - path: the path of the file
+//
+// Return an error or nil in case of success
+func (waterfall *Waterfall) SavePNG(path string) error {
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	if err := waterfall.WritePNG(writer); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// drawRow draws the colors of the levels of a row
+func (waterfall *Waterfall) drawRow(img *image.RGBA, x int, y int, row []float64) {
+
+	for column, level := range row {
+		img.SetRGBA(x+column, y, waterfall.color(level))
+	}
+}
+
+// color returns the color of a level in dB
+func (waterfall *Waterfall) color(level float64) color.RGBA {
+
+	ratio := (level - waterfall.options.MinDB) / (waterfall.options.MaxDB - waterfall.options.MinDB)
+	if !(ratio > 0) {
+		ratio = 0
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+
+	return waterfall.colors[int(math.Round(ratio*float64(len(waterfall.colors)-1)))]
+}
+
+// drawFrequencyAxis draws the frequency axis below the spectrogram
+func (waterfall *Waterfall) drawFrequencyAxis(img *image.RGBA, origin image.Point) {
+
+	width := waterfall.options.Width
+	y := origin.Y + waterfall.options.Height
+
+	for x := 0; x < width; x++ {
+		img.SetRGBA(origin.X+x, y, ink)
+	}
+
+	rate := waterfall.options.Spectrum.SampleRate
+	if !(rate > 0) {
+		return
+	}
+
+	center := waterfall.options.CenterFrequency
+	low := center - rate/2
+	high := center + rate/2
+
+	unit, suffix := frequencyUnit(math.Max(math.Abs(low), math.Abs(high)))
+	step := niceStep(rate / math.Max(1, float64(width)/80))
+	decimals := decimalsOf(step / unit)
+
+	for frequency := math.Ceil(low/step) * step; frequency <= high; frequency += step {
+
+		x := origin.X + int(math.Round((frequency-low)/rate*float64(width)))
+		for i := 1; i <= tickLength; i++ {
+			img.SetRGBA(x, y+i, ink)
+		}
+
+		label := strconv.FormatFloat(frequency/unit, 'f', decimals, 64)
+		drawText(img, x-textWidth(label)/2, y+tickLength+3, label, ink)
+	}
+
+	drawText(img, origin.X+width+8, y+tickLength+3, suffix, ink)
+}
+
+// drawTimeAxis draws the time axis on the left of the spectrogram, the time elapsed since the first row
+func (waterfall *Waterfall) drawTimeAxis(img *image.RGBA, origin image.Point) {
+
+	height := waterfall.options.Height
+	x := origin.X - 1
+
+	for y := 0; y < height; y++ {
+		img.SetRGBA(x, origin.Y+y, ink)
+	}
+
+	rowSeconds := waterfall.rowDuration.Seconds()
+	if rowSeconds <= 0 || waterfall.count == 0 {
+		return
+	}
+
+	// The top row is the newest one
+	newest := float64(waterfall.total-1) * rowSeconds
+	oldest := float64(waterfall.total-waterfall.count) * rowSeconds
+
+	step := niceStep(float64(height) * rowSeconds / math.Max(1, float64(height)/48))
+	decimals := decimalsOf(step)
+
+	for seconds := math.Ceil(oldest/step) * step; seconds <= newest; seconds += step {
+
+		y := origin.Y + int(math.Round((newest-seconds)/rowSeconds))
+		for i := 1; i <= tickLength; i++ {
+			img.SetRGBA(x-i, y, ink)
+		}
+
+		label := strconv.FormatFloat(seconds, 'f', decimals, 64) + "s"
+		drawText(img, x-tickLength-2-textWidth(label), y-glyphHeight/2, label, ink)
+	}
+}
+
+// drawColorBar draws the colors of the levels on the right of the spectrogram
+func (waterfall *Waterfall) drawColorBar(img *image.RGBA, origin image.Point) {
+
+	height := waterfall.options.Height
+	x := origin.X + waterfall.options.Width + 8
+
+	for y := 0; y < height; y++ {
+		level := waterfall.options.MaxDB - (waterfall.options.MaxDB-waterfall.options.MinDB)*float64(y)/math.Max(1, float64(height-1))
+		for i := 0; i < colorBarWidth; i++ {
+			img.SetRGBA(x+i, origin.Y+y, waterfall.color(level))
+		}
+	}
+
+	maxLabel := strconv.FormatFloat(waterfall.options.MaxDB, 'f', -1, 64) + "dB"
+	minLabel := strconv.FormatFloat(waterfall.options.MinDB, 'f', -1, 64) + "dB"
+	drawText(img, x+colorBarWidth+4, origin.Y, maxLabel, ink)
+	drawText(img, x+colorBarWidth+4, origin.Y+height-glyphHeight, minLabel, ink)
+}
+
+// frequencyUnit returns the unit of the labels of a frequency and its name
+func frequencyUnit(frequency float64) (float64, string) {
+
+	switch {
+	case frequency >= 1e9:
+		return 1e9, "GHz"
+	case frequency >= 1e6:
+		return 1e6, "MHz"
+	case frequency >= 1e3:
+		return 1e3, "kHz"
+	}
+
+	return 1, "Hz"
+}
+
+// niceStep returns the smallest step of 1, 2 or 5 times a power of ten above a value
+func niceStep(value float64) float64 {
+
+	if !(value > 0) {
+		return 1
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, factor := range []float64{1, 2, 5, 10} {
+		if factor*magnitude >= value {
+			return factor * magnitude
+		}
+	}
+
+	return 10 * magnitude
+}
+
+// decimalsOf returns the number of decimals needed to write the multiples of a step
+func decimalsOf(step float64) int {
+
+	decimals := int(math.Ceil(-math.Log10(step) - 1e-9))
+	if decimals < 0 {
+		return 0
+	}
+
+	return decimals
+}
@@ -0,0 +1,270 @@
+package waterfall
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/dsp/spectrum"
+)
+
+// TestColormap checks the colors of the ends of the colormaps and the clamping of the levels
+func TestColormap(t *testing.T) {
+
+	tests := []struct {
+		colormap  Colormap
+		first     color.RGBA
+		last      color.RGBA
+		tolerance int
+	}{
+		// Reference colors of matplotlib, #440154 and #fde725
+		{ColormapViridis, color.RGBA{68, 1, 84, 255}, color.RGBA{253, 231, 37, 255}, 4},
+		// Colors of the polynomial approximation of turbo, which departs from the reference at its ends
+		{ColormapTurbo, color.RGBA{35, 23, 27, 255}, color.RGBA{144, 13, 0, 255}, 0},
+		{ColormapGrayscale, color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 255, 255}, 0},
+	}
+
+	near := func(a color.RGBA, b color.RGBA, tolerance int) bool {
+		for _, difference := range []int{int(a.R) - int(b.R), int(a.G) - int(b.G), int(a.B) - int(b.B)} {
+			if difference > tolerance || difference < -tolerance {
+				return false
+			}
+		}
+		return a.A == b.A
+	}
+
+	for _, test := range tests {
+
+		if first := test.colormap.Color(0); !near(first, test.first, test.tolerance) {
+			t.Errorf("%v: first color %v, expected %v", test.colormap, first, test.first)
+		}
+		if last := test.colormap.Color(1); !near(last, test.last, test.tolerance) {
+			t.Errorf("%v: last color %v, expected %v", test.colormap, last, test.last)
+		}
+
+		for _, level := range []float64{-1, math.Inf(-1), math.NaN()} {
+			if c := test.colormap.Color(level); c != test.colormap.Color(0) {
+				t.Errorf("%v: color %v of the level %v, expected the first color", test.colormap, c, level)
+			}
+		}
+		for _, level := range []float64{2, math.Inf(1)} {
+			if c := test.colormap.Color(level); c != test.colormap.Color(1) {
+				t.Errorf("%v: color %v of the level %v, expected the last color", test.colormap, c, level)
+			}
+		}
+
+		if colormap, err := ParseColormap(test.colormap.String()); err != nil || colormap != test.colormap {
+			t.Errorf("%v: parsed as %v (%v)", test.colormap, colormap, err)
+		}
+	}
+
+	if _, err := ParseColormap("jet"); err == nil {
+		t.Error("expected an error for an unknown colormap")
+	}
+}
+
+// gray returns a gray color
+func gray(value uint8) color.RGBA {
+	return color.RGBA{R: value, G: value, B: value, A: 255}
+}
+
+// newTestWaterfall creates a grayscale waterfall from -100 dB to 0 dB
+func newTestWaterfall(t *testing.T, width int, height int, axes bool) *Waterfall {
+
+	waterfall, err := New(Options{
+		Spectrum: spectrum.Options{Size: 8, SampleRate: 1e6},
+		Width:    width,
+		Height:   height,
+		Colormap: ColormapGrayscale,
+		MinDB:    -100,
+		MaxDB:    0,
+		Axes:     axes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return waterfall
+}
+
+// TestLevelColor checks the colors of the levels, clamped to the range of the waterfall
+func TestLevelColor(t *testing.T) {
+
+	waterfall := newTestWaterfall(t, 8, 4, false)
+
+	tests := []struct {
+		level float64
+		color color.RGBA
+	}{
+		{-100, gray(0)},
+		{-200, gray(0)},
+		{math.Inf(-1), gray(0)},
+		{math.NaN(), gray(0)},
+		{-50, gray(128)},
+		{0, gray(255)},
+		{20, gray(255)},
+		{math.Inf(1), gray(255)},
+	}
+
+	for _, test := range tests {
+		if c := waterfall.color(test.level); c != test.color {
+			t.Errorf("level %v: color %v, expected %v", test.level, c, test.color)
+		}
+	}
+}
+
+// TestRows checks the columns of the rows, the maximum of their bins, and the order of the rows in the image, the
+// newest at the top
+func TestRows(t *testing.T) {
+
+	waterfall := newTestWaterfall(t, 4, 3, false)
+
+	tests := []struct {
+		spectrum []float64
+		colors   []color.RGBA
+	}{
+		// Two bins per column
+		{[]float64{-50, -100, -100, -100, 0, -100, -100, -200}, []color.RGBA{gray(128), gray(0), gray(255), gray(0)}},
+		// One bin per column
+		{[]float64{0, 0, -100, -50}, []color.RGBA{gray(255), gray(255), gray(0), gray(128)}},
+		// Two columns per bin
+		{[]float64{-100, 0}, []color.RGBA{gray(0), gray(0), gray(255), gray(255)}},
+		// Uneven
+		{[]float64{-100, -50, 0}, []color.RGBA{gray(0), gray(0), gray(128), gray(255)}},
+	}
+
+	for i, test := range tests {
+
+		row := waterfall.AddSpectrum(test.spectrum)
+		if row.Bounds() != image.Rect(0, 0, 4, 1) {
+			t.Fatalf("row %d: bounds %v", i, row.Bounds())
+		}
+
+		for x, expected := range test.colors {
+			if c := row.RGBAAt(x, 0); c != expected {
+				t.Errorf("row %d: column %d of color %v, expected %v", i, x, c, expected)
+			}
+		}
+	}
+
+	if waterfall.Rows() != 4 {
+		t.Errorf("%d rows, expected 4", waterfall.Rows())
+	}
+
+	// The image keeps the 3 newest rows, the newest at the top
+	img := waterfall.Image()
+	if img.Bounds() != image.Rect(0, 0, 4, 3) {
+		t.Fatalf("image bounds %v, expected 4x3", img.Bounds())
+	}
+
+	for y, test := range []int{3, 2, 1} {
+		for x, expected := range tests[test].colors {
+			if c := img.RGBAAt(x, y); c != expected {
+				t.Errorf("pixel %d,%d of color %v, expected %v", x, y, c, expected)
+			}
+		}
+	}
+}
+
+// TestEmptySpectrum checks that an empty spectrum adds no row
+func TestEmptySpectrum(t *testing.T) {
+
+	waterfall := newTestWaterfall(t, 4, 3, false)
+
+	if row := waterfall.AddSpectrum(nil); row != nil {
+		t.Errorf("row %v added for an empty spectrum", row.Bounds())
+	}
+	if row := waterfall.AddSpectrum([]float64{}); row != nil {
+		t.Errorf("row %v added for an empty spectrum", row.Bounds())
+	}
+
+	if waterfall.Rows() != 0 {
+		t.Errorf("%d rows, expected none", waterfall.Rows())
+	}
+}
+
+// TestImageAxes checks the size of the image with the axes and the position of the spectrogram in it
+func TestImageAxes(t *testing.T) {
+
+	waterfall := newTestWaterfall(t, 200, 100, true)
+	waterfall.AddSpectrum([]float64{0})
+
+	img := waterfall.Image()
+	expected := image.Rect(0, 0, leftMargin+200+rightMargin, topMargin+100+bottomMargin)
+	if img.Bounds() != expected {
+		t.Fatalf("image bounds %v, expected %v", img.Bounds(), expected)
+	}
+
+	if c := img.RGBAAt(leftMargin, topMargin); c != gray(255) {
+		t.Errorf("first pixel of the spectrogram of color %v, expected white", c)
+	}
+	if c := img.RGBAAt(leftMargin+199, topMargin); c != gray(255) {
+		t.Errorf("last pixel of the newest row of color %v, expected white", c)
+	}
+	if c := img.RGBAAt(leftMargin, topMargin+1); c != background {
+		t.Errorf("pixel below the only row of color %v, expected the background", c)
+	}
+	if c := img.RGBAAt(0, 0); c != background {
+		t.Errorf("corner of color %v, expected the background", c)
+	}
+}
+
+// TestWrite checks the rows of the spectra of samples and their duration
+func TestWrite(t *testing.T) {
+
+	waterfall, err := New(Options{
+		Spectrum: spectrum.Options{Size: 64, Overlap: 0.5, Averages: 2, SampleRate: 64e3},
+		Height:   16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 32 samples per segment, 2 segments per row
+	if duration := waterfall.RowDuration(); duration != time.Millisecond {
+		t.Errorf("row duration %v, expected 1ms", duration)
+	}
+
+	rows, err := waterfall.Write(make([]complex64, 64+3*32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || waterfall.Rows() != 2 {
+		t.Fatalf("%d rows returned and %d rows added, expected 2", len(rows), waterfall.Rows())
+	}
+	if rows[0].Bounds() != image.Rect(0, 0, 64, 1) {
+		t.Errorf("row bounds %v, expected 64x1", rows[0].Bounds())
+	}
+
+	if _, err := waterfall.Write([]string{"samples"}); err == nil {
+		t.Error("expected an error for an unknown buffer type")
+	}
+
+	for _, options := range []Options{{Width: -1}, {MinDB: -10, MaxDB: -20}} {
+		if _, err := New(options); err == nil {
+			t.Errorf("expected an error for the options %+v", options)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package npy
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the reading and writing of sample buffers in the NumPy .npy and .npz formats.
+//
+// A single channel is stored as a 1-D array of complex numbers, or a (samples, 2) array of I and Q for the integer
+// formats. Several channels are stored as a (channels, samples) array, or a (channels, samples, 2) array for the
+// integer formats, so that the first index of the array selects the channel.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+const (
+	// magic starts the .npy files
+	magic = "\x93NUMPY"
+	// headerAlignment is the alignment of the data of the arrays
+	headerAlignment = 64
+	// streamHeaderSize is the size of the headers written before the shape is known, large enough for any shape
+	streamHeaderSize = 128
+)
+
+var (
+	descrRegexp   = regexp.MustCompile(`'descr'\s*:\s*'([^']*)'`)
+	fortranRegexp = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	shapeRegexp   = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+// header is the description of an array
+type header struct {
+	descr string
+	shape []int
+}
+
+// Descr returns the NumPy dtype descriptor of the I and Q numbers of a stream format, for example "<c8" for CF32.
+//
+// Params:
+//  - format: the stream format
+//
+// Return the descriptor or an error if the format is unknown
+func Descr(format string) (string, error) {
+
+	switch format {
+	case sdrformat.CU8:
+		return "|u1", nil
+	case sdrformat.CS8:
+		return "|i1", nil
+	case sdrformat.CU16:
+		return "<u2", nil
+	case sdrformat.CS16:
+		return "<i2", nil
+	case sdrformat.CF32:
+		return "<c8", nil
+	case sdrformat.CF64:
+		return "<c16", nil
+	}
+
+	return "", fmt.Errorf("%w: %v", sdrformat.ErrUnknownFormat, format)
+}
+
+// Format returns the stream format of a NumPy dtype descriptor, the reverse of Descr.
+//
+// Params:
+//  - descr: the descriptor, such as "<i2"
+//
+// Return the stream format or an error if the descriptor does not match a stream format
+func Format(descr string) (string, error) {
+
+	switch descr {
+	case "|u1", "<u1":
+		return sdrformat.CU8, nil
+	case "|i1", "<i1":
+		return sdrformat.CS8, nil
+	case "<u2":
+		return sdrformat.CU16, nil
+	case "<i2":
+		return sdrformat.CS16, nil
+	case "<c8":
+		return sdrformat.CF32, nil
+	case "<c16":
+		return sdrformat.CF64, nil
+	}
+
+	return "", fmt.Errorf("unsupported NumPy dtype: %v", descr)
+}
+
+// shapeOf returns the shape of the array of a number of channels and samples
+func shapeOf(format string, nbChannels int, nbSamples int) []int {
+
+	var shape []int
+	if nbChannels != 1 {
+		shape = append(shape, nbChannels)
+	}
+	shape = append(shape, nbSamples)
+	if sdrformat.ElementsPerSample(format) == 2 {
+		shape = append(shape, 2)
+	}
+
+	return shape
+}
+
+// dimensions returns the number of channels and samples of an array
+func (header header) dimensions(format string) (nbChannels int, nbSamples int, err error) {
+
+	shape := header.shape
+	if sdrformat.ElementsPerSample(format) == 2 {
+		if len(shape) == 0 || shape[len(shape)-1] != 2 {
+			return 0, 0, fmt.Errorf("the last dimension of an array of %v must hold I and Q", header.descr)
+		}
+		shape = shape[:len(shape)-1]
+	}
+
+	switch len(shape) {
+	case 1:
+		return 1, shape[0], nil
+	case 2:
+		return shape[0], shape[1], nil
+	}
+
+	return 0, 0, fmt.Errorf("unsupported shape of samples: %v", header.shape)
+}
+
+// encodeHeader returns the header of an array, padded to a minimum size
+func encodeHeader(header header, minSize int) []byte {
+
+	dimensions := make([]string, len(header.shape))
+	for i, dimension := range header.shape {
+		dimensions[i] = strconv.Itoa(dimension)
+	}
+
+	shape := "(" + strings.Join(dimensions, ", ") + ")"
+	if len(dimensions) == 1 {
+		shape = "(" + dimensions[0] + ",)"
+	}
+
+	dict := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': %s, }", header.descr, shape)
+
+	// The magic, the version, the length of the header and the dictionary ended by a new line
+	size := len(magic) + 4 + len(dict) + 1
+	if size < minSize {
+		size = minSize
+	}
+	size = (size + headerAlignment - 1) / headerAlignment * headerAlignment
+
+	encoded := make([]byte, 0, size)
+	encoded = append(encoded, magic...)
+	encoded = append(encoded, 1, 0)
+	encoded = append(encoded, byte(size-10), byte((size-10)>>8))
+	encoded = append(encoded, dict...)
+	for len(encoded) < size-1 {
+		encoded = append(encoded, ' ')
+	}
+
+	return append(encoded, '\n')
+}
+
+// readHeader reads the header of an array
+func readHeader(reader io.Reader) (header header, err error) {
+
+	prefix := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(reader, prefix); err != nil {
+		return header, err
+	}
+
+	if string(prefix[:len(magic)]) != magic {
+		return header, errors.New("not a NumPy array")
+	}
+
+	var length int
+	switch prefix[len(magic)] {
+	case 1:
+		var size uint16
+		if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+			return header, err
+		}
+		length = int(size)
+	case 2, 3:
+		var size uint32
+		if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+			return header, err
+		}
+		length = int(size)
+	default:
+		return header, fmt.Errorf("unsupported version of NumPy array: %d", prefix[len(magic)])
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return header, err
+	}
+	dict := string(content)
+
+	descr := descrRegexp.FindStringSubmatch(dict)
+	fortran := fortranRegexp.FindStringSubmatch(dict)
+	shape := shapeRegexp.FindStringSubmatch(dict)
+	if descr == nil || fortran == nil || shape == nil {
+		return header, fmt.Errorf("invalid header of NumPy array: %v", strings.TrimSpace(dict))
+	}
+
+	if fortran[1] == "True" {
+		return header, errors.New("the arrays in Fortran order are not supported")
+	}
+
+	header.descr = descr[1]
+	for _, dimension := range strings.Split(shape[1], ",") {
+		dimension = strings.TrimSpace(dimension)
+		if dimension == "" {
+			continue
+		}
+		value, err := strconv.Atoi(dimension)
+		if err != nil || value < 0 {
+			return header, fmt.Errorf("invalid shape of NumPy array: (%v)", shape[1])
+		}
+		header.shape = append(header.shape, value)
+	}
+
+	return header, nil
+}
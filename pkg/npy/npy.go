@@ -0,0 +1,286 @@
+package npy
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// WriteArray writes sample buffers as a NumPy array.
+//
+// Params:
+//  - writer: the destination of the array
+//  - buffers: the buffers of all the channels, such as a [][]complex64
+//  - nbSamples: the number of samples to write per channel
+//
+// Return an error or nil in case of success
+func WriteArray(writer io.Writer, buffers interface{}, nbSamples int) error {
+
+	format, err := sdrformat.FormatOf(buffers)
+	if err != nil {
+		return err
+	}
+
+	descr, err := Descr(format)
+	if err != nil {
+		return err
+	}
+
+	channels, err := sdrformat.SplitChannels(buffers)
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(encodeHeader(header{descr: descr, shape: shapeOf(format, len(channels), nbSamples)}, 0)); err != nil {
+		return err
+	}
+
+	// The channels follow each other
+	var encoded []byte
+	for _, channel := range channels {
+		if encoded, err = sdrformat.Encode(encoded[:0], channel, nbSamples); err != nil {
+			return err
+		}
+		if _, err := writer.Write(encoded); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadArray reads a NumPy array of samples.
+//
+// Params:
+//  - reader: the source of the array
+//
+// Return the buffers of all the channels, of the Go type matching the dtype of the array, for example
+// [][]complex64 for "<c8", or an error
+func ReadArray(reader io.Reader) (buffers interface{}, err error) {
+
+	header, err := readHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := Format(header.descr)
+	if err != nil {
+		return nil, err
+	}
+
+	nbChannels, nbSamples, err := header.dimensions(format)
+	if err != nil {
+		return nil, err
+	}
+
+	buffers, err = sdrformat.MakeBuffers(format, nbChannels, nbSamples)
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := sdrformat.SplitChannels(buffers)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := make([]byte, nbSamples*sdrformat.BytesPerSample(format))
+	for _, channel := range channels {
+		if _, err := io.ReadFull(reader, encoded); err != nil {
+			return nil, err
+		}
+		if _, err := sdrformat.Decode(channel, encoded); err != nil {
+			return nil, err
+		}
+	}
+
+	return buffers, nil
+}
+
+// Save writes sample buffers to a .npy file.
+//
+// Params:
+//  - path: the path of the file
+//  - buffers: the buffers of all the channels, such as a [][]complex64
+//  - nbSamples: the number of samples to write per channel
+//
+// Return an error or nil in case of success
+func Save(path string, buffers interface{}, nbSamples int) error {
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	if err := WriteArray(writer, buffers, nbSamples); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Load reads sample buffers from a .npy file.
+//
+// Params:
+//  - path: the path of the file
+//
+// Return the buffers of all the channels or an error
+func Load(path string) (buffers interface{}, err error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadArray(bufio.NewReader(file))
+}
+
+// Writer writes the samples of a single channel to a .npy file as they are received. The shape of the array is
+// written on Close.
+type Writer struct {
+	file    *os.File
+	writer  *bufio.Writer
+	format  string
+	descr   string
+	count   int
+	encoded []byte
+	closed  bool
+}
+
+// Create creates a .npy file for the samples of a single channel.
+//
+// Params:
+//  - path: the path of the file
+//  - format: the stream format of the samples
+//
+// Return the writer or an error
+func Create(path string, format string) (writer *Writer, err error) {
+
+	descr, err := Descr(format)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reserve the room of the header
+	if _, err := file.Write(encodeHeader(header{descr: descr, shape: shapeOf(format, 1, 0)}, streamHeaderSize)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Writer{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		format: format,
+		descr:  descr,
+	}, nil
+}
+
+// Write appends samples to the array.
+//
+// Params:
+//  - buffers: the samples of a single channel, given as the buffers of all the channels, for example [][]int16
+//  - nbSamples: the number of samples to write
+//
+// Return an error or nil in case of success
+func (writer *Writer) Write(buffers interface{}, nbSamples int) (err error) {
+
+	if writer.closed {
+		return errors.New("the NumPy writer is closed")
+	}
+
+	format, err := sdrformat.FormatOf(buffers)
+	if err != nil {
+		return err
+	}
+
+	if format != writer.format {
+		return fmt.Errorf("the samples are in %v format but the array is in %v format", format, writer.format)
+	}
+
+	nbChannels, _, err := sdrformat.BuffersLength(buffers)
+	if err != nil {
+		return err
+	}
+
+	if nbChannels != 1 {
+		return fmt.Errorf("a NumPy writer holds a single channel, %d given", nbChannels)
+	}
+
+	writer.encoded, err = sdrformat.Encode(writer.encoded[:0], buffers, nbSamples)
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.writer.Write(writer.encoded); err != nil {
+		return err
+	}
+
+	writer.count += nbSamples
+
+	return nil
+}
+
+// SamplesWritten returns the number of samples written
+func (writer *Writer) SamplesWritten() int {
+	return writer.count
+}
+
+// Close writes the shape of the array and closes the file
+//
+// Return an error or nil in case of success
+func (writer *Writer) Close() error {
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+
+	if err := writer.writer.Flush(); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	encoded := encodeHeader(header{descr: writer.descr, shape: shapeOf(writer.format, 1, writer.count)}, streamHeaderSize)
+	if _, err := writer.file.WriteAt(encoded, 0); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	return writer.file.Close()
+}
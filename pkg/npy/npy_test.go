@@ -0,0 +1,337 @@
+package npy
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// formats are the stream formats stored in NumPy arrays
+var formats = []string{sdrformat.CU8, sdrformat.CS8, sdrformat.CU16, sdrformat.CS16, sdrformat.CF32, sdrformat.CF64}
+
+// testBuffers returns buffers of channels holding distinct samples
+func testBuffers(t *testing.T, format string, nbChannels int, nbSamples int) interface{} {
+
+	buffers, err := sdrformat.MakeBuffers(format, nbChannels, nbSamples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for c := 0; c < nbChannels; c++ {
+		switch typed := buffers.(type) {
+		case [][]uint8:
+			for i := range typed[c] {
+				typed[c][i] = uint8(i + 50*c)
+			}
+		case [][]int8:
+			for i := range typed[c] {
+				typed[c][i] = int8(i - 50*c)
+			}
+		case [][]uint16:
+			for i := range typed[c] {
+				typed[c][i] = uint16(i*251 + c)
+			}
+		case [][]int16:
+			for i := range typed[c] {
+				typed[c][i] = int16(i*997 - 30000 + c)
+			}
+		case [][]complex64:
+			for i := range typed[c] {
+				typed[c][i] = complex(float32(i)/7, float32(c)-float32(i)/3)
+			}
+		case [][]complex128:
+			for i := range typed[c] {
+				typed[c][i] = complex(float64(i)/7, float64(c)-float64(i)/3)
+			}
+		}
+	}
+
+	return buffers
+}
+
+func TestDescr(t *testing.T) {
+
+	for _, format := range formats {
+		descr, err := Descr(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual, err := Format(descr); err != nil || actual != format {
+			t.Errorf("the dtype %v of %v is read as %v (%v)", descr, format, actual, err)
+		}
+	}
+
+	for _, descr := range []string{"<f4", ">i2", "<c32", ""} {
+		if _, err := Format(descr); err == nil {
+			t.Errorf("the dtype %q is read as a stream format", descr)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+
+	tests := []struct {
+		header  header
+		minSize int
+		dict    string
+	}{
+		{header{"<c8", []int{3}}, 0, "{'descr': '<c8', 'fortran_order': False, 'shape': (3,), }"},
+		{header{"<i2", []int{4, 1000, 2}}, 0, "{'descr': '<i2', 'fortran_order': False, 'shape': (4, 1000, 2), }"},
+		{header{"<U12", nil}, 0, "{'descr': '<U12', 'fortran_order': False, 'shape': (), }"},
+		{header{"|u1", []int{0, 2}}, streamHeaderSize, "{'descr': '|u1', 'fortran_order': False, 'shape': (0, 2), }"},
+	}
+
+	for _, test := range tests {
+
+		encoded := encodeHeader(test.header, test.minSize)
+
+		// The data following the header is aligned, and the header holds a minimum size for the headers rewritten
+		if len(encoded)%headerAlignment != 0 || len(encoded) < test.minSize {
+			t.Errorf("header of %d bytes for %v", len(encoded), test.dict)
+		}
+		if !bytes.HasPrefix(encoded, []byte(magic+"\x01\x00")) || encoded[len(encoded)-1] != '\n' {
+			t.Errorf("header %q is not a version 1.0 header ended by a new line", encoded)
+		}
+		if length := int(binary.LittleEndian.Uint16(encoded[8:])); length != len(encoded)-10 {
+			t.Errorf("header length %d for a header of %d bytes", length, len(encoded))
+		}
+		if dict := strings.TrimRight(string(encoded[10:]), " \n"); dict != test.dict {
+			t.Errorf("header dictionary %q, expected %q", dict, test.dict)
+		}
+
+		decoded, err := readHeader(bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.descr != test.header.descr || !reflect.DeepEqual(decoded.shape, test.header.shape) {
+			t.Errorf("header %+v decoded as %+v", test.header, decoded)
+		}
+	}
+
+	// The headers of version 2.0 have a 32-bit length
+	dict := "{'descr': '<c16', 'fortran_order': False, 'shape': (5,), }\n"
+	version2 := append([]byte(magic+"\x02\x00"), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(version2[8:], uint32(len(dict)))
+	version2 = append(version2, dict...)
+	if decoded, err := readHeader(bytes.NewReader(version2)); err != nil || decoded.descr != "<c16" || !reflect.DeepEqual(decoded.shape, []int{5}) {
+		t.Errorf("version 2.0 header decoded as %+v (%v)", decoded, err)
+	}
+
+	fortran := encodeHeader(header{"<c8", []int{3}}, 0)
+	copy(fortran[10:], "{'descr': '<c8', 'fortran_order': True, 'shape': (3,), }")
+	if _, err := readHeader(bytes.NewReader(fortran)); err == nil {
+		t.Error("an array in Fortran order is accepted")
+	}
+}
+
+func TestArrayRoundTrip(t *testing.T) {
+
+	for _, format := range formats {
+		for _, nbChannels := range []int{1, 3} {
+
+			buffers := testBuffers(t, format, nbChannels, 100)
+
+			var encoded bytes.Buffer
+			if err := WriteArray(&encoded, buffers, 100); err != nil {
+				t.Fatal(err)
+			}
+
+			header, err := readHeader(bytes.NewReader(encoded.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := shapeOf(format, nbChannels, 100); !reflect.DeepEqual(header.shape, expected) {
+				t.Errorf("%v array of %d channels has the shape %v, expected %v", format, nbChannels, header.shape, expected)
+			}
+
+			dataSize := nbChannels * 100 * sdrformat.BytesPerSample(format)
+			if headerSize := encoded.Len() - dataSize; headerSize%headerAlignment != 0 {
+				t.Errorf("%v array data at offset %d, not aligned", format, headerSize)
+			}
+
+			decoded, err := ReadArray(bytes.NewReader(encoded.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, buffers) {
+				t.Errorf("%v array of %d channels differs after a round trip", format, nbChannels)
+			}
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+
+	for _, format := range formats {
+
+		path := filepath.Join(t.TempDir(), "samples.npy")
+
+		writer, err := Create(path, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buffers := testBuffers(t, format, 1, 300)
+		first, _ := sdrformat.SliceBuffers(buffers, 0, 100)
+		second, _ := sdrformat.SliceBuffers(buffers, 100, 300)
+		if err := writer.Write(first, 100); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Write(second, 200); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := int64(streamHeaderSize + 300*sdrformat.BytesPerSample(format)); info.Size() != expected {
+			t.Errorf("%v file of %d bytes, expected %d", format, info.Size(), expected)
+		}
+
+		decoded, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, buffers) {
+			t.Errorf("%v samples differ after a round trip", format)
+		}
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+
+	metadata := Metadata{Frequency: 433.92e6, SampleRate: 2.4e6, Gain: 32.5, TimeNs: 1234567890123}
+	metadata.DateTime = "2022-03-04T05:06:07.89Z"
+
+	for _, format := range formats {
+
+		buffers := testBuffers(t, format, 2, 100)
+		path := filepath.Join(t.TempDir(), "samples.npz")
+
+		if err := SaveArchive(path, buffers, 100, metadata); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, decodedMetadata, err := LoadArchive(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := metadata
+		expected.Format = format
+		if !reflect.DeepEqual(decoded, buffers) || decodedMetadata != expected {
+			t.Errorf("%v archive differs after a round trip: %+v", format, decodedMetadata)
+		}
+
+		// The arrays are stored uncompressed, as numpy.savez does
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, file := range archive.File {
+			names = append(names, file.Name)
+			if file.Method != zip.Store {
+				t.Errorf("%v entry compressed with method %d", file.Name, file.Method)
+			}
+		}
+		archive.Close()
+		if !reflect.DeepEqual(names, []string{SamplesEntry, MetadataEntry}) {
+			t.Errorf("archive entries %v", names)
+		}
+	}
+}
+
+func TestArchiveWriter(t *testing.T) {
+
+	for _, format := range formats {
+
+		path := filepath.Join(t.TempDir(), "samples.npz")
+
+		writer, err := CreateArchive(path, format, Metadata{SampleRate: 1e6})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buffers := testBuffers(t, format, 1, 200)
+		first, _ := sdrformat.SliceBuffers(buffers, 0, 50)
+		second, _ := sdrformat.SliceBuffers(buffers, 50, 200)
+		if err := writer.Write(first, 50); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Write(second, 150); err != nil {
+			t.Fatal(err)
+		}
+
+		// The metadata can change until the archive is closed, but not the format
+		writer.SetMetadata(Metadata{Frequency: 100e6, SampleRate: 2e6, Format: "CS8"})
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(path + spoolExtension); !os.IsNotExist(err) {
+			t.Errorf("the temporary file of the %v samples is not removed: %v", format, err)
+		}
+
+		decoded, metadata, err := LoadArchive(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, buffers) {
+			t.Errorf("%v samples differ after a round trip", format)
+		}
+		if expected := (Metadata{Frequency: 100e6, SampleRate: 2e6, Format: format}); metadata != expected {
+			t.Errorf("%v metadata %+v, expected %+v", format, metadata, expected)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+
+	for _, text := range []string{"", "{\"frequency\":1e+08}", "àéîõü €𝄞"} {
+
+		var encoded bytes.Buffer
+		if err := writeString(&encoded, text); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := readString(&encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded != text {
+			t.Errorf("string %q decoded as %q", text, decoded)
+		}
+	}
+}
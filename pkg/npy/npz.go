@@ -0,0 +1,312 @@
+package npy
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+const (
+	// SamplesEntry is the name of the array of the samples in the archives
+	SamplesEntry = "samples.npy"
+	// MetadataEntry is the name of the array of the metadata in the archives, a string holding a JSON object
+	MetadataEntry = "metadata.npy"
+	// spoolExtension is the extension of the temporary file of the samples of an archive being written
+	spoolExtension = ".samples.tmp"
+)
+
+// Metadata describes the samples of an archive. In Python, it is read with json.loads(str(archive["metadata"])).
+type Metadata struct {
+	// Frequency is the center frequency in Hz
+	Frequency float64 `json:"frequency"`
+	// SampleRate is the sample rate in samples per second
+	SampleRate float64 `json:"sample_rate"`
+	// Gain is the overall gain in dB
+	Gain float64 `json:"gain"`
+	// TimeNs is the hardware time in nanoseconds of the first sample
+	TimeNs uint64 `json:"time_ns"`
+	// DateTime is the UTC wall-clock time of the first sample in RFC 3339 format, or empty if unknown
+	DateTime string `json:"datetime,omitempty"`
+	// Format is the stream format of the samples
+	Format string `json:"format,omitempty"`
+}
+
+// SetTime sets the wall-clock time of the first sample
+func (metadata *Metadata) SetTime(wallTime time.Time) {
+	metadata.DateTime = wallTime.UTC().Format(time.RFC3339Nano)
+}
+
+// SaveArchive writes sample buffers and their metadata to a .npz file.
+//
+// Params:
+//  - path: the path of the file
+//  - buffers: the buffers of all the channels, such as a [][]complex64
+//  - nbSamples: the number of samples to write per channel
+//  - metadata: the description of the samples. The format is set from the buffers.
+//
+// Return an error or nil in case of success
+func SaveArchive(path string, buffers interface{}, nbSamples int, metadata Metadata) (err error) {
+
+	if metadata.Format, err = sdrformat.FormatOf(buffers); err != nil {
+		return err
+	}
+
+	return writeArchive(path, metadata, func(writer io.Writer) error {
+		return WriteArray(writer, buffers, nbSamples)
+	})
+}
+
+// LoadArchive reads sample buffers and their metadata from a .npz file.
+//
+// Params:
+//  - path: the path of the file
+//
+// Return the buffers of all the channels, the metadata, empty if the archive has none, or an error
+func LoadArchive(path string) (buffers interface{}, metadata Metadata, err error) {
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, metadata, err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+
+		switch file.Name {
+		case SamplesEntry:
+			entry, err := file.Open()
+			if err != nil {
+				return nil, metadata, err
+			}
+			buffers, err = ReadArray(bufio.NewReader(entry))
+			entry.Close()
+			if err != nil {
+				return nil, metadata, err
+			}
+
+		case MetadataEntry:
+			entry, err := file.Open()
+			if err != nil {
+				return nil, metadata, err
+			}
+			text, err := readString(bufio.NewReader(entry))
+			entry.Close()
+			if err != nil {
+				return nil, metadata, err
+			}
+			if err := json.Unmarshal([]byte(text), &metadata); err != nil {
+				return nil, metadata, err
+			}
+		}
+	}
+
+	if buffers == nil {
+		return nil, metadata, fmt.Errorf("the archive has no %v array", SamplesEntry)
+	}
+
+	return buffers, metadata, nil
+}
+
+// ArchiveWriter writes the samples of a single channel to a .npz file as they are received. The samples are kept in a
+// temporary file next to the archive, which is written on Close.
+type ArchiveWriter struct {
+	path     string
+	metadata Metadata
+	spool    *Writer
+	closed   bool
+}
+
+// CreateArchive creates a .npz file for the samples of a single channel.
+//
+// Params:
+//  - path: the path of the file
+//  - format: the stream format of the samples
+//  - metadata: the description of the samples, which can be changed until Close with SetMetadata
+//
+// Return the writer or an error
+func CreateArchive(path string, format string, metadata Metadata) (writer *ArchiveWriter, err error) {
+
+	spool, err := Create(path+spoolExtension, format)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.Format = format
+
+	return &ArchiveWriter{
+		path:     path,
+		metadata: metadata,
+		spool:    spool,
+	}, nil
+}
+
+// SetMetadata changes the description of the samples. The format can not be changed.
+func (writer *ArchiveWriter) SetMetadata(metadata Metadata) {
+	metadata.Format = writer.metadata.Format
+	writer.metadata = metadata
+}
+
+// Write appends samples to the archive.
+//
+// Params:
+//  - buffers: the samples of a single channel, given as the buffers of all the channels, for example [][]int16
+//  - nbSamples: the number of samples to write
+//
+// Return an error or nil in case of success
+func (writer *ArchiveWriter) Write(buffers interface{}, nbSamples int) error {
+	return writer.spool.Write(buffers, nbSamples)
+}
+
+// SamplesWritten returns the number of samples written
+func (writer *ArchiveWriter) SamplesWritten() int {
+	return writer.spool.SamplesWritten()
+}
+
+// Close writes the archive and removes the temporary file of the samples.
+//
+// Return an error or nil in case of success
+func (writer *ArchiveWriter) Close() error {
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+
+	spoolPath := writer.path + spoolExtension
+	defer os.Remove(spoolPath)
+
+	if err := writer.spool.Close(); err != nil {
+		return err
+	}
+
+	return writeArchive(writer.path, writer.metadata, func(destination io.Writer) error {
+
+		spool, err := os.Open(spoolPath)
+		if err != nil {
+			return err
+		}
+		defer spool.Close()
+
+		_, err = io.Copy(destination, spool)
+
+		return err
+	})
+}
+
+// writeArchive writes a .npz file whose samples array is written by a function
+func writeArchive(path string, metadata Metadata, writeSamples func(writer io.Writer) error) (err error) {
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	archive := zip.NewWriter(file)
+
+	// The arrays are stored without compression, as numpy.savez does
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: SamplesEntry, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := writeSamples(entry); err != nil {
+		return err
+	}
+
+	entry, err = archive.CreateHeader(&zip.FileHeader{Name: MetadataEntry, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := writeString(entry, string(encoded)); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// writeString writes a string as a 0-d NumPy array of unicode characters
+func writeString(writer io.Writer, text string) error {
+
+	length := utf8.RuneCountInString(text)
+
+	if _, err := writer.Write(encodeHeader(header{descr: fmt.Sprintf("<U%d", length)}, 0)); err != nil {
+		return err
+	}
+
+	encoded := make([]byte, 0, 4*length)
+	for _, character := range text {
+		encoded = append(encoded, byte(character), byte(character>>8), byte(character>>16), byte(character>>24))
+	}
+
+	_, err := writer.Write(encoded)
+
+	return err
+}
+
+// readString reads a 0-d NumPy array of unicode characters
+func readString(reader io.Reader) (string, error) {
+
+	header, err := readHeader(reader)
+	if err != nil {
+		return "", err
+	}
+
+	var length int
+	if _, err := fmt.Sscanf(header.descr, "<U%d", &length); err != nil || len(header.shape) != 0 {
+		return "", fmt.Errorf("not a NumPy string: %v %v", header.descr, header.shape)
+	}
+
+	encoded := make([]byte, 4*length)
+	if _, err := io.ReadFull(reader, encoded); err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	for i := 0; i < length; i++ {
+		character := rune(binary.LittleEndian.Uint32(encoded[4*i:]))
+		if character == 0 {
+			break
+		}
+		text.WriteRune(character)
+	}
+
+	return text.String(), nil
+}
@@ -0,0 +1,56 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/npy"
+)
+
+// SaveCaptureNPZ writes the samples of a capture to a NumPy archive, with the frequency, the sample rate and the gain
+// of the first channel read from the device.
+//
+// Params:
+//  - path: the path of the archive
+//  - dev: the device of the capture
+//  - request: the request of the capture
+//  - result: the result of the capture
+//
+// Return an error or nil in case of success
+func SaveCaptureNPZ(path string, dev *device.SDRDevice, request device.CaptureRequest, result device.CaptureResult) error {
+
+	if len(request.Channels) == 0 {
+		return errors.New("the capture has no channel")
+	}
+
+	channel := request.Channels[0]
+
+	metadata := npy.Metadata{
+		Frequency:  dev.GetFrequency(device.DirectionRX, channel),
+		SampleRate: dev.GetSampleRate(device.DirectionRX, channel),
+		Gain:       dev.GetGain(device.DirectionRX, channel),
+		TimeNs:     uint64(result.TimeNs),
+	}
+
+	return npy.SaveArchive(path, result.Buffers, int(request.NumSamples), metadata)
+}
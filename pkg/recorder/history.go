@@ -0,0 +1,127 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import "github.com/bhojpur/sdr/pkg/sdrformat"
+
+// history is a ring buffer keeping the last samples of all the channels of a stream
+type history struct {
+	buffers  interface{}
+	capacity int
+	start    int
+	count    int
+}
+
+// newHistory creates a ring buffer for a number of samples per channel
+func newHistory(format string, nbChannels int, capacity int) (*history, error) {
+
+	buffers, err := sdrformat.MakeBuffers(format, nbChannels, capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &history{
+		buffers:  buffers,
+		capacity: capacity,
+	}, nil
+}
+
+// push appends the samples between two indexes of buffers, overwriting the oldest samples
+func (history *history) push(buffers interface{}, from int, to int) error {
+
+	if history.capacity == 0 {
+		return nil
+	}
+
+	if to-from > history.capacity {
+		from = to - history.capacity
+	}
+
+	for from < to {
+
+		end := (history.start + history.count) % history.capacity
+
+		chunk := to - from
+		if chunk > history.capacity-end {
+			chunk = history.capacity - end
+		}
+
+		if err := copySamples(history.buffers, end, buffers, from, chunk); err != nil {
+			return err
+		}
+
+		from += chunk
+		history.count += chunk
+		if history.count > history.capacity {
+			history.start = (history.start + history.count - history.capacity) % history.capacity
+			history.count = history.capacity
+		}
+	}
+
+	return nil
+}
+
+// copyTo copies the samples kept, oldest first, to the beginning of buffers and returns their number
+func (history *history) copyTo(buffers interface{}) (int, error) {
+
+	first := history.count
+	if first > history.capacity-history.start {
+		first = history.capacity - history.start
+	}
+
+	if err := copySamples(buffers, 0, history.buffers, history.start, first); err != nil {
+		return 0, err
+	}
+
+	if err := copySamples(buffers, first, history.buffers, 0, history.count-first); err != nil {
+		return 0, err
+	}
+
+	return history.count, nil
+}
+
+// reset forgets the samples kept
+func (history *history) reset() {
+	history.start = 0
+	history.count = 0
+}
+
+// copySamples copies samples between buffers of all the channels
+func copySamples(dst interface{}, dstIndex int, src interface{}, srcIndex int, nbSamples int) error {
+
+	if nbSamples <= 0 {
+		return nil
+	}
+
+	dstSlice, err := sdrformat.SliceBuffers(dst, dstIndex, dstIndex+nbSamples)
+	if err != nil {
+		return err
+	}
+
+	srcSlice, err := sdrformat.SliceBuffers(src, srcIndex, srcIndex+nbSamples)
+	if err != nil {
+		return err
+	}
+
+	_, err = sdrformat.CopyBuffers(dstSlice, srcSlice)
+
+	return err
+}
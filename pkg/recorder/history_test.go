@@ -0,0 +1,126 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// ramp returns CS16 buffers of a channel whose samples hold their index, from a first index, in their I component
+func ramp(first int, nbSamples int) [][]int16 {
+
+	buffer := make([]int16, 2*nbSamples)
+	for i := 0; i < nbSamples; i++ {
+		buffer[2*i] = int16(first + i)
+	}
+
+	return [][]int16{buffer}
+}
+
+// indexes returns the indexes held by the first samples of CS16 buffers filled by ramp
+func indexes(buffers interface{}, nbSamples int) []int {
+
+	buffer := buffers.([][]int16)[0]
+
+	values := make([]int, nbSamples)
+	for i := range values {
+		values[i] = int(buffer[2*i])
+	}
+
+	return values
+}
+
+// sequence returns the integers of a range
+func sequence(first int, count int) []int {
+
+	values := make([]int, count)
+	for i := range values {
+		values[i] = first + i
+	}
+
+	return values
+}
+
+// TestHistory checks the samples kept by the ring buffer across its wraparound
+func TestHistory(t *testing.T) {
+
+	history, err := newHistory(sdrformat.CS16, 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		buffers  [][]int16
+		from     int
+		to       int
+		expected []int
+	}{
+		{"partial", ramp(0, 10), 0, 3, sequence(0, 3)},
+		{"filled", ramp(3, 10), 0, 2, sequence(0, 5)},
+		{"wraparound", ramp(5, 10), 0, 3, sequence(3, 5)},
+		{"range of a block", ramp(100, 10), 4, 6, []int{5, 6, 7, 104, 105}},
+		{"larger than the capacity", ramp(200, 20), 2, 19, sequence(214, 5)},
+		{"empty range", ramp(300, 10), 4, 4, sequence(214, 5)},
+	}
+
+	for _, test := range tests {
+
+		if err := history.push(test.buffers, test.from, test.to); err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+
+		buffers := ramp(-1000, 10)
+		count, err := history.copyTo(buffers)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+
+		if values := indexes(buffers, count); !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("%v: samples %v, expected %v", test.name, values, test.expected)
+		}
+
+		// The samples after the ones kept are left untouched
+		if values := indexes(buffers, 10)[count:]; !reflect.DeepEqual(values, sequence(-1000+count, 10-count)) {
+			t.Errorf("%v: samples after the history overwritten: %v", test.name, values)
+		}
+	}
+
+	history.reset()
+	if count, err := history.copyTo(ramp(0, 10)); err != nil || count != 0 {
+		t.Errorf("%d samples (%v) after the reset, expected none", count, err)
+	}
+
+	// Without pre-trigger samples, nothing is kept
+	empty, err := newHistory(sdrformat.CS16, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.push(ramp(0, 10), 0, 10); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := empty.copyTo(ramp(0, 10)); err != nil || count != 0 {
+		t.Errorf("%d samples (%v) in an empty history, expected none", count, err)
+	}
+}
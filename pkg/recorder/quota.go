@@ -0,0 +1,186 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recordedFile is a completed file of the directory
+type recordedFile struct {
+	path string
+	size int64
+}
+
+// scanFiles lists the files of the previous recordings of the directory having the prefix of the recorder, oldest
+// first. Files whose name does not follow the naming of the recorder are ignored.
+func (recorder *Recorder) scanFiles() error {
+
+	entries, err := os.ReadDir(recorder.options.Directory)
+	if err != nil {
+		return err
+	}
+
+	prefix := recorder.options.Prefix + "_"
+
+	var names []string
+	for _, entry := range entries {
+
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || len(name) < len(prefix)+len(startTimeLayout) {
+			continue
+		}
+
+		if _, err := time.Parse(startTimeLayout, name[len(prefix):len(prefix)+len(startTimeLayout)]); err != nil {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	recorder.files = nil
+	for _, name := range names {
+		recorder.addFile(filepath.Join(recorder.options.Directory, name))
+	}
+
+	return nil
+}
+
+// addFile adds a file to the completed files
+func (recorder *Recorder) addFile(path string) {
+
+	file := recordedFile{path: path}
+	if info, err := os.Stat(path); err == nil {
+		file.size = info.Size()
+	}
+
+	recorder.files = append(recorder.files, file)
+}
+
+// enforceQuota deletes the oldest completed segments until the quotas are respected.
+//
+// Params:
+//  - reserve: the number of bytes about to be written, counted in the quotas
+//
+// Return ErrQuotaExceeded when the quotas can not be respected, another error if a file can not be deleted
+func (recorder *Recorder) enforceQuota(reserve int64) error {
+
+	if recorder.options.MaxTotalBytes > 0 {
+
+		total := reserve + recorder.spooledBytes(recorder.fileSamples*recorder.bytesPerSample*int64(len(recorder.sinks)))
+		for _, file := range recorder.files {
+			total += file.size
+		}
+
+		for total > recorder.options.MaxTotalBytes && len(recorder.files) > 0 {
+			removed, err := recorder.removeOldest()
+			if err != nil {
+				return err
+			}
+			total -= removed
+		}
+
+		if total > recorder.options.MaxTotalBytes {
+			return ErrQuotaExceeded
+		}
+	}
+
+	if recorder.options.MinFreeBytes > 0 {
+		for {
+			free, err := diskFree(recorder.options.Directory)
+			if err != nil {
+				// The free space can not be known, only the total size is enforced
+				break
+			}
+
+			if free >= recorder.options.MinFreeBytes+uint64(reserve) {
+				break
+			}
+
+			if len(recorder.files) == 0 {
+				return ErrQuotaExceeded
+			}
+
+			if _, err := recorder.removeOldest(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeOldest deletes the files of the oldest completed segment together, such as the data and metadata files of a
+// SigMF recording, so that no file of a segment is left without the others.
+//
+// Return the number of bytes deleted or an error if a file can not be deleted
+func (recorder *Recorder) removeOldest() (removed int64, err error) {
+
+	segment := recorder.segmentName(recorder.files[0].path)
+
+	for len(recorder.files) > 0 && recorder.segmentName(recorder.files[0].path) == segment {
+
+		if err := os.Remove(recorder.files[0].path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+
+		removed += recorder.files[0].size
+		recorder.files = recorder.files[1:]
+	}
+
+	return removed, nil
+}
+
+// segmentName returns the part of the name of a file identifying its segment: the prefix and the start time shared by
+// the files of all the channels.
+func (recorder *Recorder) segmentName(path string) string {
+
+	name := filepath.Base(path)
+
+	length := len(recorder.options.Prefix) + len("_") + len(startTimeLayout)
+	if len(name) < length {
+		return name
+	}
+
+	return name[:length]
+}
+
+// spooledBytes returns the disk space needed by the samples of the current files. The samples of a .npz archive are
+// spooled to a temporary file, copied into the archive when it is closed, so they are counted twice.
+//
+// Params:
+//  - size: the size of the samples written in the current files
+//
+// Return the disk space in bytes
+func (recorder *Recorder) spooledBytes(size int64) int64 {
+
+	if recorder.options.FileFormat == FileFormatNPZ {
+		return 2 * size
+	}
+
+	return size
+}
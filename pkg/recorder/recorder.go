@@ -0,0 +1,544 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups a recording service writing the channels of RX streams to files, one file per channel. The files are
+// rotated by duration or size, named after the frequency, the sample rate and the UTC time of their first sample, and
+// the oldest recordings are deleted to keep the disk usage within quotas.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+	"github.com/bhojpur/sdr/pkg/sigmf"
+)
+
+// FileFormat is the format of the files written by a recorder
+type FileFormat string
+
+const (
+	// FileFormatRaw writes the samples as they are read from the stream, without header
+	FileFormatRaw FileFormat = "raw"
+	// FileFormatSigMF writes SigMF recordings (.sigmf-data and .sigmf-meta)
+	FileFormatSigMF FileFormat = "sigmf"
+	// FileFormatWAV writes WAV IQ files, converting the samples to a format supported by WAV files if needed
+	FileFormatWAV FileFormat = "wav"
+	// FileFormatNPY writes NumPy arrays (.npy)
+	FileFormatNPY FileFormat = "npy"
+	// FileFormatNPZ writes NumPy archives (.npz) holding the samples and their metadata
+	FileFormatNPZ FileFormat = "npz"
+)
+
+const (
+	// defaultPrefix is the prefix of the file names when none is given
+	defaultPrefix = "recording"
+	// defaultTimeoutUs is the timeout of the reads when none is given
+	defaultTimeoutUs = 100000
+	// startTimeLayout is the layout of the UTC start time in the file names
+	startTimeLayout = "20060102T150405.000Z"
+	// quotaCheckInterval is the interval between two checks of the quotas while recording
+	quotaCheckInterval = time.Second
+)
+
+// ErrQuotaExceeded is returned when the quotas can not be respected, even after deleting all the previous recordings
+var ErrQuotaExceeded = errors.New("the disk space quota of the recordings is exceeded")
+
+// deviceSettings gives the settings of the channels written in the names and the metadata of the files. It is
+// implemented by *device.SDRDevice.
+type deviceSettings interface {
+	GetFrequency(direction device.Direction, channel uint) float64
+	GetGain(direction device.Direction, channel uint) float64
+}
+
+// Options are the options of a recorder
+type Options struct {
+	// Directory is the directory of the files, created if needed. The current directory is used when empty.
+	Directory string
+	// Prefix is the prefix of the file names, "recording" when empty
+	Prefix string
+	// FileFormat is the format of the files, FileFormatRaw when empty
+	FileFormat FileFormat
+	// MaxDuration is the duration of the samples of a file after which new files are started, or 0 for no rotation
+	// by duration
+	MaxDuration time.Duration
+	// MaxFileBytes is the size of a file after which new files are started, or 0 for no rotation by size
+	MaxFileBytes int64
+	// MaxTotalBytes is the maximum size of all the recordings of the directory having the prefix, or 0 for no limit.
+	// The oldest recordings are deleted to respect it.
+	MaxTotalBytes int64
+	// MinFreeBytes is the free disk space to keep, or 0 for no limit. The oldest recordings are deleted to respect
+	// it. It is only enforced on platforms where the free space can be queried.
+	MinFreeBytes uint64
+	// TimeoutUs is the timeout in microseconds of the reads of the stream, 100 ms when 0
+	TimeoutUs uint
+	// Global is the global metadata of the SigMF recordings
+	Global sigmf.Global
+}
+
+// Recorder writes the channels of a RX stream to files, one file per channel.
+//
+// The samples of a file are contiguous: when samples are lost, detected from the timestamps of the blocks or
+// reported by an overflow, the current files are closed and the next samples start new files. The losses are counted
+// in the statistics of the stream, if any.
+//
+// The file names are deterministic: "<prefix>_<UTC start time>_ch<channel>_<frequency>Hz_<rate>sps" followed by
+// the extension of the format, for example "recording_20220101T120000.000Z_ch0_100000000Hz_2400000sps.cs16". As
+// the start time follows the prefix, sorting the names sorts the recordings by time.
+type Recorder struct {
+	mutex sync.Mutex
+
+	dev      *device.SDRDevice
+	settings deviceSettings
+	stream   device.SDRStream
+	channels []uint
+	options  Options
+
+	format         string
+	sampleRate     float64
+	bytesPerSample int64
+	maxFileSamples int64
+
+	clock       *sdrtime.Clock
+	tracker     *sdrtime.Tracker
+	lost        bool
+	sinks       []sink
+	fileSamples int64
+	files       []recordedFile
+	lastCheck   time.Time
+	closed      bool
+}
+
+// NewRecorder creates a recorder for a RX stream. The files of the previous recordings of the directory having the
+// same prefix are taken into account in the quotas.
+//
+// Params:
+//  - dev: the device of the stream
+//  - stream: the RX stream
+//  - channels: the channels of the stream
+//  - options: the options of the recorder
+//
+// Return the recorder or an error
+func NewRecorder(dev *device.SDRDevice, stream device.SDRStream, channels []uint, options Options) (recorder *Recorder, err error) {
+
+	if len(channels) == 0 {
+		return nil, errors.New("the channels of the stream must be given explicitly")
+	}
+
+	return newRecorder(dev, dev, stream, channels, options, dev.GetSampleRate(device.DirectionRX, channels[0]))
+}
+
+// newRecorder creates a recorder for a RX stream whose channel settings are given separately from the device
+//
+// Params:
+//  - dev: the device of the stream, used by the SigMF recordings
+//  - settings: the settings of the channels
+//  - stream: the RX stream
+//  - channels: the channels of the stream
+//  - options: the options of the recorder
+//  - sampleRate: the sample rate of the stream
+//
+// Return the recorder or an error
+func newRecorder(dev *device.SDRDevice, settings deviceSettings, stream device.SDRStream, channels []uint, options Options, sampleRate float64) (recorder *Recorder, err error) {
+
+	if options.Directory == "" {
+		options.Directory = "."
+	}
+	if options.Prefix == "" {
+		options.Prefix = defaultPrefix
+	}
+	if options.FileFormat == "" {
+		options.FileFormat = FileFormatRaw
+	}
+	if options.TimeoutUs == 0 {
+		options.TimeoutUs = defaultTimeoutUs
+	}
+
+	format := stream.Format()
+
+	fileFormat := format
+	switch options.FileFormat {
+	case FileFormatRaw, FileFormatSigMF, FileFormatNPY, FileFormatNPZ:
+	case FileFormatWAV:
+		fileFormat = wavFormat(format)
+	default:
+		return nil, fmt.Errorf("unknown file format: %v", options.FileFormat)
+	}
+
+	if err := os.MkdirAll(options.Directory, 0755); err != nil {
+		return nil, err
+	}
+
+	tracker, err := sdrtime.NewTracker(sampleRate, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder = &Recorder{
+		dev:            dev,
+		settings:       settings,
+		stream:         stream,
+		channels:       channels,
+		options:        options,
+		format:         format,
+		sampleRate:     sampleRate,
+		bytesPerSample: int64(sdrformat.BytesPerSample(fileFormat)),
+		tracker:        tracker,
+	}
+
+	if options.MaxDuration > 0 {
+		recorder.maxFileSamples = sdrtime.DurationToTicks(options.MaxDuration, recorder.sampleRate)
+	}
+	if options.MaxFileBytes > 0 {
+		samples := options.MaxFileBytes / recorder.bytesPerSample
+		if recorder.maxFileSamples == 0 || samples < recorder.maxFileSamples {
+			recorder.maxFileSamples = samples
+		}
+	}
+	if (options.MaxDuration > 0 || options.MaxFileBytes > 0) && recorder.maxFileSamples <= 0 {
+		return nil, errors.New("the rotation limits are too small to hold a sample")
+	}
+
+	if err := recorder.scanFiles(); err != nil {
+		return nil, err
+	}
+
+	return recorder, nil
+}
+
+// Run reads the stream and records its samples until the context is cancelled, for example on a signal with
+// signal.NotifyContext, or an error occurs. The files are flushed and closed before returning. The stream must be
+// activated.
+//
+// Params:
+//  - ctx: the context stopping the recording
+//
+// Return nil when the context is cancelled, an error otherwise
+func (recorder *Recorder) Run(ctx context.Context) (err error) {
+
+	defer func() {
+		if closeErr := recorder.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	mtu := recorder.stream.GetMTU()
+	if mtu <= 0 {
+		mtu = 1024
+	}
+
+	buffers, err := sdrformat.MakeBuffers(recorder.format, len(recorder.channels), mtu)
+	if err != nil {
+		return err
+	}
+
+	flags := make([]int, len(recorder.channels))
+
+	for ctx.Err() == nil {
+
+		timeNs, nbRead, err := device.ReadBuffers(recorder.stream, buffers, uint(mtu), flags, recorder.options.TimeoutUs)
+		if err != nil {
+			switch err.(type) {
+			case *sdrerror.Timeout:
+				continue
+			case *sdrerror.Overflow:
+				recorder.MarkLoss()
+				continue
+			}
+			return err
+		}
+
+		if nbRead == 0 {
+			continue
+		}
+
+		hasTime := flags[0]&int(device.StreamFlagHasTime) != 0
+		if err := recorder.Write(buffers, int(nbRead), timeNs, hasTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write records samples, starting new files when the rotation limits are reached. It allows to record samples read
+// by the caller instead of calling Run.
+//
+// Params:
+//  - buffers: the buffers of all the channels, of the Go type matching the format of the stream
+//  - nbSamples: the number of samples to write per channel
+//  - timeNs: the hardware time in nanoseconds of the first sample
+//  - hasTime: whether the timestamp is valid, usually the StreamFlagHasTime of the read flags
+//
+// Return an error or nil in case of success
+func (recorder *Recorder) Write(buffers interface{}, nbSamples int, timeNs uint, hasTime bool) error {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.closed {
+		return errors.New("the recorder is closed")
+	}
+
+	if time.Since(recorder.lastCheck) >= quotaCheckInterval {
+		recorder.lastCheck = time.Now()
+		if err := recorder.enforceQuota(0); err != nil {
+			return err
+		}
+	}
+
+	if nbSamples > 0 {
+		discontinuity, found := recorder.tracker.Observe(int64(timeNs), hasTime, int64(nbSamples))
+		if found || recorder.lost {
+			// The samples of a file must be contiguous, so that their position gives their time
+			var lost int64
+			if found && discontinuity.Kind == sdrtime.DiscontinuityGap {
+				lost = discontinuity.Samples
+			}
+			if stats := recorder.stream.GetStats(); stats != nil {
+				stats.ObserveDiscontinuity(uint64(lost))
+			}
+			recorder.lost = false
+
+			if err := recorder.closeFiles(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for offset := 0; offset < nbSamples; {
+
+		blockTimeNs := timeNs
+		if offset > 0 && hasTime {
+			blockTimeNs += uint(sdrtime.TicksToTimeNs64(int64(offset), recorder.sampleRate))
+		}
+
+		if recorder.sinks == nil {
+			if err := recorder.open(blockTimeNs, hasTime); err != nil {
+				return err
+			}
+		}
+
+		count := nbSamples - offset
+		if recorder.maxFileSamples > 0 && recorder.fileSamples+int64(count) > recorder.maxFileSamples {
+			count = int(recorder.maxFileSamples - recorder.fileSamples)
+		}
+
+		block, err := sdrformat.SliceBuffers(buffers, offset, offset+count)
+		if err != nil {
+			return err
+		}
+
+		channels, err := sdrformat.SplitChannels(block)
+		if err != nil {
+			return err
+		}
+
+		if len(channels) != len(recorder.sinks) {
+			return fmt.Errorf("%d channels given for a recording of %d channels", len(channels), len(recorder.sinks))
+		}
+
+		for i, channel := range channels {
+			if err := recorder.sinks[i].write(channel, count, blockTimeNs, hasTime); err != nil {
+				return err
+			}
+		}
+
+		recorder.fileSamples += int64(count)
+		offset += count
+
+		if recorder.maxFileSamples > 0 && recorder.fileSamples >= recorder.maxFileSamples {
+			if err := recorder.closeFiles(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// MarkLoss indicates that samples were lost before the next samples given to Write, for example when a read of the
+// stream reported an overflow. The next samples start new files, even if their timestamp does not reveal the loss.
+func (recorder *Recorder) MarkLoss() {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.lost = true
+}
+
+// SamplesLost returns the number of samples missing in the gaps found in the timestamps of the recorded samples
+func (recorder *Recorder) SamplesLost() int64 {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.tracker.SamplesLost()
+}
+
+// Rotate closes the current files, the next samples being written to new files. It should be called after
+// retuning the device, so that the names of the files match the frequency.
+//
+// Return an error or nil in case of success
+func (recorder *Recorder) Rotate() error {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.closeFiles()
+}
+
+// Files returns the paths of the completed files of the directory having the prefix of the recorder, oldest first,
+// including the files of the previous recordings.
+func (recorder *Recorder) Files() []string {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	paths := make([]string, len(recorder.files))
+	for i, file := range recorder.files {
+		paths[i] = file.path
+	}
+
+	return paths
+}
+
+// Close flushes and closes the current files. The recorder can not be used anymore.
+//
+// Return an error or nil in case of success
+func (recorder *Recorder) Close() error {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.closed {
+		return nil
+	}
+	recorder.closed = true
+
+	return recorder.closeFiles()
+}
+
+// open creates the files of the channels, starting with the samples of a given time
+func (recorder *Recorder) open(timeNs uint, hasTime bool) (err error) {
+
+	if hasTime && recorder.clock == nil {
+		if clock, err := sdrtime.NewClock(recorder.sampleRate, int64(timeNs), time.Now()); err == nil {
+			recorder.clock = &clock
+		}
+	}
+
+	startTime := time.Now()
+	if hasTime && recorder.clock != nil {
+		startTime = recorder.clock.WallTime(int64(timeNs))
+	}
+
+	// Make room for complete files
+	if err := recorder.enforceQuota(recorder.spooledBytes(recorder.maxFileSamples * recorder.bytesPerSample * int64(len(recorder.channels)))); err != nil {
+		return err
+	}
+
+	sinks := make([]sink, 0, len(recorder.channels))
+	defer func() {
+		if err != nil {
+			for _, sink := range sinks {
+				sink.close()
+			}
+		}
+	}()
+
+	for _, channel := range recorder.channels {
+
+		frequency := recorder.settings.GetFrequency(device.DirectionRX, channel)
+
+		segment := segment{
+			basePath:   filepath.Join(recorder.options.Directory, recorder.fileName(channel, frequency, startTime)),
+			format:     recorder.format,
+			channel:    channel,
+			sampleRate: recorder.sampleRate,
+			frequency:  frequency,
+			startTime:  startTime,
+			clock:      recorder.clock,
+		}
+
+		var sink sink
+		switch recorder.options.FileFormat {
+		case FileFormatSigMF:
+			sink, err = newSigMFSink(segment, recorder.dev, recorder.stream, recorder.options.Global)
+		case FileFormatWAV:
+			sink, err = newWAVSink(segment)
+		case FileFormatNPY:
+			sink, err = newNPYSink(segment)
+		case FileFormatNPZ:
+			sink, err = newNPZSink(segment, recorder.settings.GetGain(device.DirectionRX, channel))
+		default:
+			sink, err = newRawSink(segment)
+		}
+		if err != nil {
+			return err
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	recorder.sinks = sinks
+	recorder.fileSamples = 0
+
+	return nil
+}
+
+// closeFiles closes the current files, if any, and adds them to the completed files
+func (recorder *Recorder) closeFiles() (err error) {
+
+	for _, sink := range recorder.sinks {
+		if closeErr := sink.close(); err == nil {
+			err = closeErr
+		}
+		for _, path := range sink.paths() {
+			recorder.addFile(path)
+		}
+	}
+
+	recorder.sinks = nil
+	recorder.fileSamples = 0
+
+	return err
+}
+
+// fileName returns the name of the file of a channel without extension
+func (recorder *Recorder) fileName(channel uint, frequency float64, startTime time.Time) string {
+
+	return fmt.Sprintf("%s_%s_ch%d_%sHz_%ssps",
+		recorder.options.Prefix,
+		startTime.UTC().Format(startTimeLayout),
+		channel,
+		strconv.FormatFloat(frequency, 'f', -1, 64),
+		strconv.FormatFloat(recorder.sampleRate, 'f', -1, 64))
+}
@@ -0,0 +1,358 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrstats"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+)
+
+// testSampleRate is the sample rate of the streams of the tests, at which a sample lasts 10 us
+const testSampleRate = 100e3
+
+// fakeSettings gives the frequency 100 MHz + channel MHz and the gain 20 dB to all the channels
+type fakeSettings struct{}
+
+func (fakeSettings) GetFrequency(direction device.Direction, channel uint) float64 {
+	return 100e6 + float64(channel)*1e6
+}
+
+func (fakeSettings) GetGain(direction device.Direction, channel uint) float64 {
+	return 20
+}
+
+// fakeRxStream is a CS16 RX stream with statistics. The methods not used by the recorders are not implemented.
+type fakeRxStream struct {
+	device.SDRStream
+
+	stats *sdrstats.Stats
+}
+
+func (stream *fakeRxStream) Format() string {
+	return sdrformat.CS16
+}
+
+func (stream *fakeRxStream) GetStats() *sdrstats.Stats {
+	return stream.stats
+}
+
+// newTestRecorder creates a recorder of the channels 0 and 1 of a fake stream, whose clock gives the time
+// 2024-01-01T00:00:00Z to the hardware time 0
+func newTestRecorder(t *testing.T, options Options) (*Recorder, *fakeRxStream) {
+
+	stream := &fakeRxStream{stats: sdrstats.NewStats()}
+
+	recorder, err := newRecorder(nil, fakeSettings{}, stream, []uint{0, 1}, options, testSampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock, err := sdrtime.NewClock(testSampleRate, 0, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.clock = &clock
+
+	return recorder, stream
+}
+
+// writeSamples writes the samples of the two channels from a sample index, in blocks of at most 300 samples
+func writeSamples(t *testing.T, recorder *Recorder, start int, count int) {
+
+	for offset := 0; offset < count; offset += 300 {
+
+		length := count - offset
+		if length > 300 {
+			length = 300
+		}
+
+		buffers, err := sdrformat.MakeBuffers(sdrformat.CS16, 2, length)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		timeNs := sdrtime.TicksToTimeNs(start+offset, testSampleRate)
+		if err := recorder.Write(buffers, length, uint(timeNs), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testSegment is a segment of files expected in a recording, given by the index of its first sample
+type testSegment struct {
+	start   int
+	samples int
+}
+
+// segmentFiles returns the paths and the sizes of the raw files of the two channels of a segment
+func segmentFiles(directory string, segment testSegment) (paths []string, sizes []int64) {
+
+	milliseconds := segment.start / 100
+	for channel := 0; channel < 2; channel++ {
+		name := fmt.Sprintf("recording_20240101T000000.%03dZ_ch%d_%dHz_100000sps.cs16", milliseconds, channel,
+			100000000+channel*1000000)
+		paths = append(paths, filepath.Join(directory, name))
+		sizes = append(sizes, int64(segment.samples)*4)
+	}
+
+	return paths, sizes
+}
+
+// checkFiles checks the files of a recording against the expected segments
+func checkFiles(t *testing.T, recorder *Recorder, directory string, segments []testSegment) {
+
+	var paths []string
+	var sizes []int64
+	for _, segment := range segments {
+		segmentPaths, segmentSizes := segmentFiles(directory, segment)
+		paths = append(paths, segmentPaths...)
+		sizes = append(sizes, segmentSizes...)
+	}
+
+	if files := recorder.Files(); !reflect.DeepEqual(files, paths) {
+		t.Fatalf("files:\n%v\nexpected:\n%v", files, paths)
+	}
+
+	for i, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != sizes[i] {
+			t.Errorf("%v: %d bytes, expected %d", filepath.Base(path), info.Size(), sizes[i])
+		}
+	}
+}
+
+// TestRotation checks the files written with the rotation by duration and by size
+func TestRotation(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		options  Options
+		segments []testSegment
+	}{
+		{"no rotation", Options{}, []testSegment{{0, 2500}}},
+		{"duration", Options{MaxDuration: 10 * time.Millisecond}, []testSegment{{0, 1000}, {1000, 1000}, {2000, 500}}},
+		{"size", Options{MaxFileBytes: 1600}, []testSegment{{0, 400}, {400, 400}, {800, 400}, {1200, 400},
+			{1600, 400}, {2000, 400}, {2400, 100}}},
+		{"size before duration", Options{MaxDuration: 10 * time.Millisecond, MaxFileBytes: 1600},
+			[]testSegment{{0, 400}, {400, 400}, {800, 400}, {1200, 400}, {1600, 400}, {2000, 400}, {2400, 100}}},
+		{"duration before size", Options{MaxDuration: 10 * time.Millisecond, MaxFileBytes: 8000},
+			[]testSegment{{0, 1000}, {1000, 1000}, {2000, 500}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			directory := t.TempDir()
+			test.options.Directory = directory
+
+			recorder, _ := newTestRecorder(t, test.options)
+			writeSamples(t, recorder, 0, 2500)
+			if err := recorder.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			checkFiles(t, recorder, directory, test.segments)
+		})
+	}
+
+	stream := &fakeRxStream{}
+	if _, err := newRecorder(nil, fakeSettings{}, stream, []uint{0}, Options{Directory: t.TempDir(), MaxFileBytes: 3}, testSampleRate); err == nil {
+		t.Error("expected an error for files smaller than a sample")
+	}
+}
+
+// TestRotationOnLoss checks that the samples following a gap or an overflow start new files, and that the losses are
+// counted in the statistics of the stream
+func TestRotationOnLoss(t *testing.T) {
+
+	directory := t.TempDir()
+	recorder, stream := newTestRecorder(t, Options{Directory: directory})
+
+	writeSamples(t, recorder, 0, 300)
+
+	// Gap of 100 samples
+	writeSamples(t, recorder, 400, 300)
+
+	// Overflow without gap in the timestamps
+	recorder.MarkLoss()
+	writeSamples(t, recorder, 700, 300)
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFiles(t, recorder, directory, []testSegment{{0, 300}, {400, 300}, {700, 300}})
+
+	if lost := recorder.SamplesLost(); lost != 100 {
+		t.Errorf("%d samples lost, expected 100", lost)
+	}
+
+	snapshot := stream.stats.Snapshot()
+	if snapshot.Discontinuities != 2 || snapshot.SamplesLost != 100 {
+		t.Errorf("%d discontinuities and %d samples lost in the statistics, expected 2 and 100",
+			snapshot.Discontinuities, snapshot.SamplesLost)
+	}
+}
+
+// previousFiles are the files of previous recordings: two segments of the two channels of SigMF recordings, each
+// file holding 1000 bytes, and files of other recordings which must be ignored
+var previousFiles = []string{
+	"recording_20230101T000000.000Z_ch0_100000000Hz_100000sps.sigmf-data",
+	"recording_20230101T000000.000Z_ch0_100000000Hz_100000sps.sigmf-meta",
+	"recording_20230101T000000.000Z_ch1_101000000Hz_100000sps.sigmf-data",
+	"recording_20230101T000000.000Z_ch1_101000000Hz_100000sps.sigmf-meta",
+	"recording_20230101T000001.000Z_ch0_100000000Hz_100000sps.sigmf-data",
+	"recording_20230101T000001.000Z_ch0_100000000Hz_100000sps.sigmf-meta",
+	"recording_20230101T000001.000Z_ch1_101000000Hz_100000sps.sigmf-data",
+	"recording_20230101T000001.000Z_ch1_101000000Hz_100000sps.sigmf-meta",
+}
+
+// ignoredFiles are files of the directory not following the naming of the recorder
+var ignoredFiles = []string{
+	"recording_latest.cs16",
+	"recording_notatimestamp00000_ch0.cs16",
+	"other_20220101T000000.000Z_ch0_100000000Hz_100000sps.cs16",
+}
+
+// TestQuota checks that the oldest segments are deleted as a whole to respect the quota of the recordings, the
+// samples of the .npz files being counted twice as they are spooled
+func TestQuota(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		format        FileFormat
+		maxTotalBytes int64
+		deleted       int
+		err           error
+	}{
+		// A segment of 1000 samples of 2 channels of 4 bytes holds 8000 bytes, the previous recordings 8000 bytes
+		{"within the quota", FileFormatRaw, 16000, 0, nil},
+		{"oldest segment deleted", FileFormatRaw, 12000, 4, nil},
+		{"spooled samples counted twice", FileFormatNPZ, 20000, 4, nil},
+		{"all the segments deleted", FileFormatRaw, 9000, 8, nil},
+		{"quota exceeded", FileFormatRaw, 4000, 8, ErrQuotaExceeded},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			directory := t.TempDir()
+			for _, name := range append(previousFiles, ignoredFiles...) {
+				if err := os.WriteFile(filepath.Join(directory, name), make([]byte, 1000), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			recorder, _ := newTestRecorder(t, Options{
+				Directory:     directory,
+				FileFormat:    test.format,
+				MaxDuration:   10 * time.Millisecond,
+				MaxTotalBytes: test.maxTotalBytes,
+			})
+
+			if files := recorder.Files(); len(files) != len(previousFiles) {
+				t.Fatalf("%d previous files found, expected %d", len(files), len(previousFiles))
+			}
+
+			buffers, err := sdrformat.MakeBuffers(sdrformat.CS16, 2, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = recorder.Write(buffers, 1000, 0, true)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error %v, expected %v", err, test.err)
+			}
+			if err := recorder.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(directory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+
+			expected := append([]string(nil), previousFiles[test.deleted:]...)
+			expected = append(expected, ignoredFiles...)
+			if test.err == nil {
+				extension := ".cs16"
+				if test.format == FileFormatNPZ {
+					extension = ".npz"
+				}
+				expected = append(expected,
+					"recording_20240101T000000.000Z_ch0_100000000Hz_100000sps"+extension,
+					"recording_20240101T000000.000Z_ch1_101000000Hz_100000sps"+extension)
+			}
+			sort.Strings(expected)
+
+			if !reflect.DeepEqual(names, expected) {
+				t.Errorf("files:\n%v\nexpected:\n%v", names, expected)
+			}
+		})
+	}
+}
+
+// TestMinFreeBytes checks that a free space which can not be reached deletes all the previous recordings and fails
+func TestMinFreeBytes(t *testing.T) {
+
+	directory := t.TempDir()
+	if _, err := diskFree(directory); err != nil {
+		t.Skip(err)
+	}
+
+	for _, name := range previousFiles {
+		if err := os.WriteFile(filepath.Join(directory, name), make([]byte, 1000), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recorder, _ := newTestRecorder(t, Options{Directory: directory, MinFreeBytes: 1 << 62})
+	defer recorder.Close()
+
+	buffers, err := sdrformat.MakeBuffers(sdrformat.CS16, 2, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Write(buffers, 100, 0, true); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("error %v, expected %v", err, ErrQuotaExceeded)
+	}
+
+	if files := recorder.Files(); len(files) != 0 {
+		t.Errorf("%d files left, expected none", len(files))
+	}
+}
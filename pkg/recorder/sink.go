@@ -0,0 +1,306 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/npy"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+	"github.com/bhojpur/sdr/pkg/sigmf"
+	"github.com/bhojpur/sdr/pkg/wav"
+)
+
+// sink is a file receiving the samples of a single channel
+type sink interface {
+	// write appends samples given as single-channel buffers
+	write(buffers interface{}, nbSamples int, timeNs uint, hasTime bool) error
+	// close flushes and closes the file
+	close() error
+	// paths returns the paths of the files written
+	paths() []string
+}
+
+// segment describes the file of a channel to create
+type segment struct {
+	basePath   string
+	format     string
+	channel    uint
+	sampleRate float64
+	frequency  float64
+	startTime  time.Time
+	clock      *sdrtime.Clock
+}
+
+// rawSink writes the samples as they are read from the stream, without header
+type rawSink struct {
+	path    string
+	file    *os.File
+	writer  *bufio.Writer
+	encoded []byte
+}
+
+// newRawSink creates a raw file whose extension is the stream format, for example ".cs16"
+func newRawSink(segment segment) (*rawSink, error) {
+
+	path := segment.basePath + "." + strings.ToLower(segment.format)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rawSink{
+		path:   path,
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}, nil
+}
+
+// write encodes the samples and appends them to the file
+func (sink *rawSink) write(buffers interface{}, nbSamples int, timeNs uint, hasTime bool) (err error) {
+
+	sink.encoded, err = sdrformat.Encode(sink.encoded[:0], buffers, nbSamples)
+	if err != nil {
+		return err
+	}
+
+	_, err = sink.writer.Write(sink.encoded)
+
+	return err
+}
+
+// close flushes and closes the file
+func (sink *rawSink) close() error {
+
+	if err := sink.writer.Flush(); err != nil {
+		sink.file.Close()
+		return err
+	}
+
+	return sink.file.Close()
+}
+
+// paths returns the path of the file
+func (sink *rawSink) paths() []string {
+	return []string{sink.path}
+}
+
+// sigmfSink writes the samples in a SigMF recording
+type sigmfSink struct {
+	basePath string
+	recorder *sigmf.Recorder
+}
+
+// newSigMFSink creates a SigMF recording for a channel of a stream
+func newSigMFSink(segment segment, dev *device.SDRDevice, stream device.SDRStream, global sigmf.Global) (*sigmfSink, error) {
+
+	global.SampleRate = segment.sampleRate
+
+	recorder, err := sigmf.NewStreamRecorder(segment.basePath, dev, stream, []uint{segment.channel}, global)
+	if err != nil {
+		return nil, err
+	}
+
+	if segment.clock != nil {
+		recorder.SetClock(*segment.clock)
+	}
+
+	return &sigmfSink{
+		basePath: segment.basePath,
+		recorder: recorder,
+	}, nil
+}
+
+// write appends the samples to the recording
+func (sink *sigmfSink) write(buffers interface{}, nbSamples int, timeNs uint, hasTime bool) error {
+	return sink.recorder.Write(buffers, nbSamples, timeNs, hasTime)
+}
+
+// close closes the recording, writing its metadata
+func (sink *sigmfSink) close() error {
+	return sink.recorder.Close()
+}
+
+// paths returns the paths of the data and metadata files
+func (sink *sigmfSink) paths() []string {
+	return []string{sink.basePath + sigmf.DataExtension, sink.basePath + sigmf.MetaExtension}
+}
+
+// wavSink writes the samples in a WAV IQ file
+type wavSink struct {
+	path   string
+	writer *wav.Writer
+}
+
+// newWAVSink creates a WAV IQ file. The samples are converted to the closest format supported by WAV files.
+func newWAVSink(segment segment) (*wavSink, error) {
+
+	header := wav.Header{
+		Format:     wavFormat(segment.format),
+		SampleRate: uint32(math.Round(segment.sampleRate)),
+		StartTime:  segment.startTime,
+	}
+
+	// The auxi chunk holds the frequency on 32 bits
+	if segment.frequency > 0 && segment.frequency <= math.MaxUint32 {
+		header.CenterFrequency = uint32(math.Round(segment.frequency))
+	}
+
+	path := segment.basePath + ".wav"
+
+	writer, err := wav.Create(path, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wavSink{
+		path:   path,
+		writer: writer,
+	}, nil
+}
+
+// write appends the samples to the file, converted to the format of the file
+func (sink *wavSink) write(buffers interface{}, nbSamples int, timeNs uint, hasTime bool) error {
+	return sink.writer.Write(buffers, nbSamples)
+}
+
+// close completes the header and closes the file
+func (sink *wavSink) close() error {
+	return sink.writer.Close()
+}
+
+// paths returns the path of the file
+func (sink *wavSink) paths() []string {
+	return []string{sink.path}
+}
+
+// npySink writes the samples in a NumPy array
+type npySink struct {
+	path   string
+	writer *npy.Writer
+}
+
+// newNPYSink creates a .npy file
+func newNPYSink(segment segment) (*npySink, error) {
+
+	path := segment.basePath + ".npy"
+
+	writer, err := npy.Create(path, segment.format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &npySink{
+		path:   path,
+		writer: writer,
+	}, nil
+}
+
+// write appends the samples to the array
+func (sink *npySink) write(buffers interface{}, nbSamples int, timeNs uint, hasTime bool) error {
+	return sink.writer.Write(buffers, nbSamples)
+}
+
+// close writes the shape of the array and closes the file
+func (sink *npySink) close() error {
+	return sink.writer.Close()
+}
+
+// paths returns the path of the file
+func (sink *npySink) paths() []string {
+	return []string{sink.path}
+}
+
+// npzSink writes the samples in a NumPy archive, with the timestamp of the first sample in the metadata
+type npzSink struct {
+	path     string
+	writer   *npy.ArchiveWriter
+	metadata npy.Metadata
+	started  bool
+}
+
+// newNPZSink creates a .npz file
+func newNPZSink(segment segment, gain float64) (*npzSink, error) {
+
+	path := segment.basePath + ".npz"
+
+	metadata := npy.Metadata{
+		Frequency:  segment.frequency,
+		SampleRate: segment.sampleRate,
+		Gain:       gain,
+	}
+	metadata.SetTime(segment.startTime)
+
+	writer, err := npy.CreateArchive(path, segment.format, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &npzSink{
+		path:     path,
+		writer:   writer,
+		metadata: metadata,
+	}, nil
+}
+
+// write appends the samples to the archive
+func (sink *npzSink) write(buffers interface{}, nbSamples int, timeNs uint, hasTime bool) error {
+
+	if !sink.started {
+		sink.started = true
+		if hasTime {
+			sink.metadata.TimeNs = uint64(timeNs)
+			sink.writer.SetMetadata(sink.metadata)
+		}
+	}
+
+	return sink.writer.Write(buffers, nbSamples)
+}
+
+// close writes the archive
+func (sink *npzSink) close() error {
+	return sink.writer.Close()
+}
+
+// paths returns the path of the file
+func (sink *npzSink) paths() []string {
+	return []string{sink.path}
+}
+
+// wavFormat returns the format of the WAV files recording a stream format
+func wavFormat(format string) string {
+
+	switch format {
+	case sdrformat.CU8, sdrformat.CS8:
+		return sdrformat.CU8
+	case sdrformat.CF32, sdrformat.CF64:
+		return sdrformat.CF32
+	}
+
+	return sdrformat.CS16
+}
@@ -0,0 +1,268 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// Block is a block of samples read from a RX stream
+type Block struct {
+	// Buffers are the buffers of all the channels, of the Go type matching the format of the stream
+	Buffers interface{}
+	// NbSamples is the number of samples of the block per channel
+	NbSamples int
+	// TimeNs is the hardware time in nanoseconds of the first sample
+	TimeNs uint
+	// HasTime indicates whether the timestamp is valid
+	HasTime bool
+	// Flags are the flags returned by the read of the block for the first channel
+	Flags int
+}
+
+// Trigger decides when a triggered recorder starts a capture
+type Trigger interface {
+	// Check examines a block of samples. Every block of the stream is checked in order.
+	//
+	// Params:
+	//  - block: the block of samples
+	//
+	// Return the position of the trigger in the block, from 0 before the first sample to NbSamples after the last
+	// one, and true if the trigger fires in the block
+	Check(block Block) (index int, fired bool)
+}
+
+// PowerTrigger fires when the power of a channel in a frequency band rises above a threshold.
+//
+// The band is shifted to 0 Hz by a NCO and isolated by a moving average whose length is the sample rate divided by
+// the bandwidth. The power is then averaged over the same length. It is a coarse but cheap filter, the bandwidth is
+// the approximate width of the main lobe of the average.
+type PowerTrigger struct {
+	// FullScale is the value of a full scale sample in the unit of the buffers, as returned by
+	// GetNativeStreamFormat, or 0 for the full range of the type of the buffers (1 for the float formats). The
+	// threshold and the level are relative to it.
+	FullScale float64
+
+	channel   int
+	threshold float64
+	step      complex128
+	phase     complex128
+	window    int
+
+	band     []complex128
+	bandSum  complex128
+	power    []float64
+	powerSum float64
+	position int
+	filled   int
+	armed    bool
+	level    float64
+	samples  []complex128
+}
+
+// NewPowerTrigger creates a power trigger.
+//
+// Params:
+//  - sampleRate: the sample rate of the stream in samples per second
+//  - offset: the frequency of the center of the band relatively to the center frequency of the stream, in Hz
+//  - bandwidth: the width of the band in Hz, or 0 for the whole stream
+//  - thresholdDBFS: the power above which the trigger fires, in dB relatively to the full scale of the stream
+//  - channel: the index of the channel in the buffers of the stream
+//
+// Return the trigger or an error
+func NewPowerTrigger(sampleRate float64, offset float64, bandwidth float64, thresholdDBFS float64, channel int) (trigger *PowerTrigger, err error) {
+
+	if !(sampleRate > 0) {
+		return nil, errors.New("the sample rate of a power trigger must be positive")
+	}
+
+	if bandwidth < 0 {
+		return nil, errors.New("the bandwidth of a power trigger can not be negative")
+	}
+
+	window := 1
+	if bandwidth > 0 && bandwidth < sampleRate {
+		window = int(math.Round(sampleRate / bandwidth))
+	}
+
+	return &PowerTrigger{
+		channel:   channel,
+		threshold: math.Pow(10, thresholdDBFS/10),
+		step:      cmplx.Rect(1, -2*math.Pi*offset/sampleRate),
+		phase:     1,
+		window:    window,
+		band:      make([]complex128, window),
+		power:     make([]float64, window),
+		armed:     true,
+	}, nil
+}
+
+// Check examines a block of samples. The trigger fires when the power crosses the threshold upwards, it is armed
+// again when the power falls below the threshold.
+func (trigger *PowerTrigger) Check(block Block) (index int, fired bool) {
+
+	buffer, err := sdrformat.ChannelBuffer(block.Buffers, trigger.channel)
+	if err != nil {
+		return 0, false
+	}
+
+	if len(trigger.samples) < block.NbSamples {
+		trigger.samples = make([]complex128, block.NbSamples)
+	}
+
+	nbSamples, err := sdrformat.ToComplex128(trigger.samples[:block.NbSamples], buffer)
+	if err != nil {
+		return 0, false
+	}
+
+	samples := trigger.samples[:nbSamples]
+	if trigger.FullScale > 0 {
+		format, _ := sdrformat.FormatOf(buffer)
+		factor := complex(sdrformat.NormalizationScale(format)/trigger.FullScale, 0)
+		for i := range samples {
+			samples[i] *= factor
+		}
+	}
+
+	scale := 1 / float64(trigger.window)
+
+	for i, sample := range samples {
+
+		shifted := sample * trigger.phase
+		trigger.phase *= trigger.step
+
+		trigger.bandSum += shifted - trigger.band[trigger.position]
+		trigger.band[trigger.position] = shifted
+
+		filtered := trigger.bandSum * complex(scale, 0)
+		power := real(filtered)*real(filtered) + imag(filtered)*imag(filtered)
+
+		trigger.powerSum += power - trigger.power[trigger.position]
+		trigger.power[trigger.position] = power
+
+		trigger.position++
+		if trigger.position == trigger.window {
+			trigger.position = 0
+			trigger.resum()
+		}
+
+		// Wait for both averages to be complete
+		if trigger.filled < 2*trigger.window {
+			trigger.filled++
+			continue
+		}
+
+		trigger.level = trigger.powerSum * scale
+
+		if trigger.level >= trigger.threshold {
+			if trigger.armed && !fired {
+				index = i
+				fired = true
+			}
+			trigger.armed = false
+		} else {
+			trigger.armed = true
+		}
+	}
+
+	// Avoid the drift of the amplitude of the NCO
+	trigger.phase /= complex(cmplx.Abs(trigger.phase), 0)
+
+	return index, fired
+}
+
+// Level returns the last power measured by the trigger in dBFS
+func (trigger *PowerTrigger) Level() float64 {
+	return 10 * math.Log10(trigger.level)
+}
+
+// resum computes the sums of the averages again, to avoid the accumulation of rounding errors
+func (trigger *PowerTrigger) resum() {
+
+	trigger.bandSum = 0
+	trigger.powerSum = 0
+
+	for i := range trigger.band {
+		trigger.bandSum += trigger.band[i]
+		trigger.powerSum += trigger.power[i]
+	}
+}
+
+// BurstTrigger fires at the end of the bursts of a stream, on the rising edge of the StreamFlagEndBurst read flag.
+// The trigger is placed after the last sample of the burst, so that the burst is in the pre-trigger samples.
+type BurstTrigger struct {
+	last bool
+}
+
+// Check examines a block of samples
+func (trigger *BurstTrigger) Check(block Block) (index int, fired bool) {
+
+	endBurst := block.Flags&int(device.StreamFlagEndBurst) != 0
+	fired = endBurst && !trigger.last
+	trigger.last = endBurst
+
+	return block.NbSamples, fired
+}
+
+// GPIOTrigger fires when an external signal on GPIO pins reaches a level. The GPIO bank is read once per block, so
+// the position of the trigger is the precision of a block.
+type GPIOTrigger struct {
+	dev   *device.SDRDevice
+	bank  string
+	mask  uint32
+	level bool
+	last  bool
+}
+
+// NewGPIOTrigger creates a GPIO trigger.
+//
+// Params:
+//  - dev: the device whose GPIO pins are read
+//  - bank: the name of the GPIO bank, see ListGPIOBanks
+//  - mask: the pins of the bank watched
+//  - level: the level firing the trigger: true when any of the pins is high, false when all the pins are low
+//
+// Return the trigger
+func NewGPIOTrigger(dev *device.SDRDevice, bank string, mask uint32, level bool) *GPIOTrigger {
+
+	return &GPIOTrigger{
+		dev:   dev,
+		bank:  bank,
+		mask:  mask,
+		level: level,
+	}
+}
+
+// Check examines a block of samples. The trigger fires when the level is reached, and again only after the pins
+// left the level.
+func (trigger *GPIOTrigger) Check(block Block) (index int, fired bool) {
+
+	active := (trigger.dev.ReadGPIO(trigger.bank)&trigger.mask != 0) == trigger.level
+	fired = active && !trigger.last
+	trigger.last = active
+
+	return 0, fired
+}
@@ -0,0 +1,195 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/device"
+)
+
+// level is a number of samples of a given amplitude
+type level struct {
+	amplitude float64
+	count     int
+}
+
+// levels returns a CF32 block of a channel whose samples are constant by parts
+func levels(parts ...level) Block {
+
+	var samples []complex64
+	for _, part := range parts {
+		for i := 0; i < part.count; i++ {
+			samples = append(samples, complex(float32(part.amplitude), 0))
+		}
+	}
+
+	return Block{Buffers: [][]complex64{samples}, NbSamples: len(samples)}
+}
+
+// toneBlock returns a CF32 block of a channel holding a complex tone of amplitude 1
+func toneBlock(frequency float64, sampleRate float64, count int) Block {
+
+	samples := make([]complex64, count)
+	for i := range samples {
+		samples[i] = complex64(cmplx.Rect(1, 2*math.Pi*frequency*float64(i)/sampleRate))
+	}
+
+	return Block{Buffers: [][]complex64{samples}, NbSamples: count}
+}
+
+// expectedFire is the result of a trigger expected for a block
+type expectedFire struct {
+	fired bool
+	index int
+}
+
+// TestPowerTrigger checks that the power trigger fires when the power crosses the threshold upwards, and is armed
+// again when the power falls below it
+func TestPowerTrigger(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		blocks []Block
+		fires  []expectedFire
+	}{
+		{"rising edge in a block", []Block{levels(level{0.1, 100}, level{1, 28}), levels(level{1, 128})},
+			[]expectedFire{{true, 100}, {false, 0}}},
+		{"armed again below the threshold", []Block{
+			levels(level{0.1, 50}, level{1, 20}, level{0.1, 20}, level{1, 20}),
+			levels(level{1, 10}),
+			levels(level{0.1, 10}, level{1, 10})},
+			[]expectedFire{{true, 50}, {false, 0}, {true, 10}}},
+		{"below the threshold", []Block{levels(level{0.1, 10}, level{0.31, 10})}, []expectedFire{{false, 0}}},
+		{"above the threshold", []Block{levels(level{0.1, 10}, level{0.32, 10})}, []expectedFire{{true, 10}}},
+		{"averages filling", []Block{levels(level{1, 10})}, []expectedFire{{true, 2}}},
+	}
+
+	for _, test := range tests {
+
+		// -10 dBFS is an amplitude of 0.316
+		trigger, err := NewPowerTrigger(1e6, 0, 0, -10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, block := range test.blocks {
+			index, fired := trigger.Check(block)
+			if fired != test.fires[i].fired || (fired && index != test.fires[i].index) {
+				t.Errorf("%v: block %d: fired %v at %d, expected %v at %d", test.name, i, fired, index,
+					test.fires[i].fired, test.fires[i].index)
+			}
+		}
+	}
+}
+
+// TestPowerTriggerBand checks that the power trigger measures the power of its band only, a tone at the opposite
+// offset being rejected
+func TestPowerTriggerBand(t *testing.T) {
+
+	tests := []struct {
+		frequency float64
+		fired     bool
+	}{
+		{100e3, true},
+		{-100e3, false},
+		{0, false},
+	}
+
+	for _, test := range tests {
+
+		trigger, err := NewPowerTrigger(1e6, 100e3, 10e3, -10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		index, fired := trigger.Check(toneBlock(test.frequency, 1e6, 1000))
+		if fired != test.fired {
+			t.Errorf("tone at %v Hz: fired %v at %d (%.1f dBFS), expected %v", test.frequency, fired, index,
+				trigger.Level(), test.fired)
+		}
+
+		// The trigger waits for the moving average of the band and the average of its power
+		if fired && index != 200 {
+			t.Errorf("tone at %v Hz: fired at %d, expected 200", test.frequency, index)
+		}
+		if test.fired && math.Abs(trigger.Level()) > 0.01 {
+			t.Errorf("tone at %v Hz: level %.3f dBFS, expected 0", test.frequency, trigger.Level())
+		}
+	}
+}
+
+// TestPowerTriggerFullScale checks the level of the integer samples relatively to the full scale of the device
+func TestPowerTriggerFullScale(t *testing.T) {
+
+	buffer := make([]int16, 200)
+	for i := 0; i < len(buffer); i += 2 {
+		buffer[i] = 2047
+	}
+	block := Block{Buffers: [][]int16{buffer}, NbSamples: 100}
+
+	tests := []struct {
+		fullScale float64
+		level     float64
+	}{
+		{0, 20 * math.Log10(2047.0/32768)},
+		{2047, 0},
+	}
+
+	for _, test := range tests {
+
+		trigger, err := NewPowerTrigger(1e6, 0, 0, -10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trigger.FullScale = test.fullScale
+
+		trigger.Check(block)
+		if math.Abs(trigger.Level()-test.level) > 0.01 {
+			t.Errorf("full scale %v: level %.3f dBFS, expected %.3f", test.fullScale, trigger.Level(), test.level)
+		}
+	}
+}
+
+// TestBurstTrigger checks that the burst trigger fires after the last sample of the blocks ending a burst
+func TestBurstTrigger(t *testing.T) {
+
+	endBurst := int(device.StreamFlagEndBurst)
+	flags := []int{0, endBurst, endBurst, 0, endBurst | int(device.StreamFlagHasTime)}
+	fires := []bool{false, true, false, false, true}
+
+	trigger := &BurstTrigger{}
+	for i, flag := range flags {
+
+		block := levels(level{1, 10 + i})
+		block.Flags = flag
+
+		index, fired := trigger.Check(block)
+		if fired != fires[i] {
+			t.Errorf("block %d: fired %v, expected %v", i, fired, fires[i])
+		}
+		if fired && index != block.NbSamples {
+			t.Errorf("block %d: fired at %d, expected %d", i, index, block.NbSamples)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/npy"
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+	"github.com/bhojpur/sdr/pkg/sigmf"
+)
+
+// defaultCaptureQueueSize is the number of captures waiting to be received when no queue size is given
+const defaultCaptureQueueSize = 4
+
+// TriggerOptions are the options of a triggered recorder
+type TriggerOptions struct {
+	// PreTrigger is the duration of the samples kept before the trigger
+	PreTrigger time.Duration
+	// PostTrigger is the duration of the samples recorded after the trigger
+	PostTrigger time.Duration
+	// TimeoutUs is the timeout in microseconds of the reads of the stream, 100 ms when 0
+	TimeoutUs uint
+	// QueueSize is the number of captures waiting to be received before the recording blocks, 4 when 0
+	QueueSize int
+}
+
+// TriggeredCapture holds the samples recorded around a trigger
+type TriggeredCapture struct {
+	// Buffers are the buffers of all the channels, of the Go type matching the format of the stream
+	Buffers interface{}
+	// NbSamples is the number of samples per channel
+	NbSamples int
+	// TriggerIndex is the index of the first sample following the trigger, which is also the number of pre-trigger
+	// samples
+	TriggerIndex int
+	// TimeNs is the hardware time in nanoseconds of the first sample
+	TimeNs uint
+	// TriggerTimeNs is the hardware time in nanoseconds of the trigger
+	TriggerTimeNs uint
+	// HasTime indicates whether the timestamps are valid
+	HasTime bool
+	// WallTime is the wall-clock time of the first sample, estimated from the time of the first block received
+	WallTime time.Time
+	// SampleRate is the sample rate in samples per second
+	SampleRate float64
+	// Frequency is the center frequency of the first channel in Hz when the trigger fired
+	Frequency float64
+	// Gain is the overall gain of the first channel in dB when the trigger fired
+	Gain float64
+	// Truncated indicates that the capture was stopped before the end of the post-trigger samples, because samples
+	// were lost
+	Truncated bool
+}
+
+// TriggeredRecorder keeps the last samples of a RX stream and records the samples around the triggers.
+//
+// The history is cleared when samples are lost, detected from the timestamps of the blocks or reported by an overflow,
+// so that the samples of a capture are contiguous and their timestamps exact. The losses are counted in the
+// statistics of the stream, if any. The triggers firing while a capture is recorded are ignored.
+type TriggeredRecorder struct {
+	mutex sync.Mutex
+
+	settings deviceSettings
+	stream   device.SDRStream
+	channels []uint
+	trigger  Trigger
+	options  TriggerOptions
+
+	format      string
+	sampleRate  float64
+	postSamples int
+	history     *history
+	tracker     *sdrtime.Tracker
+	clock       *sdrtime.Clock
+	lost        bool
+
+	capture   *TriggeredCapture
+	total     int
+	collected int
+	manual    int32
+	captures  chan TriggeredCapture
+	done      chan struct{}
+	closing   sync.Once
+	closed    bool
+}
+
+// NewTriggeredRecorder creates a triggered recorder for a RX stream.
+//
+// Params:
+//  - dev: the device of the stream
+//  - stream: the RX stream
+//  - channels: the channels of the stream
+//  - trigger: the trigger starting the captures, or nil to start them only with Fire
+//  - options: the options of the recorder
+//
+// Return the recorder or an error
+func NewTriggeredRecorder(dev *device.SDRDevice, stream device.SDRStream, channels []uint, trigger Trigger, options TriggerOptions) (recorder *TriggeredRecorder, err error) {
+
+	if len(channels) == 0 {
+		return nil, errors.New("the channels of the stream must be given explicitly")
+	}
+
+	return newTriggeredRecorder(dev, stream, channels, trigger, options, dev.GetSampleRate(device.DirectionRX, channels[0]))
+}
+
+// newTriggeredRecorder creates a triggered recorder for a RX stream whose channel settings are given separately
+//
+// Params:
+//  - settings: the settings of the channels, usually the device of the stream
+//  - stream: the RX stream
+//  - channels: the channels of the stream
+//  - trigger: the trigger starting the captures, or nil to start them only with Fire
+//  - options: the options of the recorder
+//  - sampleRate: the sample rate of the stream
+//
+// Return the recorder or an error
+func newTriggeredRecorder(settings deviceSettings, stream device.SDRStream, channels []uint, trigger Trigger, options TriggerOptions, sampleRate float64) (recorder *TriggeredRecorder, err error) {
+
+	if options.PreTrigger < 0 || options.PostTrigger < 0 {
+		return nil, errors.New("the pre-trigger and post-trigger durations can not be negative")
+	}
+
+	if options.TimeoutUs == 0 {
+		options.TimeoutUs = defaultTimeoutUs
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = defaultCaptureQueueSize
+	}
+
+	tracker, err := sdrtime.NewTracker(sampleRate, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := newHistory(stream.Format(), len(channels), int(sdrtime.DurationToTicks(options.PreTrigger, sampleRate)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &TriggeredRecorder{
+		settings:    settings,
+		stream:      stream,
+		channels:    channels,
+		trigger:     trigger,
+		options:     options,
+		format:      stream.Format(),
+		sampleRate:  sampleRate,
+		postSamples: int(sdrtime.DurationToTicks(options.PostTrigger, sampleRate)),
+		history:     history,
+		tracker:     tracker,
+		captures:    make(chan TriggeredCapture, options.QueueSize),
+		done:        make(chan struct{}),
+	}, nil
+}
+
+// Captures returns the channel receiving the captures. It is closed when the recorder is closed. The recording blocks
+// when the captures are not received, until the recorder is closed.
+func (recorder *TriggeredRecorder) Captures() <-chan TriggeredCapture {
+	return recorder.captures
+}
+
+// Fire starts a capture at the beginning of the next block processed, whatever the trigger
+func (recorder *TriggeredRecorder) Fire() {
+	atomic.StoreInt32(&recorder.manual, 1)
+}
+
+// Run reads the stream and processes its samples until the context is cancelled or an error occurs. The recorder is
+// closed before returning. The stream must be activated.
+//
+// Params:
+//  - ctx: the context stopping the recording
+//
+// Return nil when the context is cancelled, an error otherwise
+func (recorder *TriggeredRecorder) Run(ctx context.Context) (err error) {
+
+	defer recorder.Close()
+
+	mtu := recorder.stream.GetMTU()
+	if mtu <= 0 {
+		mtu = 1024
+	}
+
+	buffers, err := sdrformat.MakeBuffers(recorder.format, len(recorder.channels), mtu)
+	if err != nil {
+		return err
+	}
+
+	flags := make([]int, len(recorder.channels))
+
+	for ctx.Err() == nil {
+
+		timeNs, nbRead, err := device.ReadBuffers(recorder.stream, buffers, uint(mtu), flags, recorder.options.TimeoutUs)
+		if err != nil {
+			switch err.(type) {
+			case *sdrerror.Timeout:
+				continue
+			case *sdrerror.Overflow:
+				recorder.MarkLoss()
+				continue
+			}
+			return err
+		}
+
+		block := Block{
+			Buffers:   buffers,
+			NbSamples: int(nbRead),
+			TimeNs:    timeNs,
+			HasTime:   flags[0]&int(device.StreamFlagHasTime) != 0,
+			Flags:     flags[0],
+		}
+
+		if err := recorder.Process(block); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Process checks a block of samples against the trigger and keeps or records its samples. It allows to process the
+// samples read by the caller instead of calling Run.
+//
+// Params:
+//  - block: the block of samples read from the stream
+//
+// Return an error or nil in case of success
+func (recorder *TriggeredRecorder) Process(block Block) error {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.closed {
+		return errors.New("the triggered recorder is closed")
+	}
+
+	if block.NbSamples == 0 {
+		return nil
+	}
+
+	if block.HasTime && recorder.clock == nil {
+		if clock, err := sdrtime.NewClock(recorder.sampleRate, int64(block.TimeNs), time.Now()); err == nil {
+			recorder.clock = &clock
+		}
+	}
+
+	discontinuity, found := recorder.tracker.Observe(int64(block.TimeNs), block.HasTime, int64(block.NbSamples))
+	if found || recorder.lost {
+		var lost int64
+		if found && discontinuity.Kind == sdrtime.DiscontinuityGap {
+			lost = discontinuity.Samples
+		}
+		if stats := recorder.stream.GetStats(); stats != nil {
+			stats.ObserveDiscontinuity(uint64(lost))
+		}
+		recorder.lost = false
+
+		recorder.history.reset()
+		if recorder.capture != nil {
+			recorder.capture.Truncated = true
+			recorder.complete()
+		}
+	}
+
+	index, fired := 0, false
+	if recorder.trigger != nil {
+		index, fired = recorder.trigger.Check(block)
+	}
+	if atomic.SwapInt32(&recorder.manual, 0) != 0 {
+		index, fired = 0, true
+	}
+
+	offset := 0
+	if recorder.capture != nil {
+		var err error
+		if offset, err = recorder.collect(block, 0); err != nil {
+			return err
+		}
+		if recorder.capture != nil {
+			return nil
+		}
+	}
+
+	if fired && index >= offset {
+
+		if err := recorder.history.push(block.Buffers, offset, index); err != nil {
+			return err
+		}
+
+		if err := recorder.start(block, index); err != nil {
+			return err
+		}
+
+		var err error
+		if offset, err = recorder.collect(block, index); err != nil {
+			return err
+		}
+		if recorder.capture != nil {
+			return nil
+		}
+	}
+
+	return recorder.history.push(block.Buffers, offset, block.NbSamples)
+}
+
+// MarkLoss indicates that samples were lost before the next block given to Process, for example when a read of the
+// stream reported an overflow. The history is cleared and a capture being recorded is truncated, even if the
+// timestamp of the next block does not reveal the loss.
+func (recorder *TriggeredRecorder) MarkLoss() {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.lost = true
+}
+
+// Close stops the recorder and closes the channel of the captures. A capture being recorded is discarded.
+func (recorder *TriggeredRecorder) Close() {
+
+	// Unblock a capture being sent before taking the mutex held by the sender
+	recorder.closing.Do(func() { close(recorder.done) })
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.closed {
+		return
+	}
+
+	recorder.closed = true
+	recorder.capture = nil
+	close(recorder.captures)
+}
+
+// start starts a capture with the history, the trigger being at an index of a block
+func (recorder *TriggeredRecorder) start(block Block, index int) error {
+
+	total := recorder.history.count + recorder.postSamples
+
+	buffers, err := sdrformat.MakeBuffers(recorder.format, len(recorder.channels), total)
+	if err != nil {
+		return err
+	}
+
+	nbPreSamples, err := recorder.history.copyTo(buffers)
+	if err != nil {
+		return err
+	}
+	recorder.history.reset()
+
+	triggerTimeNs := int64(block.TimeNs) + sdrtime.TicksToTimeNs64(int64(index), recorder.sampleRate)
+	timeNs := triggerTimeNs - sdrtime.TicksToTimeNs64(int64(nbPreSamples), recorder.sampleRate)
+	if timeNs < 0 {
+		timeNs = 0
+	}
+
+	capture := &TriggeredCapture{
+		Buffers:       buffers,
+		TriggerIndex:  nbPreSamples,
+		TimeNs:        uint(timeNs),
+		TriggerTimeNs: uint(triggerTimeNs),
+		HasTime:       block.HasTime,
+		SampleRate:    recorder.sampleRate,
+		Frequency:     recorder.settings.GetFrequency(device.DirectionRX, recorder.channels[0]),
+		Gain:          recorder.settings.GetGain(device.DirectionRX, recorder.channels[0]),
+	}
+
+	if block.HasTime && recorder.clock != nil {
+		capture.WallTime = recorder.clock.WallTime(timeNs)
+	} else {
+		capture.WallTime = time.Now().Add(-sdrtime.TicksToDuration(int64(nbPreSamples), recorder.sampleRate))
+	}
+
+	recorder.capture = capture
+	recorder.total = total
+	recorder.collected = nbPreSamples
+
+	return nil
+}
+
+// collect appends the samples of a block from an index to the capture, and completes the capture when it is full.
+// It returns the index of the first sample of the block not used.
+func (recorder *TriggeredRecorder) collect(block Block, from int) (int, error) {
+
+	count := recorder.total - recorder.collected
+	if count > block.NbSamples-from {
+		count = block.NbSamples - from
+	}
+
+	if err := copySamples(recorder.capture.Buffers, recorder.collected, block.Buffers, from, count); err != nil {
+		return from, err
+	}
+
+	recorder.collected += count
+	if recorder.collected == recorder.total {
+		recorder.complete()
+	}
+
+	return from + count, nil
+}
+
+// complete sends the capture being recorded to the channel of the captures. The capture is discarded when the recorder
+// is closed while waiting for the receiver.
+func (recorder *TriggeredRecorder) complete() {
+
+	capture := *recorder.capture
+	capture.NbSamples = recorder.collected
+	if buffers, err := sdrformat.SliceBuffers(capture.Buffers, 0, recorder.collected); err == nil {
+		capture.Buffers = buffers
+	}
+
+	recorder.capture = nil
+	select {
+	case recorder.captures <- capture:
+	case <-recorder.done:
+	}
+}
+
+// SaveSigMF writes the capture to a SigMF recording, with an annotation starting at the trigger.
+//
+// Params:
+//  - basePath: the path of the recording, without extension
+//  - global: the global metadata of the recording. The sample rate is set when 0.
+//
+// Return an error or nil in case of success
+func (capture TriggeredCapture) SaveSigMF(basePath string, global sigmf.Global) error {
+
+	format, err := sdrformat.FormatOf(capture.Buffers)
+	if err != nil {
+		return err
+	}
+
+	nbChannels, _, err := sdrformat.BuffersLength(capture.Buffers)
+	if err != nil {
+		return err
+	}
+
+	if global.SampleRate == 0 {
+		global.SampleRate = capture.SampleRate
+	}
+
+	recorder, err := sigmf.NewRecorder(basePath, format, nbChannels, global, capture.Frequency)
+	if err != nil {
+		return err
+	}
+
+	if clock, err := sdrtime.NewClock(capture.SampleRate, int64(capture.TimeNs), capture.WallTime); err == nil {
+		recorder.SetClock(clock)
+	}
+
+	if err := recorder.Write(capture.Buffers, capture.NbSamples, capture.TimeNs, capture.HasTime); err != nil {
+		recorder.Close()
+		return err
+	}
+
+	recorder.Annotate(sigmf.Annotation{
+		SampleStart: uint64(capture.TriggerIndex),
+		SampleCount: uint64(capture.NbSamples - capture.TriggerIndex),
+		Label:       "trigger",
+	})
+
+	return recorder.Close()
+}
+
+// SaveNPZ writes the capture to a NumPy archive, whose metadata holds the frequency, the sample rate, the gain and
+// the timestamp of the first sample.
+//
+// Params:
+//  - path: the path of the archive
+//
+// Return an error or nil in case of success
+func (capture TriggeredCapture) SaveNPZ(path string) error {
+
+	metadata := npy.Metadata{
+		Frequency:  capture.Frequency,
+		SampleRate: capture.SampleRate,
+		Gain:       capture.Gain,
+		TimeNs:     uint64(capture.TimeNs),
+	}
+	metadata.SetTime(capture.WallTime)
+
+	return npy.SaveArchive(path, capture.Buffers, capture.NbSamples, metadata)
+}
@@ -0,0 +1,207 @@
+package recorder
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrstats"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+)
+
+// scriptedTrigger fires in given blocks at given indexes
+type scriptedTrigger struct {
+	fires map[int]int
+	block int
+}
+
+func (trigger *scriptedTrigger) Check(block Block) (index int, fired bool) {
+
+	index, fired = trigger.fires[trigger.block]
+	trigger.block++
+
+	return index, fired
+}
+
+// expectedCapture describes a capture expected from a triggered recorder
+type expectedCapture struct {
+	first        int
+	triggerIndex int
+	nbSamples    int
+	truncated    bool
+}
+
+// TestTriggeredRecorder checks the captures of a triggered recorder keeping 50 samples before the triggers and 100
+// samples after them, for blocks of 64 samples
+func TestTriggeredRecorder(t *testing.T) {
+
+	contiguous := []int{0, 64, 128, 192, 256, 320, 384, 448}
+	gap := []int{0, 64, 128, 292, 356, 420}
+
+	tests := []struct {
+		name     string
+		fires    map[int]int
+		starts   []int
+		lossAt   int
+		captures []expectedCapture
+		lost     uint64
+	}{
+		{"trigger in the middle of a block", map[int]int{2: 10}, contiguous, -1,
+			[]expectedCapture{{88, 50, 150, false}}, 0},
+		{"history shorter than the pre-trigger", map[int]int{0: 20}, contiguous, -1,
+			[]expectedCapture{{0, 20, 120, false}}, 0},
+		{"trigger at the end of a block", map[int]int{1: 64}, contiguous, -1,
+			[]expectedCapture{{78, 50, 150, false}}, 0},
+		{"new trigger in the block ending a capture", map[int]int{2: 10, 3: 50}, contiguous, -1,
+			[]expectedCapture{{88, 50, 150, false}, {238, 4, 104, false}}, 0},
+		{"trigger during a capture ignored", map[int]int{2: 10, 3: 40}, contiguous, -1,
+			[]expectedCapture{{88, 50, 150, false}}, 0},
+		{"gap truncating a capture", map[int]int{2: 10}, gap, -1,
+			[]expectedCapture{{88, 50, 104, true}}, 100},
+		{"loss truncating a capture", map[int]int{2: 10}, contiguous, 3,
+			[]expectedCapture{{88, 50, 104, true}}, 0},
+		{"history cleared by a gap", map[int]int{3: 5}, gap, -1,
+			[]expectedCapture{{292, 5, 105, false}}, 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			stream := &fakeRxStream{stats: sdrstats.NewStats()}
+			trigger := &scriptedTrigger{fires: test.fires}
+
+			options := TriggerOptions{PreTrigger: 500 * time.Microsecond, PostTrigger: time.Millisecond}
+			recorder, err := newTriggeredRecorder(fakeSettings{}, stream, []uint{0}, trigger, options, testSampleRate)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, start := range test.starts {
+
+				if i == test.lossAt {
+					recorder.MarkLoss()
+				}
+
+				block := Block{
+					Buffers:   ramp(start, 64),
+					NbSamples: 64,
+					TimeNs:    uint(sdrtime.TicksToTimeNs(start, testSampleRate)),
+					HasTime:   true,
+				}
+				if err := recorder.Process(block); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			recorder.Close()
+
+			var captures []TriggeredCapture
+			for capture := range recorder.Captures() {
+				captures = append(captures, capture)
+			}
+
+			if len(captures) != len(test.captures) {
+				t.Fatalf("%d captures, expected %d", len(captures), len(test.captures))
+			}
+
+			for i, capture := range captures {
+
+				expected := test.captures[i]
+
+				if capture.NbSamples != expected.nbSamples || capture.TriggerIndex != expected.triggerIndex ||
+					capture.Truncated != expected.truncated {
+					t.Errorf("capture %d: %d samples, trigger at %d, truncated %v, expected %d, %d, %v", i,
+						capture.NbSamples, capture.TriggerIndex, capture.Truncated, expected.nbSamples,
+						expected.triggerIndex, expected.truncated)
+				}
+
+				if values := indexes(capture.Buffers, capture.NbSamples); !reflect.DeepEqual(values, sequence(expected.first, expected.nbSamples)) {
+					t.Errorf("capture %d: samples %v, expected the samples from %d", i, values, expected.first)
+				}
+
+				timeNs := uint(sdrtime.TicksToTimeNs(expected.first, testSampleRate))
+				triggerTimeNs := uint(sdrtime.TicksToTimeNs(expected.first+expected.triggerIndex, testSampleRate))
+				if capture.TimeNs != timeNs || capture.TriggerTimeNs != triggerTimeNs || !capture.HasTime {
+					t.Errorf("capture %d: times %d and %d ns, expected %d and %d ns", i, capture.TimeNs,
+						capture.TriggerTimeNs, timeNs, triggerTimeNs)
+				}
+
+				if capture.SampleRate != testSampleRate || capture.Frequency != 100e6 || capture.Gain != 20 {
+					t.Errorf("capture %d: settings %v sps, %v Hz, %v dB", i, capture.SampleRate, capture.Frequency,
+						capture.Gain)
+				}
+			}
+
+			snapshot := stream.stats.Snapshot()
+			expectedDiscontinuities := uint64(0)
+			if test.lost > 0 || test.lossAt >= 0 {
+				expectedDiscontinuities = 1
+			}
+			if snapshot.Discontinuities != expectedDiscontinuities || snapshot.SamplesLost != test.lost {
+				t.Errorf("%d discontinuities and %d samples lost, expected %d and %d", snapshot.Discontinuities,
+					snapshot.SamplesLost, expectedDiscontinuities, test.lost)
+			}
+		})
+	}
+}
+
+// TestTriggeredRecorderFire checks that Fire starts a capture at the beginning of the next block
+func TestTriggeredRecorderFire(t *testing.T) {
+
+	stream := &fakeRxStream{}
+	options := TriggerOptions{PreTrigger: 500 * time.Microsecond, PostTrigger: time.Millisecond}
+	recorder, err := newTriggeredRecorder(fakeSettings{}, stream, []uint{0}, nil, options, testSampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for start := 0; start < 512; start += 64 {
+		if start == 128 {
+			recorder.Fire()
+		}
+		if err := recorder.Process(Block{Buffers: ramp(start, 64), NbSamples: 64}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recorder.Close()
+
+	capture, ok := <-recorder.Captures()
+	if !ok {
+		t.Fatal("no capture")
+	}
+	if capture.TriggerIndex != 50 || capture.NbSamples != 150 || capture.HasTime {
+		t.Errorf("capture of %d samples with the trigger at %d, expected 150 and 50", capture.NbSamples,
+			capture.TriggerIndex)
+	}
+	if values := indexes(capture.Buffers, capture.NbSamples); !reflect.DeepEqual(values, sequence(78, 150)) {
+		t.Errorf("samples %v, expected the samples from 78", values)
+	}
+
+	if _, ok := <-recorder.Captures(); ok {
+		t.Error("unexpected second capture")
+	}
+
+	if err := recorder.Process(Block{Buffers: ramp(0, 64), NbSamples: 64}); err == nil {
+		t.Error("expected an error after the close")
+	}
+}
@@ -20,6 +20,8 @@ package sdrerror
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
+import "fmt"
+
 // SDRError is an error of the SDR layer
 type SDRError interface {
 	// Error returns the error message
@@ -164,3 +166,24 @@ func (err *Unknown) Error() string {
 func (err *Unknown) SDRErrorCode() int {
 	return -255
 }
+
+// OutOfRange denotes that a value is outside of the ranges supported by the device. It is detected by the bindings
+// before calling into SoapySDR, which has no dedicated code, so it is reported with the code of NotSupported.
+type OutOfRange struct {
+	// Setting is the name of the setting
+	Setting string
+	// Value is the rejected value
+	Value float64
+	// Ranges is a human readable description of the supported ranges
+	Ranges string
+}
+
+// Error returns the error message
+func (err *OutOfRange) Error() string {
+	return fmt.Sprintf("%v %v is outside of the supported ranges %v", err.Setting, err.Value, err.Ranges)
+}
+
+// SDRErrorCode returns the original error code for the SoapySDR
+func (err *OutOfRange) SDRErrorCode() int {
+	return -5
+}
@@ -0,0 +1,254 @@
+package sdrformat
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"math"
+)
+
+// The conversions between formats normalize the samples: the integer formats are scaled so that their full scale
+// matches the [-1, 1] range of the float formats. The unsigned formats are centered on the middle of their range.
+
+const (
+	scale8  = 128.0
+	scale16 = 32768.0
+)
+
+// NormalizationScale returns the value of a sample of a format converted to 1 by ToComplex128: 128 for the 8 bits
+// formats, 32768 for the 16 bits formats and 1 for the float formats.
+//
+// Params:
+//  - format: the format, one of the formats of this package
+//
+// Return the scale
+func NormalizationScale(format string) float64 {
+
+	switch format {
+	case CU8, CS8:
+		return scale8
+	case CU16, CS16:
+		return scale16
+	}
+
+	return 1
+}
+
+// ToComplex128 converts the samples of a single channel buffer of any format to normalized complex128 samples.
+//
+// Params:
+//  - dst: the destination samples
+//  - src: the single channel buffer to convert, such as a []int16
+//
+// Return the number of samples converted, limited by the length of dst
+func ToComplex128(dst []complex128, src interface{}) (nbSamples int, err error) {
+
+	length, err := BufferLength(src)
+	if err != nil {
+		return 0, err
+	}
+
+	nbSamples = length
+	if len(dst) < nbSamples {
+		nbSamples = len(dst)
+	}
+
+	switch typed := src.(type) {
+	case []uint8:
+		for i := 0; i < nbSamples; i++ {
+			dst[i] = complex((float64(typed[2*i])-scale8)/scale8, (float64(typed[2*i+1])-scale8)/scale8)
+		}
+	case []int8:
+		for i := 0; i < nbSamples; i++ {
+			dst[i] = complex(float64(typed[2*i])/scale8, float64(typed[2*i+1])/scale8)
+		}
+	case []uint16:
+		for i := 0; i < nbSamples; i++ {
+			dst[i] = complex((float64(typed[2*i])-scale16)/scale16, (float64(typed[2*i+1])-scale16)/scale16)
+		}
+	case []int16:
+		for i := 0; i < nbSamples; i++ {
+			dst[i] = complex(float64(typed[2*i])/scale16, float64(typed[2*i+1])/scale16)
+		}
+	case []complex64:
+		for i := 0; i < nbSamples; i++ {
+			dst[i] = complex128(typed[i])
+		}
+	case []complex128:
+		copy(dst[:nbSamples], typed)
+	}
+
+	return nbSamples, nil
+}
+
+// FromComplex128 converts normalized complex128 samples to a single channel buffer of any format. The samples out of
+// the range of the integer formats are saturated.
+//
+// Params:
+//  - dst: the single channel buffer receiving the samples, such as a []int16
+//  - src: the normalized samples
+//
+// Return the number of samples converted, limited by the length of dst
+func FromComplex128(dst interface{}, src []complex128) (nbSamples int, err error) {
+
+	length, err := BufferLength(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	nbSamples = length
+	if len(src) < nbSamples {
+		nbSamples = len(src)
+	}
+
+	switch typed := dst.(type) {
+	case []uint8:
+		for i := 0; i < nbSamples; i++ {
+			typed[2*i] = uint8(quantize(real(src[i])*scale8+scale8, 0, math.MaxUint8))
+			typed[2*i+1] = uint8(quantize(imag(src[i])*scale8+scale8, 0, math.MaxUint8))
+		}
+	case []int8:
+		for i := 0; i < nbSamples; i++ {
+			typed[2*i] = int8(quantize(real(src[i])*scale8, math.MinInt8, math.MaxInt8))
+			typed[2*i+1] = int8(quantize(imag(src[i])*scale8, math.MinInt8, math.MaxInt8))
+		}
+	case []uint16:
+		for i := 0; i < nbSamples; i++ {
+			typed[2*i] = uint16(quantize(real(src[i])*scale16+scale16, 0, math.MaxUint16))
+			typed[2*i+1] = uint16(quantize(imag(src[i])*scale16+scale16, 0, math.MaxUint16))
+		}
+	case []int16:
+		for i := 0; i < nbSamples; i++ {
+			typed[2*i] = int16(quantize(real(src[i])*scale16, math.MinInt16, math.MaxInt16))
+			typed[2*i+1] = int16(quantize(imag(src[i])*scale16, math.MinInt16, math.MaxInt16))
+		}
+	case []complex64:
+		for i := 0; i < nbSamples; i++ {
+			typed[i] = complex64(src[i])
+		}
+	case []complex128:
+		copy(typed[:nbSamples], src)
+	}
+
+	return nbSamples, nil
+}
+
+// ConvertBuffer converts the samples of a single channel buffer to a single channel buffer of any format.
+//
+// Params:
+//  - dst: the destination buffer, such as a []complex64
+//  - src: the source buffer, such as a []int16
+//
+// Return the number of samples converted, limited by the length of the buffers
+func ConvertBuffer(dst interface{}, src interface{}) (nbSamples int, err error) {
+
+	length, err := BufferLength(src)
+	if err != nil {
+		return 0, err
+	}
+
+	intermediate := make([]complex128, length)
+	if _, err := ToComplex128(intermediate, src); err != nil {
+		return 0, err
+	}
+
+	return FromComplex128(dst, intermediate)
+}
+
+// ConvertBuffers converts the samples of the buffers of all the channels to buffers of another format. Buffers of the
+// same format are copied.
+//
+// Params:
+//  - dst: the destination buffers, such as a [][]complex64
+//  - src: the source buffers, such as a [][]int16
+//
+// Return the number of samples converted per channel, limited by the length of the buffers
+func ConvertBuffers(dst interface{}, src interface{}) (nbSamples int, err error) {
+
+	dstFormat, err := FormatOf(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	srcFormat, err := FormatOf(src)
+	if err != nil {
+		return 0, err
+	}
+
+	if dstFormat == srcFormat {
+		return CopyBuffers(dst, src)
+	}
+
+	dstChannels, dstSamples, err := BuffersLength(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	srcChannels, srcSamples, err := BuffersLength(src)
+	if err != nil {
+		return 0, err
+	}
+
+	if dstChannels != srcChannels {
+		return 0, errors.New("the buffers must have the same number of channels")
+	}
+
+	nbSamples = dstSamples
+	if srcSamples < nbSamples {
+		nbSamples = srcSamples
+	}
+
+	intermediate := make([]complex128, nbSamples)
+	for channel := 0; channel < dstChannels; channel++ {
+
+		srcBuffer, err := ChannelBuffer(src, channel)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := ToComplex128(intermediate, srcBuffer); err != nil {
+			return 0, err
+		}
+
+		dstBuffer, err := ChannelBuffer(dst, channel)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := FromComplex128(dstBuffer, intermediate); err != nil {
+			return 0, err
+		}
+	}
+
+	return nbSamples, nil
+}
+
+// quantize rounds a value to the nearest integer, saturated in the given range
+func quantize(value float64, minimum float64, maximum float64) float64 {
+
+	value = math.Round(value)
+	if value < minimum {
+		return minimum
+	}
+	if value > maximum {
+		return maximum
+	}
+
+	return value
+}
@@ -0,0 +1,212 @@
+package sdrformat
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Encode appends samples of the buffers of all the channels to a byte slice, in little endian, the samples of the
+// channels being interleaved: the first sample of each channel, then the second sample of each channel...
+//
+// Params:
+//  - dst: the byte slice to append to
+//  - buffers: the buffers of all the channels
+//  - nbSamples: the number of samples to encode per channel, which must be held by all the buffers
+//
+// Return the extended byte slice
+func Encode(dst []byte, buffers interface{}, nbSamples int) ([]byte, error) {
+
+	nbChannels, length, err := BuffersLength(buffers)
+	if err != nil {
+		return dst, err
+	}
+
+	if nbSamples > length {
+		return dst, errors.New("the buffers do not hold the number of samples to encode")
+	}
+
+	format, _ := FormatOf(buffers)
+	dst = grow(dst, nbChannels*nbSamples*BytesPerSample(format))
+
+	switch typed := buffers.(type) {
+	case [][]uint8:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				dst = append(dst, buffer[2*i], buffer[2*i+1])
+			}
+		}
+	case [][]int8:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				dst = append(dst, byte(buffer[2*i]), byte(buffer[2*i+1]))
+			}
+		}
+	case [][]uint16:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				dst = appendUint16(dst, buffer[2*i])
+				dst = appendUint16(dst, buffer[2*i+1])
+			}
+		}
+	case [][]int16:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				dst = appendUint16(dst, uint16(buffer[2*i]))
+				dst = appendUint16(dst, uint16(buffer[2*i+1]))
+			}
+		}
+	case [][]complex64:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				dst = appendUint32(dst, math.Float32bits(real(buffer[i])))
+				dst = appendUint32(dst, math.Float32bits(imag(buffer[i])))
+			}
+		}
+	case [][]complex128:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				dst = appendUint64(dst, math.Float64bits(real(buffer[i])))
+				dst = appendUint64(dst, math.Float64bits(imag(buffer[i])))
+			}
+		}
+	}
+
+	return dst, nil
+}
+
+// Decode decodes little endian samples, the samples of the channels being interleaved, into the buffers of all the
+// channels. It is the reverse of Encode.
+//
+// Params:
+//  - buffers: the buffers of all the channels receiving the samples
+//  - src: the encoded samples
+//
+// Return the number of samples decoded per channel, limited by the length of the buffers and the number of complete
+// samples of src
+func Decode(buffers interface{}, src []byte) (nbSamples int, err error) {
+
+	nbChannels, length, err := BuffersLength(buffers)
+	if err != nil {
+		return 0, err
+	}
+
+	if nbChannels == 0 {
+		return 0, nil
+	}
+
+	format, _ := FormatOf(buffers)
+	size := BytesPerSample(format)
+
+	nbSamples = len(src) / (size * nbChannels)
+	if nbSamples > length {
+		nbSamples = length
+	}
+
+	offset := 0
+	switch typed := buffers.(type) {
+	case [][]uint8:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				buffer[2*i] = src[offset]
+				buffer[2*i+1] = src[offset+1]
+				offset += size
+			}
+		}
+	case [][]int8:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				buffer[2*i] = int8(src[offset])
+				buffer[2*i+1] = int8(src[offset+1])
+				offset += size
+			}
+		}
+	case [][]uint16:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				buffer[2*i] = binary.LittleEndian.Uint16(src[offset:])
+				buffer[2*i+1] = binary.LittleEndian.Uint16(src[offset+2:])
+				offset += size
+			}
+		}
+	case [][]int16:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				buffer[2*i] = int16(binary.LittleEndian.Uint16(src[offset:]))
+				buffer[2*i+1] = int16(binary.LittleEndian.Uint16(src[offset+2:]))
+				offset += size
+			}
+		}
+	case [][]complex64:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				buffer[i] = complex(
+					math.Float32frombits(binary.LittleEndian.Uint32(src[offset:])),
+					math.Float32frombits(binary.LittleEndian.Uint32(src[offset+4:])))
+				offset += size
+			}
+		}
+	case [][]complex128:
+		for i := 0; i < nbSamples; i++ {
+			for _, buffer := range typed {
+				buffer[i] = complex(
+					math.Float64frombits(binary.LittleEndian.Uint64(src[offset:])),
+					math.Float64frombits(binary.LittleEndian.Uint64(src[offset+8:])))
+				offset += size
+			}
+		}
+	default:
+		return 0, fmt.Errorf("%w: buffers of type %T", ErrUnknownFormat, buffers)
+	}
+
+	return nbSamples, nil
+}
+
+// grow makes sure that a byte slice can be extended by n bytes without allocation
+func grow(dst []byte, n int) []byte {
+
+	if cap(dst)-len(dst) >= n {
+		return dst
+	}
+
+	grown := make([]byte, len(dst), len(dst)+n)
+	copy(grown, dst)
+
+	return grown
+}
+
+// appendUint16 appends an uint16 in little endian
+func appendUint16(dst []byte, value uint16) []byte {
+	return append(dst, byte(value), byte(value>>8))
+}
+
+// appendUint32 appends an uint32 in little endian
+func appendUint32(dst []byte, value uint32) []byte {
+	return append(dst, byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+}
+
+// appendUint64 appends an uint64 in little endian
+func appendUint64(dst []byte, value uint64) []byte {
+	return appendUint32(appendUint32(dst, uint32(value)), uint32(value>>32))
+}
@@ -0,0 +1,497 @@
+package sdrformat
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The stream formats supported by the streams.
+//
+// The buffers of a stream are a slice of buffers, one per channel, whose Go type depends on the stream format:
+//  - CU8: [][]uint8, I and Q interleaved
+//  - CS8: [][]int8, I and Q interleaved
+//  - CU16: [][]uint16, I and Q interleaved
+//  - CS16: [][]int16, I and Q interleaved
+//  - CF32: [][]complex64
+//  - CF64: [][]complex128
+const (
+	// CU8 is the complex unsigned 8 bits format
+	CU8 = "CU8"
+	// CS8 is the complex signed 8 bits format
+	CS8 = "CS8"
+	// CU16 is the complex unsigned 16 bits format
+	CU16 = "CU16"
+	// CS16 is the complex signed 16 bits format
+	CS16 = "CS16"
+	// CF32 is the complex float 32 bits format
+	CF32 = "CF32"
+	// CF64 is the complex float 64 bits format
+	CF64 = "CF64"
+)
+
+// ErrUnknownFormat is returned when a format or a buffer type is not one of the supported stream formats
+var ErrUnknownFormat = errors.New("unknown stream format")
+
+// ElementsPerSample returns the number of buffer elements used by a single complex sample of the format: 2 for the
+// interleaved integer formats, 1 for the complex formats. It returns 0 for an unknown format.
+func ElementsPerSample(format string) int {
+
+	switch format {
+	case CU8, CS8, CU16, CS16:
+		return 2
+	case CF32, CF64:
+		return 1
+	}
+
+	return 0
+}
+
+// BytesPerSample returns the number of bytes used by a single complex sample of the format. It returns 0 for an
+// unknown format.
+func BytesPerSample(format string) int {
+
+	switch format {
+	case CU8, CS8:
+		return 2
+	case CU16, CS16:
+		return 4
+	case CF32:
+		return 8
+	case CF64:
+		return 16
+	}
+
+	return 0
+}
+
+// FormatOf returns the stream format matching the Go type of a buffer. The buffer can be a single channel buffer
+// (such as []int16) or the buffers of all the channels (such as [][]int16).
+func FormatOf(buffer interface{}) (format string, err error) {
+
+	switch buffer.(type) {
+	case []uint8, [][]uint8:
+		return CU8, nil
+	case []int8, [][]int8:
+		return CS8, nil
+	case []uint16, [][]uint16:
+		return CU16, nil
+	case []int16, [][]int16:
+		return CS16, nil
+	case []complex64, [][]complex64:
+		return CF32, nil
+	case []complex128, [][]complex128:
+		return CF64, nil
+	}
+
+	return "", fmt.Errorf("%w: buffer of type %T", ErrUnknownFormat, buffer)
+}
+
+// MakeBuffer allocates a single channel buffer for the given format.
+//
+// Params:
+//  - format: the stream format
+//  - nbSamples: the number of complex samples the buffer must hold
+//
+// Return the buffer, for example a []int16 of 2*nbSamples elements for "CS16"
+func MakeBuffer(format string, nbSamples int) (buffer interface{}, err error) {
+
+	switch format {
+	case CU8:
+		return make([]uint8, 2*nbSamples), nil
+	case CS8:
+		return make([]int8, 2*nbSamples), nil
+	case CU16:
+		return make([]uint16, 2*nbSamples), nil
+	case CS16:
+		return make([]int16, 2*nbSamples), nil
+	case CF32:
+		return make([]complex64, nbSamples), nil
+	case CF64:
+		return make([]complex128, nbSamples), nil
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, format)
+}
+
+// MakeBuffers allocates the buffers of all the channels for the given format.
+//
+// Params:
+//  - format: the stream format
+//  - nbChannels: the number of channels
+//  - nbSamples: the number of complex samples each channel buffer must hold
+//
+// Return the buffers, for example a [][]int16 for "CS16"
+func MakeBuffers(format string, nbChannels int, nbSamples int) (buffers interface{}, err error) {
+
+	switch format {
+	case CU8:
+		result := make([][]uint8, nbChannels)
+		for i := range result {
+			result[i] = make([]uint8, 2*nbSamples)
+		}
+		return result, nil
+	case CS8:
+		result := make([][]int8, nbChannels)
+		for i := range result {
+			result[i] = make([]int8, 2*nbSamples)
+		}
+		return result, nil
+	case CU16:
+		result := make([][]uint16, nbChannels)
+		for i := range result {
+			result[i] = make([]uint16, 2*nbSamples)
+		}
+		return result, nil
+	case CS16:
+		result := make([][]int16, nbChannels)
+		for i := range result {
+			result[i] = make([]int16, 2*nbSamples)
+		}
+		return result, nil
+	case CF32:
+		result := make([][]complex64, nbChannels)
+		for i := range result {
+			result[i] = make([]complex64, nbSamples)
+		}
+		return result, nil
+	case CF64:
+		result := make([][]complex128, nbChannels)
+		for i := range result {
+			result[i] = make([]complex128, nbSamples)
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrUnknownFormat, format)
+}
+
+// BufferLength returns the number of complex samples held by a single channel buffer.
+func BufferLength(buffer interface{}) (nbSamples int, err error) {
+
+	switch typed := buffer.(type) {
+	case []uint8:
+		return len(typed) / 2, nil
+	case []int8:
+		return len(typed) / 2, nil
+	case []uint16:
+		return len(typed) / 2, nil
+	case []int16:
+		return len(typed) / 2, nil
+	case []complex64:
+		return len(typed), nil
+	case []complex128:
+		return len(typed), nil
+	}
+
+	return 0, fmt.Errorf("%w: buffer of type %T", ErrUnknownFormat, buffer)
+}
+
+// BuffersLength returns the number of channels of the buffers and the number of complex samples held by the shortest
+// channel buffer.
+func BuffersLength(buffers interface{}) (nbChannels int, nbSamples int, err error) {
+
+	first := true
+	length := func(channelLength int) {
+		if first || channelLength < nbSamples {
+			nbSamples = channelLength
+			first = false
+		}
+	}
+
+	switch typed := buffers.(type) {
+	case [][]uint8:
+		for _, buffer := range typed {
+			length(len(buffer) / 2)
+		}
+		nbChannels = len(typed)
+	case [][]int8:
+		for _, buffer := range typed {
+			length(len(buffer) / 2)
+		}
+		nbChannels = len(typed)
+	case [][]uint16:
+		for _, buffer := range typed {
+			length(len(buffer) / 2)
+		}
+		nbChannels = len(typed)
+	case [][]int16:
+		for _, buffer := range typed {
+			length(len(buffer) / 2)
+		}
+		nbChannels = len(typed)
+	case [][]complex64:
+		for _, buffer := range typed {
+			length(len(buffer))
+		}
+		nbChannels = len(typed)
+	case [][]complex128:
+		for _, buffer := range typed {
+			length(len(buffer))
+		}
+		nbChannels = len(typed)
+	default:
+		return 0, 0, fmt.Errorf("%w: buffers of type %T", ErrUnknownFormat, buffers)
+	}
+
+	return nbChannels, nbSamples, nil
+}
+
+// SliceBuffer returns the part of a single channel buffer between two sample indexes, without copying the samples.
+//
+// Params:
+//  - buffer: a single channel buffer
+//  - from: the index of the first complex sample
+//  - to: the index following the last complex sample
+//
+// Return the slice of the buffer, of the same type as the buffer
+func SliceBuffer(buffer interface{}, from int, to int) (slice interface{}, err error) {
+
+	switch typed := buffer.(type) {
+	case []uint8:
+		return typed[2*from : 2*to], nil
+	case []int8:
+		return typed[2*from : 2*to], nil
+	case []uint16:
+		return typed[2*from : 2*to], nil
+	case []int16:
+		return typed[2*from : 2*to], nil
+	case []complex64:
+		return typed[from:to], nil
+	case []complex128:
+		return typed[from:to], nil
+	}
+
+	return nil, fmt.Errorf("%w: buffer of type %T", ErrUnknownFormat, buffer)
+}
+
+// SliceBuffers returns the part of the buffers of all the channels between two sample indexes, without copying the
+// samples.
+//
+// Params:
+//  - buffers: the buffers of all the channels
+//  - from: the index of the first complex sample
+//  - to: the index following the last complex sample
+//
+// Return the slice of the buffers, of the same type as the buffers
+func SliceBuffers(buffers interface{}, from int, to int) (slices interface{}, err error) {
+
+	switch typed := buffers.(type) {
+	case [][]uint8:
+		result := make([][]uint8, len(typed))
+		for i, buffer := range typed {
+			result[i] = buffer[2*from : 2*to]
+		}
+		return result, nil
+	case [][]int8:
+		result := make([][]int8, len(typed))
+		for i, buffer := range typed {
+			result[i] = buffer[2*from : 2*to]
+		}
+		return result, nil
+	case [][]uint16:
+		result := make([][]uint16, len(typed))
+		for i, buffer := range typed {
+			result[i] = buffer[2*from : 2*to]
+		}
+		return result, nil
+	case [][]int16:
+		result := make([][]int16, len(typed))
+		for i, buffer := range typed {
+			result[i] = buffer[2*from : 2*to]
+		}
+		return result, nil
+	case [][]complex64:
+		result := make([][]complex64, len(typed))
+		for i, buffer := range typed {
+			result[i] = buffer[from:to]
+		}
+		return result, nil
+	case [][]complex128:
+		result := make([][]complex128, len(typed))
+		for i, buffer := range typed {
+			result[i] = buffer[from:to]
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("%w: buffers of type %T", ErrUnknownFormat, buffers)
+}
+
+// ChannelBuffer returns the buffer of a channel from the buffers of all the channels, without copying the samples.
+//
+// Params:
+//  - buffers: the buffers of all the channels, such as a [][]int16
+//  - channel: the index of the channel in the buffers
+//
+// Return the buffer of the channel, such as a []int16
+func ChannelBuffer(buffers interface{}, channel int) (buffer interface{}, err error) {
+
+	nbChannels, _, err := BuffersLength(buffers)
+	if err != nil {
+		return nil, err
+	}
+
+	if channel < 0 || channel >= nbChannels {
+		return nil, fmt.Errorf("channel %d out of the %d channels of the buffers", channel, nbChannels)
+	}
+
+	switch typed := buffers.(type) {
+	case [][]uint8:
+		return typed[channel], nil
+	case [][]int8:
+		return typed[channel], nil
+	case [][]uint16:
+		return typed[channel], nil
+	case [][]int16:
+		return typed[channel], nil
+	case [][]complex64:
+		return typed[channel], nil
+	case [][]complex128:
+		return typed[channel], nil
+	}
+
+	return nil, fmt.Errorf("%w: buffers of type %T", ErrUnknownFormat, buffers)
+}
+
+// SplitChannels returns the buffer of each channel as separate single-channel buffers, without copying the samples.
+// It allows to process the channels of a stream independently with the functions taking the buffers of all the
+// channels.
+//
+// Params:
+//  - buffers: the buffers of all the channels, such as a [][]int16
+//
+// Return a list with, for each channel, buffers of the same type as the buffers holding only this channel
+func SplitChannels(buffers interface{}) (channels []interface{}, err error) {
+
+	switch typed := buffers.(type) {
+	case [][]uint8:
+		for _, buffer := range typed {
+			channels = append(channels, [][]uint8{buffer})
+		}
+		return channels, nil
+	case [][]int8:
+		for _, buffer := range typed {
+			channels = append(channels, [][]int8{buffer})
+		}
+		return channels, nil
+	case [][]uint16:
+		for _, buffer := range typed {
+			channels = append(channels, [][]uint16{buffer})
+		}
+		return channels, nil
+	case [][]int16:
+		for _, buffer := range typed {
+			channels = append(channels, [][]int16{buffer})
+		}
+		return channels, nil
+	case [][]complex64:
+		for _, buffer := range typed {
+			channels = append(channels, [][]complex64{buffer})
+		}
+		return channels, nil
+	case [][]complex128:
+		for _, buffer := range typed {
+			channels = append(channels, [][]complex128{buffer})
+		}
+		return channels, nil
+	}
+
+	return nil, fmt.Errorf("%w: buffers of type %T", ErrUnknownFormat, buffers)
+}
+
+// CopyBuffers copies the samples of the buffers of all the channels into other buffers of the same type. The number
+// of channels must match and the number of samples copied is the minimum of the lengths of the buffers.
+//
+// Params:
+//  - dst: the destination buffers
+//  - src: the source buffers
+//
+// Return the number of samples copied per channel
+func CopyBuffers(dst interface{}, src interface{}) (nbSamples int, err error) {
+
+	dstChannels, dstSamples, err := BuffersLength(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	srcChannels, srcSamples, err := BuffersLength(src)
+	if err != nil {
+		return 0, err
+	}
+
+	if dstChannels != srcChannels {
+		return 0, errors.New("the buffers must have the same number of channels")
+	}
+
+	nbSamples = dstSamples
+	if srcSamples < nbSamples {
+		nbSamples = srcSamples
+	}
+
+	switch typedDst := dst.(type) {
+	case [][]uint8:
+		if typedSrc, ok := src.([][]uint8); ok {
+			for i := range typedDst {
+				copy(typedDst[i][:2*nbSamples], typedSrc[i])
+			}
+			return nbSamples, nil
+		}
+	case [][]int8:
+		if typedSrc, ok := src.([][]int8); ok {
+			for i := range typedDst {
+				copy(typedDst[i][:2*nbSamples], typedSrc[i])
+			}
+			return nbSamples, nil
+		}
+	case [][]uint16:
+		if typedSrc, ok := src.([][]uint16); ok {
+			for i := range typedDst {
+				copy(typedDst[i][:2*nbSamples], typedSrc[i])
+			}
+			return nbSamples, nil
+		}
+	case [][]int16:
+		if typedSrc, ok := src.([][]int16); ok {
+			for i := range typedDst {
+				copy(typedDst[i][:2*nbSamples], typedSrc[i])
+			}
+			return nbSamples, nil
+		}
+	case [][]complex64:
+		if typedSrc, ok := src.([][]complex64); ok {
+			for i := range typedDst {
+				copy(typedDst[i][:nbSamples], typedSrc[i])
+			}
+			return nbSamples, nil
+		}
+	case [][]complex128:
+		if typedSrc, ok := src.([][]complex128); ok {
+			for i := range typedDst {
+				copy(typedDst[i][:nbSamples], typedSrc[i])
+			}
+			return nbSamples, nil
+		}
+	}
+
+	return 0, fmt.Errorf("can not copy buffers of type %T into buffers of type %T", src, dst)
+}
@@ -0,0 +1,274 @@
+package sdrstats
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metricPrefix is the prefix of all the exported metric names
+const metricPrefix = "bhojpur_sdr_"
+
+// DefaultRegistry is the registry used by the servers to export the statistics of the streams
+var DefaultRegistry = NewRegistry()
+
+// Registry keeps track of the statistics of the named streams, and of the streaming status indicators (SSI) logged by
+// SoapySDR. A Registry is an http.Handler exporting all the statistics in the Prometheus text format.
+type Registry struct {
+	mutex   sync.RWMutex
+	streams map[string]*registeredStats
+	ssi     map[string]uint64
+}
+
+// registeredStats associates the statistics of a stream with its labels
+type registeredStats struct {
+	stats  *Stats
+	labels map[string]string
+}
+
+// NewRegistry creates a new empty registry
+func NewRegistry() *Registry {
+
+	return &Registry{
+		streams: make(map[string]*registeredStats),
+		ssi:     make(map[string]uint64),
+	}
+}
+
+// Register registers the statistics of a stream. If a stream with the same name is already registered, its
+// statistics are returned.
+//
+// Params:
+//  - name: the name of the stream, exported as the "stream" label
+//  - labels: optional additional labels exported with all the metrics of the stream, such as the driver or the channel
+//
+// Return the statistics of the stream
+func (registry *Registry) Register(name string, labels map[string]string) *Stats {
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if existing, ok := registry.streams[name]; ok {
+		return existing.stats
+	}
+
+	copiedLabels := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copiedLabels[k] = v
+	}
+
+	stats := NewStats()
+	registry.streams[name] = &registeredStats{
+		stats:  stats,
+		labels: copiedLabels,
+	}
+
+	return stats
+}
+
+// Unregister removes the statistics of a stream from the registry.
+//
+// Params:
+//  - name: the name of the stream
+func (registry *Registry) Unregister(name string) {
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	delete(registry.streams, name)
+}
+
+// ObserveSSI records a streaming status indicator message logged by SoapySDR with the SSI level. Such messages are
+// made of indicator characters such as "O" (overflow) and "U" (underflow), each occurrence is counted.
+//
+// Params:
+//  - message: the logged message
+func (registry *Registry) ObserveSSI(message string) {
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for _, indicator := range message {
+		if indicator == ' ' || indicator == '\n' || indicator == '\r' || indicator == '\t' {
+			continue
+		}
+		registry.ssi[string(indicator)]++
+	}
+}
+
+// SSICount returns the number of occurrences of a streaming status indicator, for example "O" or "U".
+func (registry *Registry) SSICount(indicator string) uint64 {
+
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	return registry.ssi[indicator]
+}
+
+// metricDefinition describes how a metric is exported from a Snapshot
+type metricDefinition struct {
+	name       string
+	metricType string
+	help       string
+	value      func(snapshot *Snapshot) float64
+}
+
+var streamMetrics = []metricDefinition{
+	{"stream_samples_read_total", "counter", "Number of elements read per channel.",
+		func(s *Snapshot) float64 { return float64(s.SamplesRead) }},
+	{"stream_samples_written_total", "counter", "Number of elements written per channel.",
+		func(s *Snapshot) float64 { return float64(s.SamplesWritten) }},
+	{"stream_overflows_total", "counter", "Number of overflows reported by the stream.",
+		func(s *Snapshot) float64 { return float64(s.Overflows) }},
+	{"stream_underflows_total", "counter", "Number of underflows reported by the stream.",
+		func(s *Snapshot) float64 { return float64(s.Underflows) }},
+	{"stream_timeouts_total", "counter", "Number of read or write operations that timed out.",
+		func(s *Snapshot) float64 { return float64(s.Timeouts) }},
+	{"stream_time_errors_total", "counter", "Number of time errors reported by read or write.",
+		func(s *Snapshot) float64 { return float64(s.TimeErrors) }},
+	{"stream_corrupted_reads_total", "counter", "Number of read operations reporting a data corruption.",
+		func(s *Snapshot) float64 { return float64(s.CorruptedReads) }},
+	{"stream_late_packets_total", "counter", "Number of late packets reported by the stream status.",
+		func(s *Snapshot) float64 { return float64(s.LatePackets) }},
+	{"stream_short_reads_total", "counter", "Number of reads returning less elements than requested.",
+		func(s *Snapshot) float64 { return float64(s.ShortReads) }},
+	{"stream_errors_total", "counter", "Number of non-specific stream errors.",
+		func(s *Snapshot) float64 { return float64(s.StreamErrors) }},
+	{"stream_discontinuities_total", "counter", "Number of discontinuities found in the timestamps of the samples read.",
+		func(s *Snapshot) float64 { return float64(s.Discontinuities) }},
+	{"stream_samples_lost_total", "counter", "Number of samples missing in the gaps of the timestamps.",
+		func(s *Snapshot) float64 { return float64(s.SamplesLost) }},
+	{"stream_configured_sample_rate", "gauge", "Sample rate configured on the stream in samples per second.",
+		func(s *Snapshot) float64 { return s.ConfiguredSampleRate }},
+	{"stream_achieved_sample_rate", "gauge", "Sample rate measured on the stream in samples per second.",
+		func(s *Snapshot) float64 { return s.AchievedSampleRate }},
+}
+
+// WritePrometheus writes all the statistics of the registry in the Prometheus text exposition format.
+//
+// Params:
+//  - w: the writer receiving the metrics
+//
+// Return an error or nil in case of success
+func (registry *Registry) WritePrometheus(w io.Writer) error {
+
+	registry.mutex.RLock()
+
+	names := make([]string, 0, len(registry.streams))
+	for name := range registry.streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	snapshots := make([]Snapshot, len(names))
+	labels := make([]string, len(names))
+	for i, name := range names {
+		entry := registry.streams[name]
+		snapshots[i] = entry.stats.Snapshot()
+		labels[i] = formatLabels(name, entry.labels)
+	}
+
+	indicators := make([]string, 0, len(registry.ssi))
+	for indicator := range registry.ssi {
+		indicators = append(indicators, indicator)
+	}
+	sort.Strings(indicators)
+
+	ssiCounts := make([]uint64, len(indicators))
+	for i, indicator := range indicators {
+		ssiCounts[i] = registry.ssi[indicator]
+	}
+
+	registry.mutex.RUnlock()
+
+	buffered := bufio.NewWriter(w)
+
+	for _, metric := range streamMetrics {
+		fmt.Fprintf(buffered, "# HELP %s%s %s\n", metricPrefix, metric.name, metric.help)
+		fmt.Fprintf(buffered, "# TYPE %s%s %s\n", metricPrefix, metric.name, metric.metricType)
+		for i := range snapshots {
+			fmt.Fprintf(buffered, "%s%s{%s} %v\n", metricPrefix, metric.name, labels[i], metric.value(&snapshots[i]))
+		}
+	}
+
+	fmt.Fprintf(buffered, "# HELP %sssi_indicators_total Number of streaming status indicators logged by SoapySDR.\n", metricPrefix)
+	fmt.Fprintf(buffered, "# TYPE %sssi_indicators_total counter\n", metricPrefix)
+	for i, indicator := range indicators {
+		fmt.Fprintf(buffered, "%sssi_indicators_total{indicator=\"%s\"} %v\n", metricPrefix, escapeLabelValue(indicator), ssiCounts[i])
+	}
+
+	return buffered.Flush()
+}
+
+// ServeHTTP exports the statistics of the registry in the Prometheus text exposition format
+func (registry *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	if err := registry.WritePrometheus(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// formatLabels formats the labels of a stream, the stream name first then the other labels sorted by name
+func formatLabels(name string, labels map[string]string) string {
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if k != "stream" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys)+1)
+	parts = append(parts, fmt.Sprintf("stream=\"%s\"", escapeLabelValue(name)))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", sanitizeLabelName(k), escapeLabelValue(labels[k])))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// sanitizeLabelName replaces the characters not allowed in a Prometheus label name by underscores
+func sanitizeLabelName(name string) string {
+
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// escapeLabelValue escapes a label value as required by the Prometheus text format
+func escapeLabelValue(value string) string {
+
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return strings.ReplaceAll(value, `"`, `\"`)
+}
@@ -0,0 +1,173 @@
+package sdrstats
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+)
+
+// expectedMetrics is the exposition of the registry filled by newTestRegistry. The labels of the streams are sorted
+// after the stream name, their names sanitized and their values escaped.
+const expectedMetrics = `# HELP bhojpur_sdr_stream_samples_read_total Number of elements read per channel.
+# TYPE bhojpur_sdr_stream_samples_read_total counter
+bhojpur_sdr_stream_samples_read_total{stream="rx",channel="0",driver="lime"} 1536
+bhojpur_sdr_stream_samples_read_total{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_samples_written_total Number of elements written per channel.
+# TYPE bhojpur_sdr_stream_samples_written_total counter
+bhojpur_sdr_stream_samples_written_total{stream="rx",channel="0",driver="lime"} 0
+bhojpur_sdr_stream_samples_written_total{stream="tx",serial_number="a\"b\\c\nd"} 4096
+# HELP bhojpur_sdr_stream_overflows_total Number of overflows reported by the stream.
+# TYPE bhojpur_sdr_stream_overflows_total counter
+bhojpur_sdr_stream_overflows_total{stream="rx",channel="0",driver="lime"} 1
+bhojpur_sdr_stream_overflows_total{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_underflows_total Number of underflows reported by the stream.
+# TYPE bhojpur_sdr_stream_underflows_total counter
+bhojpur_sdr_stream_underflows_total{stream="rx",channel="0",driver="lime"} 0
+bhojpur_sdr_stream_underflows_total{stream="tx",serial_number="a\"b\\c\nd"} 1
+# HELP bhojpur_sdr_stream_timeouts_total Number of read or write operations that timed out.
+# TYPE bhojpur_sdr_stream_timeouts_total counter
+bhojpur_sdr_stream_timeouts_total{stream="rx",channel="0",driver="lime"} 0
+bhojpur_sdr_stream_timeouts_total{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_time_errors_total Number of time errors reported by read or write.
+# TYPE bhojpur_sdr_stream_time_errors_total counter
+bhojpur_sdr_stream_time_errors_total{stream="rx",channel="0",driver="lime"} 0
+bhojpur_sdr_stream_time_errors_total{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_corrupted_reads_total Number of read operations reporting a data corruption.
+# TYPE bhojpur_sdr_stream_corrupted_reads_total counter
+bhojpur_sdr_stream_corrupted_reads_total{stream="rx",channel="0",driver="lime"} 0
+bhojpur_sdr_stream_corrupted_reads_total{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_late_packets_total Number of late packets reported by the stream status.
+# TYPE bhojpur_sdr_stream_late_packets_total counter
+bhojpur_sdr_stream_late_packets_total{stream="rx",channel="0",driver="lime"} 0
+bhojpur_sdr_stream_late_packets_total{stream="tx",serial_number="a\"b\\c\nd"} 1
+# HELP bhojpur_sdr_stream_short_reads_total Number of reads returning less elements than requested.
+# TYPE bhojpur_sdr_stream_short_reads_total counter
+bhojpur_sdr_stream_short_reads_total{stream="rx",channel="0",driver="lime"} 1
+bhojpur_sdr_stream_short_reads_total{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_errors_total Number of non-specific stream errors.
+# TYPE bhojpur_sdr_stream_errors_total counter
+bhojpur_sdr_stream_errors_total{stream="rx",channel="0",driver="lime"} 0
+bhojpur_sdr_stream_errors_total{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_discontinuities_total Number of discontinuities found in the timestamps of the samples read.
+# TYPE bhojpur_sdr_stream_discontinuities_total counter
+bhojpur_sdr_stream_discontinuities_total{stream="rx",channel="0",driver="lime"} 1
+bhojpur_sdr_stream_discontinuities_total{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_samples_lost_total Number of samples missing in the gaps of the timestamps.
+# TYPE bhojpur_sdr_stream_samples_lost_total counter
+bhojpur_sdr_stream_samples_lost_total{stream="rx",channel="0",driver="lime"} 100
+bhojpur_sdr_stream_samples_lost_total{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_configured_sample_rate Sample rate configured on the stream in samples per second.
+# TYPE bhojpur_sdr_stream_configured_sample_rate gauge
+bhojpur_sdr_stream_configured_sample_rate{stream="rx",channel="0",driver="lime"} 2.4e+06
+bhojpur_sdr_stream_configured_sample_rate{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_stream_achieved_sample_rate Sample rate measured on the stream in samples per second.
+# TYPE bhojpur_sdr_stream_achieved_sample_rate gauge
+bhojpur_sdr_stream_achieved_sample_rate{stream="rx",channel="0",driver="lime"} 0
+bhojpur_sdr_stream_achieved_sample_rate{stream="tx",serial_number="a\"b\\c\nd"} 0
+# HELP bhojpur_sdr_ssi_indicators_total Number of streaming status indicators logged by SoapySDR.
+# TYPE bhojpur_sdr_ssi_indicators_total counter
+bhojpur_sdr_ssi_indicators_total{indicator="L"} 1
+bhojpur_sdr_ssi_indicators_total{indicator="O"} 2
+bhojpur_sdr_ssi_indicators_total{indicator="U"} 1
+`
+
+// newTestRegistry returns a registry with two streams and streaming status indicators
+func newTestRegistry() *Registry {
+
+	registry := NewRegistry()
+
+	rx := registry.Register("rx", map[string]string{"driver": "lime", "channel": "0"})
+	rx.SetConfiguredSampleRate(2.4e6)
+	rx.ObserveRead(1024, 1024, nil)
+	rx.ObserveRead(1024, 512, nil)
+	rx.ObserveRead(1024, 0, &sdrerror.Overflow{})
+	rx.ObserveDiscontinuity(100)
+
+	tx := registry.Register("tx", map[string]string{"serial-number": "a\"b\\c\nd", "stream": "ignored"})
+	tx.ObserveWrite(4096, 4096, nil)
+	tx.ObserveStatus(&sdrerror.Underflow{})
+	tx.ObserveStatus(&sdrerror.TimeError{})
+
+	registry.ObserveSSI("OOU\n")
+	registry.ObserveSSI(" L")
+
+	return registry
+}
+
+func TestWritePrometheus(t *testing.T) {
+
+	var buffer bytes.Buffer
+	if err := newTestRegistry().WritePrometheus(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := buffer.String(); actual != expectedMetrics {
+		t.Errorf("exposition:\n%s\nexpected:\n%s", actual, expectedMetrics)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+
+	recorder := httptest.NewRecorder()
+	newTestRegistry().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("content type %q", contentType)
+	}
+
+	if body := recorder.Body.String(); body != expectedMetrics {
+		t.Errorf("body:\n%s\nexpected:\n%s", body, expectedMetrics)
+	}
+}
+
+func TestRegister(t *testing.T) {
+
+	registry := NewRegistry()
+
+	labels := map[string]string{"driver": "rtlsdr"}
+	stats := registry.Register("rx", labels)
+	labels["driver"] = "changed"
+
+	if again := registry.Register("rx", nil); again != stats {
+		t.Fatal("a stream registered twice has different statistics")
+	}
+
+	var buffer bytes.Buffer
+	registry.WritePrometheus(&buffer)
+	if !bytes.Contains(buffer.Bytes(), []byte(`bhojpur_sdr_stream_samples_read_total{stream="rx",driver="rtlsdr"} 0`)) {
+		t.Errorf("the labels of the stream are not copied at registration:\n%s", buffer.String())
+	}
+
+	registry.Unregister("rx")
+	buffer.Reset()
+	registry.WritePrometheus(&buffer)
+	if bytes.Contains(buffer.Bytes(), []byte(`stream="rx"`)) {
+		t.Errorf("an unregistered stream is exported:\n%s", buffer.String())
+	}
+
+	if count := registry.SSICount("O"); count != 0 {
+		t.Errorf("%d overflow indicators, expected 0", count)
+	}
+}
@@ -0,0 +1,269 @@
+package sdrstats
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the health statistics of the streams: counters of samples and stream errors, and the
+// achieved versus configured sample rate.
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+)
+
+// rateWindow is the minimum duration over which the achieved sample rate is measured
+const rateWindow = time.Second
+
+// Stats holds the health counters and gauges of a single stream. All the methods are safe for concurrent use.
+type Stats struct {
+	samplesRead     uint64
+	samplesWritten  uint64
+	overflows       uint64
+	underflows      uint64
+	timeouts        uint64
+	timeErrors      uint64
+	corruptions     uint64
+	latePackets     uint64
+	shortReads      uint64
+	streamErrors    uint64
+	discontinuities uint64
+	samplesLost     uint64
+
+	// configuredRate and achievedRate are float64 bits, accessed atomically
+	configuredRate uint64
+	achievedRate   uint64
+
+	// The window used to measure the achieved sample rate
+	mutex         sync.Mutex
+	windowStart   time.Time
+	windowSamples uint64
+	now           func() time.Time
+}
+
+// Snapshot is a copy of the values of a Stats at a given time
+type Snapshot struct {
+	// SamplesRead is the number of elements read per channel
+	SamplesRead uint64
+	// SamplesWritten is the number of elements written per channel
+	SamplesWritten uint64
+	// Overflows is the number of overflows reported by read or by the stream status
+	Overflows uint64
+	// Underflows is the number of underflows reported by write or by the stream status
+	Underflows uint64
+	// Timeouts is the number of read or write operations that timed out
+	Timeouts uint64
+	// TimeErrors is the number of time errors reported by read or write
+	TimeErrors uint64
+	// CorruptedReads is the number of read operations that reported a data corruption
+	CorruptedReads uint64
+	// LatePackets is the number of time errors (late packets) reported by the stream status
+	LatePackets uint64
+	// ShortReads is the number of successful read operations returning less elements than requested
+	ShortReads uint64
+	// StreamErrors is the number of non-specific stream errors
+	StreamErrors uint64
+	// Discontinuities is the number of discontinuities found in the timestamps of the samples read
+	Discontinuities uint64
+	// SamplesLost is the number of samples missing in the gaps found in the timestamps of the samples read
+	SamplesLost uint64
+	// ConfiguredSampleRate is the sample rate configured on the channels of the stream, in samples per second
+	ConfiguredSampleRate float64
+	// AchievedSampleRate is the sample rate measured on the stream, in samples per second
+	AchievedSampleRate float64
+}
+
+// NewStats creates a new set of statistics for a stream
+func NewStats() *Stats {
+
+	return &Stats{now: time.Now}
+}
+
+// ObserveRead records the result of a read operation on the stream.
+//
+// Params:
+//  - requested: the number of elements requested by the read
+//  - read: the number of elements read per channel
+//  - err: the error returned by the read, if any
+func (stats *Stats) ObserveRead(requested uint, read uint, err error) {
+
+	if err != nil {
+		// A failed operation transfers no sample, so that the achieved rate decays toward 0 during a stall
+		stats.observeError(err, false)
+		stats.observeSamples(0)
+		return
+	}
+
+	atomic.AddUint64(&stats.samplesRead, uint64(read))
+	if read < requested {
+		atomic.AddUint64(&stats.shortReads, 1)
+	}
+
+	stats.observeSamples(uint64(read))
+}
+
+// ObserveWrite records the result of a write operation on the stream.
+//
+// Params:
+//  - requested: the number of elements given to the write
+//  - written: the number of elements written per channel
+//  - err: the error returned by the write, if any
+func (stats *Stats) ObserveWrite(requested uint, written uint, err error) {
+
+	if err != nil {
+		// A failed operation transfers no sample, so that the achieved rate decays toward 0 during a stall
+		stats.observeError(err, false)
+		stats.observeSamples(0)
+		return
+	}
+
+	atomic.AddUint64(&stats.samplesWritten, uint64(written))
+
+	stats.observeSamples(uint64(written))
+}
+
+// ObserveStatus records the result of a ReadStreamStatus call on the stream. Time errors reported by the status are
+// late packets.
+//
+// Params:
+//  - err: the error returned by the status, if any
+func (stats *Stats) ObserveStatus(err error) {
+
+	if err != nil {
+		stats.observeError(err, true)
+	}
+}
+
+// ObserveDiscontinuity records a discontinuity found in the timestamps of the samples read, such as one reported by a
+// sdrtime.Tracker.
+//
+// Params:
+//  - lost: the number of samples missing in the gap, 0 if no sample is missing or the number is unknown
+func (stats *Stats) ObserveDiscontinuity(lost uint64) {
+
+	atomic.AddUint64(&stats.discontinuities, 1)
+	atomic.AddUint64(&stats.samplesLost, lost)
+}
+
+// SetConfiguredSampleRate sets the sample rate configured on the channels of the stream.
+//
+// Params:
+//  - rate: the sample rate in samples per second
+func (stats *Stats) SetConfiguredSampleRate(rate float64) {
+
+	atomic.StoreUint64(&stats.configuredRate, math.Float64bits(rate))
+}
+
+// ConfiguredSampleRate returns the sample rate configured on the channels of the stream in samples per second
+func (stats *Stats) ConfiguredSampleRate() float64 {
+
+	return math.Float64frombits(atomic.LoadUint64(&stats.configuredRate))
+}
+
+// AchievedSampleRate returns the sample rate measured on the stream in samples per second. The rate is measured over
+// windows of at least one second, so it is zero until the stream has been running for one second. Operations that
+// fail, such as timeouts, count as transferring no sample, so the rate drops toward 0 while the stream stalls.
+func (stats *Stats) AchievedSampleRate() float64 {
+
+	return math.Float64frombits(atomic.LoadUint64(&stats.achievedRate))
+}
+
+// Snapshot returns a copy of the current values of the statistics
+func (stats *Stats) Snapshot() Snapshot {
+
+	return Snapshot{
+		SamplesRead:          atomic.LoadUint64(&stats.samplesRead),
+		SamplesWritten:       atomic.LoadUint64(&stats.samplesWritten),
+		Overflows:            atomic.LoadUint64(&stats.overflows),
+		Underflows:           atomic.LoadUint64(&stats.underflows),
+		Timeouts:             atomic.LoadUint64(&stats.timeouts),
+		TimeErrors:           atomic.LoadUint64(&stats.timeErrors),
+		CorruptedReads:       atomic.LoadUint64(&stats.corruptions),
+		LatePackets:          atomic.LoadUint64(&stats.latePackets),
+		ShortReads:           atomic.LoadUint64(&stats.shortReads),
+		StreamErrors:         atomic.LoadUint64(&stats.streamErrors),
+		Discontinuities:      atomic.LoadUint64(&stats.discontinuities),
+		SamplesLost:          atomic.LoadUint64(&stats.samplesLost),
+		ConfiguredSampleRate: stats.ConfiguredSampleRate(),
+		AchievedSampleRate:   stats.AchievedSampleRate(),
+	}
+}
+
+// observeError increments the counter matching the given error
+func (stats *Stats) observeError(err error, fromStatus bool) {
+
+	sdrErr, ok := err.(sdrerror.SDRError)
+	if !ok {
+		return
+	}
+
+	switch sdrErr.(type) {
+	case *sdrerror.Overflow:
+		atomic.AddUint64(&stats.overflows, 1)
+	case *sdrerror.Underflow:
+		atomic.AddUint64(&stats.underflows, 1)
+	case *sdrerror.Timeout:
+		// A timeout of the status only means that no event was reported
+		if !fromStatus {
+			atomic.AddUint64(&stats.timeouts, 1)
+		}
+	case *sdrerror.TimeError:
+		if fromStatus {
+			atomic.AddUint64(&stats.latePackets, 1)
+		} else {
+			atomic.AddUint64(&stats.timeErrors, 1)
+		}
+	case *sdrerror.Corruption:
+		atomic.AddUint64(&stats.corruptions, 1)
+	case *sdrerror.StreamError:
+		atomic.AddUint64(&stats.streamErrors, 1)
+	}
+}
+
+// observeSamples accumulates transferred samples and updates the achieved sample rate once per window
+func (stats *Stats) observeSamples(count uint64) {
+
+	now := stats.now()
+
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	if stats.windowStart.IsZero() {
+		stats.windowStart = now
+		stats.windowSamples = count
+		return
+	}
+
+	stats.windowSamples += count
+
+	elapsed := now.Sub(stats.windowStart)
+	if elapsed < rateWindow {
+		return
+	}
+
+	rate := float64(stats.windowSamples) / elapsed.Seconds()
+	atomic.StoreUint64(&stats.achievedRate, math.Float64bits(rate))
+
+	stats.windowStart = now
+	stats.windowSamples = 0
+}
@@ -0,0 +1,167 @@
+package sdrstats
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+)
+
+// fakeClock is a clock advanced by the tests, replacing time.Now in the rate measurements
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+// newTestStats creates statistics measuring the rate with a fake clock
+func newTestStats() (*Stats, *fakeClock) {
+
+	clock := &fakeClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	stats := NewStats()
+	stats.now = clock.Now
+
+	return stats, clock
+}
+
+func TestStatsCounters(t *testing.T) {
+
+	stats := NewStats()
+
+	stats.ObserveRead(100, 100, nil)
+	stats.ObserveRead(100, 50, nil)
+	stats.ObserveRead(100, 0, &sdrerror.Overflow{})
+	stats.ObserveRead(100, 0, &sdrerror.Timeout{})
+	stats.ObserveRead(100, 0, &sdrerror.TimeError{})
+	stats.ObserveRead(100, 0, &sdrerror.Corruption{})
+	stats.ObserveRead(100, 0, &sdrerror.StreamError{})
+	stats.ObserveRead(100, 0, errors.New("not a stream error"))
+
+	stats.ObserveWrite(200, 200, nil)
+	stats.ObserveWrite(200, 0, &sdrerror.Underflow{})
+	stats.ObserveWrite(200, 0, &sdrerror.Timeout{})
+
+	stats.ObserveStatus(nil)
+	stats.ObserveStatus(&sdrerror.Timeout{})
+	stats.ObserveStatus(&sdrerror.TimeError{})
+	stats.ObserveStatus(&sdrerror.Underflow{})
+	stats.ObserveStatus(&sdrerror.Overflow{})
+
+	stats.ObserveDiscontinuity(30)
+	stats.ObserveDiscontinuity(0)
+
+	stats.SetConfiguredSampleRate(2.4e6)
+
+	expected := Snapshot{
+		SamplesRead:          150,
+		SamplesWritten:       200,
+		Overflows:            2,
+		Underflows:           2,
+		Timeouts:             2,
+		TimeErrors:           1,
+		CorruptedReads:       1,
+		LatePackets:          1,
+		ShortReads:           1,
+		StreamErrors:         1,
+		Discontinuities:      2,
+		SamplesLost:          30,
+		ConfiguredSampleRate: 2.4e6,
+	}
+
+	if snapshot := stats.Snapshot(); snapshot != expected {
+		t.Errorf("snapshot %+v, expected %+v", snapshot, expected)
+	}
+}
+
+func TestStatsAchievedRate(t *testing.T) {
+
+	stats, clock := newTestStats()
+
+	// The first operation starts the window, the rate is only measured after a second
+	stats.ObserveRead(1000, 1000, nil)
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	stats.ObserveRead(1000, 500, nil)
+
+	if rate := stats.AchievedSampleRate(); rate != 0 {
+		t.Fatalf("rate %v before the end of the first window, expected 0", rate)
+	}
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	stats.ObserveRead(1000, 500, nil)
+
+	if rate := stats.AchievedSampleRate(); rate != 2000 {
+		t.Fatalf("rate %v, expected 2000", rate)
+	}
+
+	// The window lasts until a second after its start, however many operations it holds
+	clock.now = clock.now.Add(2 * time.Second)
+	stats.ObserveWrite(3000, 3000, nil)
+
+	if rate := stats.AchievedSampleRate(); rate != 1500 {
+		t.Fatalf("rate %v over 2 seconds, expected 1500", rate)
+	}
+}
+
+func TestStatsRateDecay(t *testing.T) {
+
+	stats, clock := newTestStats()
+
+	stats.ObserveRead(1000, 1000, nil)
+	clock.now = clock.now.Add(time.Second)
+	stats.ObserveRead(1000, 1000, nil)
+
+	if rate := stats.AchievedSampleRate(); rate != 2000 {
+		t.Fatalf("rate %v, expected 2000", rate)
+	}
+
+	// A stalled stream only fails its operations, the rate drops to 0 after a window without samples
+	for i := 0; i < 4; i++ {
+		clock.now = clock.now.Add(250 * time.Millisecond)
+		stats.ObserveRead(1000, 0, &sdrerror.Timeout{})
+	}
+
+	if rate := stats.AchievedSampleRate(); rate != 0 {
+		t.Fatalf("rate %v after a stall, expected 0", rate)
+	}
+
+	// Half of the operations of the window fail
+	for i := 0; i < 4; i++ {
+		clock.now = clock.now.Add(250 * time.Millisecond)
+		if i%2 == 0 {
+			stats.ObserveWrite(1000, 0, &sdrerror.Underflow{})
+		} else {
+			stats.ObserveWrite(1000, 1000, nil)
+		}
+	}
+
+	if rate := stats.AchievedSampleRate(); rate != 2000 {
+		t.Fatalf("rate %v after the recovery, expected 2000", rate)
+	}
+
+	if timeouts := stats.Snapshot().Timeouts; timeouts != 4 {
+		t.Fatalf("%d timeouts, expected 4", timeouts)
+	}
+}
@@ -0,0 +1,149 @@
+package sdrtime
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Clock maps the hardware time of a device to the index of the samples of a stream and to the wall-clock time.
+//
+// A clock is defined by a reference: the hardware time of the sample of index 0 and the wall-clock time matching this
+// hardware time, for example the time of the first sample of a recording and the local time at which it was received.
+type Clock struct {
+	rate           float64
+	hardwareTimeNs int64
+	wallTime       time.Time
+}
+
+// NewClock creates a clock for a given sample rate.
+//
+// Params:
+//  - rate: the sample rate in samples per second, which must be positive
+//  - hardwareTimeNs: the hardware time in nanoseconds of the sample of index 0
+//  - wallTime: the wall-clock time matching the hardware time
+//
+// Return the clock or an error if the rate is not positive
+func NewClock(rate float64, hardwareTimeNs int64, wallTime time.Time) (clock Clock, err error) {
+
+	if !(rate > 0) {
+		return clock, errors.New("the rate of a clock must be positive")
+	}
+
+	return Clock{
+		rate:           rate,
+		hardwareTimeNs: hardwareTimeNs,
+		wallTime:       wallTime,
+	}, nil
+}
+
+// Rate returns the sample rate of the clock in samples per second
+func (clock Clock) Rate() float64 {
+	return clock.rate
+}
+
+// ReferenceTimeNs returns the hardware time in nanoseconds of the sample of index 0
+func (clock Clock) ReferenceTimeNs() int64 {
+	return clock.hardwareTimeNs
+}
+
+// ReferenceWallTime returns the wall-clock time of the sample of index 0
+func (clock Clock) ReferenceWallTime() time.Time {
+	return clock.wallTime
+}
+
+// SampleIndex returns the index of the sample at a hardware time, rounded to the nearest sample.
+//
+// Params:
+//  - hardwareTimeNs: the hardware time in nanoseconds
+//
+// Return the index of the sample, negative for a time before the reference
+func (clock Clock) SampleIndex(hardwareTimeNs int64) int64 {
+
+	return TimeNsToTicks64(addSaturate(hardwareTimeNs, negateSaturate(clock.hardwareTimeNs)), clock.rate)
+}
+
+// HardwareTimeNs returns the hardware time of a sample.
+//
+// Params:
+//  - index: the index of the sample
+//
+// Return the hardware time in nanoseconds
+func (clock Clock) HardwareTimeNs(index int64) int64 {
+
+	return addSaturate(clock.hardwareTimeNs, TicksToTimeNs64(index, clock.rate))
+}
+
+// WallTime returns the wall-clock time matching a hardware time.
+//
+// Params:
+//  - hardwareTimeNs: the hardware time in nanoseconds
+//
+// Return the wall-clock time
+func (clock Clock) WallTime(hardwareTimeNs int64) time.Time {
+
+	return clock.wallTime.Add(time.Duration(addSaturate(hardwareTimeNs, negateSaturate(clock.hardwareTimeNs))))
+}
+
+// SampleWallTime returns the wall-clock time of a sample.
+//
+// Params:
+//  - index: the index of the sample
+//
+// Return the wall-clock time
+func (clock Clock) SampleWallTime(index int64) time.Time {
+
+	return clock.wallTime.Add(TicksToDuration(index, clock.rate))
+}
+
+// HardwareTimeAt returns the hardware time matching a wall-clock time.
+//
+// Params:
+//  - wallTime: the wall-clock time
+//
+// Return the hardware time in nanoseconds
+func (clock Clock) HardwareTimeAt(wallTime time.Time) int64 {
+
+	return addSaturate(clock.hardwareTimeNs, int64(wallTime.Sub(clock.wallTime)))
+}
+
+// SampleIndexAt returns the index of the sample at a wall-clock time, rounded to the nearest sample.
+//
+// Params:
+//  - wallTime: the wall-clock time
+//
+// Return the index of the sample, negative for a time before the reference
+func (clock Clock) SampleIndexAt(wallTime time.Time) int64 {
+
+	return DurationToTicks(wallTime.Sub(clock.wallTime), clock.rate)
+}
+
+// negateSaturate negates an integer, saturating at the limits of int64
+func negateSaturate(value int64) int64 {
+
+	if value == math.MinInt64 {
+		return math.MaxInt64
+	}
+
+	return -value
+}
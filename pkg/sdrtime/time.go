@@ -21,11 +21,17 @@ package sdrtime
 // THE SOFTWARE.
 
 // It groups utility functions to convert time and ticks.
+//
+// The conversions are a pure Go implementation of the SoapySDR_ticksToTimeNs and SoapySDR_timeNsToTicks functions of
+// the SoapySDR library, giving the same results. The integer part of the rate is handled with integer arithmetic and
+// only the fractional part with floating point, so that the conversions stay exact for large tick counts.
+
+import (
+	"math"
+	"time"
+)
 
-// #cgo CFLAGS: -g -Wall
-// #cgo LDFLAGS: -lSoapySDR
-// #include <SoapySDR/Time.h>
-import "C"
+const nsPerSecond = int64(time.Second)
 
 // TicksToTimeNs converts a tick count into a time in nanoseconds using the tick rate.
 //
@@ -36,7 +42,7 @@ import "C"
 // Return the time in nanoseconds
 func TicksToTimeNs(ticks int, rate float64) int {
 
-	return int(C.SoapySDR_ticksToTimeNs(C.longlong(ticks), C.double(rate)))
+	return int(TicksToTimeNs64(int64(ticks), rate))
 }
 
 // TimeNsToTicks converts a time in nanoseconds into a tick count using the tick rate.
@@ -48,5 +54,121 @@ func TicksToTimeNs(ticks int, rate float64) int {
 // Return the integer tick count
 func TimeNsToTicks(timeNs int, rate float64) int {
 
-	return int(C.SoapySDR_timeNsToTicks(C.longlong(timeNs), C.double(rate)))
+	return int(TimeNsToTicks64(int64(timeNs), rate))
+}
+
+// TicksToTimeNs64 converts a tick count into a time in nanoseconds using the tick rate. The result saturates at the
+// limits of int64 instead of overflowing.
+//
+// Params:
+//  - ticks: a integer tick count
+//  - rate: the ticks per second, which must be positive
+//
+// Return the time in nanoseconds
+func TicksToTimeNs64(ticks int64, rate float64) int64 {
+
+	rateInt := int64(rate)
+
+	// The full seconds are computed with integers, the remaining ticks and the fractional part of the rate as float
+	full := int64(0)
+	if rateInt != 0 {
+		full = ticks / rateInt
+	}
+	remainder := ticks - full*rateInt
+	part := float64(full) * (rate - float64(rateInt))
+	frac := ((float64(remainder) - part) * float64(nsPerSecond)) / rate
+
+	return saturate(float64(ticks)*float64(nsPerSecond)/rate, full*nsPerSecond+roundSaturate(frac))
+}
+
+// TimeNsToTicks64 converts a time in nanoseconds into a tick count using the tick rate. The result saturates at the
+// limits of int64 instead of overflowing.
+//
+// Params:
+//  - timeNs: time in nanoseconds
+//  - rate: the ticks per second, which must be positive
+//
+// Return the integer tick count
+func TimeNsToTicks64(timeNs int64, rate float64) int64 {
+
+	rateInt := int64(rate)
+
+	// The full seconds are computed with integers, the remaining nanoseconds and the fractional part of the rate as
+	// float
+	full := timeNs / nsPerSecond
+	remainder := timeNs - full*nsPerSecond
+	part := float64(full) * (rate - float64(rateInt))
+	frac := part + (float64(remainder)*rate)/float64(nsPerSecond)
+
+	return saturate(float64(timeNs)*rate/float64(nsPerSecond), full*rateInt+roundSaturate(frac))
+}
+
+// TicksToDuration converts a tick count into a duration using the tick rate.
+//
+// Params:
+//  - ticks: a integer tick count
+//  - rate: the ticks per second, which must be positive
+//
+// Return the duration of the ticks
+func TicksToDuration(ticks int64, rate float64) time.Duration {
+
+	return time.Duration(TicksToTimeNs64(ticks, rate))
+}
+
+// DurationToTicks converts a duration into a tick count using the tick rate.
+//
+// Params:
+//  - duration: the duration to convert
+//  - rate: the ticks per second, which must be positive
+//
+// Return the integer tick count
+func DurationToTicks(duration time.Duration, rate float64) int64 {
+
+	return TimeNsToTicks64(int64(duration), rate)
+}
+
+// saturate returns the result of a conversion, or the limit of int64 when the estimate of the result shows that it
+// does not fit in an int64. The intermediate products of the conversion may wrap around, but the result is exact as long
+// as it fits.
+func saturate(estimate float64, result int64) int64 {
+
+	switch {
+	case estimate >= math.MaxInt64:
+		return math.MaxInt64
+	case estimate <= math.MinInt64:
+		return math.MinInt64
+	}
+
+	return result
+}
+
+// addSaturate adds two integers, saturating at the limits of int64
+func addSaturate(a int64, b int64) int64 {
+
+	if b > 0 && a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+
+	if b < 0 && a < math.MinInt64-b {
+		return math.MinInt64
+	}
+
+	return a + b
+}
+
+// roundSaturate rounds a float half away from zero, as llround, saturating at the limits of int64
+func roundSaturate(value float64) int64 {
+
+	value = math.Round(value)
+
+	switch {
+	case math.IsNaN(value):
+		return 0
+	case value >= math.MaxInt64:
+		return math.MaxInt64
+	case value <= math.MinInt64:
+		return math.MinInt64
+	}
+
+	return int64(value)
 }
@@ -0,0 +1,176 @@
+package sdrtime
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/sdrtime/internal/soapytime"
+)
+
+// referenceLimit bounds the results compared with SoapySDR, whose conversions overflow near the limits of int64
+const referenceLimit = 9e18
+
+// secondsPerDay is the number of seconds of a day, for the tick counts of long running streams
+const secondsPerDay = 86400
+
+// testRates returns usual sample rates, fractional rates and random rates
+func testRates(random *rand.Rand) []float64 {
+
+	rates := []float64{1, 1000, 48000, 250e3, 1e6 / 3, 2.4e6, 30.72e6, 61.44e6 / 7, 122.88e6, 1e9, 2e9 / 3, 3.2e9}
+	for i := 0; i < 200; i++ {
+		// Integer rates up to 1 GHz, then any rate above 1 Hz
+		rates = append(rates, math.Floor(math.Pow(10, 9*random.Float64())))
+		rates = append(rates, 1+math.Pow(10, 9.5*random.Float64()))
+	}
+
+	return rates
+}
+
+// randomCount returns a random count of units spanning up to 30 days, of both signs
+func randomCount(random *rand.Rand, perSecond float64) int64 {
+
+	span := perSecond * 30 * secondsPerDay
+	count := int64(math.Pow(span, random.Float64()))
+	if random.Intn(2) == 0 {
+		count = -count
+	}
+
+	return count
+}
+
+// TestTicksToTimeNsReference compares the conversion of ticks to SoapySDR_ticksToTimeNs over random rates and tick
+// counts up to several days
+func TestTicksToTimeNsReference(t *testing.T) {
+
+	random := rand.New(rand.NewSource(1))
+
+	for _, rate := range testRates(random) {
+		for i := 0; i < 500; i++ {
+
+			ticks := randomCount(random, rate)
+			if math.Abs(float64(ticks)*1e9/rate) > referenceLimit {
+				continue
+			}
+
+			expected := soapytime.TicksToTimeNs(ticks, rate)
+			if actual := TicksToTimeNs64(ticks, rate); actual != expected {
+				t.Fatalf("TicksToTimeNs64(%d, %v) = %d, SoapySDR gives %d", ticks, rate, actual, expected)
+			}
+			if actual := TicksToTimeNs(int(ticks), rate); int64(actual) != expected {
+				t.Fatalf("TicksToTimeNs(%d, %v) = %d, SoapySDR gives %d", ticks, rate, actual, expected)
+			}
+		}
+	}
+}
+
+// TestTimeNsToTicksReference compares the conversion of times to SoapySDR_timeNsToTicks over random rates and times up
+// to several days
+func TestTimeNsToTicksReference(t *testing.T) {
+
+	random := rand.New(rand.NewSource(2))
+
+	for _, rate := range testRates(random) {
+		for i := 0; i < 500; i++ {
+
+			timeNs := randomCount(random, 1e9)
+			if math.Abs(float64(timeNs)*rate/1e9) > referenceLimit {
+				continue
+			}
+
+			expected := soapytime.TimeNsToTicks(timeNs, rate)
+			if actual := TimeNsToTicks64(timeNs, rate); actual != expected {
+				t.Fatalf("TimeNsToTicks64(%d, %v) = %d, SoapySDR gives %d", timeNs, rate, actual, expected)
+			}
+			if actual := TimeNsToTicks(int(timeNs), rate); int64(actual) != expected {
+				t.Fatalf("TimeNsToTicks(%d, %v) = %d, SoapySDR gives %d", timeNs, rate, actual, expected)
+			}
+		}
+	}
+}
+
+// TestRoundTrip checks that the ticks are recovered from their time when a tick is longer than a nanosecond
+func TestRoundTrip(t *testing.T) {
+
+	random := rand.New(rand.NewSource(3))
+
+	for _, rate := range testRates(random) {
+		if rate >= 1e9 {
+			continue
+		}
+		for i := 0; i < 500; i++ {
+			ticks := randomCount(random, rate)
+			if actual := TimeNsToTicks64(TicksToTimeNs64(ticks, rate), rate); actual != ticks {
+				t.Fatalf("%d ticks at %v Hz give back %d ticks", ticks, rate, actual)
+			}
+		}
+	}
+}
+
+// TestExtremes checks the conversions at the limits of int64: the results saturate instead of overflowing, match
+// SoapySDR when it does not overflow, and are monotonic
+func TestExtremes(t *testing.T) {
+
+	rates := []float64{1, 1000, 1e6 / 3, 30.72e6, 1e9 - 0.5, 1e9, 1e9 + 0.5, 2e9 / 3 * 2, 3.2e9, 1e12}
+
+	for _, rate := range rates {
+		for _, count := range []int64{math.MaxInt64, math.MinInt64, math.MaxInt64 - 1, math.MinInt64 + 1} {
+
+			timeNs := TicksToTimeNs64(count, rate)
+			estimate := float64(count) * 1e9 / rate
+			switch {
+			case estimate >= math.MaxInt64 && timeNs != math.MaxInt64:
+				t.Errorf("TicksToTimeNs64(%d, %v) = %d, expected to saturate at the maximum", count, rate, timeNs)
+			case estimate <= math.MinInt64 && timeNs != math.MinInt64:
+				t.Errorf("TicksToTimeNs64(%d, %v) = %d, expected to saturate at the minimum", count, rate, timeNs)
+			case math.Abs(estimate) < referenceLimit && timeNs != soapytime.TicksToTimeNs(count, rate):
+				t.Errorf("TicksToTimeNs64(%d, %v) = %d, SoapySDR gives %d", count, rate, timeNs,
+					soapytime.TicksToTimeNs(count, rate))
+			}
+
+			ticks := TimeNsToTicks64(count, rate)
+			estimate = float64(count) * rate / 1e9
+			switch {
+			case estimate >= math.MaxInt64 && ticks != math.MaxInt64:
+				t.Errorf("TimeNsToTicks64(%d, %v) = %d, expected to saturate at the maximum", count, rate, ticks)
+			case estimate <= math.MinInt64 && ticks != math.MinInt64:
+				t.Errorf("TimeNsToTicks64(%d, %v) = %d, expected to saturate at the minimum", count, rate, ticks)
+			case math.Abs(estimate) < referenceLimit && ticks != soapytime.TimeNsToTicks(count, rate):
+				t.Errorf("TimeNsToTicks64(%d, %v) = %d, SoapySDR gives %d", count, rate, ticks,
+					soapytime.TimeNsToTicks(count, rate))
+			}
+		}
+
+		// No wrap around near the limits
+		for _, start := range []int64{math.MaxInt64 - 1000, math.MinInt64} {
+			previousNs, previousTicks := TicksToTimeNs64(start, rate), TimeNsToTicks64(start, rate)
+			for count := start + 1; count <= start+1000 && count > start; count++ {
+				timeNs, ticks := TicksToTimeNs64(count, rate), TimeNsToTicks64(count, rate)
+				if timeNs < previousNs || ticks < previousTicks {
+					t.Fatalf("the conversions at %v Hz decrease at %d", rate, count)
+				}
+				previousNs, previousTicks = timeNs, ticks
+			}
+		}
+	}
+}
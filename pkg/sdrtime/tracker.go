@@ -0,0 +1,221 @@
+package sdrtime
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DiscontinuityKind is the kind of a discontinuity between two blocks of samples of a stream
+type DiscontinuityKind int
+
+const (
+	// DiscontinuityGap indicates that samples are missing between the previous block and the current one
+	DiscontinuityGap DiscontinuityKind = iota
+	// DiscontinuityDuplicate indicates that the current block starts before the end of the previous one, its first
+	// samples repeating samples already received
+	DiscontinuityDuplicate
+	// DiscontinuityRegression indicates that the current block starts before the start of the previous one: the time
+	// of the stream went backwards, for example after the hardware time was set
+	DiscontinuityRegression
+)
+
+// String returns a human readable name of the discontinuity kind
+func (kind DiscontinuityKind) String() string {
+
+	switch kind {
+	case DiscontinuityGap:
+		return "gap"
+	case DiscontinuityDuplicate:
+		return "duplicate"
+	case DiscontinuityRegression:
+		return "regression"
+	}
+
+	return fmt.Sprintf("DiscontinuityKind(%d)", int(kind))
+}
+
+// Discontinuity describes a discontinuity detected between two blocks of samples of a stream
+type Discontinuity struct {
+	// Kind is the kind of the discontinuity
+	Kind DiscontinuityKind
+	// SampleIndex is the number of samples received before the block starting after the discontinuity. It is the
+	// position in the received samples where the missing samples must be inserted.
+	SampleIndex int64
+	// ExpectedTimeNs is the hardware time in nanoseconds expected for the block, following the previous block
+	ExpectedTimeNs int64
+	// TimeNs is the hardware time in nanoseconds of the block
+	TimeNs int64
+	// Samples is the number of samples lost for a gap, or the number of samples repeated for a duplicate or a
+	// regression
+	Samples int64
+}
+
+// Tracker detects the discontinuities of a stream from the timestamps of its blocks of samples, such as the timeNs
+// returned by the Read function of the streams. It detects the samples lost even when the driver does not report an
+// overflow.
+type Tracker struct {
+	rate      float64
+	tolerance int64
+
+	started     bool
+	originNs    int64
+	originCount int64
+	lastTimeNs  int64
+	count       int64
+	lost        int64
+	events      int64
+}
+
+// NewTracker creates a tracker for a stream.
+//
+// Params:
+//  - rate: the sample rate of the stream in samples per second
+//  - tolerance: the number of samples of difference between the expected and the actual position of a block that is
+//    not considered as a discontinuity, to absorb the rounding of the timestamps. 0 reports any difference.
+//
+// Return the tracker or an error
+func NewTracker(rate float64, tolerance int64) (tracker *Tracker, err error) {
+
+	if !(rate > 0) {
+		return nil, errors.New("the rate of a tracker must be positive")
+	}
+
+	if tolerance < 0 {
+		return nil, errors.New("the tolerance of a tracker can not be negative")
+	}
+
+	return &Tracker{
+		rate:      rate,
+		tolerance: tolerance,
+	}, nil
+}
+
+// Observe checks the position of a new block of samples against the previous blocks.
+//
+// Params:
+//  - timeNs: the hardware time in nanoseconds of the first sample of the block
+//  - hasTime: whether the timestamp is valid, usually the StreamFlagHasTime of the read flags. Blocks without a valid
+//    timestamp are assumed to follow the previous block.
+//  - nbSamples: the number of samples of the block
+//
+// Return the discontinuity before the block and true if one is detected, false otherwise
+func (tracker *Tracker) Observe(timeNs int64, hasTime bool, nbSamples int64) (discontinuity Discontinuity, found bool) {
+
+	defer func() {
+		tracker.count += nbSamples
+	}()
+
+	if !hasTime {
+		return discontinuity, false
+	}
+
+	if !tracker.started {
+		tracker.resync(timeNs)
+		tracker.started = true
+		return discontinuity, false
+	}
+
+	// The expected time is computed from the last resynchronization, so that the rounding does not accumulate
+	expectedNs := tracker.originNs + TicksToTimeNs64(tracker.count-tracker.originCount, tracker.rate)
+	delta := TimeNsToTicks64(timeNs-expectedNs, tracker.rate)
+
+	if delta <= tracker.tolerance && delta >= -tracker.tolerance {
+		tracker.lastTimeNs = timeNs
+		return discontinuity, false
+	}
+
+	discontinuity = Discontinuity{
+		SampleIndex:    tracker.count,
+		ExpectedTimeNs: expectedNs,
+		TimeNs:         timeNs,
+	}
+
+	switch {
+	case delta > 0:
+		discontinuity.Kind = DiscontinuityGap
+		discontinuity.Samples = delta
+		tracker.lost += delta
+	case timeNs < tracker.lastTimeNs:
+		discontinuity.Kind = DiscontinuityRegression
+		discontinuity.Samples = -delta
+	default:
+		discontinuity.Kind = DiscontinuityDuplicate
+		discontinuity.Samples = -delta
+	}
+
+	tracker.events++
+	tracker.resync(timeNs)
+
+	return discontinuity, true
+}
+
+// Reset forgets the previous blocks, for example after the stream was restarted. The counters are kept.
+func (tracker *Tracker) Reset() {
+	tracker.started = false
+}
+
+// SetRate changes the sample rate of the stream. The next block is not compared to the previous ones.
+//
+// Params:
+//  - rate: the sample rate of the stream in samples per second
+//
+// Return an error if the rate is not positive
+func (tracker *Tracker) SetRate(rate float64) error {
+
+	if !(rate > 0) {
+		return errors.New("the rate of a tracker must be positive")
+	}
+
+	tracker.rate = rate
+	tracker.started = false
+
+	return nil
+}
+
+// Rate returns the sample rate of the stream in samples per second
+func (tracker *Tracker) Rate() float64 {
+	return tracker.rate
+}
+
+// SamplesReceived returns the number of samples observed
+func (tracker *Tracker) SamplesReceived() int64 {
+	return tracker.count
+}
+
+// SamplesLost returns the number of samples lost in the gaps detected
+func (tracker *Tracker) SamplesLost() int64 {
+	return tracker.lost
+}
+
+// Discontinuities returns the number of discontinuities detected
+func (tracker *Tracker) Discontinuities() int64 {
+	return tracker.events
+}
+
+// resync makes a block the new reference of the expected times
+func (tracker *Tracker) resync(timeNs int64) {
+
+	tracker.originNs = timeNs
+	tracker.originCount = tracker.count
+	tracker.lastTimeNs = timeNs
+}
@@ -0,0 +1,240 @@
+package sdrtime
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"testing"
+)
+
+// trackerBlock is a block of samples observed by a tracker, with the discontinuity expected before it
+type trackerBlock struct {
+	timeNs    int64
+	hasTime   bool
+	nbSamples int64
+	found     bool
+	kind      DiscontinuityKind
+	samples   int64
+}
+
+// timed returns a block with a timestamp, without discontinuity before it
+func timed(timeNs int64, nbSamples int64) trackerBlock {
+	return trackerBlock{timeNs: timeNs, hasTime: true, nbSamples: nbSamples}
+}
+
+// untimed returns a block without timestamp
+func untimed(nbSamples int64) trackerBlock {
+	return trackerBlock{nbSamples: nbSamples}
+}
+
+// after returns a block with a timestamp following a discontinuity
+func after(timeNs int64, nbSamples int64, kind DiscontinuityKind, samples int64) trackerBlock {
+	return trackerBlock{timeNs: timeNs, hasTime: true, nbSamples: nbSamples, found: true, kind: kind, samples: samples}
+}
+
+// TestTracker checks the discontinuities detected in sequences of blocks at 1 Msps
+func TestTracker(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		tolerance int64
+		blocks    []trackerBlock
+		lost      int64
+	}{
+		{"contiguous", 0, []trackerBlock{timed(5000, 100), timed(105000, 100), timed(205000, 50), timed(255000, 10)}, 0},
+		{"gap", 0, []trackerBlock{timed(0, 100), after(150000, 100, DiscontinuityGap, 50), timed(250000, 100)}, 50},
+		{"gaps", 0, []trackerBlock{timed(0, 100), after(101000, 100, DiscontinuityGap, 1),
+			after(301000, 100, DiscontinuityGap, 100)}, 101},
+		{"duplicate", 0, []trackerBlock{timed(0, 100), timed(100000, 100),
+			after(150000, 100, DiscontinuityDuplicate, 50), timed(250000, 100)}, 0},
+		{"regression", 0, []trackerBlock{timed(0, 100), timed(100000, 100),
+			after(50000, 100, DiscontinuityRegression, 150), timed(150000, 100)}, 0},
+		{"duplicate of the whole block", 0, []trackerBlock{timed(0, 100), timed(100000, 100),
+			after(100000, 100, DiscontinuityDuplicate, 100)}, 0},
+		{"within the tolerance", 1, []trackerBlock{timed(0, 100), timed(101000, 100), timed(199000, 100),
+			timed(300000, 100)}, 0},
+		{"beyond the tolerance", 1, []trackerBlock{timed(0, 100), timed(101000, 100),
+			after(202000, 100, DiscontinuityGap, 2), after(300000, 100, DiscontinuityDuplicate, 2)}, 2},
+		{"half sample rounding", 0, []trackerBlock{timed(0, 100), timed(100400, 100), timed(199600, 100),
+			after(300600, 100, DiscontinuityGap, 1)}, 1},
+		{"blocks without timestamp", 0, []trackerBlock{timed(0, 100), untimed(100), timed(200000, 100), untimed(100),
+			after(450000, 100, DiscontinuityGap, 50)}, 50},
+		{"first blocks without timestamp", 0, []trackerBlock{untimed(100), untimed(100), timed(7000000, 100),
+			timed(7100000, 100)}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			tracker, err := NewTracker(1e6, test.tolerance)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			count := int64(0)
+			events := int64(0)
+			for i, block := range test.blocks {
+
+				discontinuity, found := tracker.Observe(block.timeNs, block.hasTime, block.nbSamples)
+				if found != block.found {
+					t.Fatalf("block %d: discontinuity %v (%+v), expected %v", i, found, discontinuity, block.found)
+				}
+
+				if found {
+					events++
+					if discontinuity.Kind != block.kind || discontinuity.Samples != block.samples {
+						t.Errorf("block %d: %v of %d samples, expected %v of %d samples", i, discontinuity.Kind,
+							discontinuity.Samples, block.kind, block.samples)
+					}
+					if discontinuity.SampleIndex != count {
+						t.Errorf("block %d: discontinuity at sample %d, expected %d", i, discontinuity.SampleIndex, count)
+					}
+					if discontinuity.TimeNs != block.timeNs {
+						t.Errorf("block %d: discontinuity at %d ns, expected %d ns", i, discontinuity.TimeNs, block.timeNs)
+					}
+				}
+
+				count += block.nbSamples
+			}
+
+			if tracker.SamplesReceived() != count {
+				t.Errorf("%d samples received, expected %d", tracker.SamplesReceived(), count)
+			}
+			if tracker.SamplesLost() != test.lost {
+				t.Errorf("%d samples lost, expected %d", tracker.SamplesLost(), test.lost)
+			}
+			if tracker.Discontinuities() != events {
+				t.Errorf("%d discontinuities, expected %d", tracker.Discontinuities(), events)
+			}
+		})
+	}
+}
+
+// TestTrackerExpectedTime checks the time expected for a block after a gap
+func TestTrackerExpectedTime(t *testing.T) {
+
+	tracker, err := NewTracker(1e6, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker.Observe(1000000, true, 100)
+	tracker.Observe(1100000, true, 100)
+	discontinuity, found := tracker.Observe(1500000, true, 100)
+
+	if !found || discontinuity.ExpectedTimeNs != 1200000 || discontinuity.Samples != 300 {
+		t.Errorf("discontinuity %+v, expected a gap of 300 samples after 1200000 ns", discontinuity)
+	}
+
+	// The block after the gap is the new reference
+	if discontinuity, found := tracker.Observe(1600000, true, 100); found {
+		t.Errorf("unexpected discontinuity %+v", discontinuity)
+	}
+}
+
+// TestTrackerReset checks that the block following a reset or a change of rate is not compared to the previous ones
+func TestTrackerReset(t *testing.T) {
+
+	tracker, err := NewTracker(1e6, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker.Observe(0, true, 100)
+	tracker.Observe(300000, true, 100)
+
+	tracker.Reset()
+	if discontinuity, found := tracker.Observe(10000000, true, 100); found {
+		t.Errorf("discontinuity %+v after the reset", discontinuity)
+	}
+	if discontinuity, found := tracker.Observe(10100000, true, 100); found {
+		t.Errorf("discontinuity %+v after the first block following the reset", discontinuity)
+	}
+
+	// The counters are kept
+	if tracker.SamplesReceived() != 400 || tracker.SamplesLost() != 200 || tracker.Discontinuities() != 1 {
+		t.Errorf("counters %d, %d, %d after the reset, expected 400, 200, 1", tracker.SamplesReceived(),
+			tracker.SamplesLost(), tracker.Discontinuities())
+	}
+
+	if err := tracker.SetRate(2e6); err != nil {
+		t.Fatal(err)
+	}
+	if tracker.Rate() != 2e6 {
+		t.Errorf("rate %v, expected 2e6", tracker.Rate())
+	}
+
+	// 100 samples last 50 us at the new rate
+	if discontinuity, found := tracker.Observe(20000000, true, 100); found {
+		t.Errorf("discontinuity %+v after the change of rate", discontinuity)
+	}
+	if discontinuity, found := tracker.Observe(20050000, true, 100); found {
+		t.Errorf("discontinuity %+v at the new rate", discontinuity)
+	}
+	if _, found := tracker.Observe(20150000, true, 100); !found {
+		t.Error("no gap detected at the new rate")
+	}
+
+	if err := tracker.SetRate(0); err == nil {
+		t.Error("expected an error for a zero rate")
+	}
+	if tracker.Rate() != 2e6 {
+		t.Errorf("rate %v after an invalid rate, expected 2e6", tracker.Rate())
+	}
+}
+
+// TestTrackerFractionalRate checks that the rounding of the timestamps to nanoseconds does not accumulate at rates
+// whose sample period is not a whole number of nanoseconds
+func TestTrackerFractionalRate(t *testing.T) {
+
+	for _, rate := range []float64{1e6 / 3, 2.4e6, 44100, 61.44e6 / 7} {
+
+		tracker, err := NewTracker(rate, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Blocks of varying sizes, each timestamp rounded to the nanosecond independently, as a driver does
+		const startNs = 123456789
+		index := int64(0)
+		for block := 0; block < 100000; block++ {
+
+			nbSamples := int64(1000 + block%37)
+			timeNs := startNs + TicksToTimeNs64(index, rate)
+
+			if discontinuity, found := tracker.Observe(timeNs, true, nbSamples); found {
+				t.Fatalf("rate %v: discontinuity %+v at block %d", rate, discontinuity, block)
+			}
+
+			index += nbSamples
+		}
+	}
+}
+
+// TestNewTracker checks the parameters of the trackers
+func TestNewTracker(t *testing.T) {
+
+	if _, err := NewTracker(0, 0); err == nil {
+		t.Error("expected an error for a zero rate")
+	}
+	if _, err := NewTracker(1e6, -1); err == nil {
+		t.Error("expected an error for a negative tolerance")
+	}
+}
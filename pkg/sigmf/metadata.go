@@ -0,0 +1,195 @@
+package sigmf
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the functions to record and read SigMF (Signal Metadata Format) recordings. A recording is made of a
+// ".sigmf-data" file holding the samples and a ".sigmf-meta" JSON file describing them.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+const (
+	// Version is the version of the SigMF specification of the recordings
+	Version = "1.0.0"
+	// DataExtension is the extension of the data files
+	DataExtension = ".sigmf-data"
+	// MetaExtension is the extension of the metadata files
+	MetaExtension = ".sigmf-meta"
+)
+
+// Global is the global object of the metadata of a recording
+type Global struct {
+	// Datatype is the format of the samples, such as "cf32_le"
+	Datatype string `json:"core:datatype"`
+	// SampleRate is the sample rate of the recording in samples per second
+	SampleRate float64 `json:"core:sample_rate,omitempty"`
+	// Version is the version of the SigMF specification
+	Version string `json:"core:version"`
+	// NumChannels is the number of interleaved channels in the data file
+	NumChannels int `json:"core:num_channels,omitempty"`
+	// SHA512 is the SHA512 hash of the data file
+	SHA512 string `json:"core:sha512,omitempty"`
+	// Description is a text description of the recording
+	Description string `json:"core:description,omitempty"`
+	// Author identifies the author of the recording
+	Author string `json:"core:author,omitempty"`
+	// License is the license of the recording
+	License string `json:"core:license,omitempty"`
+	// HW describes the hardware used to make the recording
+	HW string `json:"core:hw,omitempty"`
+	// Recorder is the name and version of the software used to make the recording
+	Recorder string `json:"core:recorder,omitempty"`
+}
+
+// Capture is a segment of a recording with constant parameters
+type Capture struct {
+	// SampleStart is the index of the first sample of the segment
+	SampleStart uint64 `json:"core:sample_start"`
+	// GlobalIndex is the index of the first sample of the segment in the system that produced it
+	GlobalIndex *uint64 `json:"core:global_index,omitempty"`
+	// Frequency is the center frequency of the segment in Hz
+	Frequency float64 `json:"core:frequency,omitempty"`
+	// Datetime is the time of the first sample of the segment, in ISO-8601 format
+	Datetime string `json:"core:datetime,omitempty"`
+}
+
+// Annotation describes a range of samples of a recording
+type Annotation struct {
+	// SampleStart is the index of the first sample described
+	SampleStart uint64 `json:"core:sample_start"`
+	// SampleCount is the number of samples described
+	SampleCount uint64 `json:"core:sample_count,omitempty"`
+	// FreqLowerEdge is the lower frequency of the annotated signal in Hz
+	FreqLowerEdge float64 `json:"core:freq_lower_edge,omitempty"`
+	// FreqUpperEdge is the upper frequency of the annotated signal in Hz
+	FreqUpperEdge float64 `json:"core:freq_upper_edge,omitempty"`
+	// Label is a short description of the annotation
+	Label string `json:"core:label,omitempty"`
+	// Comment is a text comment of the annotation
+	Comment string `json:"core:comment,omitempty"`
+	// Generator identifies what generated the annotation
+	Generator string `json:"core:generator,omitempty"`
+}
+
+// Metadata is the content of a metadata file
+type Metadata struct {
+	Global      Global       `json:"global"`
+	Captures    []Capture    `json:"captures"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+// Datatype returns the SigMF datatype of a stream format, for example "ci16_le" for "CS16".
+//
+// Params:
+//  - format: the stream format
+//
+// Return the datatype or an error if the format is unknown
+func Datatype(format string) (datatype string, err error) {
+
+	switch format {
+	case sdrformat.CU8:
+		return "cu8", nil
+	case sdrformat.CS8:
+		return "ci8", nil
+	case sdrformat.CU16:
+		return "cu16_le", nil
+	case sdrformat.CS16:
+		return "ci16_le", nil
+	case sdrformat.CF32:
+		return "cf32_le", nil
+	case sdrformat.CF64:
+		return "cf64_le", nil
+	}
+
+	return "", fmt.Errorf("%w: %v", sdrformat.ErrUnknownFormat, format)
+}
+
+// Format returns the stream format of a SigMF datatype, for example "CS16" for "ci16_le".
+//
+// Params:
+//  - datatype: the SigMF datatype
+//
+// Return the stream format or an error if the datatype has no matching stream format
+func Format(datatype string) (format string, err error) {
+
+	switch strings.ToLower(datatype) {
+	case "cu8":
+		return sdrformat.CU8, nil
+	case "ci8":
+		return sdrformat.CS8, nil
+	case "cu16_le":
+		return sdrformat.CU16, nil
+	case "ci16_le":
+		return sdrformat.CS16, nil
+	case "cf32_le":
+		return sdrformat.CF32, nil
+	case "cf64_le":
+		return sdrformat.CF64, nil
+	}
+
+	return "", fmt.Errorf("unsupported SigMF datatype: %v", datatype)
+}
+
+// BasePath returns the path of a recording without the SigMF extension, so that the data and metadata files can be
+// found from any of them.
+func BasePath(path string) string {
+
+	path = strings.TrimSuffix(path, DataExtension)
+	path = strings.TrimSuffix(path, MetaExtension)
+
+	return path
+}
+
+// WriteMetadata writes a metadata file.
+//
+// Params:
+//  - path: the path of the metadata file
+//  - metadata: the metadata to write
+//
+// Return an error or nil in case of success
+func WriteMetadata(path string, metadata Metadata) error {
+
+	if metadata.Captures == nil {
+		metadata.Captures = []Capture{}
+	}
+	if metadata.Annotations == nil {
+		metadata.Annotations = []Annotation{}
+	}
+
+	content, err := json.MarshalIndent(metadata, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file first, so that a reader never sees a partial metadata file
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(content, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,331 @@
+package sigmf
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+)
+
+const (
+	// playerDefaultLead is the default maximum advance of the written samples over the real time
+	playerDefaultLead = 100 * time.Millisecond
+	// playerDefaultTimeoutUs is the default timeout of the writes in microseconds
+	playerDefaultTimeoutUs = 1000000
+	// playerAckTimeout is the time waited for the acknowledgement of the end of the burst
+	playerAckTimeout = time.Second
+)
+
+// PlayOptions are the options of a Player
+type PlayOptions struct {
+	// Channels are the TX channels, one per channel of the recording. Empty selects the first channels of the device.
+	Channels []uint
+	// Format is the format of the TX stream, or empty for the format of the recording. The samples are converted when
+	// the formats differ.
+	Format string
+	// StreamArgs are the arguments of the TX stream, or empty for defaults
+	StreamArgs map[string]string
+	// StartTimeNs is the hardware time in nanoseconds of the first sample, or 0 to transmit immediately
+	StartTimeNs uint
+	// Lead is the maximum advance of the written samples over the real time, or 0 for the default of 100 ms. It
+	// paces the writes on drivers that do not block when their buffers are full.
+	Lead time.Duration
+	// TimeoutUs is the timeout of each write in microseconds, or 0 for the default of one second
+	TimeoutUs uint
+}
+
+// Player transmits a SigMF recording on the TX channels of a device.
+//
+// The sample rate and the frequency of the channels are configured from the metadata of the recording. The frequency
+// is changed between the captures of the recording, without timing guarantee. The whole recording is transmitted as
+// a single burst, ended with StreamFlagEndBurst.
+type Player struct {
+	dev     *device.SDRDevice
+	reader  *Reader
+	options PlayOptions
+
+	// writeBuffers writes to the TX stream: device.WriteBuffers, replaced by the tests
+	writeBuffers func(stream device.SDRStream, buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (uint, error)
+}
+
+// NewPlayer creates a player for a recording.
+//
+// Params:
+//  - dev: the device transmitting the recording
+//  - reader: the recording, transmitted from its current position
+//  - options: the options of the player
+//
+// Return the player or an error
+func NewPlayer(dev *device.SDRDevice, reader *Reader, options PlayOptions) (player *Player, err error) {
+
+	if reader.SampleRate() <= 0 {
+		return nil, errors.New("the recording does not define its sample rate")
+	}
+
+	if len(options.Channels) == 0 {
+		for channel := 0; channel < reader.NumChannels(); channel++ {
+			options.Channels = append(options.Channels, uint(channel))
+		}
+	}
+
+	if len(options.Channels) != reader.NumChannels() {
+		return nil, fmt.Errorf("the recording has %d channels but %d TX channels are given", reader.NumChannels(), len(options.Channels))
+	}
+
+	if options.Format == "" {
+		options.Format = reader.Format()
+	}
+	if options.Lead <= 0 {
+		options.Lead = playerDefaultLead
+	}
+	if options.TimeoutUs == 0 {
+		options.TimeoutUs = playerDefaultTimeoutUs
+	}
+
+	return &Player{
+		dev:          dev,
+		reader:       reader,
+		options:      options,
+		writeBuffers: device.WriteBuffers,
+	}, nil
+}
+
+// Configure sets the sample rate and the frequency of the TX channels from the metadata of the recording.
+//
+// Return an error or nil in case of success
+func (player *Player) Configure() error {
+
+	for _, channel := range player.options.Channels {
+		if err := player.dev.SetSampleRate(device.DirectionTX, channel, player.reader.SampleRate()); err != nil {
+			return fmt.Errorf("can not set the sample rate of TX channel %d: %w", channel, err)
+		}
+	}
+
+	return player.tune(player.reader.Position())
+}
+
+// Play configures the TX channels and transmits the recording until its end or the cancellation of the context.
+//
+// Params:
+//  - ctx: the context of the transmission. When cancelled, the burst is ended and the transmission stopped.
+//
+// Return an error or nil in case of success
+func (player *Player) Play(ctx context.Context) error {
+
+	if err := player.Configure(); err != nil {
+		return err
+	}
+
+	stream, err := player.dev.SetupSDRStream(device.DirectionTX, player.options.Format, player.options.Channels, player.options.StreamArgs)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	if err := stream.Activate(0, 0, 0); err != nil {
+		return err
+	}
+	defer stream.Deactivate(0, 0)
+
+	mtu := stream.GetMTU()
+	if mtu <= 0 {
+		mtu = 4096
+	}
+
+	return player.transmit(ctx, stream, mtu)
+}
+
+// transmit writes the recording to an active TX stream, in blocks of at most the MTU of the stream not crossing the
+// captures, until its end or the cancellation of the context.
+//
+// Params:
+//  - ctx: the context of the transmission
+//  - stream: the TX stream
+//  - mtu: the maximum number of samples per channel of a block
+//
+// Return an error or nil in case of success
+func (player *Player) transmit(ctx context.Context, stream device.SDRStream, mtu int) error {
+
+	buffers, err := sdrformat.MakeBuffers(player.options.Format, len(player.options.Channels), mtu)
+	if err != nil {
+		return err
+	}
+
+	flags := make([]int, len(player.options.Channels))
+	rate := player.reader.SampleRate()
+
+	// The local time of the first sample, from which the writes are paced
+	start := time.Now()
+	if player.options.StartTimeNs > 0 {
+		if now := player.dev.GetHardwareTime(""); player.options.StartTimeNs > now {
+			start = start.Add(time.Duration(player.options.StartTimeNs - now))
+		}
+	}
+
+	first := true
+	sent := int64(0)
+	for player.reader.Position() < player.reader.NumSamples() {
+
+		if err := ctx.Err(); err != nil {
+			player.endBurst(stream, buffers, flags)
+			return err
+		}
+
+		// The blocks do not cross the captures, so that the frequency can be changed between them
+		position := player.reader.Position()
+		if capture, found := player.reader.CaptureAt(position); found && capture.SampleStart == position && !first {
+			if err := player.tune(position); err != nil {
+				return err
+			}
+		}
+
+		count := player.reader.NextCaptureStart(position) - position
+		if count > uint64(mtu) {
+			count = uint64(mtu)
+		}
+
+		block, err := sdrformat.SliceBuffers(buffers, 0, int(count))
+		if err != nil {
+			return err
+		}
+
+		nbRead, err := player.reader.Read(block)
+		if err != nil {
+			return err
+		}
+
+		flag := 0
+		if first && player.options.StartTimeNs > 0 {
+			flag |= int(device.StreamFlagHasTime)
+		}
+		if player.reader.Position() >= player.reader.NumSamples() {
+			flag |= int(device.StreamFlagEndBurst)
+		}
+
+		if err := player.write(stream, block, nbRead, flags, flag); err != nil {
+			return err
+		}
+
+		first = false
+		sent += int64(nbRead)
+
+		// Do not get ahead of the real time by more than the lead
+		ahead := sdrtime.TicksToDuration(sent, rate) - time.Since(start) - player.options.Lead
+		if ahead > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(ahead):
+			}
+		}
+	}
+
+	waitEndBurst(stream, len(flags))
+
+	return nil
+}
+
+// tune sets the frequency of the TX channels to the frequency of the capture containing a sample
+func (player *Player) tune(index uint64) error {
+
+	capture, found := player.reader.CaptureAt(index)
+	if !found || capture.Frequency == 0 {
+		return nil
+	}
+
+	for _, channel := range player.options.Channels {
+		if err := player.dev.SetFrequency(device.DirectionTX, channel, capture.Frequency, nil); err != nil {
+			return fmt.Errorf("can not set the frequency of TX channel %d: %w", channel, err)
+		}
+	}
+
+	return nil
+}
+
+// write writes a block of samples completely, the time flag being only given with the first write
+func (player *Player) write(stream device.SDRStream, block interface{}, nbSamples int, flags []int, flag int) error {
+
+	for written := 0; written < nbSamples; {
+
+		remaining, err := sdrformat.SliceBuffers(block, written, nbSamples)
+		if err != nil {
+			return err
+		}
+
+		for i := range flags {
+			flags[i] = flag
+		}
+
+		nbWritten, err := player.writeBuffers(stream, remaining, uint(nbSamples-written), flags, player.options.StartTimeNs, player.options.TimeoutUs)
+		if err != nil {
+			return err
+		}
+
+		if nbWritten == 0 {
+			return sdrerror.Err(-1)
+		}
+
+		written += int(nbWritten)
+		flag &^= int(device.StreamFlagHasTime)
+	}
+
+	return nil
+}
+
+// endBurst ends the burst early, with an empty write carrying StreamFlagEndBurst
+func (player *Player) endBurst(stream device.SDRStream, buffers interface{}, flags []int) {
+
+	for i := range flags {
+		flags[i] = int(device.StreamFlagEndBurst)
+	}
+
+	player.writeBuffers(stream, buffers, 0, flags, 0, player.options.TimeoutUs)
+	waitEndBurst(stream, len(flags))
+}
+
+// waitEndBurst waits for the acknowledgement of the end of the burst, so that the stream is not deactivated while
+// the last samples are transmitted. It returns immediately when the stream does not report its status.
+func waitEndBurst(stream device.SDRStream, nbChannels int) {
+
+	chanMask := make([]uint, nbChannels)
+	flags := make([]int, nbChannels)
+
+	deadline := time.Now().Add(playerAckTimeout)
+	for time.Now().Before(deadline) {
+
+		_, err := stream.ReadStreamStatus(chanMask, flags, uint(time.Until(deadline)/time.Microsecond))
+		switch err.(type) {
+		case nil:
+			if flags[0]&int(device.StreamFlagEndBurst) != 0 {
+				return
+			}
+		case *sdrerror.Timeout, *sdrerror.Underflow, *sdrerror.TimeError:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package sigmf
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// fakeTxStream is a TX stream acknowledging the ends of bursts. The methods not used by the players are not
+// implemented.
+type fakeTxStream struct {
+	device.SDRStream
+}
+
+func (stream *fakeTxStream) ReadStreamStatus(chanMask []uint, flags []int, timeoutUs uint) (timeNs uint, err error) {
+
+	flags[0] = int(device.StreamFlagEndBurst)
+
+	return 0, nil
+}
+
+// testWrite is a write of a player to a TX stream
+type testWrite struct {
+	start     int
+	nbSamples int
+	flags     int
+}
+
+// fakeWriter records the writes of a player, accepting at most a given number of samples per write
+type fakeWriter struct {
+	maxSamples int
+	writes     []testWrite
+	samples    interface{}
+	position   int
+}
+
+func (writer *fakeWriter) write(stream device.SDRStream, buffers interface{}, nbElems uint, flags []int, timeNs uint, timeoutUs uint) (uint, error) {
+
+	nbSamples := int(nbElems)
+	if nbSamples > writer.maxSamples {
+		nbSamples = writer.maxSamples
+	}
+
+	writer.writes = append(writer.writes, testWrite{start: writer.position, nbSamples: nbSamples, flags: flags[0]})
+
+	written, err := sdrformat.SliceBuffers(buffers, 0, nbSamples)
+	if err != nil {
+		return 0, err
+	}
+	if writer.samples == nil {
+		format, _ := sdrformat.FormatOf(buffers)
+		if writer.samples, err = sdrformat.MakeBuffers(format, len(flags), 0); err != nil {
+			return 0, err
+		}
+	}
+	writer.samples = appendBuffers(writer.samples, written)
+	writer.position += nbSamples
+
+	return uint(nbSamples), nil
+}
+
+// newTestPlayer opens a recording of 3500 samples in two captures and creates a player of it writing in a fake writer
+func newTestPlayer(t *testing.T, format string, maxSamples int) (*Player, *fakeWriter, interface{}) {
+
+	basePath, samples := writeRecording(t, sdrformat.CS16, 2, 1000, 2500)
+
+	reader, err := Open(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reader.Close() })
+
+	// The captures are kept without frequency, the player having no device to tune
+	for i := range reader.Metadata.Captures {
+		reader.Metadata.Captures[i].Frequency = 0
+	}
+
+	player, err := NewPlayer(nil, reader, PlayOptions{Format: format})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := &fakeWriter{maxSamples: maxSamples}
+	player.writeBuffers = writer.write
+
+	return player, writer, samples
+}
+
+// TestPlayerBlocks checks the blocks written by a player: the recording split by the MTU and the captures, the end
+// of the burst carried by the last block only, and the samples converted to the format of the stream
+func TestPlayerBlocks(t *testing.T) {
+
+	endBurst := int(device.StreamFlagEndBurst)
+
+	tests := []struct {
+		name       string
+		format     string
+		mtu        int
+		maxSamples int
+		writes     []testWrite
+	}{
+		{"blocks of the MTU", "", 1024, 4096, []testWrite{
+			{0, 1000, 0}, {1000, 1024, 0}, {2024, 1024, 0}, {3048, 452, endBurst},
+		}},
+		{"blocks of the captures", "", 4096, 4096, []testWrite{{0, 1000, 0}, {1000, 2500, endBurst}}},
+		{"partial writes", "", 4096, 2000, []testWrite{{0, 1000, 0}, {1000, 2000, endBurst}, {3000, 500, endBurst}}},
+		{"conversion", sdrformat.CF32, 2000, 4096, []testWrite{{0, 1000, 0}, {1000, 2000, 0}, {3000, 500, endBurst}}},
+	}
+
+	for _, test := range tests {
+
+		player, writer, samples := newTestPlayer(t, test.format, test.maxSamples)
+
+		if err := player.transmit(context.Background(), &fakeTxStream{}, test.mtu); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if !reflect.DeepEqual(writer.writes, test.writes) {
+			t.Errorf("%s: writes %v, expected %v", test.name, writer.writes, test.writes)
+		}
+
+		if test.format != "" {
+			converted, _ := sdrformat.MakeBuffers(test.format, 2, 3500)
+			sdrformat.ConvertBuffers(converted, samples)
+			samples = converted
+		}
+		if !reflect.DeepEqual(writer.samples, samples) {
+			t.Errorf("%s: the samples written differ from the recording", test.name)
+		}
+	}
+}
+
+// TestPlayerCancel checks that a cancelled transmission ends the burst with an empty write
+func TestPlayerCancel(t *testing.T) {
+
+	player, writer, _ := newTestPlayer(t, "", 4096)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := player.transmit(ctx, &fakeTxStream{}, 1024); err != context.Canceled {
+		t.Errorf("error %v, expected context.Canceled", err)
+	}
+
+	expected := []testWrite{{0, 0, int(device.StreamFlagEndBurst)}}
+	if !reflect.DeepEqual(writer.writes, expected) {
+		t.Errorf("writes %v, expected %v", writer.writes, expected)
+	}
+}
+
+func TestNewPlayer(t *testing.T) {
+
+	basePath, _ := writeRecording(t, sdrformat.CS16, 2, 10, 10)
+
+	reader, err := Open(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	player, err := NewPlayer(nil, reader, PlayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options := player.options; !reflect.DeepEqual(options.Channels, []uint{0, 1}) ||
+		options.Format != sdrformat.CS16 || options.Lead != playerDefaultLead ||
+		options.TimeoutUs != playerDefaultTimeoutUs {
+		t.Errorf("options %+v, expected the defaults", options)
+	}
+
+	if _, err := NewPlayer(nil, reader, PlayOptions{Channels: []uint{0}}); err == nil {
+		t.Error("expected an error for a channel per channel of the recording missing")
+	}
+
+	reader.Metadata.Global.SampleRate = 0
+	if _, err := NewPlayer(nil, reader, PlayOptions{}); err == nil {
+		t.Error("expected an error for a recording without sample rate")
+	}
+}
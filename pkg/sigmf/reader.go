@@ -0,0 +1,328 @@
+package sigmf
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// ReadMetadata reads a metadata file.
+//
+// Params:
+//  - path: the path of the metadata file
+//
+// Return the metadata or an error
+func ReadMetadata(path string) (metadata Metadata, err error) {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return metadata, err
+	}
+
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return metadata, fmt.Errorf("invalid SigMF metadata %v: %w", path, err)
+	}
+
+	return metadata, nil
+}
+
+// Reader reads the samples of a SigMF recording
+type Reader struct {
+	// Metadata is the metadata of the recording
+	Metadata Metadata
+
+	file       *os.File
+	reader     *bufio.Reader
+	format     string
+	nbChannels int
+	nbSamples  uint64
+	position   uint64
+	raw        []byte
+	native     interface{}
+}
+
+// Open opens a SigMF recording.
+//
+// Params:
+//  - path: the path of the recording, with or without the extension of the data or metadata file
+//
+// Return the reader or an error
+func Open(path string) (reader *Reader, err error) {
+
+	basePath := BasePath(path)
+
+	metadata, err := ReadMetadata(basePath + MetaExtension)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := Format(metadata.Global.Datatype)
+	if err != nil {
+		return nil, err
+	}
+
+	nbChannels := metadata.Global.NumChannels
+	if nbChannels <= 0 {
+		nbChannels = 1
+	}
+
+	file, err := os.Open(basePath + DataExtension)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Reader{
+		Metadata:   metadata,
+		file:       file,
+		reader:     bufio.NewReader(file),
+		format:     format,
+		nbChannels: nbChannels,
+		nbSamples:  uint64(info.Size()) / uint64(sdrformat.BytesPerSample(format)*nbChannels),
+	}, nil
+}
+
+// Format returns the stream format matching the datatype of the recording
+func (reader *Reader) Format() string {
+	return reader.format
+}
+
+// NumChannels returns the number of channels of the recording
+func (reader *Reader) NumChannels() int {
+	return reader.nbChannels
+}
+
+// SampleRate returns the sample rate of the recording in samples per second, or 0 if it is unknown
+func (reader *Reader) SampleRate() float64 {
+	return reader.Metadata.Global.SampleRate
+}
+
+// NumSamples returns the number of samples per channel of the recording
+func (reader *Reader) NumSamples() uint64 {
+	return reader.nbSamples
+}
+
+// Position returns the index of the next sample to read
+func (reader *Reader) Position() uint64 {
+	return reader.position
+}
+
+// CaptureAt returns the capture containing a sample, or false if no capture contains it.
+//
+// Params:
+//  - index: the index of the sample
+//
+// Return the capture and true if found
+func (reader *Reader) CaptureAt(index uint64) (capture Capture, found bool) {
+
+	for _, candidate := range reader.Metadata.Captures {
+		if candidate.SampleStart > index {
+			break
+		}
+		capture = candidate
+		found = true
+	}
+
+	return capture, found
+}
+
+// NextCaptureStart returns the index of the first sample of the capture following a sample, or the number of samples
+// of the recording if there is no following capture.
+//
+// Params:
+//  - index: the index of the sample
+//
+// Return the index of the first sample of the next capture
+func (reader *Reader) NextCaptureStart(index uint64) uint64 {
+
+	for _, capture := range reader.Metadata.Captures {
+		if capture.SampleStart > index {
+			return capture.SampleStart
+		}
+	}
+
+	return reader.nbSamples
+}
+
+// AnnotationsIn returns the annotations overlapping a range of samples.
+//
+// Params:
+//  - from: the index of the first sample of the range
+//  - to: the index following the last sample of the range
+//
+// Return the annotations overlapping the range
+func (reader *Reader) AnnotationsIn(from uint64, to uint64) []Annotation {
+
+	var annotations []Annotation
+	for _, annotation := range reader.Metadata.Annotations {
+		end := annotation.SampleStart + annotation.SampleCount
+		if annotation.SampleCount == 0 {
+			end = annotation.SampleStart + 1
+		}
+		if annotation.SampleStart < to && end > from {
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	return annotations
+}
+
+// Seek moves the position of the reader.
+//
+// Params:
+//  - index: the index of the next sample to read
+//
+// Return an error or nil in case of success
+func (reader *Reader) Seek(index uint64) error {
+
+	if index > reader.nbSamples {
+		return errors.New("can not seek after the end of the recording")
+	}
+
+	offset := int64(index) * int64(sdrformat.BytesPerSample(reader.format)*reader.nbChannels)
+	if _, err := reader.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader.reader.Reset(reader.file)
+	reader.position = index
+
+	return nil
+}
+
+// Read reads the next samples of the recording into the buffers of all the channels. When the format of the buffers
+// is not the format of the recording, the samples are converted.
+//
+// Params:
+//  - buffers: the buffers of all the channels, for example a [][]complex64, with one buffer per channel of the
+//    recording
+//
+// Return the number of samples read per channel, and io.EOF at the end of the recording
+func (reader *Reader) Read(buffers interface{}) (nbSamples int, err error) {
+
+	nbChannels, length, err := sdrformat.BuffersLength(buffers)
+	if err != nil {
+		return 0, err
+	}
+
+	if nbChannels != reader.nbChannels {
+		return 0, fmt.Errorf("the recording has %d channels but %d buffers are given", reader.nbChannels, nbChannels)
+	}
+
+	if remaining := reader.nbSamples - reader.position; uint64(length) > remaining {
+		length = int(remaining)
+	}
+
+	if length == 0 {
+		return 0, io.EOF
+	}
+
+	size := length * sdrformat.BytesPerSample(reader.format) * reader.nbChannels
+	if cap(reader.raw) < size {
+		reader.raw = make([]byte, size)
+	}
+	raw := reader.raw[:size]
+
+	if _, err := io.ReadFull(reader.reader, raw); err != nil {
+		return 0, err
+	}
+
+	format, _ := sdrformat.FormatOf(buffers)
+	if format == reader.format {
+		nbSamples, err = sdrformat.Decode(buffers, raw)
+	} else {
+		if _, nativeLength, _ := sdrformat.BuffersLength(reader.native); reader.native == nil || nativeLength < length {
+			if reader.native, err = sdrformat.MakeBuffers(reader.format, reader.nbChannels, length); err != nil {
+				return 0, err
+			}
+		}
+
+		native, sliceErr := sdrformat.SliceBuffers(reader.native, 0, length)
+		if sliceErr != nil {
+			return 0, sliceErr
+		}
+
+		if _, err := sdrformat.Decode(native, raw); err != nil {
+			return 0, err
+		}
+
+		nbSamples, err = sdrformat.ConvertBuffers(buffers, native)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	reader.position += uint64(nbSamples)
+
+	return nbSamples, nil
+}
+
+// ReadBlock reads the next block of samples of the recording into new buffers.
+//
+// Params:
+//  - format: the stream format of the returned buffers, or an empty string for the format of the recording
+//  - nbSamples: the maximum number of samples per channel of the block
+//
+// Return the buffers of all the channels, holding exactly the samples read, and io.EOF at the end of the recording
+func (reader *Reader) ReadBlock(format string, nbSamples int) (buffers interface{}, err error) {
+
+	if format == "" {
+		format = reader.format
+	}
+
+	if remaining := reader.nbSamples - reader.position; uint64(nbSamples) > remaining {
+		nbSamples = int(remaining)
+	}
+
+	if nbSamples == 0 {
+		return nil, io.EOF
+	}
+
+	buffers, err = sdrformat.MakeBuffers(format, reader.nbChannels, nbSamples)
+	if err != nil {
+		return nil, err
+	}
+
+	nbRead, err := reader.Read(buffers)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdrformat.SliceBuffers(buffers, 0, nbRead)
+}
+
+// Close closes the data file of the recording
+func (reader *Reader) Close() error {
+	return reader.file.Close()
+}
@@ -0,0 +1,249 @@
+package sigmf
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"io"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// testBuffers returns the buffers of several channels holding distinct samples within the full scale
+func testBuffers(t *testing.T, format string, nbChannels int, nbSamples int, seed int) interface{} {
+
+	buffers, err := sdrformat.MakeBuffers(format, nbChannels, nbSamples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]complex128, nbSamples)
+	for channel := 0; channel < nbChannels; channel++ {
+
+		for i := range samples {
+			phase := float64(i*7+seed) + float64(channel)/3
+			samples[i] = complex(0.9*math.Sin(phase), 0.9*math.Cos(phase/5))
+		}
+
+		if _, err := sdrformat.FromComplex128(reflect.ValueOf(buffers).Index(channel).Interface(), samples); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buffers
+}
+
+// appendBuffers returns the concatenation of the buffers of several channels
+func appendBuffers(first interface{}, second interface{}) interface{} {
+
+	a := reflect.ValueOf(first)
+	b := reflect.ValueOf(second)
+
+	result := reflect.MakeSlice(a.Type(), a.Len(), a.Len())
+	for channel := 0; channel < a.Len(); channel++ {
+		result.Index(channel).Set(reflect.AppendSlice(a.Index(channel), b.Index(channel)))
+	}
+
+	return result.Interface()
+}
+
+// writeRecording writes a recording of two blocks of samples, with a capture at 100 MHz then at 101 MHz from the
+// second block
+func writeRecording(t *testing.T, format string, nbChannels int, first int, second int) (basePath string, samples interface{}) {
+
+	basePath = filepath.Join(t.TempDir(), "recording")
+	recorder, err := NewRecorder(basePath, format, nbChannels, Global{SampleRate: 1e6}, 100e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstBlock := testBuffers(t, format, nbChannels, first, 0)
+	secondBlock := testBuffers(t, format, nbChannels, second, 1)
+
+	if err := recorder.Write(firstBlock, first, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	recorder.Retune(101e6)
+	if err := recorder.Write(secondBlock, second, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return basePath, appendBuffers(firstBlock, secondBlock)
+}
+
+// otherFormat returns a format of another element type than a format
+func otherFormat(format string) string {
+
+	switch format {
+	case sdrformat.CF32, sdrformat.CF64:
+		return sdrformat.CS16
+	}
+
+	return sdrformat.CF32
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	formats := []string{sdrformat.CU8, sdrformat.CS8, sdrformat.CU16, sdrformat.CS16, sdrformat.CF32, sdrformat.CF64}
+
+	for _, format := range formats {
+		for _, nbChannels := range []int{1, 2} {
+
+			basePath, expected := writeRecording(t, format, nbChannels, 1000, 2500)
+
+			reader, err := Open(basePath + DataExtension)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reader.Format() != format || reader.NumChannels() != nbChannels || reader.SampleRate() != 1e6 {
+				t.Errorf("%v: recording of format %v, %d channels and %v samples per second", format,
+					reader.Format(), reader.NumChannels(), reader.SampleRate())
+			}
+			if reader.NumSamples() != 3500 {
+				t.Fatalf("%v: recording of %d samples, expected 3500", format, reader.NumSamples())
+			}
+
+			// Blocks of 1500 samples in the format of the recording
+			var samples interface{}
+			for {
+				block, err := reader.ReadBlock("", 1500)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%v: %v", format, err)
+				}
+				if samples == nil {
+					samples = block
+				} else {
+					samples = appendBuffers(samples, block)
+				}
+			}
+
+			if !reflect.DeepEqual(samples, expected) {
+				t.Errorf("%v: samples of %d channels differ after a round trip", format, nbChannels)
+			}
+			if reader.Position() != 3500 {
+				t.Errorf("%v: position %d at the end, expected 3500", format, reader.Position())
+			}
+
+			// The samples converted to another element type
+			other := otherFormat(format)
+			converted, err := sdrformat.MakeBuffers(other, nbChannels, 3500)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sdrformat.ConvertBuffers(converted, expected); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := reader.Seek(0); err != nil {
+				t.Fatal(err)
+			}
+			read, err := reader.ReadBlock(other, 4000)
+			if err != nil {
+				t.Fatalf("%v: %v", format, err)
+			}
+			if !reflect.DeepEqual(read, converted) {
+				t.Errorf("%v: samples of %d channels read as %v differ from the converted samples", format,
+					nbChannels, other)
+			}
+
+			if err := reader.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestReaderCaptures(t *testing.T) {
+
+	basePath, expected := writeRecording(t, sdrformat.CS16, 2, 1000, 2500)
+
+	reader, err := Open(basePath + MetaExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	tests := []struct {
+		index     uint64
+		frequency float64
+		next      uint64
+	}{
+		{0, 100e6, 1000},
+		{999, 100e6, 1000},
+		{1000, 101e6, 3500},
+		{3499, 101e6, 3500},
+	}
+
+	for _, test := range tests {
+
+		capture, found := reader.CaptureAt(test.index)
+		if !found || capture.Frequency != test.frequency {
+			t.Errorf("sample %d in a capture at %v Hz (found %v), expected %v Hz", test.index, capture.Frequency, found,
+				test.frequency)
+		}
+		if next := reader.NextCaptureStart(test.index); next != test.next {
+			t.Errorf("sample %d followed by a capture at %d, expected %d", test.index, next, test.next)
+		}
+	}
+
+	// Reading from the middle of the recording, into buffers longer than the rest
+	if err := reader.Seek(3000); err != nil {
+		t.Fatal(err)
+	}
+
+	buffers, _ := sdrformat.MakeBuffers(sdrformat.CS16, 2, 1000)
+	nbSamples, err := reader.Read(buffers)
+	if err != nil || nbSamples != 500 {
+		t.Fatalf("%d samples read (%v), expected 500", nbSamples, err)
+	}
+
+	read, _ := sdrformat.SliceBuffers(buffers, 0, 500)
+	tail, _ := sdrformat.SliceBuffers(expected, 3000, 3500)
+	if !reflect.DeepEqual(read, tail) {
+		t.Error("samples read after a seek differ")
+	}
+
+	if _, err := reader.Read(buffers); err != io.EOF {
+		t.Errorf("read after the end of the recording returned %v, expected EOF", err)
+	}
+
+	if err := reader.Seek(3501); err == nil {
+		t.Error("expected an error for a seek after the end of the recording")
+	}
+
+	wrongChannels, _ := sdrformat.MakeBuffers(sdrformat.CS16, 1, 10)
+	if err := reader.Seek(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Read(wrongChannels); err == nil {
+		t.Error("expected an error for buffers of another number of channels")
+	}
+}
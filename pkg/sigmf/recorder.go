@@ -0,0 +1,592 @@
+package sigmf
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/sdrerror"
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+	"github.com/bhojpur/sdr/pkg/version"
+)
+
+// datetimeLayout is the ISO-8601 layout of the datetime of the captures
+const datetimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Recorder writes the samples of a RX stream to a SigMF recording.
+//
+// The recorder keeps the metadata in memory and writes the metadata file on Flush and Close. The changes of frequency
+// during the recording start new captures and the changes of gain are recorded as annotations. The samples of a
+// capture are contiguous: the samples following a loss, detected from their timestamps or reported by MarkLoss, start
+// a new capture with their own datetime.
+type Recorder struct {
+	mutex sync.Mutex
+
+	basePath   string
+	file       *os.File
+	writer     *bufio.Writer
+	hash       hash.Hash
+	metadata   Metadata
+	format     string
+	nbChannels int
+	count      uint64
+	encoded    []byte
+	closed     bool
+
+	clock       *sdrtime.Clock
+	tracker     *sdrtime.Tracker
+	lost        bool
+	lastTimeNs  int64
+	lastIndex   uint64
+	hasLastTime bool
+
+	dev     *device.SDRDevice
+	channel uint
+}
+
+// NewRecorder creates a SigMF recording. The data file is created immediately and the metadata file on Flush or Close.
+//
+// Params:
+//  - basePath: the path of the recording, without extension
+//  - format: the stream format of the samples, from which the datatype of the recording is derived
+//  - nbChannels: the number of channels of the samples
+//  - global: the global metadata of the recording. The datatype, the version and the number of channels are set by
+//    the recorder, the recorder name is set when empty.
+//  - frequency: the center frequency of the first capture in Hz, or 0 when unknown
+//
+// Return the recorder or an error
+func NewRecorder(basePath string, format string, nbChannels int, global Global, frequency float64) (recorder *Recorder, err error) {
+
+	datatype, err := Datatype(format)
+	if err != nil {
+		return nil, err
+	}
+
+	if nbChannels <= 0 {
+		return nil, errors.New("a recording must have at least one channel")
+	}
+
+	global.Datatype = datatype
+	global.Version = Version
+	global.NumChannels = nbChannels
+	if global.Recorder == "" {
+		global.Recorder = "Bhojpur SDR " + version.Version
+	}
+
+	file, err := os.Create(basePath + DataExtension)
+	if err != nil {
+		return nil, err
+	}
+
+	// The gaps can only be detected from the timestamps when the sample rate is known
+	var tracker *sdrtime.Tracker
+	if global.SampleRate > 0 {
+		if tracker, err = sdrtime.NewTracker(global.SampleRate, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	checksum := sha512.New()
+
+	return &Recorder{
+		basePath:   basePath,
+		file:       file,
+		writer:     bufio.NewWriter(io.MultiWriter(file, checksum)),
+		hash:       checksum,
+		format:     format,
+		nbChannels: nbChannels,
+		tracker:    tracker,
+		metadata: Metadata{
+			Global:   global,
+			Captures: []Capture{{SampleStart: 0, Frequency: frequency}},
+		},
+	}, nil
+}
+
+// NewStreamRecorder creates a SigMF recording for a RX stream of a device. The sample rate, the frequency and the
+// description of the hardware are read from the device.
+//
+// Params:
+//  - basePath: the path of the recording, without extension
+//  - dev: the device of the stream
+//  - stream: the RX stream
+//  - channels: the channels of the stream
+//  - global: the global metadata of the recording, completed by the recorder. The author is set to the name and
+//    version of Bhojpur SDR when empty.
+//
+// Return the recorder or an error
+func NewStreamRecorder(basePath string, dev *device.SDRDevice, stream device.SDRStream, channels []uint, global Global) (recorder *Recorder, err error) {
+
+	if len(channels) == 0 {
+		return nil, errors.New("the channels of the stream must be given explicitly")
+	}
+
+	if global.SampleRate == 0 {
+		global.SampleRate = dev.GetSampleRate(device.DirectionRX, channels[0])
+	}
+	if global.HW == "" {
+		global.HW = hardwareDescription(dev)
+	}
+	if global.Author == "" {
+		global.Author = "Bhojpur SDR " + version.Version
+	}
+
+	recorder, err = NewRecorder(basePath, stream.Format(), len(channels), global, dev.GetFrequency(device.DirectionRX, channels[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	recorder.dev = dev
+	recorder.channel = channels[0]
+
+	return recorder, nil
+}
+
+// SetClock sets the clock used to convert the hardware times of the samples to the datetime of the captures. When no
+// clock is set, a clock is created on the first samples written with a timestamp, using the local time.
+//
+// Params:
+//  - clock: the clock of the stream
+func (recorder *Recorder) SetClock(clock sdrtime.Clock) {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.clock = &clock
+}
+
+// Write appends samples to the recording.
+//
+// Params:
+//  - buffers: the buffers of all the channels, of the Go type matching the format of the recording
+//  - nbSamples: the number of samples to write per channel
+//  - timeNs: the hardware time in nanoseconds of the first sample
+//  - hasTime: whether the timestamp is valid, usually the StreamFlagHasTime of the read flags
+//
+// Return an error or nil in case of success
+func (recorder *Recorder) Write(buffers interface{}, nbSamples int, timeNs uint, hasTime bool) error {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.closed {
+		return errors.New("the recorder is closed")
+	}
+
+	format, err := sdrformat.FormatOf(buffers)
+	if err != nil {
+		return err
+	}
+
+	if format != recorder.format {
+		return fmt.Errorf("the samples are in %v format but the recording is in %v format", format, recorder.format)
+	}
+
+	recorder.observeBlock(int64(timeNs), hasTime, nbSamples)
+
+	recorder.encoded, err = sdrformat.Encode(recorder.encoded[:0], buffers, nbSamples)
+	if err != nil {
+		return err
+	}
+
+	if _, err := recorder.writer.Write(recorder.encoded); err != nil {
+		return err
+	}
+
+	recorder.count += uint64(nbSamples)
+
+	return nil
+}
+
+// Record reads samples from a RX stream and appends them to the recording. The read timeouts are retried and the
+// overflows start a new capture.
+//
+// Params:
+//  - stream: the RX stream, which must be activated
+//  - nbSamples: the number of samples to record per channel
+//  - timeoutUs: the timeout in microseconds of each read
+//
+// Return an error or nil in case of success
+func (recorder *Recorder) Record(stream device.SDRStream, nbSamples int, timeoutUs uint) error {
+
+	mtu := stream.GetMTU()
+	if mtu <= 0 || mtu > nbSamples {
+		mtu = nbSamples
+	}
+
+	buffers, err := sdrformat.MakeBuffers(recorder.format, recorder.nbChannels, mtu)
+	if err != nil {
+		return err
+	}
+
+	flags := make([]int, recorder.nbChannels)
+
+	for recorded := 0; recorded < nbSamples; {
+
+		count := nbSamples - recorded
+		if count > mtu {
+			count = mtu
+		}
+
+		timeNs, nbRead, err := device.ReadBuffers(stream, buffers, uint(count), flags, timeoutUs)
+		if err != nil {
+			switch err.(type) {
+			case *sdrerror.Timeout:
+				continue
+			case *sdrerror.Overflow:
+				recorder.MarkLoss()
+				continue
+			}
+			return err
+		}
+
+		if nbRead == 0 {
+			continue
+		}
+
+		hasTime := flags[0]&int(device.StreamFlagHasTime) != 0
+		if err := recorder.Write(buffers, int(nbRead), timeNs, hasTime); err != nil {
+			return err
+		}
+
+		recorded += int(nbRead)
+	}
+
+	return nil
+}
+
+// Retune records a change of the center frequency by starting a new capture at the current position.
+//
+// Params:
+//  - frequency: the new center frequency in Hz
+func (recorder *Recorder) Retune(frequency float64) {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	capture := Capture{
+		SampleStart: recorder.count,
+		GlobalIndex: recorder.globalIndex(recorder.count),
+		Frequency:   frequency,
+		Datetime:    recorder.datetime(recorder.count),
+	}
+
+	// A capture without any sample is replaced, the captures must have distinct starts
+	last := len(recorder.metadata.Captures) - 1
+	if last >= 0 && recorder.metadata.Captures[last].SampleStart == recorder.count {
+		recorder.metadata.Captures[last] = capture
+		return
+	}
+
+	recorder.metadata.Captures = append(recorder.metadata.Captures, capture)
+}
+
+// GainChanged records a change of the gain as an annotation at the current position.
+//
+// Params:
+//  - gain: the new gain in dB
+func (recorder *Recorder) GainChanged(gain float64) {
+
+	recorder.Annotate(Annotation{
+		SampleStart: recorder.SamplesWritten(),
+		Label:       "gain",
+		Comment:     fmt.Sprintf("gain set to %v dB", gain),
+	})
+}
+
+// Annotate adds an annotation to the recording. See SamplesWritten for the current position.
+//
+// Params:
+//  - annotation: the annotation to add
+func (recorder *Recorder) Annotate(annotation Annotation) {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if annotation.Generator == "" {
+		annotation.Generator = recorder.metadata.Global.Recorder
+	}
+
+	recorder.metadata.Annotations = append(recorder.metadata.Annotations, annotation)
+}
+
+// Tune sets the RX frequency of the device of a stream recorder and starts a new capture.
+//
+// Params:
+//  - frequency: the new center frequency in Hz
+//  - args: the tuning arguments, or nil for defaults
+//
+// Return an error or nil in case of success
+func (recorder *Recorder) Tune(frequency float64, args map[string]string) error {
+
+	if recorder.dev == nil {
+		return errors.New("the recorder is not attached to a device")
+	}
+
+	if err := recorder.dev.SetFrequency(device.DirectionRX, recorder.channel, frequency, args); err != nil {
+		return err
+	}
+
+	recorder.Retune(recorder.dev.GetFrequency(device.DirectionRX, recorder.channel))
+
+	return nil
+}
+
+// SetGain sets the RX gain of the device of a stream recorder and records an annotation.
+//
+// Params:
+//  - gain: the new gain in dB
+//
+// Return an error or nil in case of success
+func (recorder *Recorder) SetGain(gain float64) error {
+
+	if recorder.dev == nil {
+		return errors.New("the recorder is not attached to a device")
+	}
+
+	if err := recorder.dev.SetGain(device.DirectionRX, recorder.channel, gain); err != nil {
+		return err
+	}
+
+	recorder.GainChanged(recorder.dev.GetGain(device.DirectionRX, recorder.channel))
+
+	return nil
+}
+
+// MarkLoss indicates that samples were lost before the next samples given to Write, for example when a read of the
+// stream reported an overflow. The next samples start a new capture, even if their timestamp does not reveal the loss.
+func (recorder *Recorder) MarkLoss() {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.lost = true
+}
+
+// SamplesLost returns the number of samples missing in the gaps found in the timestamps of the recorded samples
+func (recorder *Recorder) SamplesLost() int64 {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.tracker == nil {
+		return 0
+	}
+
+	return recorder.tracker.SamplesLost()
+}
+
+// SamplesWritten returns the number of samples written per channel
+func (recorder *Recorder) SamplesWritten() uint64 {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.count
+}
+
+// Metadata returns a copy of the current metadata of the recording
+func (recorder *Recorder) Metadata() Metadata {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return recorder.copyMetadata()
+}
+
+// Flush writes the buffered samples to the data file and writes the metadata file.
+//
+// Return an error or nil in case of success
+func (recorder *Recorder) Flush() error {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.closed {
+		return errors.New("the recorder is closed")
+	}
+
+	if err := recorder.writer.Flush(); err != nil {
+		return err
+	}
+
+	return WriteMetadata(recorder.basePath+MetaExtension, recorder.copyMetadata())
+}
+
+// Close writes the buffered samples, closes the data file and writes the final metadata file, including the hash
+// of the data.
+//
+// Return an error or nil in case of success
+func (recorder *Recorder) Close() error {
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	if recorder.closed {
+		return nil
+	}
+	recorder.closed = true
+
+	flushErr := recorder.writer.Flush()
+	closeErr := recorder.file.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	recorder.metadata.Global.SHA512 = hex.EncodeToString(recorder.hash.Sum(nil))
+
+	return WriteMetadata(recorder.basePath+MetaExtension, recorder.copyMetadata())
+}
+
+// observeBlock checks that a block of samples follows the samples already written, and starts a new capture at the
+// current position when it does not. It must be called with the mutex locked.
+func (recorder *Recorder) observeBlock(timeNs int64, hasTime bool, nbSamples int) {
+
+	discontinuity := false
+	if recorder.tracker != nil && nbSamples > 0 {
+		_, discontinuity = recorder.tracker.Observe(timeNs, hasTime, int64(nbSamples))
+	}
+
+	if (discontinuity || recorder.lost) && recorder.count > 0 {
+		last := recorder.metadata.Captures[len(recorder.metadata.Captures)-1]
+		capture := Capture{SampleStart: recorder.count, Frequency: last.Frequency}
+
+		if last.SampleStart == recorder.count {
+			recorder.metadata.Captures[len(recorder.metadata.Captures)-1] = capture
+		} else {
+			recorder.metadata.Captures = append(recorder.metadata.Captures, capture)
+		}
+
+		// The time of the previous samples does not give the time of the next ones
+		recorder.hasLastTime = false
+	}
+	recorder.lost = false
+
+	if hasTime {
+		recorder.observeTime(timeNs)
+	}
+}
+
+// observeTime records the hardware time of the samples at the current position. It must be called with the mutex
+// locked.
+func (recorder *Recorder) observeTime(timeNs int64) {
+
+	if recorder.clock == nil {
+		clock, err := sdrtime.NewClock(recorder.metadata.Global.SampleRate, timeNs, time.Now())
+		if err == nil {
+			recorder.clock = &clock
+		}
+	}
+
+	recorder.lastTimeNs = timeNs
+	recorder.lastIndex = recorder.count
+	recorder.hasLastTime = true
+
+	// The datetime and the global index of the captures starting at this position are now known
+	for i := range recorder.metadata.Captures {
+		capture := &recorder.metadata.Captures[i]
+		if capture.SampleStart != recorder.count {
+			continue
+		}
+		if capture.Datetime == "" {
+			capture.Datetime = recorder.datetime(recorder.count)
+		}
+		if capture.GlobalIndex == nil {
+			capture.GlobalIndex = recorder.globalIndex(recorder.count)
+		}
+	}
+}
+
+// globalIndex returns the index of a sample in the hardware time of the stream, or nil if it is unknown. It must be
+// called with the mutex locked.
+func (recorder *Recorder) globalIndex(index uint64) *uint64 {
+
+	rate := recorder.metadata.Global.SampleRate
+	if !recorder.hasLastTime || !(rate > 0) || recorder.lastTimeNs < 0 {
+		return nil
+	}
+
+	globalIndex := uint64(sdrtime.TimeNsToTicks64(recorder.lastTimeNs, rate)) + index - recorder.lastIndex
+
+	return &globalIndex
+}
+
+// datetime returns the datetime of a sample, or an empty string if it is unknown. It must be called with the mutex
+// locked.
+func (recorder *Recorder) datetime(index uint64) string {
+
+	if recorder.clock == nil || !recorder.hasLastTime {
+		return ""
+	}
+
+	timeNs := recorder.lastTimeNs + sdrtime.TicksToTimeNs64(int64(index-recorder.lastIndex), recorder.clock.Rate())
+
+	return recorder.clock.WallTime(timeNs).UTC().Format(datetimeLayout)
+}
+
+// copyMetadata returns a copy of the metadata. It must be called with the mutex locked.
+func (recorder *Recorder) copyMetadata() Metadata {
+
+	metadata := recorder.metadata
+	metadata.Captures = append([]Capture(nil), recorder.metadata.Captures...)
+	metadata.Annotations = append([]Annotation(nil), recorder.metadata.Annotations...)
+
+	// The annotations must be sorted by sample start
+	sort.SliceStable(metadata.Annotations, func(i, j int) bool {
+		return metadata.Annotations[i].SampleStart < metadata.Annotations[j].SampleStart
+	})
+
+	return metadata
+}
+
+// hardwareDescription describes the hardware of a device from its hardware key and information
+func hardwareDescription(dev *device.SDRDevice) string {
+
+	info := dev.GetHardwareInfo()
+	keys := make([]string, 0, len(info))
+	for key := range info {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{dev.GetHardwareKey()}
+	for _, key := range keys {
+		parts = append(parts, key+"="+info[key])
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,188 @@
+package sigmf
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+)
+
+// TestDatatype checks the datatypes of the stream formats and the datatype written in the metadata of the recordings
+func TestDatatype(t *testing.T) {
+
+	tests := []struct {
+		format   string
+		datatype string
+	}{
+		{sdrformat.CU8, "cu8"},
+		{sdrformat.CS8, "ci8"},
+		{sdrformat.CU16, "cu16_le"},
+		{sdrformat.CS16, "ci16_le"},
+		{sdrformat.CF32, "cf32_le"},
+		{sdrformat.CF64, "cf64_le"},
+	}
+
+	for _, test := range tests {
+
+		datatype, err := Datatype(test.format)
+		if err != nil || datatype != test.datatype {
+			t.Errorf("%v: datatype %q (%v), expected %q", test.format, datatype, err, test.datatype)
+		}
+
+		format, err := Format(test.datatype)
+		if err != nil || format != test.format {
+			t.Errorf("%v: format %q (%v), expected %q", test.datatype, format, err, test.format)
+		}
+
+		basePath := filepath.Join(t.TempDir(), "recording")
+		recorder, err := NewRecorder(basePath, test.format, 2, Global{SampleRate: 1e6}, 100e6)
+		if err != nil {
+			t.Fatalf("%v: %v", test.format, err)
+		}
+
+		buffers, err := sdrformat.MakeBuffers(test.format, 2, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := recorder.Write(buffers, 10, 0, false); err != nil {
+			t.Fatalf("%v: %v", test.format, err)
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatalf("%v: %v", test.format, err)
+		}
+
+		metadata, err := ReadMetadata(basePath + MetaExtension)
+		if err != nil {
+			t.Fatalf("%v: %v", test.format, err)
+		}
+		if metadata.Global.Datatype != test.datatype || metadata.Global.NumChannels != 2 || metadata.Global.SHA512 == "" {
+			t.Errorf("%v: global %+v", test.format, metadata.Global)
+		}
+	}
+
+	if _, err := Datatype("CS12"); err == nil {
+		t.Error("expected an error for the CS12 format")
+	}
+	if _, err := Format("ri16_le"); err == nil {
+		t.Error("expected an error for a real datatype")
+	}
+}
+
+// TestRecorderCaptures checks the captures started by the retunes and the losses of samples, and the annotations of
+// the changes of gain
+func TestRecorderCaptures(t *testing.T) {
+
+	basePath := filepath.Join(t.TempDir(), "recording")
+	recorder, err := NewRecorder(basePath, sdrformat.CF32, 1, Global{SampleRate: 1e6}, 100e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock, err := sdrtime.NewClock(1e6, 0, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.SetClock(clock)
+
+	buffers := [][]complex64{make([]complex64, 100)}
+	write := func(timeNs uint, hasTime bool) {
+		if err := recorder.Write(buffers, 100, timeNs, hasTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A retune before the first samples replaces the first capture
+	recorder.Retune(99e6)
+
+	write(1000000, true)
+	write(1100000, true)
+
+	recorder.Retune(101e6)
+	recorder.GainChanged(20)
+	write(1200000, true)
+
+	// Gap of 100 samples
+	write(1400000, true)
+
+	// Loss reported by the stream
+	recorder.MarkLoss()
+	write(1500000, true)
+
+	// Loss followed by samples without timestamp
+	recorder.MarkLoss()
+	write(0, false)
+
+	if lost := recorder.SamplesLost(); lost != 100 {
+		t.Errorf("%d samples lost, expected 100", lost)
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := ReadMetadata(basePath + MetaExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := func(value uint64) *uint64 {
+		return &value
+	}
+
+	expected := []Capture{
+		{SampleStart: 0, GlobalIndex: index(1000), Frequency: 99e6, Datetime: "2024-01-01T00:00:00.001000000Z"},
+		{SampleStart: 200, GlobalIndex: index(1200), Frequency: 101e6, Datetime: "2024-01-01T00:00:00.001200000Z"},
+		{SampleStart: 300, GlobalIndex: index(1400), Frequency: 101e6, Datetime: "2024-01-01T00:00:00.001400000Z"},
+		{SampleStart: 400, GlobalIndex: index(1500), Frequency: 101e6, Datetime: "2024-01-01T00:00:00.001500000Z"},
+		{SampleStart: 500, Frequency: 101e6},
+	}
+
+	if !reflect.DeepEqual(metadata.Captures, expected) {
+		t.Errorf("captures:\n%+v\nexpected:\n%+v", metadata.Captures, expected)
+	}
+
+	if len(metadata.Annotations) != 1 {
+		t.Fatalf("%d annotations, expected 1", len(metadata.Annotations))
+	}
+	annotation := metadata.Annotations[0]
+	if annotation.SampleStart != 200 || annotation.Label != "gain" || annotation.Comment != "gain set to 20 dB" ||
+		annotation.Generator == "" {
+		t.Errorf("annotation %+v", annotation)
+	}
+
+	reader, err := Open(basePath + DataExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if reader.NumSamples() != 600 {
+		t.Errorf("%d samples, expected 600", reader.NumSamples())
+	}
+	if capture, found := reader.CaptureAt(350); !found || capture.SampleStart != 300 {
+		t.Errorf("capture of the sample 350: %+v, %v", capture, found)
+	}
+}
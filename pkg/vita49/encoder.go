@@ -0,0 +1,224 @@
+package vita49
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// Encoder encodes the IF Data and IF Context packets of a stream
+type Encoder struct {
+	// StreamID is the stream identifier of the packets
+	StreamID uint32
+	// TSI is the type of the integer-seconds timestamps of the packets, TSIOther by default as the hardware time of a
+	// device is usually not referenced to UTC
+	TSI TSI
+
+	payloadFormat string
+	dataCount     uint8
+	contextCount  uint8
+	context       Context
+	hasContext    bool
+	native        interface{}
+}
+
+// NewEncoder creates an encoder for a stream.
+//
+// Params:
+//  - streamID: the stream identifier of the packets
+//  - payloadFormat: the format of the samples in the packets: CS16 (16-bit I and Q in a 32-bit word) or CF32
+//    (32-bit float I and Q)
+//
+// Return the encoder or an error if the payload format is not supported
+func NewEncoder(streamID uint32, payloadFormat string) (encoder *Encoder, err error) {
+
+	if payloadFormat != sdrformat.CS16 && payloadFormat != sdrformat.CF32 {
+		return nil, fmt.Errorf("unsupported VRT payload format: %v", payloadFormat)
+	}
+
+	return &Encoder{
+		StreamID:      streamID,
+		TSI:           TSIOther,
+		payloadFormat: payloadFormat,
+	}, nil
+}
+
+// PayloadFormat returns the format of the samples in the packets
+func (encoder *Encoder) PayloadFormat() string {
+	return encoder.payloadFormat
+}
+
+// MaxSamples returns the maximum number of samples of an IF Data packet of a given size in bytes, such as the payload
+// size of an UDP datagram.
+func (encoder *Encoder) MaxSamples(packetBytes int) int {
+
+	words := packetBytes / 4
+	if words > maxPacketWords {
+		words = maxPacketWords
+	}
+
+	// The header, the stream identifier, the timestamps and the trailer
+	words -= headerWords + 1
+
+	if words <= 0 {
+		return 0
+	}
+
+	return words * 4 / sdrformat.BytesPerSample(encoder.payloadFormat)
+}
+
+// EncodeData appends an IF Data packet holding samples.
+//
+// Params:
+//  - dst: the byte slice to append to
+//  - buffer: the samples of a single channel, such as a []complex64, converted to the payload format if needed
+//  - timeNs: the hardware time in nanoseconds of the first sample
+//  - hasTime: whether the timestamp is valid. Without timestamp, the packet has no timestamp field.
+//  - trailer: the indicators of the packet
+//
+// Return the extended byte slice or an error if the samples do not fit in a packet
+func (encoder *Encoder) EncodeData(dst []byte, buffer interface{}, timeNs uint, hasTime bool, trailer Trailer) ([]byte, error) {
+
+	nbSamples, err := sdrformat.BufferLength(buffer)
+	if err != nil {
+		return dst, err
+	}
+
+	payloadWords := nbSamples * sdrformat.BytesPerSample(encoder.payloadFormat) / 4
+	if payloadWords > maxPacketWords-headerWords-1 {
+		return dst, fmt.Errorf("%d samples do not fit in a VRT packet", nbSamples)
+	}
+
+	// Convert the samples to the payload format
+	if format, _ := sdrformat.FormatOf(buffer); format != encoder.payloadFormat {
+		if length, _ := sdrformat.BufferLength(encoder.native); encoder.native == nil || length < nbSamples {
+			if encoder.native, err = sdrformat.MakeBuffer(encoder.payloadFormat, nbSamples); err != nil {
+				return dst, err
+			}
+		}
+		native, err := sdrformat.SliceBuffer(encoder.native, 0, nbSamples)
+		if err != nil {
+			return dst, err
+		}
+		if _, err := sdrformat.ConvertBuffer(native, buffer); err != nil {
+			return dst, err
+		}
+		buffer = native
+	}
+
+	tsi, tsf := encoder.timestampTypes(hasTime)
+	words := 2 + timestampWords(tsi, tsf) + payloadWords + 1
+
+	dst = appendUint32(dst, encodeHeader(PacketIFData, true, tsi, tsf, encoder.dataCount, words))
+	dst = appendUint32(dst, encoder.StreamID)
+	dst = appendTimestamp(dst, tsi, tsf, timeNs)
+
+	switch typed := buffer.(type) {
+	case []int16:
+		for _, value := range typed[:2*nbSamples] {
+			dst = append(dst, byte(uint16(value)>>8), byte(value))
+		}
+	case []complex64:
+		for _, value := range typed[:nbSamples] {
+			dst = appendUint32(dst, math.Float32bits(real(value)))
+			dst = appendUint32(dst, math.Float32bits(imag(value)))
+		}
+	}
+
+	dst = appendUint32(dst, encodeTrailer(trailer))
+	encoder.dataCount = (encoder.dataCount + 1) & 0xF
+
+	return dst, nil
+}
+
+// ContextChanged returns whether a context differs from the last context encoded
+func (encoder *Encoder) ContextChanged(context Context) bool {
+	return !encoder.hasContext || context != encoder.context
+}
+
+// EncodeContext appends an IF Context packet describing the stream.
+//
+// Params:
+//  - dst: the byte slice to append to
+//  - context: the description of the stream
+//  - timeNs: the hardware time in nanoseconds from which the context applies
+//  - hasTime: whether the timestamp is valid
+//
+// Return the extended byte slice
+func (encoder *Encoder) EncodeContext(dst []byte, context Context, timeNs uint, hasTime bool) []byte {
+
+	changed := encoder.ContextChanged(context)
+	encoder.context = context
+	encoder.hasContext = true
+
+	tsi, tsf := encoder.timestampTypes(hasTime)
+	// The indicator field, the bandwidth, the frequency, the gain and the sample rate
+	words := 2 + timestampWords(tsi, tsf) + 1 + 2 + 2 + 1 + 2
+
+	dst = appendUint32(dst, encodeHeader(PacketIFContext, false, tsi, tsf, encoder.contextCount, words))
+	dst = appendUint32(dst, encoder.StreamID)
+	dst = appendTimestamp(dst, tsi, tsf, timeNs)
+	dst = encodeContext(dst, context, changed)
+
+	encoder.contextCount = (encoder.contextCount + 1) & 0xF
+
+	return dst
+}
+
+// timestampTypes returns the types of the timestamps of a packet
+func (encoder *Encoder) timestampTypes(hasTime bool) (TSI, TSF) {
+
+	if !hasTime {
+		return TSINone, TSFNone
+	}
+
+	return encoder.TSI, TSFRealTime
+}
+
+// timestampWords returns the number of words of the timestamps
+func timestampWords(tsi TSI, tsf TSF) int {
+
+	words := 0
+	if tsi != TSINone {
+		words++
+	}
+	if tsf != TSFNone {
+		words += 2
+	}
+
+	return words
+}
+
+// appendTimestamp appends the timestamps of a packet from a time in nanoseconds
+func appendTimestamp(dst []byte, tsi TSI, tsf TSF, timeNs uint) []byte {
+
+	if tsi != TSINone {
+		dst = appendUint32(dst, uint32(uint64(timeNs)/1000000000))
+	}
+	if tsf != TSFNone {
+		dst = appendUint64(dst, uint64(timeNs)%1000000000*picosecondsPerNs)
+	}
+
+	return dst
+}
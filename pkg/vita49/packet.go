@@ -0,0 +1,428 @@
+package vita49
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the functions to encode and decode VITA 49 (VRT) packets: the IF Data packets carrying the samples and the
+// IF Context packets describing them, and to stream them over UDP.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// PacketType is the type of a VRT packet
+type PacketType uint8
+
+const (
+	// PacketIFData is an IF Data packet with a stream identifier
+	PacketIFData PacketType = 0x1
+	// PacketIFContext is an IF Context packet
+	PacketIFContext PacketType = 0x4
+)
+
+// TSI is the type of the integer-seconds timestamp of a packet
+type TSI uint8
+
+const (
+	// TSINone indicates that the packet has no integer-seconds timestamp
+	TSINone TSI = 0
+	// TSIUTC indicates an integer-seconds timestamp in UTC
+	TSIUTC TSI = 1
+	// TSIGPS indicates an integer-seconds timestamp in GPS time
+	TSIGPS TSI = 2
+	// TSIOther indicates an integer-seconds timestamp of another epoch, such as the hardware time of a device
+	TSIOther TSI = 3
+)
+
+// TSF is the type of the fractional-seconds timestamp of a packet
+type TSF uint8
+
+const (
+	// TSFNone indicates that the packet has no fractional-seconds timestamp
+	TSFNone TSF = 0
+	// TSFSampleCount indicates a fractional timestamp counting samples
+	TSFSampleCount TSF = 1
+	// TSFRealTime indicates a fractional timestamp in picoseconds
+	TSFRealTime TSF = 2
+	// TSFFreeRunning indicates a free running count fractional timestamp
+	TSFFreeRunning TSF = 3
+)
+
+// The bits of the Context Indicator Field 0 describing the fields of the context packets
+const (
+	cifChangeIndicator = 1 << 31
+	cifBandwidth       = 1 << 29
+	cifRFFrequency     = 1 << 27
+	cifGain            = 1 << 23
+	cifSampleRate      = 1 << 21
+)
+
+// The bits of the trailer of the data packets, the enable bits being 12 bits above the indicator bits
+const (
+	trailerCalibratedTime = 1 << 19
+	trailerValidData      = 1 << 18
+	trailerReferenceLock  = 1 << 17
+	trailerOverRange      = 1 << 13
+	trailerSampleLoss     = 1 << 12
+	trailerEnableShift    = 12
+)
+
+const (
+	// headerWords is the maximum number of words before the payload: header, stream identifier and timestamps
+	headerWords = 5
+	// maxPacketWords is the maximum size of a packet in 32-bit words
+	maxPacketWords = 0xFFFF
+	// picosecondsPerNs is the number of picoseconds in a nanosecond
+	picosecondsPerNs = 1000
+)
+
+// Trailer holds the state and event indicators of an IF Data packet
+type Trailer struct {
+	// CalibratedTime indicates that the timestamp is calibrated to an external reference
+	CalibratedTime bool
+	// ValidData indicates that the samples are valid
+	ValidData bool
+	// ReferenceLock indicates that the reference clock is locked
+	ReferenceLock bool
+	// OverRange indicates that some samples are saturated
+	OverRange bool
+	// SampleLoss indicates a discontinuity before the packet, such as an overflow of the stream
+	SampleLoss bool
+}
+
+// Context describes the signal of a stream, sent in IF Context packets
+type Context struct {
+	// Frequency is the RF reference frequency in Hz
+	Frequency float64
+	// SampleRate is the sample rate in samples per second
+	SampleRate float64
+	// Bandwidth is the bandwidth in Hz
+	Bandwidth float64
+	// Gain is the gain in dB
+	Gain float64
+}
+
+// Packet is a decoded VRT packet
+type Packet struct {
+	// Type is the type of the packet
+	Type PacketType
+	// StreamID is the stream identifier of the packet
+	StreamID uint32
+	// Count is the packet count, modulo 16
+	Count uint8
+	// TSI is the type of the integer-seconds timestamp
+	TSI TSI
+	// TSF is the type of the fractional-seconds timestamp
+	TSF TSF
+	// IntegerTimestamp is the integer-seconds timestamp
+	IntegerTimestamp uint32
+	// FractionalTimestamp is the fractional-seconds timestamp
+	FractionalTimestamp uint64
+	// Payload holds the samples of an IF Data packet
+	Payload []byte
+	// HasTrailer indicates that an IF Data packet has a trailer
+	HasTrailer bool
+	// Trailer holds the indicators of the trailer of an IF Data packet
+	Trailer Trailer
+	// Context holds the fields of an IF Context packet
+	Context Context
+	// ContextFields is the Context Indicator Field 0 of an IF Context packet, telling which fields are present
+	ContextFields uint32
+}
+
+// TimeNs returns the timestamp of a packet in nanoseconds, or false if the packet has no real-time timestamp
+func (packet Packet) TimeNs() (timeNs uint64, valid bool) {
+
+	if packet.TSI == TSINone || packet.TSF != TSFRealTime {
+		return 0, false
+	}
+
+	return uint64(packet.IntegerTimestamp)*1000000000 + packet.FractionalTimestamp/picosecondsPerNs, true
+}
+
+// Samples decodes the payload of an IF Data packet into the buffer of a channel. The samples are converted when the
+// format of the buffer is not the payload format.
+//
+// Params:
+//  - buffer: the buffer receiving the samples, such as a []complex64
+//  - payloadFormat: the format of the payload, CS16 or CF32
+//
+// Return the number of samples decoded
+func (packet Packet) Samples(buffer interface{}, payloadFormat string) (nbSamples int, err error) {
+
+	if packet.Type != PacketIFData {
+		return 0, errors.New("only the IF Data packets hold samples")
+	}
+
+	bytesPerSample := sdrformat.BytesPerSample(payloadFormat)
+	if bytesPerSample == 0 {
+		return 0, fmt.Errorf("unsupported VRT payload format: %v", payloadFormat)
+	}
+
+	native, err := sdrformat.MakeBuffer(payloadFormat, len(packet.Payload)/bytesPerSample)
+	if err != nil {
+		return 0, err
+	}
+
+	switch typed := native.(type) {
+	case []int16:
+		for i := range typed {
+			typed[i] = int16(binary.BigEndian.Uint16(packet.Payload[2*i:]))
+		}
+	case []complex64:
+		for i := range typed {
+			typed[i] = complex(
+				math.Float32frombits(binary.BigEndian.Uint32(packet.Payload[8*i:])),
+				math.Float32frombits(binary.BigEndian.Uint32(packet.Payload[8*i+4:])))
+		}
+	default:
+		return 0, fmt.Errorf("unsupported VRT payload format: %v", payloadFormat)
+	}
+
+	return sdrformat.ConvertBuffer(buffer, native)
+}
+
+// Decode decodes a VRT packet.
+//
+// Params:
+//  - data: the content of the packet
+//
+// Return the packet or an error if it is not a valid IF Data or IF Context packet. The payload of the packet refers
+// to data.
+func Decode(data []byte) (packet Packet, err error) {
+
+	if len(data) < 8 {
+		return packet, errors.New("the VRT packet is too short")
+	}
+
+	header := binary.BigEndian.Uint32(data)
+	packet.Type = PacketType(header >> 28)
+	packet.TSI = TSI((header >> 22) & 0x3)
+	packet.TSF = TSF((header >> 20) & 0x3)
+	packet.Count = uint8((header >> 16) & 0xF)
+	hasClassID := header&(1<<27) != 0
+	packet.HasTrailer = packet.Type == PacketIFData && header&(1<<26) != 0
+
+	size := int(header&0xFFFF) * 4
+	if size < 8 {
+		return packet, fmt.Errorf("the VRT packet announces %d bytes, less than its header", size)
+	}
+	if size > len(data) {
+		return packet, fmt.Errorf("the VRT packet announces %d bytes but has %d", size, len(data))
+	}
+	data = data[:size]
+
+	if packet.Type != PacketIFData && packet.Type != PacketIFContext {
+		return packet, fmt.Errorf("unsupported VRT packet type %d", packet.Type)
+	}
+
+	offset := 4
+	packet.StreamID = binary.BigEndian.Uint32(data[offset:])
+	offset += 4
+
+	if hasClassID {
+		if offset+8 > len(data) {
+			return packet, errors.New("the VRT packet is too short for its class identifier")
+		}
+		offset += 8
+	}
+	if packet.TSI != TSINone {
+		if offset+4 > len(data) {
+			return packet, errors.New("the VRT packet is too short for its timestamp")
+		}
+		packet.IntegerTimestamp = binary.BigEndian.Uint32(data[offset:])
+		offset += 4
+	}
+	if packet.TSF != TSFNone {
+		if offset+8 > len(data) {
+			return packet, errors.New("the VRT packet is too short for its timestamp")
+		}
+		packet.FractionalTimestamp = binary.BigEndian.Uint64(data[offset:])
+		offset += 8
+	}
+
+	end := len(data)
+	if packet.HasTrailer {
+		if end-4 < offset {
+			return packet, errors.New("the VRT packet is too short for its trailer")
+		}
+		end -= 4
+		packet.Trailer = decodeTrailer(binary.BigEndian.Uint32(data[end:]))
+	}
+
+	if offset > end {
+		return packet, errors.New("the VRT packet is too short")
+	}
+
+	if packet.Type == PacketIFData {
+		packet.Payload = data[offset:end]
+		return packet, nil
+	}
+
+	packet.ContextFields, packet.Context, err = decodeContext(data[offset:end])
+
+	return packet, err
+}
+
+// encodeHeader encodes the header word of a packet
+func encodeHeader(packetType PacketType, hasTrailer bool, tsi TSI, tsf TSF, count uint8, words int) uint32 {
+
+	header := uint32(packetType) << 28
+	if hasTrailer {
+		header |= 1 << 26
+	}
+	header |= uint32(tsi&0x3) << 22
+	header |= uint32(tsf&0x3) << 20
+	header |= uint32(count&0xF) << 16
+	header |= uint32(words) & 0xFFFF
+
+	return header
+}
+
+// encodeTrailer encodes the trailer word of a data packet. All the indicators of the trailer are enabled.
+func encodeTrailer(trailer Trailer) uint32 {
+
+	indicators := []struct {
+		bit uint32
+		set bool
+	}{
+		{trailerCalibratedTime, trailer.CalibratedTime},
+		{trailerValidData, trailer.ValidData},
+		{trailerReferenceLock, trailer.ReferenceLock},
+		{trailerOverRange, trailer.OverRange},
+		{trailerSampleLoss, trailer.SampleLoss},
+	}
+
+	word := uint32(0)
+	for _, indicator := range indicators {
+		word |= indicator.bit << trailerEnableShift
+		if indicator.set {
+			word |= indicator.bit
+		}
+	}
+
+	return word
+}
+
+// decodeTrailer decodes the trailer word of a data packet. The indicators not enabled are false.
+func decodeTrailer(word uint32) Trailer {
+
+	isSet := func(bit uint32) bool {
+		return word&(bit<<trailerEnableShift) != 0 && word&bit != 0
+	}
+
+	return Trailer{
+		CalibratedTime: isSet(trailerCalibratedTime),
+		ValidData:      isSet(trailerValidData),
+		ReferenceLock:  isSet(trailerReferenceLock),
+		OverRange:      isSet(trailerOverRange),
+		SampleLoss:     isSet(trailerSampleLoss),
+	}
+}
+
+// encodeContext appends the Context Indicator Field 0 and the fields of a context
+func encodeContext(dst []byte, context Context, changed bool) []byte {
+
+	cif := uint32(cifBandwidth | cifRFFrequency | cifGain | cifSampleRate)
+	if changed {
+		cif |= cifChangeIndicator
+	}
+
+	dst = appendUint32(dst, cif)
+	// The fields follow the order of the bits of the indicator field, from the most significant
+	dst = appendUint64(dst, uint64(toFixed(context.Bandwidth, 20)))
+	dst = appendUint64(dst, uint64(toFixed(context.Frequency, 20)))
+	dst = appendUint32(dst, uint32(uint16(toFixed(context.Gain, 7))))
+	dst = appendUint64(dst, uint64(toFixed(context.SampleRate, 20)))
+
+	return dst
+}
+
+// decodeContext decodes the fields of a context packet supported by the encoder. The decoding stops at the first
+// field which is not supported, as its size is unknown.
+func decodeContext(data []byte) (cif uint32, context Context, err error) {
+
+	if len(data) < 4 {
+		return 0, context, errors.New("the VRT context packet is too short")
+	}
+
+	cif = binary.BigEndian.Uint32(data)
+	data = data[4:]
+
+	fields := []struct {
+		bit   uint32
+		size  int
+		value func([]byte)
+	}{
+		{1 << 30, 4, nil},
+		{cifBandwidth, 8, func(b []byte) { context.Bandwidth = fromFixed(int64(binary.BigEndian.Uint64(b)), 20) }},
+		{1 << 28, 8, nil},
+		{cifRFFrequency, 8, func(b []byte) { context.Frequency = fromFixed(int64(binary.BigEndian.Uint64(b)), 20) }},
+		{1 << 26, 8, nil},
+		{1 << 25, 8, nil},
+		{1 << 24, 4, nil},
+		{cifGain, 4, func(b []byte) {
+			word := binary.BigEndian.Uint32(b)
+			context.Gain = fromFixed(int64(int16(word)), 7) + fromFixed(int64(int16(word>>16)), 7)
+		}},
+		{1 << 22, 4, nil},
+		{cifSampleRate, 8, func(b []byte) { context.SampleRate = fromFixed(int64(binary.BigEndian.Uint64(b)), 20) }},
+	}
+
+	for _, field := range fields {
+		if cif&field.bit == 0 {
+			continue
+		}
+		if len(data) < field.size {
+			return cif, context, errors.New("the VRT context packet is too short for its fields")
+		}
+		if field.value != nil {
+			field.value(data)
+		}
+		data = data[field.size:]
+	}
+
+	return cif, context, nil
+}
+
+// toFixed converts a value to a fixed point number with the given number of fractional bits
+func toFixed(value float64, radix uint) int64 {
+	return int64(math.Round(value * float64(uint64(1)<<radix)))
+}
+
+// fromFixed converts a fixed point number with the given number of fractional bits to a value
+func fromFixed(value int64, radix uint) float64 {
+	return float64(value) / float64(uint64(1)<<radix)
+}
+
+// appendUint32 appends an uint32 in big endian
+func appendUint32(dst []byte, value uint32) []byte {
+	return append(dst, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+}
+
+// appendUint64 appends an uint64 in big endian
+func appendUint64(dst []byte, value uint64) []byte {
+	return appendUint32(appendUint32(dst, uint32(value>>32)), uint32(value))
+}
@@ -0,0 +1,263 @@
+package vita49
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// testTimeNs is a hardware time with both integer and fractional seconds
+const testTimeNs = 1234567890123456789
+
+// testSamples returns samples of a channel in a payload format, with distinct values
+func testSamples(format string, nbSamples int) interface{} {
+
+	if format == sdrformat.CS16 {
+		samples := make([]int16, 2*nbSamples)
+		for i := range samples {
+			samples[i] = int16(i*997 - 30000)
+		}
+		return samples
+	}
+
+	samples := make([]complex64, nbSamples)
+	for i := range samples {
+		samples[i] = complex(float32(i)/3, -float32(i)/7)
+	}
+	return samples
+}
+
+func TestEncodeHeader(t *testing.T) {
+
+	if header := encodeHeader(PacketIFData, true, TSIOther, TSFRealTime, 5, 10); header != 0x14E5000A {
+		t.Errorf("data header %#08x, expected 0x14E5000A", header)
+	}
+
+	if header := encodeHeader(PacketIFContext, false, TSINone, TSFNone, 0x1F, 0x12345); header != 0x400F2345 {
+		t.Errorf("context header %#08x, expected 0x400F2345", header)
+	}
+}
+
+func TestTrailerRoundTrip(t *testing.T) {
+
+	if word := encodeTrailer(Trailer{ValidData: true, SampleLoss: true}); word != 0xE3041000 {
+		t.Errorf("trailer %#08x, expected 0xE3041000", word)
+	}
+
+	for i := 0; i < 32; i++ {
+		trailer := Trailer{
+			CalibratedTime: i&1 != 0,
+			ValidData:      i&2 != 0,
+			ReferenceLock:  i&4 != 0,
+			OverRange:      i&8 != 0,
+			SampleLoss:     i&16 != 0,
+		}
+		if decoded := decodeTrailer(encodeTrailer(trailer)); decoded != trailer {
+			t.Errorf("trailer %+v decoded as %+v", trailer, decoded)
+		}
+	}
+
+	// The indicators which are not enabled are ignored
+	if decoded := decodeTrailer(trailerValidData | trailerSampleLoss); decoded != (Trailer{}) {
+		t.Errorf("trailer without enable bits decoded as %+v", decoded)
+	}
+}
+
+func TestDataRoundTrip(t *testing.T) {
+
+	for _, format := range []string{sdrformat.CS16, sdrformat.CF32} {
+		for _, hasTime := range []bool{false, true} {
+
+			encoder, err := NewEncoder(0x12345678, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			samples := testSamples(format, 100)
+			trailer := Trailer{ValidData: true, SampleLoss: true}
+
+			// The packet count wraps after 16 packets
+			for i := 0; i < 20; i++ {
+
+				data, err := encoder.EncodeData(nil, samples, testTimeNs, hasTime, trailer)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(data)%4 != 0 {
+					t.Fatalf("%v packet of %d bytes, not a multiple of 32-bit words", format, len(data))
+				}
+
+				packet, err := Decode(data)
+				if err != nil {
+					t.Fatalf("%v packet: %v", format, err)
+				}
+
+				if packet.Type != PacketIFData || packet.StreamID != 0x12345678 || packet.Count != uint8(i%16) {
+					t.Fatalf("%v packet %d decoded as type %d, stream %#x, count %d", format, i, packet.Type, packet.StreamID, packet.Count)
+				}
+				if !packet.HasTrailer || packet.Trailer != trailer {
+					t.Fatalf("%v packet trailer %+v, expected %+v", format, packet.Trailer, trailer)
+				}
+
+				timeNs, valid := packet.TimeNs()
+				if valid != hasTime || (hasTime && timeNs != testTimeNs) {
+					t.Fatalf("%v packet time %d (%v), expected %d (%v)", format, timeNs, valid, uint64(testTimeNs), hasTime)
+				}
+				if hasTime && (packet.TSI != TSIOther || packet.TSF != TSFRealTime) {
+					t.Fatalf("%v packet timestamp types %d and %d", format, packet.TSI, packet.TSF)
+				}
+			}
+
+			packet, _ := Decode(mustEncodeData(t, encoder, samples))
+
+			decoded, _ := sdrformat.MakeBuffer(format, 100)
+			nbSamples, err := packet.Samples(decoded, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nbSamples != 100 {
+				t.Fatalf("%d %v samples decoded, expected 100", nbSamples, format)
+			}
+
+			switch expected := samples.(type) {
+			case []int16:
+				for i, value := range decoded.([]int16) {
+					if value != expected[i] {
+						t.Fatalf("%v value %d decoded as %d, expected %d", format, i, value, expected[i])
+					}
+				}
+			case []complex64:
+				for i, value := range decoded.([]complex64) {
+					if value != expected[i] {
+						t.Fatalf("%v sample %d decoded as %v, expected %v", format, i, value, expected[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+// mustEncodeData encodes an IF Data packet with a timestamp
+func mustEncodeData(t *testing.T, encoder *Encoder, samples interface{}) []byte {
+
+	data, err := encoder.EncodeData(nil, samples, testTimeNs, true, Trailer{ValidData: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestContextRoundTrip(t *testing.T) {
+
+	encoder, err := NewEncoder(7, sdrformat.CS16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contexts := []Context{
+		{Frequency: 100.1e6, SampleRate: 2.4e6, Bandwidth: 2e6, Gain: 30.5},
+		{Frequency: 100.1e6, SampleRate: 2.4e6, Bandwidth: 2e6, Gain: 30.5},
+		{Frequency: 5.8e9, SampleRate: 61.44e6, Bandwidth: 56e6, Gain: -10.25},
+	}
+	changed := []bool{true, false, true}
+
+	for i, context := range contexts {
+
+		packet, err := Decode(encoder.EncodeContext(nil, context, testTimeNs, true))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if packet.Type != PacketIFContext || packet.StreamID != 7 || packet.HasTrailer {
+			t.Fatalf("context packet decoded as type %d, stream %d, trailer %v", packet.Type, packet.StreamID, packet.HasTrailer)
+		}
+
+		fields := uint32(cifBandwidth | cifRFFrequency | cifGain | cifSampleRate)
+		if changed[i] {
+			fields |= cifChangeIndicator
+		}
+		if packet.ContextFields != fields {
+			t.Fatalf("context %d fields %#08x, expected %#08x", i, packet.ContextFields, fields)
+		}
+
+		// The frequencies have 20 fractional bits and the gain 7
+		decoded := packet.Context
+		if math.Abs(decoded.Frequency-context.Frequency) > 1e-6 || math.Abs(decoded.SampleRate-context.SampleRate) > 1e-6 ||
+			math.Abs(decoded.Bandwidth-context.Bandwidth) > 1e-6 || decoded.Gain != context.Gain {
+			t.Fatalf("context %+v decoded as %+v", context, decoded)
+		}
+
+		if timeNs, valid := packet.TimeNs(); !valid || timeNs != testTimeNs {
+			t.Fatalf("context time %d (%v), expected %d", timeNs, valid, uint64(testTimeNs))
+		}
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+
+	encoder, err := NewEncoder(1, sdrformat.CF32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := mustEncodeData(t, encoder, testSamples(sdrformat.CF32, 10))
+	context := encoder.EncodeContext(nil, Context{Frequency: 1e9}, testTimeNs, true)
+
+	for _, packet := range [][]byte{data, context} {
+		for n := 0; n < len(packet); n++ {
+			if _, err := Decode(packet[:n]); err == nil {
+				t.Fatalf("packet truncated to %d of %d bytes decoded without error", n, len(packet))
+			}
+		}
+		if _, err := Decode(append(append([]byte(nil), packet...), 0, 0, 0, 0)); err != nil {
+			t.Fatalf("packet followed by padding: %v", err)
+		}
+	}
+
+	// Packets whose size field is too small for the fields announced by their header
+	tests := []struct {
+		name   string
+		header uint32
+	}{
+		{"size below the header", encodeHeader(PacketIFData, true, TSINone, TSFNone, 0, 1)},
+		{"timestamps", encodeHeader(PacketIFData, true, TSIOther, TSFRealTime, 0, 3)},
+		{"trailer", encodeHeader(PacketIFData, true, TSINone, TSFNone, 0, 2)},
+		{"class identifier", encodeHeader(PacketIFData, false, TSINone, TSFNone, 0, 3) | 1<<27},
+		{"context fields", encodeHeader(PacketIFContext, false, TSINone, TSFNone, 0, 4)},
+		{"unknown type", encodeHeader(PacketType(0x7), false, TSINone, TSFNone, 0, 2)},
+	}
+
+	for _, test := range tests {
+		packet := appendUint32(nil, test.header)
+		packet = appendUint32(packet, 1)
+		// The context indicator field announces all the supported fields
+		packet = appendUint32(packet, cifBandwidth|cifRFFrequency|cifGain|cifSampleRate)
+		packet = append(packet, make([]byte, 64)...)
+
+		if _, err := Decode(packet); err == nil {
+			t.Errorf("packet too short for its %s decoded without error", test.name)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package vita49
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"errors"
+	"net"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+	"github.com/bhojpur/sdr/pkg/sdrtime"
+)
+
+const (
+	// DefaultPacketBytes is the default maximum size of the packets sent, fitting in an Ethernet frame
+	DefaultPacketBytes = 1472
+	// maxDatagramBytes is the maximum size of an UDP datagram
+	maxDatagramBytes = 65536
+)
+
+// Sender sends the packets of a stream over UDP
+type Sender struct {
+	// PacketBytes is the maximum size in bytes of the packets sent, DefaultPacketBytes by default
+	PacketBytes int
+	// ContextSource, when set, returns the current context of the stream, such as a function calling
+	// vitadevice.Context. It is read once per block given to SendBlock, and an IF Context packet is sent before the
+	// data packets of the block when the context changed.
+	ContextSource func() Context
+
+	encoder    *Encoder
+	conn       net.Conn
+	sampleRate float64
+	packet     []byte
+}
+
+// NewSender creates a sender of the packets of a stream.
+//
+// Params:
+//  - address: the UDP address of the receiver, such as "192.168.1.10:4991"
+//  - streamID: the stream identifier of the packets
+//  - payloadFormat: the format of the samples in the packets, CS16 or CF32
+//
+// Return the sender or an error
+func NewSender(address string, streamID uint32, payloadFormat string) (sender *Sender, err error) {
+
+	encoder, err := NewEncoder(streamID, payloadFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sender{
+		PacketBytes: DefaultPacketBytes,
+		encoder:     encoder,
+		conn:        conn,
+	}, nil
+}
+
+// Encoder returns the encoder of the sender, to change the type of the timestamps for example
+func (sender *Sender) Encoder() *Encoder {
+	return sender.encoder
+}
+
+// SendContext sends an IF Context packet when the context differs from the last one sent.
+//
+// Params:
+//  - context: the description of the stream
+//  - timeNs: the hardware time in nanoseconds from which the context applies
+//  - hasTime: whether the timestamp is valid
+//
+// Return whether a packet was sent and an error
+func (sender *Sender) SendContext(context Context, timeNs uint, hasTime bool) (sent bool, err error) {
+
+	if !sender.encoder.ContextChanged(context) {
+		return false, nil
+	}
+
+	sender.sampleRate = context.SampleRate
+	sender.packet = sender.encoder.EncodeContext(sender.packet[:0], context, timeNs, hasTime)
+
+	if _, err := sender.conn.Write(sender.packet); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SendBlock sends a block of samples read from a RX stream, split in IF Data packets of at most PacketBytes bytes.
+// The timestamps of the packets following the first one are derived from the sample rate of the last context sent;
+// without context, only the first packet has a timestamp. When ContextSource is set, it is read once for the block
+// and an IF Context packet is sent before the data packets if the context changed.
+//
+// Params:
+//  - buffer: the samples of a single channel, such as a []complex64
+//  - timeNs: the hardware time in nanoseconds of the first sample
+//  - hasTime: whether the timestamp is valid
+//  - overflow: whether samples were lost before the block, for example after an overflow of the stream. It sets the
+//    sample loss indicator of the first packet.
+//
+// Return an error or nil in case of success
+func (sender *Sender) SendBlock(buffer interface{}, timeNs uint, hasTime bool, overflow bool) error {
+
+	nbSamples, err := sdrformat.BufferLength(buffer)
+	if err != nil {
+		return err
+	}
+
+	packetSamples := sender.encoder.MaxSamples(sender.PacketBytes)
+	if packetSamples <= 0 {
+		return errors.New("the packet size is too small to hold samples")
+	}
+
+	// Reading the context may query the device, so it is only done once per block
+	if sender.ContextSource != nil {
+		if _, err := sender.SendContext(sender.ContextSource(), timeNs, hasTime); err != nil {
+			return err
+		}
+	}
+
+	for offset := 0; offset < nbSamples; offset += packetSamples {
+
+		end := offset + packetSamples
+		if end > nbSamples {
+			end = nbSamples
+		}
+
+		block, err := sdrformat.SliceBuffer(buffer, offset, end)
+		if err != nil {
+			return err
+		}
+
+		packetTimeNs := timeNs
+		packetHasTime := hasTime
+		if offset > 0 {
+			packetHasTime = hasTime && sender.sampleRate > 0
+			if packetHasTime {
+				packetTimeNs += uint(sdrtime.TicksToTimeNs64(int64(offset), sender.sampleRate))
+			}
+		}
+
+		trailer := Trailer{
+			ValidData:  true,
+			SampleLoss: overflow && offset == 0,
+		}
+
+		sender.packet, err = sender.encoder.EncodeData(sender.packet[:0], block, packetTimeNs, packetHasTime, trailer)
+		if err != nil {
+			return err
+		}
+
+		if _, err := sender.conn.Write(sender.packet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the connection of the sender
+func (sender *Sender) Close() error {
+	return sender.conn.Close()
+}
+
+// Receiver receives VRT packets over UDP
+type Receiver struct {
+	conn   *net.UDPConn
+	buffer []byte
+}
+
+// Listen creates a receiver of VRT packets.
+//
+// Params:
+//  - address: the local UDP address to listen on, such as ":4991"
+//
+// Return the receiver or an error
+func Listen(address string) (receiver *Receiver, err error) {
+
+	udpAddress, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", udpAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Receiver{
+		conn:   conn,
+		buffer: make([]byte, maxDatagramBytes),
+	}, nil
+}
+
+// Conn returns the connection of the receiver, to set deadlines for example
+func (receiver *Receiver) Conn() *net.UDPConn {
+	return receiver.conn
+}
+
+// Receive waits for the next packet and decodes it. The payload of the packet is a copy which remains valid after the
+// next call.
+//
+// Return the packet or an error
+func (receiver *Receiver) Receive() (packet Packet, err error) {
+
+	n, _, err := receiver.conn.ReadFromUDP(receiver.buffer)
+	if err != nil {
+		return packet, err
+	}
+
+	packet, err = Decode(receiver.buffer[:n])
+	if err != nil {
+		return packet, err
+	}
+
+	if packet.Payload != nil {
+		packet.Payload = append([]byte(nil), packet.Payload...)
+	}
+
+	return packet, nil
+}
+
+// Close closes the connection of the receiver
+func (receiver *Receiver) Close() error {
+	return receiver.conn.Close()
+}
@@ -0,0 +1,97 @@
+package vita49
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+func TestSenderContextSource(t *testing.T) {
+
+	receiver, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer receiver.Close()
+
+	sender, err := NewSender(receiver.Conn().LocalAddr().String(), 3, sdrformat.CS16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	// 19 samples per packet: 25 words minus the header, the stream identifier, the timestamps and the trailer
+	sender.PacketBytes = 100
+
+	reads := 0
+	sender.ContextSource = func() Context {
+		reads++
+		return Context{Frequency: 100e6, SampleRate: 1e6}
+	}
+
+	receive := func() Packet {
+		receiver.Conn().SetReadDeadline(time.Now().Add(5 * time.Second))
+		packet, err := receiver.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return packet
+	}
+
+	for block := 0; block < 2; block++ {
+
+		if err := sender.SendBlock(testSamples(sdrformat.CS16, 50), testTimeNs, true, block == 0); err != nil {
+			t.Fatal(err)
+		}
+
+		if reads != block+1 {
+			t.Fatalf("context read %d times for %d blocks", reads, block+1)
+		}
+
+		// The context is only sent with the first block, as it does not change
+		if block == 0 {
+			if packet := receive(); packet.Type != PacketIFContext || packet.Context.Frequency != 100e6 {
+				t.Fatalf("packet of type %d, expected a context packet", packet.Type)
+			}
+		}
+
+		for i, expected := range []int{19, 19, 12} {
+
+			packet := receive()
+			if packet.Type != PacketIFData || len(packet.Payload) != 4*expected {
+				t.Fatalf("packet %d of type %d with %d bytes, expected %d samples", i, packet.Type, len(packet.Payload), expected)
+			}
+
+			// The timestamps follow the sample rate of the context
+			timeNs, _ := packet.TimeNs()
+			if expectedNs := uint64(testTimeNs + 19000*i); timeNs != expectedNs {
+				t.Fatalf("packet %d time %d, expected %d", i, timeNs, expectedNs)
+			}
+
+			if packet.Trailer.SampleLoss != (block == 0 && i == 0) {
+				t.Fatalf("packet %d of block %d with sample loss %v", i, block, packet.Trailer.SampleLoss)
+			}
+		}
+	}
+}
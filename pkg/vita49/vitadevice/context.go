@@ -0,0 +1,47 @@
+package vitadevice
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the helpers describing the streams of a device in VITA 49 (VRT) context packets. They are kept apart from
+// the vita49 package, which encodes and decodes the packets without depending on SoapySDR.
+
+import (
+	"github.com/bhojpur/sdr/pkg/device"
+	"github.com/bhojpur/sdr/pkg/vita49"
+)
+
+// Context reads the context of a channel of a device: its frequency, sample rate, bandwidth and gain.
+//
+// Params:
+//  - dev: the device streaming the channel
+//  - direction: the direction of the stream
+//  - channel: the channel of the stream
+//
+// Return the context of the channel
+func Context(dev *device.SDRDevice, direction device.Direction, channel uint) vita49.Context {
+
+	return vita49.Context{
+		Frequency:  dev.GetFrequency(direction, channel),
+		SampleRate: dev.GetSampleRate(direction, channel),
+		Bandwidth:  dev.GetBandwidth(direction, channel),
+		Gain:       dev.GetGain(direction, channel),
+	}
+}
@@ -0,0 +1,152 @@
+package wav
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// It groups the functions to read and write IQ recordings in the WAV format used by SDR#, HDSDR and SDRuno: a
+// 2-channel PCM or float WAV file, the left channel holding I and the right channel Q, with an optional "auxi" chunk
+// holding the center frequency and the time of the recording. Files over 4 GB use the RF64 extension.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+const (
+	// formatPCM is the WAV format tag of the integer samples
+	formatPCM = 1
+	// formatFloat is the WAV format tag of the float samples
+	formatFloat = 3
+	// ds64Size is the size of the ds64 chunk of the RF64 files, without table
+	ds64Size = 28
+	// auxiSize is the size of the auxi chunk written
+	auxiSize = 72
+	// maxRIFFSize is the maximum size of a RIFF file, above which RF64 is used
+	maxRIFFSize = 0xFFFFFFFF
+	// maxMetadataChunkSize is the maximum size of the ds64, fmt and auxi chunks read
+	maxMetadataChunkSize = 64 * 1024
+)
+
+// Header describes an IQ recording
+type Header struct {
+	// Format is the stream format of the samples: CU8 for 8-bit PCM, CS16 for 16-bit PCM or CF32 for 32-bit float
+	Format string
+	// SampleRate is the sample rate in samples per second
+	SampleRate uint32
+	// CenterFrequency is the center frequency in Hz, or 0 if unknown. It is stored in the auxi chunk.
+	CenterFrequency uint32
+	// StartTime is the time of the first sample, or the zero time if unknown. It is stored in the auxi chunk.
+	StartTime time.Time
+	// StopTime is the time of the end of the recording, or the zero time if unknown. It is stored in the auxi chunk.
+	StopTime time.Time
+	// HasAuxi indicates that the recording has an auxi chunk
+	HasAuxi bool
+}
+
+// bitsPerSample returns the WAV format tag and the number of bits per I or Q value of a stream format
+func bitsPerSample(format string) (tag uint16, bits uint16, err error) {
+
+	switch format {
+	case sdrformat.CU8:
+		return formatPCM, 8, nil
+	case sdrformat.CS16:
+		return formatPCM, 16, nil
+	case sdrformat.CF32:
+		return formatFloat, 32, nil
+	}
+
+	return 0, 0, fmt.Errorf("%w: %v can not be stored in a WAV IQ file", sdrformat.ErrUnknownFormat, format)
+}
+
+// streamFormat returns the stream format matching a WAV format tag and a number of bits per I or Q value
+func streamFormat(tag uint16, bits uint16) (format string, err error) {
+
+	switch {
+	case tag == formatPCM && bits == 8:
+		return sdrformat.CU8, nil
+	case tag == formatPCM && bits == 16:
+		return sdrformat.CS16, nil
+	case tag == formatFloat && bits == 32:
+		return sdrformat.CF32, nil
+	}
+
+	return "", fmt.Errorf("unsupported WAV samples: format %d with %d bits", tag, bits)
+}
+
+// encodeAuxi encodes the auxi chunk content: the start and stop times as SYSTEMTIME structures, followed by the center
+// frequency, the sample rate, the IF frequency, the bandwidth, the IQ offset and unused fields.
+func encodeAuxi(header Header) []byte {
+
+	content := make([]byte, auxiSize)
+	encodeSystemTime(content[0:16], header.StartTime)
+	encodeSystemTime(content[16:32], header.StopTime)
+	binary.LittleEndian.PutUint32(content[32:], header.CenterFrequency)
+	binary.LittleEndian.PutUint32(content[36:], header.SampleRate)
+	binary.LittleEndian.PutUint32(content[44:], header.SampleRate)
+
+	return content
+}
+
+// decodeAuxi decodes the auxi chunk content into a header
+func decodeAuxi(content []byte, header *Header) {
+
+	if len(content) >= 16 {
+		header.StartTime = decodeSystemTime(content[0:16])
+	}
+	if len(content) >= 32 {
+		header.StopTime = decodeSystemTime(content[16:32])
+	}
+	if len(content) >= 36 {
+		header.CenterFrequency = binary.LittleEndian.Uint32(content[32:])
+	}
+	header.HasAuxi = true
+}
+
+// encodeSystemTime encodes a time as a Windows SYSTEMTIME structure, in UTC. The zero time is encoded as zeros.
+func encodeSystemTime(dst []byte, t time.Time) {
+
+	if t.IsZero() {
+		return
+	}
+
+	t = t.UTC()
+	fields := []int{t.Year(), int(t.Month()), int(t.Weekday()), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond() / int(time.Millisecond)}
+	for i, field := range fields {
+		binary.LittleEndian.PutUint16(dst[2*i:], uint16(field))
+	}
+}
+
+// decodeSystemTime decodes a Windows SYSTEMTIME structure, considered in UTC. Zeros are decoded as the zero time.
+func decodeSystemTime(src []byte) time.Time {
+
+	var fields [8]int
+	for i := range fields {
+		fields[i] = int(binary.LittleEndian.Uint16(src[2*i:]))
+	}
+
+	if fields[0] == 0 {
+		return time.Time{}
+	}
+
+	return time.Date(fields[0], time.Month(fields[1]), fields[3], fields[4], fields[5], fields[6], fields[7]*int(time.Millisecond), time.UTC)
+}
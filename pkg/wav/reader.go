@@ -0,0 +1,276 @@
+package wav
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// Reader reads an IQ recording from a WAV or RF64 file
+type Reader struct {
+	// Header is the description of the recording
+	Header Header
+
+	file       *os.File
+	reader     *bufio.Reader
+	dataOffset int64
+	nbSamples  uint64
+	position   uint64
+	raw        []byte
+	native     interface{}
+}
+
+// Open opens an IQ recording in a WAV or RF64 file.
+//
+// Params:
+//  - path: the path of the file
+//
+// Return the reader or an error
+func Open(path string) (reader *Reader, err error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err = parse(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("invalid WAV IQ file %v: %w", path, err)
+	}
+
+	return reader, nil
+}
+
+// parse reads the chunks of the file up to the data chunk
+func parse(file *os.File) (*Reader, error) {
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	riff := make([]byte, 12)
+	if _, err := io.ReadFull(file, riff); err != nil {
+		return nil, err
+	}
+
+	id := string(riff[0:4])
+	if (id != "RIFF" && id != "RF64") || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF WAVE file")
+	}
+
+	reader := &Reader{file: file}
+	var tag, bits, nbChannels uint16
+	var ds64DataSize uint64
+	hasFmt := false
+
+	offset := int64(12)
+	for {
+		chunkHeader := make([]byte, 8)
+		if _, err := file.ReadAt(chunkHeader, offset); err != nil {
+			return nil, errors.New("no data chunk found")
+		}
+
+		chunkID := string(chunkHeader[0:4])
+		chunkSize := uint64(binary.LittleEndian.Uint32(chunkHeader[4:]))
+		offset += 8
+
+		if chunkID == "data" {
+			if !hasFmt {
+				return nil, errors.New("the fmt chunk must precede the data chunk")
+			}
+			if chunkSize == maxRIFFSize && id == "RF64" {
+				chunkSize = ds64DataSize
+			}
+			// Recordings interrupted before their header was completed have a wrong data size
+			if remaining := uint64(info.Size() - offset); chunkSize == 0 || chunkSize > remaining {
+				chunkSize = remaining
+			}
+			reader.dataOffset = offset
+			reader.nbSamples = chunkSize / uint64(sdrformat.BytesPerSample(reader.Header.Format))
+			break
+		}
+
+		// Only the chunks describing the recording are read, the others are skipped
+		var content []byte
+		switch chunkID {
+		case "ds64", "fmt ", "auxi":
+			if chunkSize > maxMetadataChunkSize {
+				return nil, fmt.Errorf("the %q chunk of %d bytes is too large", chunkID, chunkSize)
+			}
+			content = make([]byte, chunkSize)
+			if _, err := file.ReadAt(content, offset); err != nil {
+				return nil, err
+			}
+		}
+
+		switch chunkID {
+		case "ds64":
+			if len(content) < 16 {
+				return nil, errors.New("invalid ds64 chunk")
+			}
+			ds64DataSize = binary.LittleEndian.Uint64(content[8:])
+		case "fmt ":
+			if len(content) < 16 {
+				return nil, errors.New("invalid fmt chunk")
+			}
+			tag = binary.LittleEndian.Uint16(content[0:])
+			nbChannels = binary.LittleEndian.Uint16(content[2:])
+			reader.Header.SampleRate = binary.LittleEndian.Uint32(content[4:])
+			bits = binary.LittleEndian.Uint16(content[14:])
+			// WAVE_FORMAT_EXTENSIBLE stores the actual format tag in the sub format
+			if tag == 0xFFFE && len(content) >= 26 {
+				tag = binary.LittleEndian.Uint16(content[24:])
+			}
+			if nbChannels != 2 {
+				return nil, fmt.Errorf("an IQ file must have 2 channels, not %d", nbChannels)
+			}
+			if reader.Header.Format, err = streamFormat(tag, bits); err != nil {
+				return nil, err
+			}
+			hasFmt = true
+		case "auxi":
+			decodeAuxi(content, &reader.Header)
+		}
+
+		// The chunks are aligned on 2 bytes
+		offset += int64(chunkSize + chunkSize%2)
+	}
+
+	if _, err := file.Seek(reader.dataOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	reader.reader = bufio.NewReader(file)
+
+	return reader, nil
+}
+
+// NumSamples returns the number of samples of the recording
+func (reader *Reader) NumSamples() uint64 {
+	return reader.nbSamples
+}
+
+// Position returns the index of the next sample to read
+func (reader *Reader) Position() uint64 {
+	return reader.position
+}
+
+// Seek moves the position of the reader.
+//
+// Params:
+//  - index: the index of the next sample to read
+//
+// Return an error or nil in case of success
+func (reader *Reader) Seek(index uint64) error {
+
+	if index > reader.nbSamples {
+		return errors.New("can not seek after the end of the recording")
+	}
+
+	offset := reader.dataOffset + int64(index)*int64(sdrformat.BytesPerSample(reader.Header.Format))
+	if _, err := reader.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader.reader.Reset(reader.file)
+	reader.position = index
+
+	return nil
+}
+
+// Read reads the next samples of the recording. When the format of the buffers is not the format of the recording,
+// the samples are converted.
+//
+// Params:
+//  - buffers: the buffers of a single channel, for example a [][]complex64
+//
+// Return the number of samples read, and io.EOF at the end of the recording
+func (reader *Reader) Read(buffers interface{}) (nbSamples int, err error) {
+
+	nbChannels, length, err := sdrformat.BuffersLength(buffers)
+	if err != nil {
+		return 0, err
+	}
+
+	if nbChannels != 1 {
+		return 0, fmt.Errorf("a WAV IQ file holds a single channel, %d buffers given", nbChannels)
+	}
+
+	if remaining := reader.nbSamples - reader.position; uint64(length) > remaining {
+		length = int(remaining)
+	}
+
+	if length == 0 {
+		return 0, io.EOF
+	}
+
+	size := length * sdrformat.BytesPerSample(reader.Header.Format)
+	if cap(reader.raw) < size {
+		reader.raw = make([]byte, size)
+	}
+	raw := reader.raw[:size]
+
+	if _, err := io.ReadFull(reader.reader, raw); err != nil {
+		return 0, err
+	}
+
+	if format, _ := sdrformat.FormatOf(buffers); format == reader.Header.Format {
+		nbSamples, err = sdrformat.Decode(buffers, raw)
+	} else {
+		if _, nativeLength, _ := sdrformat.BuffersLength(reader.native); reader.native == nil || nativeLength < length {
+			if reader.native, err = sdrformat.MakeBuffers(reader.Header.Format, 1, length); err != nil {
+				return 0, err
+			}
+		}
+
+		native, sliceErr := sdrformat.SliceBuffers(reader.native, 0, length)
+		if sliceErr != nil {
+			return 0, sliceErr
+		}
+
+		if _, err := sdrformat.Decode(native, raw); err != nil {
+			return 0, err
+		}
+
+		nbSamples, err = sdrformat.ConvertBuffers(buffers, native)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	reader.position += uint64(nbSamples)
+
+	return nbSamples, nil
+}
+
+// Close closes the file
+func (reader *Reader) Close() error {
+	return reader.file.Close()
+}
@@ -0,0 +1,321 @@
+package wav
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// testSamples returns the buffers of a single channel holding distinct samples
+func testSamples(t *testing.T, format string, nbSamples int, seed int) interface{} {
+
+	buffers, err := sdrformat.MakeBuffers(format, 1, nbSamples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch typed := buffers.(type) {
+	case [][]uint8:
+		for i := range typed[0] {
+			typed[0][i] = uint8(i*7 + seed)
+		}
+	case [][]int16:
+		for i := range typed[0] {
+			typed[0][i] = int16(i*997 - 30000 + seed)
+		}
+	case [][]complex64:
+		for i := range typed[0] {
+			typed[0][i] = complex(float32(i+seed)/1000, -float32(i)/3)
+		}
+	}
+
+	return buffers
+}
+
+// readAll reads all the samples of a recording in its own format
+func readAll(t *testing.T, reader *Reader) interface{} {
+
+	buffers, err := sdrformat.MakeBuffers(reader.Header.Format, 1, int(reader.NumSamples()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nbSamples, err := reader.Read(buffers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(nbSamples) != reader.NumSamples() {
+		t.Fatalf("%d samples read, expected %d", nbSamples, reader.NumSamples())
+	}
+
+	if _, err := reader.Read(buffers); err != io.EOF {
+		t.Fatalf("read after the end of the recording returned %v, expected EOF", err)
+	}
+
+	return buffers
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	startTime := time.Date(2022, 3, 4, 5, 6, 7, 890*int(time.Millisecond), time.UTC)
+
+	headers := []Header{
+		{SampleRate: 2048000, CenterFrequency: 100000000, StartTime: startTime},
+		{SampleRate: 48000},
+	}
+
+	for _, format := range []string{sdrformat.CU8, sdrformat.CS16, sdrformat.CF32} {
+		for _, header := range headers {
+
+			header.Format = format
+			path := filepath.Join(t.TempDir(), "iq.wav")
+
+			writer, err := Create(path, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			first := testSamples(t, format, 1000, 0)
+			second := testSamples(t, format, 24000, 1)
+			if err := writer.Write(first, 1000); err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Write(second, 24000); err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := header
+			expected.HasAuxi = !header.StartTime.IsZero()
+			if expected.HasAuxi {
+				// 25000 samples at 2048000 samples per second, the SYSTEMTIME having a millisecond resolution
+				expected.StopTime = startTime.Add(12 * time.Millisecond)
+			}
+			if reader.Header != expected {
+				t.Errorf("%v header %+v, expected %+v", format, reader.Header, expected)
+			}
+
+			if reader.NumSamples() != 25000 {
+				t.Fatalf("%v recording of %d samples, expected 25000", format, reader.NumSamples())
+			}
+
+			samples := readAll(t, reader)
+			firstRead, _ := sdrformat.SliceBuffers(samples, 0, 1000)
+			secondRead, _ := sdrformat.SliceBuffers(samples, 1000, 25000)
+			if !reflect.DeepEqual(firstRead, first) || !reflect.DeepEqual(secondRead, second) {
+				t.Errorf("%v samples differ after a round trip", format)
+			}
+
+			// The samples are converted when read in another format
+			if err := reader.Seek(1000); err != nil {
+				t.Fatal(err)
+			}
+			converted, _ := sdrformat.MakeBuffers(sdrformat.CF64, 1, 10)
+			if nbSamples, err := reader.Read(converted); err != nil || nbSamples != 10 {
+				t.Fatalf("%v read as CF64 returned %d samples and %v", format, nbSamples, err)
+			}
+
+			reader.Close()
+		}
+	}
+}
+
+func TestRF64(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "large.wav")
+
+	writer, err := Create(path, Header{Format: sdrformat.CS16, SampleRate: 10000000, CenterFrequency: 433920000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := testSamples(t, sdrformat.CS16, 1000, 0)
+	if err := writer.Write(samples, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	// The recording grows over 4 GiB in a sparse file, without writing the samples
+	nbSamples := uint64(maxRIFFSize/4 + 1000)
+	if err := writer.writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.file.Truncate(writer.dataOffset + int64(4*nbSamples)); err != nil {
+		t.Skipf("can not create a file over 4 GiB: %v", err)
+	}
+	writer.dataSize = 4 * nbSamples
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := make([]byte, 48)
+	_, err = io.ReadFull(file, start)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(start[0:4]) != "RF64" || binary.LittleEndian.Uint32(start[4:]) != maxRIFFSize || string(start[12:16]) != "ds64" {
+		t.Fatalf("the recording over 4 GiB does not start with a RF64 header and a ds64 chunk: %q", start[:16])
+	}
+	if dataSize := binary.LittleEndian.Uint64(start[28:]); dataSize != 4*nbSamples {
+		t.Fatalf("ds64 data size %d, expected %d", dataSize, 4*nbSamples)
+	}
+	if sampleCount := binary.LittleEndian.Uint64(start[36:]); sampleCount != nbSamples {
+		t.Fatalf("ds64 sample count %d, expected %d", sampleCount, nbSamples)
+	}
+
+	reader, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if reader.NumSamples() != nbSamples {
+		t.Fatalf("RF64 recording of %d samples, expected %d", reader.NumSamples(), nbSamples)
+	}
+	if reader.Header.CenterFrequency != 433920000 || reader.Header.SampleRate != 10000000 {
+		t.Fatalf("RF64 header %+v", reader.Header)
+	}
+
+	read, _ := sdrformat.MakeBuffers(sdrformat.CS16, 1, 1000)
+	if _, err := reader.Read(read); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, samples) {
+		t.Fatal("the first samples of the RF64 recording differ")
+	}
+
+	if err := reader.Seek(nbSamples - 10); err != nil {
+		t.Fatal(err)
+	}
+	if nbRead, err := reader.Read(read); err != nil || nbRead != 10 {
+		t.Fatalf("%d samples read at the end of the RF64 recording, expected 10: %v", nbRead, err)
+	}
+}
+
+// chunk encodes a chunk, padded to an even size
+func chunk(id string, content []byte) []byte {
+
+	encoded := appendChunk(nil, id, content)
+	if len(content)%2 != 0 {
+		encoded = append(encoded, 0)
+	}
+
+	return encoded
+}
+
+// writeChunks writes a RIFF WAVE file made of chunks
+func writeChunks(t *testing.T, chunks ...[]byte) string {
+
+	content := []byte("RIFF\x00\x00\x00\x00WAVE")
+	for _, chunk := range chunks {
+		content = append(content, chunk...)
+	}
+	binary.LittleEndian.PutUint32(content[4:], uint32(len(content)-8))
+
+	path := filepath.Join(t.TempDir(), "chunks.wav")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestUnknownChunks(t *testing.T) {
+
+	fmtChunk := make([]byte, 16)
+	binary.LittleEndian.PutUint16(fmtChunk[0:], formatPCM)
+	binary.LittleEndian.PutUint16(fmtChunk[2:], 2)
+	binary.LittleEndian.PutUint32(fmtChunk[4:], 250000)
+	binary.LittleEndian.PutUint32(fmtChunk[8:], 1000000)
+	binary.LittleEndian.PutUint16(fmtChunk[12:], 4)
+	binary.LittleEndian.PutUint16(fmtChunk[14:], 16)
+
+	data := make([]byte, 40)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	// Chunks of odd size are padded, and the unknown chunks are skipped whatever their position
+	path := writeChunks(t,
+		chunk("LIST", []byte("INFOISFT\x05\x00\x00\x00test\x00")),
+		chunk("fmt ", fmtChunk),
+		chunk("bext", make([]byte, 601)),
+		chunk("auxi", encodeAuxi(Header{SampleRate: 250000, CenterFrequency: 7100000})),
+		chunk("data", data))
+
+	reader, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if reader.Header.Format != sdrformat.CS16 || reader.Header.SampleRate != 250000 || reader.Header.CenterFrequency != 7100000 || !reader.Header.HasAuxi {
+		t.Fatalf("header %+v", reader.Header)
+	}
+	if reader.NumSamples() != 10 {
+		t.Fatalf("%d samples, expected 10", reader.NumSamples())
+	}
+
+	samples := readAll(t, reader).([][]int16)
+	if samples[0][0] != 0x0100 || samples[0][19] != 0x2726 {
+		t.Fatalf("samples %v do not match the data chunk", samples[0])
+	}
+
+	// A huge unknown chunk is skipped without being read, the file then has no data chunk
+	huge := appendChunk(nil, "junk", nil)
+	binary.LittleEndian.PutUint32(huge[4:], 0xFFFFFF00)
+	if _, err := Open(writeChunks(t, chunk("fmt ", fmtChunk), huge)); err == nil {
+		t.Error("a file without data chunk was opened")
+	}
+
+	// The metadata chunks are read in memory, their size is limited
+	oversized := appendChunk(nil, "auxi", nil)
+	binary.LittleEndian.PutUint32(oversized[4:], maxMetadataChunkSize+1)
+	if _, err := Open(writeChunks(t, chunk("fmt ", fmtChunk), oversized, chunk("data", data))); err == nil {
+		t.Error("a file with an oversized auxi chunk was opened")
+	}
+
+	// The data chunk needs the format of the samples
+	if _, err := Open(writeChunks(t, chunk("data", data), chunk("fmt ", fmtChunk))); err == nil {
+		t.Error("a file with the data chunk before the fmt chunk was opened")
+	}
+}
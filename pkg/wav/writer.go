@@ -0,0 +1,243 @@
+package wav
+
+// Copyright (c) 2018 Bhojpur Consulting Private Limited, India. All rights reserved.
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bhojpur/sdr/pkg/sdrformat"
+)
+
+// Writer writes an IQ recording in a WAV file.
+//
+// The header is written with placeholder sizes, completed on Close. A "JUNK" chunk reserves the room of the "ds64"
+// chunk, so that the file can be converted to RF64 when it grows over 4 GB.
+type Writer struct {
+	header     Header
+	file       *os.File
+	writer     *bufio.Writer
+	sizeOffset int64
+	dataOffset int64
+	dataSize   uint64
+	encoded    []byte
+	converted  interface{}
+	closed     bool
+}
+
+// Create creates an IQ recording in a WAV file.
+//
+// Params:
+//  - path: the path of the file
+//  - header: the description of the recording. The auxi chunk is written when the center frequency or the start time
+//    is set.
+//
+// Return the writer or an error
+func Create(path string, header Header) (writer *Writer, err error) {
+
+	tag, bits, err := bitsPerSample(header.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.SampleRate == 0 {
+		return nil, errors.New("the sample rate of a WAV file must be positive")
+	}
+
+	header.HasAuxi = header.CenterFrequency != 0 || !header.StartTime.IsZero()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	blockAlign := 2 * bits / 8
+
+	fmtChunk := make([]byte, 16)
+	binary.LittleEndian.PutUint16(fmtChunk[0:], tag)
+	binary.LittleEndian.PutUint16(fmtChunk[2:], 2)
+	binary.LittleEndian.PutUint32(fmtChunk[4:], header.SampleRate)
+	binary.LittleEndian.PutUint32(fmtChunk[8:], header.SampleRate*uint32(blockAlign))
+	binary.LittleEndian.PutUint16(fmtChunk[12:], blockAlign)
+	binary.LittleEndian.PutUint16(fmtChunk[14:], bits)
+
+	content := []byte("RIFF\x00\x00\x00\x00WAVE")
+	content = appendChunk(content, "JUNK", make([]byte, ds64Size))
+	content = appendChunk(content, "fmt ", fmtChunk)
+	if header.HasAuxi {
+		content = appendChunk(content, "auxi", encodeAuxi(header))
+	}
+	content = append(content, "data\x00\x00\x00\x00"...)
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Writer{
+		header:     header,
+		file:       file,
+		writer:     bufio.NewWriter(file),
+		sizeOffset: int64(len(content)) - 4,
+		dataOffset: int64(len(content)),
+	}, nil
+}
+
+// Write appends samples to the recording. The samples are converted when their format is not the format of the
+// recording.
+//
+// Params:
+//  - buffers: the samples of a single channel, given as the buffers of all the channels, for example [][]int16
+//  - nbSamples: the number of samples to write
+//
+// Return an error or nil in case of success
+func (writer *Writer) Write(buffers interface{}, nbSamples int) (err error) {
+
+	if writer.closed {
+		return errors.New("the WAV writer is closed")
+	}
+
+	nbChannels, _, err := sdrformat.BuffersLength(buffers)
+	if err != nil {
+		return err
+	}
+
+	if nbChannels != 1 {
+		return fmt.Errorf("a WAV IQ file holds a single channel, %d given", nbChannels)
+	}
+
+	if format, _ := sdrformat.FormatOf(buffers); format != writer.header.Format {
+		if _, length, _ := sdrformat.BuffersLength(writer.converted); writer.converted == nil || length < nbSamples {
+			if writer.converted, err = sdrformat.MakeBuffers(writer.header.Format, 1, nbSamples); err != nil {
+				return err
+			}
+		}
+		if _, err := sdrformat.ConvertBuffers(writer.converted, buffers); err != nil {
+			return err
+		}
+		buffers = writer.converted
+	}
+
+	writer.encoded, err = sdrformat.Encode(writer.encoded[:0], buffers, nbSamples)
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.writer.Write(writer.encoded); err != nil {
+		return err
+	}
+
+	writer.dataSize += uint64(len(writer.encoded))
+
+	return nil
+}
+
+// SamplesWritten returns the number of samples written
+func (writer *Writer) SamplesWritten() uint64 {
+	return writer.dataSize / uint64(sdrformat.BytesPerSample(writer.header.Format))
+}
+
+// Close completes the header of the file and closes it. The stop time of the auxi chunk is set from the start time
+// and the number of samples when it is not given. The file is converted to RF64 when it is over 4 GB.
+//
+// Return an error or nil in case of success
+func (writer *Writer) Close() error {
+
+	if writer.closed {
+		return nil
+	}
+	writer.closed = true
+
+	if err := writer.writer.Flush(); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	if err := writer.completeHeader(); err != nil {
+		writer.file.Close()
+		return err
+	}
+
+	return writer.file.Close()
+}
+
+// completeHeader writes the sizes of the chunks and the stop time
+func (writer *Writer) completeHeader() error {
+
+	riffSize := uint64(writer.dataOffset) + writer.dataSize - 8
+
+	if riffSize > maxRIFFSize || writer.dataSize > maxRIFFSize {
+		// Convert the JUNK chunk to a ds64 chunk
+		ds64 := make([]byte, ds64Size)
+		binary.LittleEndian.PutUint64(ds64[0:], riffSize)
+		binary.LittleEndian.PutUint64(ds64[8:], writer.dataSize)
+		binary.LittleEndian.PutUint64(ds64[16:], writer.SamplesWritten())
+
+		if _, err := writer.file.WriteAt(appendChunk([]byte("RF64\xff\xff\xff\xffWAVE"), "ds64", ds64), 0); err != nil {
+			return err
+		}
+		if _, err := writer.file.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, writer.sizeOffset); err != nil {
+			return err
+		}
+	} else {
+		if _, err := writer.file.WriteAt(uint32Bytes(uint32(riffSize)), 4); err != nil {
+			return err
+		}
+		if _, err := writer.file.WriteAt(uint32Bytes(uint32(writer.dataSize)), writer.sizeOffset); err != nil {
+			return err
+		}
+	}
+
+	if writer.header.HasAuxi && writer.header.StopTime.IsZero() && !writer.header.StartTime.IsZero() {
+		duration := float64(writer.SamplesWritten()) / float64(writer.header.SampleRate)
+		writer.header.StopTime = writer.header.StartTime.Add(time.Duration(duration * float64(time.Second)))
+
+		// The auxi chunk follows the RIFF header, the JUNK or ds64 chunk and the fmt chunk
+		auxiOffset := int64(12 + 8 + ds64Size + 8 + 16 + 8)
+		if _, err := writer.file.WriteAt(encodeAuxi(writer.header), auxiOffset); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendChunk appends a chunk to a byte slice
+func appendChunk(dst []byte, id string, content []byte) []byte {
+
+	dst = append(dst, id...)
+	dst = append(dst, uint32Bytes(uint32(len(content)))...)
+
+	return append(dst, content...)
+}
+
+// uint32Bytes encodes an uint32 in little endian
+func uint32Bytes(value uint32) []byte {
+
+	content := make([]byte, 4)
+	binary.LittleEndian.PutUint32(content, value)
+
+	return content
+}